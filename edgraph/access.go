@@ -5,79 +5,711 @@ package edgraph
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/golang/glog"
 	"github.com/outcaste-io/outserv/gql"
 	"github.com/outcaste-io/outserv/protos/pb"
 	"github.com/outcaste-io/outserv/query"
+	"github.com/outcaste-io/outserv/schema"
+	"github.com/outcaste-io/outserv/worker"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/outcaste-io/ristretto/z"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Login handles login requests from clients. This version rejects all requests
-// since ACL is only supported in the enterprise version.
+// The permissions a rule can give to a group. They follow the UNIX file permission convention, so
+// a rule with permission 6 allows to read and write the predicate.
+const (
+	// Read allows to query the predicate.
+	Read int32 = 4
+	// Write allows to mutate the predicate.
+	Write int32 = 2
+	// Modify allows to alter the schema of the predicate.
+	Modify int32 = 1
+)
+
+// aclRefreshInterval is how often the ACL rules get reloaded, if no change to them was seen.
+// Only the changes applied by this Alpha's group wake up RefreshAcls earlier.
+const aclRefreshInterval = 30 * time.Second
+
+var errAclDisabled = errors.New("ACL is disabled. Set --acl secret-file to enable it")
+
+// userData is the user information carried by an access JWT.
+type userData struct {
+	namespace uint64
+	userId    string
+	groupIds  []string
+}
+
+// Login handles login requests from clients. A user logs in either with the userid and password,
+// or with the refresh JWT returned by an earlier login. In both the cases, a new pair of access
+// and refresh JWTs is returned.
 func Login(ctx context.Context,
 	request *pb.LoginRequest) (*pb.Response, error) {
 	if err := x.HealthCheck(); err != nil {
 		return nil, err
 	}
+	if !x.WorkerConfig.AclEnabled {
+		return nil, errAclDisabled
+	}
+
+	user, ns, err := authenticateLogin(ctx, request)
+	if err != nil {
+		glog.Warningf("Login failed for user %q: %v", request.Userid, err)
+		return nil, err
+	}
+
+	var groups []string
+	for _, g := range user.Groups {
+		groups = append(groups, g.Name)
+	}
+	out := &pb.Jwt{}
+	if out.AccessJwt, err = getAccessJwt(user.Name, groups, ns); err != nil {
+		return nil, err
+	}
+	if out.RefreshJwt, err = getRefreshJwt(user.Name, ns); err != nil {
+		return nil, err
+	}
+	data, err := out.Marshal()
+	if err != nil {
+		return nil, errors.Wrapf(err, "while marshaling the JWTs")
+	}
+	return &pb.Response{Json: data}, nil
+}
+
+// authenticateLogin returns the user logging in, along with the namespace it belongs to.
+func authenticateLogin(ctx context.Context, request *pb.LoginRequest) (*AclUser, uint64, error) {
+	if len(request.RefreshToken) > 0 {
+		claims, err := x.ParseJWT(request.RefreshToken)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid refresh token")
+		}
+		if refresh, _ := claims["refresh"].(bool); !refresh {
+			return nil, 0, errors.New("invalid refresh token: not a refresh JWT")
+		}
+		userId, ok := claims["userid"].(string)
+		if !ok {
+			return nil, 0, errors.Errorf("userid in refresh token is not a string: %v",
+				claims["userid"])
+		}
+		ns, ok := claims["namespace"].(float64)
+		if !ok {
+			return nil, 0, errors.Errorf("namespace in refresh token is not valid: %v",
+				claims["namespace"])
+		}
+		// The password isn't checked here, the user only needs to still exist.
+		user, err := queryUser(ctx, uint64(ns), userId, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		if user == nil {
+			return nil, 0, errors.Errorf("unable to find user %q", userId)
+		}
+		return user, uint64(ns), nil
+	}
+
+	if request.Userid == "" || request.Password == "" {
+		return nil, 0, x.ErrorInvalidLogin
+	}
+	user, err := queryUser(ctx, request.Namespace, request.Userid, request.Password)
+	if err != nil {
+		return nil, 0, err
+	}
+	if user == nil || !user.PasswordMatch {
+		return nil, 0, x.ErrorInvalidLogin
+	}
+	return user, request.Namespace, nil
+}
+
+// queryUser returns the user with the given userid along with its groups, or nil if there's no
+// such user. The password gets checked only if it's not empty.
+func queryUser(ctx context.Context, ns uint64, userId, password string) (*AclUser, error) {
+	params, checkPwd := "$userid: string", ""
+	vars := map[string]string{"$userid": userId}
+	if password != "" {
+		params += ", $password: string"
+		checkPwd = "password_match: checkpwd(dgraph.password, $password)"
+		vars["$password"] = password
+	}
+	q := `query search(` + params + `) {
+		user(func: eq(dgraph.xid, $userid)) @filter(type(dgraph.type.User)) {
+			uid
+			dgraph.xid
+			` + checkPwd + `
+			dgraph.user.group {
+				uid
+				dgraph.xid
+			}
+		}
+	}`
+
+	var res struct {
+		User []*AclUser `json:"user"`
+	}
+	if err := queryAcl(ctx, ns, q, vars, &res); err != nil {
+		return nil, errors.Wrapf(err, "while querying user %q", userId)
+	}
+	if len(res.User) == 0 {
+		return nil, nil
+	}
+	return res.User[0], nil
+}
+
+func getAccessJwt(userId string, groups []string, ns uint64) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userid":    userId,
+		"groups":    groups,
+		"namespace": ns,
+		"exp":       time.Now().Add(worker.Config.AccessJwtTtl).Unix(),
+	})
+	signed, err := token.SignedString([]byte(x.WorkerConfig.HmacSecret))
+	return signed, errors.Wrapf(err, "unable to encode access jwt")
+}
+
+func getRefreshJwt(userId string, ns uint64) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userid":    userId,
+		"namespace": ns,
+		"refresh":   true,
+		"exp":       time.Now().Add(worker.Config.RefreshJwtTtl).Unix(),
+	})
+	signed, err := token.SignedString([]byte(x.WorkerConfig.HmacSecret))
+	return signed, errors.Wrapf(err, "unable to encode refresh jwt")
+}
+
+// validateToken verifies the signature and expiry of the access JWT, and returns the user data it
+// carries.
+func validateToken(jwtStr string) (*userData, error) {
+	claims, err := x.ParseJWT(jwtStr)
+	if err != nil {
+		return nil, err
+	}
+	if refresh, _ := claims["refresh"].(bool); refresh {
+		return nil, errors.New("refresh JWT can't be used as an access JWT")
+	}
+	userId, ok := claims["userid"].(string)
+	if !ok {
+		return nil, errors.Errorf("userid in claims is not a string: %v", claims["userid"])
+	}
+	ns, ok := claims["namespace"].(float64)
+	if !ok {
+		return nil, errors.Errorf("namespace in claims is not valid: %v", claims["namespace"])
+	}
+	groups, ok := claims["groups"].([]interface{})
+	if !ok && claims["groups"] != nil {
+		return nil, errors.Errorf("groups in claims is not a list: %v", claims["groups"])
+	}
+	user := &userData{namespace: uint64(ns), userId: userId}
+	for _, g := range groups {
+		group, ok := g.(string)
+		if !ok {
+			return nil, errors.Errorf("group in claims is not a string: %v", g)
+		}
+		user.groupIds = append(user.groupIds, group)
+	}
+	return user, nil
+}
 
-	glog.Warningf("Login failed: %s", x.ErrNotSupported)
-	return &pb.Response{}, x.ErrNotSupported
+// extractUserAndGroups returns the user data from the access JWT attached to the context.
+func extractUserAndGroups(ctx context.Context) (*userData, error) {
+	accessJwt, err := x.ExtractJwt(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	user, err := validateToken(accessJwt)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return user, nil
 }
 
-// ResetAcl is an empty method since ACL is only supported in the enterprise version.
+// ResetAcl makes sure that the guardians group and the groot user exist in the galaxy namespace.
+// With a closer, it keeps retrying until it succeeds or the closer gets signalled. Without one,
+// e.g. after a drop all, it tries only once.
 func ResetAcl(closer *z.Closer) {
-	// do nothing
+	if closer != nil {
+		defer closer.Done()
+	}
+	if !x.WorkerConfig.AclEnabled {
+		return
+	}
+	upsertGuardianAndGroot(closer, x.GalaxyNamespace)
 }
 
 func upsertGuardianAndGroot(closer *z.Closer, ns uint64) {
-	// do nothing
+	if !x.WorkerConfig.AclEnabled {
+		return
+	}
+	ctx := context.Background()
+	if closer != nil {
+		ctx = closer.Ctx()
+	}
+	for {
+		err := createGuardianAndGroot(ctx, ns, "password")
+		if err == nil {
+			glog.Infof("Guardians group and groot user are present in namespace %#x", ns)
+			return
+		}
+		glog.Warningf("Unable to upsert the guardians group and groot user: %v", err)
+		if closer == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func upsertGuardian(ctx context.Context, ns uint64) error {
+	uid, err := getAclNode(ctx, ns, aclTypeGroup, x.GuardiansId)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		uids, err := mutateAcl(ctx, ns, &pb.Mutation{
+			Edges: newAclNodeEdges("_:guardians", aclTypeGroup, x.GuardiansId),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "while creating the guardians group")
+		}
+		uid = uids["_:guardians"]
+	}
+	x.GuardiansUid.Store(ns, uid)
+	return nil
+}
+
+func upsertGroot(ctx context.Context, ns uint64, password string) error {
+	uid, err := getAclNode(ctx, ns, aclTypeUser, x.GrootId)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		guardians, ok := x.GuardiansUid.Load(ns)
+		if !ok {
+			return errors.Errorf("guardians group not found in namespace %#x", ns)
+		}
+		edges := newAclNodeEdges("_:groot", aclTypeUser, x.GrootId)
+		edges = append(edges, passwordEdge("_:groot", password), &pb.Edge{
+			Subject:   "_:groot",
+			Predicate: "dgraph.user.group",
+			ObjectId:  guardians.(string),
+		})
+		uids, err := mutateAcl(ctx, ns, &pb.Mutation{Edges: edges})
+		if err != nil {
+			return errors.Wrapf(err, "while creating the groot user")
+		}
+		uid = uids["_:groot"]
+	}
+	x.GrootUid.Store(ns, uid)
+	return nil
+}
+
+// aclCache holds the permissions of the groups over the predicates, as given by the ACL rules
+// stored in each namespace. RefreshAcls keeps it current.
+type aclCache struct {
+	sync.RWMutex
+	// perms maps namespace -> predicate or GraphQL type -> group -> permission.
+	perms map[uint64]map[string]map[string]int32
+}
+
+var aclCachePtr = &aclCache{perms: make(map[uint64]map[string]map[string]int32)}
+
+func (c *aclCache) set(ns uint64, perms map[string]map[string]int32) {
+	c.Lock()
+	defer c.Unlock()
+	c.perms[ns] = perms
+}
+
+func (c *aclCache) delete(ns uint64) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.perms, ns)
+}
+
+// permission returns the permissions the groups have over the predicate. A rule on a GraphQL type
+// applies to all the predicates named <type>.<field>. For any group, a rule on the predicate
+// itself takes precedence over the rule on its type, so a single field can be restricted.
+func (c *aclCache) permission(ns uint64, groups []string, pred string) int32 {
+	c.RLock()
+	defer c.RUnlock()
+
+	predPerms := c.perms[ns][pred]
+	var typePerms map[string]int32
+	if idx := strings.Index(pred, "."); idx > 0 {
+		typePerms = c.perms[ns][pred[:idx]]
+	}
+	var perm int32
+	for _, g := range groups {
+		if p, ok := predPerms[g]; ok {
+			perm |= p
+			continue
+		}
+		perm |= typePerms[g]
+	}
+	return perm
 }
 
-// ResetAcls is an empty method since ACL is only supported in the enterprise version.
+// loadAclRules reads the rules of all the groups in the namespace into the cache.
+func loadAclRules(ctx context.Context, ns uint64) error {
+	groups, err := queryGroups(ctx, ns, "")
+	if err != nil {
+		return errors.Wrapf(err, "while reading the ACL rules of namespace %#x", ns)
+	}
+	perms := make(map[string]map[string]int32)
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			if r.Predicate == "" {
+				continue
+			}
+			if perms[r.Predicate] == nil {
+				perms[r.Predicate] = make(map[string]int32)
+			}
+			perms[r.Predicate][g.Name] = r.Permission
+		}
+	}
+	aclCachePtr.set(ns, perms)
+	return nil
+}
+
+// RefreshAcls keeps the ACL rule cache current. The rules get reloaded whenever a mutation
+// touching them gets committed by this Alpha, and every aclRefreshInterval to pick up the changes
+// applied by the other groups.
 func RefreshAcls(closer *z.Closer) {
-	// do nothing
-	<-closer.HasBeenClosed()
-	closer.Done()
+	defer closer.Done()
+	if !x.WorkerConfig.AclEnabled {
+		return
+	}
+
+	ticker := time.NewTicker(aclRefreshInterval)
+	defer ticker.Stop()
+	for {
+		var namespaces []uint64
+		var preds []string
+		for ns := range schema.State().Namespaces() {
+			namespaces = append(namespaces, ns)
+			preds = append(preds, x.NamespaceAttr(ns, "dgraph.acl.rule"),
+				x.NamespaceAttr(ns, "dgraph.rule.predicate"),
+				x.NamespaceAttr(ns, "dgraph.rule.permission"))
+		}
+		// Start watching before reading the rules, so no change gets missed.
+		w := worker.WatchCommits(preds)
+
+		wait := ticker.C
+		for _, ns := range namespaces {
+			if err := loadAclRules(closer.Ctx(), ns); err != nil {
+				glog.Warningf("Unable to refresh the ACL rules: %v", err)
+				// The server is probably still starting up. Retry soon.
+				wait = time.After(time.Second)
+			}
+		}
+
+		select {
+		case <-closer.HasBeenClosed():
+			worker.StopWatchingCommits(w)
+			return
+		case <-w.C():
+		case <-wait:
+		}
+		worker.StopWatchingCommits(w)
+	}
+}
+
+// unauthorizedPreds returns the sorted predicates on which the user lacks the permission perm.
+// The ACL predicates are only accessible to the guardians, while the other pre-defined predicates
+// are accessible to everyone.
+func unauthorizedPreds(user *userData, preds map[string]struct{}, perm int32) []string {
+	var blocked []string
+	for pred := range preds {
+		attr := strings.TrimPrefix(pred, "~")
+		switch {
+		case attr == "" || attr == "uid" || attr == "val" || attr == "expand" ||
+			strings.HasPrefix(attr, "val("):
+		case x.IsAclPredicate(attr):
+			blocked = append(blocked, pred)
+		case x.IsPreDefinedPredicate(attr):
+		case aclCachePtr.permission(user.namespace, user.groupIds, attr)&perm == 0:
+			blocked = append(blocked, pred)
+		}
+	}
+	sort.Strings(blocked)
+	return blocked
 }
 
 func authorizeAlter(ctx context.Context, op *pb.Operation) error {
+	if !x.WorkerConfig.AclEnabled {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if x.IsGuardian(user.groupIds) {
+		return nil
+	}
+	if op.DropAll || op.DropOp == pb.Operation_ALL || op.DropOp == pb.Operation_DATA {
+		return status.Errorf(codes.PermissionDenied,
+			"only guardians are allowed to drop all data, but user %q is not a guardian",
+			user.userId)
+	}
+
+	preds := make(map[string]struct{})
+	if op.DropAttr != "" {
+		preds[op.DropAttr] = struct{}{}
+	}
+	if op.DropOp == pb.Operation_ATTR {
+		preds[op.DropValue] = struct{}{}
+	}
+	if op.Schema != "" {
+		result, err := schema.ParseWithNamespace(op.Schema, user.namespace)
+		if err != nil {
+			return err
+		}
+		for _, update := range result.Preds {
+			preds[x.ParseAttr(update.Predicate)] = struct{}{}
+		}
+	}
+	if blocked := unauthorizedPreds(user, preds, Modify); len(blocked) > 0 {
+		return status.Errorf(codes.PermissionDenied,
+			"unauthorized to alter the predicates: %s", strings.Join(blocked, ", "))
+	}
 	return nil
 }
 
 func authorizeMutation(ctx context.Context, gmu *pb.Mutation) error {
+	if !x.WorkerConfig.AclEnabled {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if x.IsGuardian(user.groupIds) {
+		return nil
+	}
+
+	preds := make(map[string]struct{})
+	for _, edge := range gmu.Edges {
+		preds[edge.Predicate] = struct{}{}
+	}
+	if blocked := unauthorizedPreds(user, preds, Write); len(blocked) > 0 {
+		return status.Errorf(codes.PermissionDenied,
+			"unauthorized to mutate the predicates: %s", strings.Join(blocked, ", "))
+	}
 	return nil
 }
 
+// blockPreds adds the predicates used by the query block itself, excluding its children, to preds.
+func blockPreds(gq *gql.GraphQuery, preds map[string]struct{}) {
+	if gq.Func != nil {
+		preds[gq.Func.Attr] = struct{}{}
+		if gq.Func.IsSearch() {
+			for _, pred := range gq.Func.SearchPredicates() {
+				preds[pred] = struct{}{}
+			}
+		}
+	}
+	preds[gq.Attr] = struct{}{}
+	for _, order := range gq.Order {
+		preds[order.Attr] = struct{}{}
+	}
+	for _, attr := range gq.GroupbyAttrs {
+		preds[attr.Attr] = struct{}{}
+	}
+	var filterPreds func(f *gql.FilterTree)
+	filterPreds = func(f *gql.FilterTree) {
+		if f == nil {
+			return
+		}
+		if f.Func != nil {
+			preds[f.Func.Attr] = struct{}{}
+		}
+		for _, child := range f.Child {
+			filterPreds(child)
+		}
+	}
+	filterPreds(gq.Filter)
+}
+
+func queryPreds(gqs []*gql.GraphQuery, preds map[string]struct{}) {
+	for _, gq := range gqs {
+		blockPreds(gq, preds)
+		queryPreds(gq.Children, preds)
+	}
+}
+
+// removeBlockedPreds leaves out the query blocks using any of the blocked predicates. A block whose
+// function, filter or ordering uses a blocked predicate is left out as a whole, so the predicate
+// can't be probed through them.
+func removeBlockedPreds(gqs []*gql.GraphQuery, blocked map[string]struct{}) []*gql.GraphQuery {
+	out := gqs[:0]
+	for _, gq := range gqs {
+		preds := make(map[string]struct{})
+		blockPreds(gq, preds)
+		skip := false
+		for pred := range preds {
+			if _, ok := blocked[pred]; ok {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		gq.Children = removeBlockedPreds(gq.Children, blocked)
+		out = append(out, gq)
+	}
+	return out
+}
+
 func authorizeQuery(ctx context.Context, parsedReq *gql.Result, graphql bool) error {
-	// always allow access
+	if !x.WorkerConfig.AclEnabled || len(parsedReq.Query) == 0 {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if x.IsGuardian(user.groupIds) {
+		return nil
+	}
+
+	preds := make(map[string]struct{})
+	queryPreds(parsedReq.Query, preds)
+	blocked := unauthorizedPreds(user, preds, Read)
+	if len(blocked) == 0 {
+		return nil
+	}
+	if !graphql {
+		return status.Errorf(codes.PermissionDenied,
+			"unauthorized to query the predicates: %s", strings.Join(blocked, ", "))
+	}
+	// GraphQL queries get the fields which can't be read left out, which then resolve to null.
+	blockedMap := make(map[string]struct{}, len(blocked))
+	for _, pred := range blocked {
+		blockedMap[pred] = struct{}{}
+	}
+	parsedReq.Query = removeBlockedPreds(parsedReq.Query, blockedMap)
 	return nil
 }
 
 func authorizeSchemaQuery(ctx context.Context, er *query.ExecutionResult) error {
-	// always allow schema access
+	if !x.WorkerConfig.AclEnabled || getAuthMode(ctx) == NoAuthorize {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if x.IsGuardian(user.groupIds) {
+		return nil
+	}
+
+	// Only the schema of the predicates which can be read is returned.
+	nodes := er.SchemaNode[:0]
+	for _, node := range er.SchemaNode {
+		preds := map[string]struct{}{node.Predicate: {}}
+		if len(unauthorizedPreds(user, preds, Read)) == 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	er.SchemaNode = nodes
 	return nil
 }
 
-func AuthorizeGuardians(ctx context.Context) error {
-	// always allow access
+// AuthorizeRead returns an error if the user making the request can't read all the predicates.
+// It's meant for the reads which don't go through a DQL query, like those of the index.
+func AuthorizeRead(ctx context.Context, preds ...string) error {
+	if !x.WorkerConfig.AclEnabled || getAuthMode(ctx) == NoAuthorize {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if x.IsGuardian(user.groupIds) {
+		return nil
+	}
+
+	predSet := make(map[string]struct{}, len(preds))
+	for _, pred := range preds {
+		predSet[pred] = struct{}{}
+	}
+	if blocked := unauthorizedPreds(user, predSet, Read); len(blocked) > 0 {
+		return status.Errorf(codes.PermissionDenied,
+			"unauthorized to query the predicates: %s", strings.Join(blocked, ", "))
+	}
 	return nil
 }
 
-func AuthGuardianOfTheGalaxy(ctx context.Context) error {
-	// always allow access
+// AuthorizeGuardians returns an error if the user making the request isn't a guardian of its
+// namespace.
+func AuthorizeGuardians(ctx context.Context) error {
+	if !x.WorkerConfig.AclEnabled {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if !x.IsGuardian(user.groupIds) {
+		return status.Errorf(codes.PermissionDenied,
+			"only guardians are allowed access, but user %q is not a member of the guardians group",
+			user.userId)
+	}
 	return nil
 }
 
-func validateToken(jwtStr string) ([]string, error) {
-	return nil, nil
+// AuthGuardianOfTheGalaxy returns an error if the user making the request isn't a guardian of the
+// galaxy namespace.
+func AuthGuardianOfTheGalaxy(ctx context.Context) error {
+	if !x.WorkerConfig.AclEnabled {
+		return nil
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if user.namespace != x.GalaxyNamespace || !x.IsGuardian(user.groupIds) {
+		return status.Errorf(codes.PermissionDenied,
+			"only guardians of the galaxy are allowed this operation, but user %q isn't one",
+			user.userId)
+	}
+	return nil
 }
 
-func upsertGuardian(ctx context.Context) error {
-	return nil
+// queryAcl runs the DQL query in the namespace without any authorization, and decodes its result
+// into out.
+func queryAcl(ctx context.Context, ns uint64, q string, vars map[string]string,
+	out interface{}) error {
+	resp, err := doQuery(x.AttachNamespace(ctx, ns), &Request{
+		Req:    &pb.Request{Query: q, Vars: vars, ReadOnly: true},
+		doAuth: NoAuthorize,
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.GetJson(), out)
 }
 
-func upsertGroot(ctx context.Context) error {
-	return nil
+// mutateAcl commits the mutation in the namespace without any authorization, and returns the uids
+// assigned to its blank nodes.
+func mutateAcl(ctx context.Context, ns uint64, mu *pb.Mutation) (map[string]string, error) {
+	resp, err := doQuery(x.AttachNamespace(ctx, ns), &Request{
+		Req:    &pb.Request{Mutations: []*pb.Mutation{mu}, CommitNow: true},
+		doAuth: NoAuthorize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetTxn().GetUids(), nil
 }
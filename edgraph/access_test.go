@@ -0,0 +1,53 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package edgraph
+
+import (
+	"testing"
+
+	"github.com/outcaste-io/outserv/gql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAclPermission(t *testing.T) {
+	c := &aclCache{perms: make(map[uint64]map[string]map[string]int32)}
+	c.set(0, map[string]map[string]int32{
+		"Post":        {"dev": Read | Write, "ops": Read},
+		"Post.secret": {"dev": 0},
+		"Author.name": {"ops": Write},
+	})
+
+	require.Equal(t, Read|Write, c.permission(0, []string{"dev"}, "Post.title"))
+	// The rule on the field takes precedence over the rule on its type.
+	require.Equal(t, int32(0), c.permission(0, []string{"dev"}, "Post.secret"))
+	// The permissions of the groups add up.
+	require.Equal(t, Read, c.permission(0, []string{"dev", "ops"}, "Post.secret"))
+	require.Equal(t, Write, c.permission(0, []string{"dev", "ops"}, "Author.name"))
+	require.Equal(t, int32(0), c.permission(0, []string{"dev"}, "Author.name"))
+	// The rules are per namespace.
+	require.Equal(t, int32(0), c.permission(1, []string{"dev"}, "Post.title"))
+}
+
+func TestRemoveBlockedPreds(t *testing.T) {
+	res, err := gql.Parse(gql.Request{Str: `{
+		q(func: type(Post)) {
+			Post.title
+			Post.secret
+			Post.author @filter(eq(Author.email, "a@b.com")) {
+				Author.name
+			}
+		}
+	}`})
+	require.NoError(t, err)
+
+	preds := make(map[string]struct{})
+	queryPreds(res.Query, preds)
+	require.Contains(t, preds, "Author.email")
+
+	blocked := map[string]struct{}{"Post.secret": {}, "Author.email": {}}
+	query := removeBlockedPreds(res.Query, blocked)
+	require.Len(t, query, 1)
+	// The block filtering on a blocked predicate is left out as a whole.
+	require.Len(t, query[0].Children, 1)
+	require.Equal(t, "Post.title", query[0].Children[0].Attr)
+}
@@ -0,0 +1,507 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package edgraph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/pkg/errors"
+)
+
+const (
+	aclTypeUser  = "dgraph.type.User"
+	aclTypeGroup = "dgraph.type.Group"
+	aclTypeRule  = "dgraph.type.Rule"
+)
+
+// AclUser is a user of the ACL system.
+type AclUser struct {
+	Uid    string      `json:"uid"`
+	Name   string      `json:"dgraph.xid"`
+	Groups []*AclGroup `json:"dgraph.user.group"`
+	// PasswordMatch is only set when querying the user for a login.
+	PasswordMatch bool `json:"password_match"`
+}
+
+// AclGroup is a group of users, which the rules give permissions to.
+type AclGroup struct {
+	Uid   string     `json:"uid"`
+	Name  string     `json:"dgraph.xid"`
+	Rules []*AclRule `json:"dgraph.acl.rule"`
+}
+
+// AclRule gives a group the permission over a predicate. The predicate can also be the name of a
+// GraphQL type, in which case the rule applies to all the fields of the type.
+type AclRule struct {
+	Uid        string `json:"uid"`
+	Predicate  string `json:"dgraph.rule.predicate"`
+	Permission int32  `json:"dgraph.rule.permission"`
+}
+
+func newAclNodeEdges(subject, typ, xid string) []*pb.Edge {
+	return []*pb.Edge{
+		{
+			Subject:     subject,
+			Predicate:   "dgraph.xid",
+			ObjectValue: types.StringToBinary(xid),
+		},
+		{
+			Subject:     subject,
+			Predicate:   "dgraph.type",
+			ObjectValue: types.StringToBinary(typ),
+		},
+	}
+}
+
+func passwordEdge(subject, password string) *pb.Edge {
+	return &pb.Edge{
+		Subject:     subject,
+		Predicate:   "dgraph.password",
+		ObjectValue: types.StringToBinary(password),
+	}
+}
+
+// ruleEdges returns the edges creating the rule node, and linking it to the group.
+func ruleEdges(group, subject string, rule *AclRule) []*pb.Edge {
+	return []*pb.Edge{
+		{
+			Subject:     subject,
+			Predicate:   "dgraph.type",
+			ObjectValue: types.StringToBinary(aclTypeRule),
+		},
+		{
+			Subject:     subject,
+			Predicate:   "dgraph.rule.predicate",
+			ObjectValue: types.StringToBinary(rule.Predicate),
+		},
+		permissionEdge(subject, rule.Permission),
+		{
+			Subject:   group,
+			Predicate: "dgraph.acl.rule",
+			ObjectId:  subject,
+		},
+	}
+}
+
+func permissionEdge(subject string, perm int32) *pb.Edge {
+	return &pb.Edge{
+		Subject:     subject,
+		Predicate:   "dgraph.rule.permission",
+		ObjectValue: types.StringToBinary(strconv.Itoa(int(perm))),
+	}
+}
+
+// deleteEdges returns the edges deleting all the given predicates of the node.
+func deleteEdges(subject string, preds ...string) []*pb.Edge {
+	var edges []*pb.Edge
+	for _, pred := range preds {
+		edges = append(edges, &pb.Edge{
+			Subject:     subject,
+			Predicate:   pred,
+			ObjectValue: types.StringToBinary(x.Star),
+			Op:          pb.Edge_DEL,
+		})
+	}
+	return edges
+}
+
+// getAclNode returns the uid of the node of the given ACL type and xid, or an empty string if
+// there's no such node.
+func getAclNode(ctx context.Context, ns uint64, typ, xid string) (string, error) {
+	q := fmt.Sprintf(`query node($xid: string) {
+		node(func: eq(dgraph.xid, $xid)) @filter(type(%s)) {
+			uid
+		}
+	}`, typ)
+
+	var res struct {
+		Node []struct {
+			Uid string `json:"uid"`
+		} `json:"node"`
+	}
+	if err := queryAcl(ctx, ns, q, map[string]string{"$xid": xid}, &res); err != nil {
+		return "", errors.Wrapf(err, "while querying %s %q", typ, xid)
+	}
+	if len(res.Node) == 0 {
+		return "", nil
+	}
+	return res.Node[0].Uid, nil
+}
+
+const aclRuleFields = `dgraph.acl.rule {
+				uid
+				dgraph.rule.predicate
+				dgraph.rule.permission
+			}`
+
+// queryGroups returns the group with the given name, or all the groups if the name is empty.
+func queryGroups(ctx context.Context, ns uint64, name string) ([]*AclGroup, error) {
+	q := `{
+		groups(func: type(dgraph.type.Group)) {
+			uid
+			dgraph.xid
+			` + aclRuleFields + `
+		}
+	}`
+	vars := map[string]string{}
+	if name != "" {
+		q = `query groups($name: string) {
+		groups(func: eq(dgraph.xid, $name)) @filter(type(dgraph.type.Group)) {
+			uid
+			dgraph.xid
+			` + aclRuleFields + `
+		}
+	}`
+		vars["$name"] = name
+	}
+
+	var res struct {
+		Groups []*AclGroup `json:"groups"`
+	}
+	if err := queryAcl(ctx, ns, q, vars, &res); err != nil {
+		return nil, errors.Wrapf(err, "while querying groups")
+	}
+	return res.Groups, nil
+}
+
+// queryUsers returns the user with the given name, or all the users if the name is empty.
+func queryUsers(ctx context.Context, ns uint64, name string) ([]*AclUser, error) {
+	fields := `uid
+			dgraph.xid
+			dgraph.user.group {
+				uid
+				dgraph.xid
+				` + aclRuleFields + `
+			}`
+	q := `{
+		users(func: type(dgraph.type.User)) {
+			` + fields + `
+		}
+	}`
+	vars := map[string]string{}
+	if name != "" {
+		q = `query users($name: string) {
+		users(func: eq(dgraph.xid, $name)) @filter(type(dgraph.type.User)) {
+			` + fields + `
+		}
+	}`
+		vars["$name"] = name
+	}
+
+	var res struct {
+		Users []*AclUser `json:"users"`
+	}
+	if err := queryAcl(ctx, ns, q, vars, &res); err != nil {
+		return nil, errors.Wrapf(err, "while querying users")
+	}
+	return res.Users, nil
+}
+
+// guardianNamespace returns the namespace of the guardian making the request.
+func guardianNamespace(ctx context.Context) (uint64, error) {
+	if !x.WorkerConfig.AclEnabled {
+		return 0, errAclDisabled
+	}
+	if err := AuthorizeGuardians(ctx); err != nil {
+		return 0, err
+	}
+	return x.ExtractNamespace(ctx)
+}
+
+// groupUids returns the uids of the given groups, or an error if any of them doesn't exist.
+func groupUids(ctx context.Context, ns uint64, names []string) ([]string, error) {
+	var uids []string
+	for _, name := range names {
+		uid, err := getAclNode(ctx, ns, aclTypeGroup, name)
+		if err != nil {
+			return nil, err
+		}
+		if uid == "" {
+			return nil, errors.Errorf("group %q doesn't exist", name)
+		}
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+func validateRules(rules []*AclRule) error {
+	for _, rule := range rules {
+		if rule.Predicate == "" {
+			return errors.New("rule must have a predicate or type")
+		}
+		if rule.Permission < 0 || rule.Permission > Read|Write|Modify {
+			return errors.Errorf("invalid permission %d for %q, it should be between 0 and 7",
+				rule.Permission, rule.Predicate)
+		}
+	}
+	return nil
+}
+
+// QueryAclUsers returns the user with the given name, or all the users if the name is empty.
+// Only guardians can query the users.
+func QueryAclUsers(ctx context.Context, name string) ([]*AclUser, error) {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryUsers(ctx, ns, name)
+}
+
+// QueryAclGroups returns the group with the given name, or all the groups if the name is empty.
+// Only guardians can query the groups.
+func QueryAclGroups(ctx context.Context, name string) ([]*AclGroup, error) {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryGroups(ctx, ns, name)
+}
+
+// CurrentAclUser returns the user making the request.
+func CurrentAclUser(ctx context.Context) (*AclUser, error) {
+	if !x.WorkerConfig.AclEnabled {
+		return nil, errAclDisabled
+	}
+	user, err := extractUserAndGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := queryUsers(ctx, user.namespace, user.userId)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// AddAclUser creates a user with the given password, as a member of the given groups.
+func AddAclUser(ctx context.Context, name, password string, groups []string) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if uid, err := getAclNode(ctx, ns, aclTypeUser, name); err != nil {
+		return err
+	} else if uid != "" {
+		return errors.Errorf("user %q already exists", name)
+	}
+	uids, err := groupUids(ctx, ns, groups)
+	if err != nil {
+		return err
+	}
+
+	edges := append(newAclNodeEdges("_:user", aclTypeUser, name), passwordEdge("_:user", password))
+	for _, uid := range uids {
+		edges = append(edges, &pb.Edge{
+			Subject:   "_:user",
+			Predicate: "dgraph.user.group",
+			ObjectId:  uid,
+		})
+	}
+	_, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges})
+	return err
+}
+
+// UpdateAclUser changes the password of the user if it's not empty, and its group memberships.
+func UpdateAclUser(ctx context.Context, name, password string,
+	addGroups, removeGroups []string) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	uid, err := getAclNode(ctx, ns, aclTypeUser, name)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		return errors.Errorf("user %q doesn't exist", name)
+	}
+	if name == x.GrootId {
+		for _, g := range removeGroups {
+			if g == x.GuardiansId {
+				return errors.New("groot can't be removed from the guardians group")
+			}
+		}
+	}
+
+	var edges []*pb.Edge
+	if password != "" {
+		edges = append(edges, passwordEdge(uid, password))
+	}
+	add, err := groupUids(ctx, ns, addGroups)
+	if err != nil {
+		return err
+	}
+	for _, g := range add {
+		edges = append(edges, &pb.Edge{Subject: uid, Predicate: "dgraph.user.group", ObjectId: g})
+	}
+	remove, err := groupUids(ctx, ns, removeGroups)
+	if err != nil {
+		return err
+	}
+	for _, g := range remove {
+		edges = append(edges, &pb.Edge{
+			Subject:   uid,
+			Predicate: "dgraph.user.group",
+			ObjectId:  g,
+			Op:        pb.Edge_DEL,
+		})
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	_, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges})
+	return err
+}
+
+// DeleteAclUser deletes the user. The groot user can't be deleted.
+func DeleteAclUser(ctx context.Context, name string) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if name == x.GrootId {
+		return errors.New("groot user can't be deleted")
+	}
+	uid, err := getAclNode(ctx, ns, aclTypeUser, name)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		return errors.Errorf("user %q doesn't exist", name)
+	}
+	edges := deleteEdges(uid, "dgraph.xid", "dgraph.type", "dgraph.password", "dgraph.user.group")
+	_, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges})
+	return err
+}
+
+// AddAclGroup creates a group with the given rules.
+func AddAclGroup(ctx context.Context, name string, rules []*AclRule) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateRules(rules); err != nil {
+		return err
+	}
+	if uid, err := getAclNode(ctx, ns, aclTypeGroup, name); err != nil {
+		return err
+	} else if uid != "" {
+		return errors.Errorf("group %q already exists", name)
+	}
+
+	edges := newAclNodeEdges("_:group", aclTypeGroup, name)
+	for i, rule := range rules {
+		edges = append(edges, ruleEdges("_:group", fmt.Sprintf("_:rule-%d", i), rule)...)
+	}
+	if _, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges}); err != nil {
+		return err
+	}
+	return loadAclRules(ctx, ns)
+}
+
+// UpdateAclGroup sets the given rules on the group, replacing the permissions of the existing
+// rules for the same predicates, and removes the rules for the predicates in removeRules.
+func UpdateAclGroup(ctx context.Context, name string, setRules []*AclRule,
+	removeRules []string) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateRules(setRules); err != nil {
+		return err
+	}
+	groups, err := queryGroups(ctx, ns, name)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return errors.Errorf("group %q doesn't exist", name)
+	}
+	group := groups[0]
+	existing := make(map[string]*AclRule)
+	for _, rule := range group.Rules {
+		existing[rule.Predicate] = rule
+	}
+
+	var edges []*pb.Edge
+	for i, rule := range setRules {
+		if old, ok := existing[rule.Predicate]; ok {
+			edges = append(edges, permissionEdge(old.Uid, rule.Permission))
+			continue
+		}
+		edges = append(edges, ruleEdges(group.Uid, fmt.Sprintf("_:rule-%d", i), rule)...)
+	}
+	for _, pred := range removeRules {
+		old, ok := existing[pred]
+		if !ok {
+			continue
+		}
+		edges = append(edges, &pb.Edge{
+			Subject:   group.Uid,
+			Predicate: "dgraph.acl.rule",
+			ObjectId:  old.Uid,
+			Op:        pb.Edge_DEL,
+		})
+		edges = append(edges, deleteEdges(old.Uid,
+			"dgraph.type", "dgraph.rule.predicate", "dgraph.rule.permission")...)
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	if _, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges}); err != nil {
+		return err
+	}
+	return loadAclRules(ctx, ns)
+}
+
+// DeleteAclGroup deletes the group along with its rules, and removes its users from it. The
+// guardians group can't be deleted.
+func DeleteAclGroup(ctx context.Context, name string) error {
+	ns, err := guardianNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	if name == x.GuardiansId {
+		return errors.New("guardians group can't be deleted")
+	}
+	groups, err := queryGroups(ctx, ns, name)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return errors.Errorf("group %q doesn't exist", name)
+	}
+	group := groups[0]
+	users, err := queryUsers(ctx, ns, "")
+	if err != nil {
+		return err
+	}
+
+	edges := deleteEdges(group.Uid, "dgraph.xid", "dgraph.type", "dgraph.acl.rule")
+	for _, rule := range group.Rules {
+		edges = append(edges, deleteEdges(rule.Uid,
+			"dgraph.type", "dgraph.rule.predicate", "dgraph.rule.permission")...)
+	}
+	for _, user := range users {
+		for _, g := range user.Groups {
+			if g.Uid != group.Uid {
+				continue
+			}
+			edges = append(edges, &pb.Edge{
+				Subject:   user.Uid,
+				Predicate: "dgraph.user.group",
+				ObjectId:  group.Uid,
+				Op:        pb.Edge_DEL,
+			})
+		}
+	}
+	if _, err = mutateAcl(ctx, ns, &pb.Mutation{Edges: edges}); err != nil {
+		return err
+	}
+	return loadAclRules(ctx, ns)
+}
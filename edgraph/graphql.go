@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/outcaste-io/outserv/graphql/schema"
@@ -29,12 +30,19 @@ import (
 //		    i)  If query is not provided then update gqlRes with the found query and proceed
 //			ii) If query is provided then match query retrieved, if identical do nothing else
 //				throw "query does not match persisted query"
-func ProcessPersistedQuery(ctx context.Context, gqlReq *schema.Request) error {
+//
+// If allowlist is set, only the queries persisted beforehand via AddPersistedQueries are
+// executed. Queries sent without a sha256Hash are looked up by the sha256 of their text, and
+// unknown queries are rejected instead of being persisted.
+func ProcessPersistedQuery(ctx context.Context, gqlReq *schema.Request, allowlist bool) error {
 	query := gqlReq.Query
 	sha256Hash := gqlReq.Extensions.PersistedQuery.Sha256Hash
 
 	if sha256Hash == "" {
-		return nil
+		if !allowlist || query == "" {
+			return nil
+		}
+		sha256Hash = queryHash(query)
 	}
 
 	if x.WorkerConfig.AclEnabled {
@@ -47,15 +55,107 @@ func ProcessPersistedQuery(ctx context.Context, gqlReq *schema.Request) error {
 		}
 	}
 
-	join := sha256Hash + query
+	pq, err := persistedQueries.get(ctx, sha256Hash, query)
+	if err != nil {
+		return err
+	}
+
+	if pq == nil {
+		if query == "" {
+			return errors.New("PersistedQueryNotFound")
+		}
+		if allowlist {
+			return errPersistedQueryNotAllowed
+		}
+		if !hashMatches(query, sha256Hash) {
+			return errors.New("provided sha does not match query")
+		}
+		return persistedQueries.store(ctx, "", sha256Hash, query, false)
+	}
+	// The queries persisted by the clients themselves share the store with the allowed ones, so
+	// only the ones marked by AddPersistedQueries are allowed.
+	if allowlist && !pq.allowed {
+		return errPersistedQueryNotAllowed
+	}
+
+	if len(query) > 0 && pq.query != query {
+		return errors.New("query does not match persisted query")
+	}
+
+	gqlReq.Query = pq.query
+	return nil
+
+}
+
+var errPersistedQueryNotAllowed = errors.New("PersistedQueryNotAllowed: only the queries added " +
+	"via addPersistedQueries can be executed")
+
+// AddPersistedQueries persists the given queries in the namespace of the context, so that they
+// can be executed by their sha256 hash alone. These are the only queries that get executed, if
+// persisted queries are enforced via --graphql persisted-queries-only. It returns the sha256
+// hashes of the queries, in their order.
+func AddPersistedQueries(ctx context.Context, queries []string) ([]string, error) {
+	hashes := make([]string, 0, len(queries))
+	for _, query := range queries {
+		if strings.TrimSpace(query) == "" {
+			return nil, errors.New("can't persist an empty query")
+		}
+		sha256Hash := queryHash(query)
+		pq, err := persistedQueries.get(ctx, sha256Hash, query)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case pq == nil:
+			err = persistedQueries.store(ctx, "", sha256Hash, query, true)
+		case !pq.allowed:
+			// The query was already persisted by a client, it only needs to be allowed.
+			err = persistedQueries.store(ctx, pq.uid, sha256Hash, query, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, sha256Hash)
+	}
+	return hashes, nil
+}
+
+// persistedQuery is a query persisted along with its sha256 hash.
+type persistedQuery struct {
+	uid   string
+	query string
+	// allowed is set if the query was added via AddPersistedQueries, rather than persisted by a
+	// client.
+	allowed bool
+}
+
+// persistedQueryStore persists the queries in the namespace of the context.
+type persistedQueryStore interface {
+	// get returns the query persisted with the given sha256Hash, if any. query can be empty.
+	get(ctx context.Context, sha256Hash, query string) (*persistedQuery, error)
+	// store persists query, or only marks it as allowed if it was already persisted as uid.
+	store(ctx context.Context, uid, sha256Hash, query string, allowed bool) error
+}
+
+// persistedQueries is the store of the persisted queries. It's replaced in tests.
+var persistedQueries persistedQueryStore = dbPersistedQueries{}
 
+// dbPersistedQueries stores the persisted queries in dgraph.graphql.p_query, prefixed with their
+// hash, of which only the hash is indexed. The allowed ones also have dgraph.graphql.p_allowed,
+// which only AddPersistedQueries sets.
+type dbPersistedQueries struct{}
+
+func (dbPersistedQueries) get(ctx context.Context, sha256Hash,
+	query string) (*persistedQuery, error) {
 	queryForSHA := `query Me($join: string){
 						me(func: eq(dgraph.graphql.p_query, $join)){
+							uid
 							dgraph.graphql.p_query
+							dgraph.graphql.p_allowed
 						}
 					}`
 	variables := map[string]string{
-		"$join": join,
+		"$join": sha256Hash + query,
 	}
 	req := &Request{
 		Req: &pb.Request{
@@ -69,85 +169,81 @@ func ProcessPersistedQuery(ctx context.Context, gqlReq *schema.Request) error {
 
 	if err != nil {
 		glog.Errorf("Error while querying sha %s", sha256Hash)
-		return err
+		return nil, err
 	}
 
 	type shaQueryResponse struct {
 		Me []struct {
+			Uid            string `json:"uid"`
 			PersistedQuery string `json:"dgraph.graphql.p_query"`
+			Allowed        bool   `json:"dgraph.graphql.p_allowed"`
 		} `json:"me"`
 	}
 
 	shaQueryRes := &shaQueryResponse{}
 	if len(storedQuery.Json) > 0 {
 		if err := json.Unmarshal(storedQuery.Json, shaQueryRes); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if len(shaQueryRes.Me) == 0 {
-		if query == "" {
-			return errors.New("PersistedQueryNotFound")
-		}
-		if match, err := hashMatches(query, sha256Hash); err != nil {
-			return err
-		} else if !match {
-			return errors.New("provided sha does not match query")
-		}
-
-		req = &Request{
-			Req: &pb.Request{
-				Mutations: []*pb.Mutation{
-					{
-						Edges: []*pb.Edge{
-							{
-								Subject:     "_:a",
-								Predicate:   "dgraph.graphql.p_query",
-								ObjectValue: types.StringToBinary(join),
-							},
-							{
-								Subject:   "_:a",
-								Predicate: "dgraph.type",
-								ObjectValue: types.StringToBinary(
-									"dgraph.graphql.persisted_query"),
-							},
-						},
-					},
-				},
-				CommitNow: true,
-			},
-			doAuth: NoAuthorize,
-		}
-
-		ctx := context.WithValue(ctx, IsGraphql, true)
-		_, err := doQuery(ctx, req)
-		return err
+		return nil, nil
 	}
-
 	if len(shaQueryRes.Me) != 1 {
-		return fmt.Errorf("same sha returned %d queries", len(shaQueryRes.Me))
+		return nil, fmt.Errorf("same sha returned %d queries", len(shaQueryRes.Me))
 	}
 
-	gotQuery := ""
-	if len(shaQueryRes.Me[0].PersistedQuery) >= 64 {
-		gotQuery = shaQueryRes.Me[0].PersistedQuery[64:]
+	me := shaQueryRes.Me[0]
+	pq := &persistedQuery{uid: me.Uid, allowed: me.Allowed}
+	if len(me.PersistedQuery) >= 64 {
+		pq.query = me.PersistedQuery[64:]
 	}
+	return pq, nil
+}
 
-	if len(query) > 0 && gotQuery != query {
-		return errors.New("query does not match persisted query")
+func (dbPersistedQueries) store(ctx context.Context, uid, sha256Hash, query string,
+	allowed bool) error {
+	var edges []*pb.Edge
+	if uid == "" {
+		uid = "_:a"
+		edges = append(edges, &pb.Edge{
+			Subject:     uid,
+			Predicate:   "dgraph.graphql.p_query",
+			ObjectValue: types.StringToBinary(sha256Hash + query),
+		}, &pb.Edge{
+			Subject:     uid,
+			Predicate:   "dgraph.type",
+			ObjectValue: types.StringToBinary("dgraph.graphql.persisted_query"),
+		})
+	}
+	if allowed {
+		val, err := types.ToBinary(types.TypeBool, true)
+		x.Check(err)
+		edges = append(edges, &pb.Edge{
+			Subject:     uid,
+			Predicate:   "dgraph.graphql.p_allowed",
+			ObjectValue: val,
+		})
+	}
+	req := &Request{
+		Req: &pb.Request{
+			Mutations: []*pb.Mutation{{Edges: edges}},
+			CommitNow: true,
+		},
+		doAuth: NoAuthorize,
 	}
 
-	gqlReq.Query = gotQuery
-	return nil
+	ctx = context.WithValue(ctx, IsGraphql, true)
+	_, err := doQuery(ctx, req)
+	return err
+}
 
+func queryHash(query string) string {
+	hash := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(hash[:])
 }
 
-func hashMatches(query, sha256Hash string) (bool, error) {
-	hasher := sha256.New()
-	_, err := hasher.Write([]byte(query))
-	if err != nil {
-		return false, err
-	}
-	hashGenerated := hex.EncodeToString(hasher.Sum(nil))
-	return hashGenerated == sha256Hash, nil
+func hashMatches(query, sha256Hash string) bool {
+	return queryHash(query) == sha256Hash
 }
@@ -0,0 +1,133 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package edgraph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/stretchr/testify/require"
+)
+
+// memPersistedQueries keeps the persisted queries in memory, by their hash.
+type memPersistedQueries map[string]*persistedQuery
+
+func (m memPersistedQueries) get(_ context.Context, sha256Hash,
+	query string) (*persistedQuery, error) {
+	pq, ok := m[sha256Hash]
+	if !ok {
+		return nil, nil
+	}
+	cp := *pq
+	return &cp, nil
+}
+
+func (m memPersistedQueries) store(_ context.Context, uid, sha256Hash, query string,
+	allowed bool) error {
+	if uid == "" {
+		m[sha256Hash] = &persistedQuery{uid: fmt.Sprintf("%#x", len(m)+1), query: query}
+	}
+	if allowed {
+		m[sha256Hash].allowed = true
+	}
+	return nil
+}
+
+func withPersistedQueries(t *testing.T) memPersistedQueries {
+	store := make(memPersistedQueries)
+	prev := persistedQueries
+	persistedQueries = store
+	t.Cleanup(func() { persistedQueries = prev })
+	return store
+}
+
+func persistedRequest(query, sha256Hash string) *schema.Request {
+	req := &schema.Request{Query: query}
+	req.Extensions.PersistedQuery.Sha256Hash = sha256Hash
+	return req
+}
+
+func TestPersistedQuery(t *testing.T) {
+	store := withPersistedQueries(t)
+	ctx := context.Background()
+	query := `query { queryAuthor { name } }`
+	hash := queryHash(query)
+
+	req := persistedRequest("", hash)
+	require.EqualError(t, ProcessPersistedQuery(ctx, req, false), "PersistedQueryNotFound")
+	require.EqualError(t, ProcessPersistedQuery(ctx, persistedRequest(query, queryHash("x")),
+		false), "provided sha does not match query")
+
+	// Clients persist the queries themselves, unless persisted queries are enforced.
+	require.NoError(t, ProcessPersistedQuery(ctx, persistedRequest(query, hash), false))
+	require.Len(t, store, 1)
+	require.False(t, store[hash].allowed)
+	req = persistedRequest("", hash)
+	require.NoError(t, ProcessPersistedQuery(ctx, req, false))
+	require.Equal(t, query, req.Query)
+
+	require.EqualError(t, ProcessPersistedQuery(ctx, persistedRequest(`query { x }`, hash),
+		false), "query does not match persisted query")
+}
+
+func TestPersistedQueryAllowlist(t *testing.T) {
+	store := withPersistedQueries(t)
+	ctx := context.Background()
+	allowed := `query { queryAuthor { name } }`
+	other := `query { queryPost { title } }`
+
+	// A query persisted by a client isn't allowed.
+	require.NoError(t, ProcessPersistedQuery(ctx, persistedRequest(other, queryHash(other)),
+		false))
+	for _, req := range []*schema.Request{
+		persistedRequest("", queryHash(other)),
+		persistedRequest(other, queryHash(other)),
+		persistedRequest(other, ""),
+	} {
+		require.Equal(t, errPersistedQueryNotAllowed, ProcessPersistedQuery(ctx, req, true))
+	}
+	// Nor can clients persist new queries.
+	newQuery := `query { queryCountry { name } }`
+	require.Equal(t, errPersistedQueryNotAllowed,
+		ProcessPersistedQuery(ctx, persistedRequest(newQuery, queryHash(newQuery)), true))
+	require.Len(t, store, 1)
+
+	hashes, err := AddPersistedQueries(ctx, []string{allowed, other})
+	require.NoError(t, err)
+	require.Equal(t, []string{queryHash(allowed), queryHash(other)}, hashes)
+	require.Len(t, store, 2)
+	// The query persisted by the client is allowed in place.
+	require.Equal(t, "0x1", store[queryHash(other)].uid)
+
+	for _, query := range []string{allowed, other} {
+		req := persistedRequest("", queryHash(query))
+		require.NoError(t, ProcessPersistedQuery(ctx, req, true))
+		require.Equal(t, query, req.Query)
+
+		// The queries sent without their hash are looked up by it.
+		req = persistedRequest(query, "")
+		require.NoError(t, ProcessPersistedQuery(ctx, req, true))
+		require.Equal(t, query, req.Query)
+	}
+}
+
+func TestAddPersistedQueries(t *testing.T) {
+	store := withPersistedQueries(t)
+	ctx := context.Background()
+	query := `query { queryAuthor { name } }`
+
+	_, err := AddPersistedQueries(ctx, []string{query, " "})
+	require.EqualError(t, err, "can't persist an empty query")
+
+	// Adding the same query again keeps it as is.
+	for i := 0; i < 2; i++ {
+		hashes, err := AddPersistedQueries(ctx, []string{query})
+		require.NoError(t, err)
+		require.Equal(t, []string{queryHash(query)}, hashes)
+		require.Len(t, store, 1)
+		require.Equal(t, &persistedQuery{uid: "0x1", query: query, allowed: true},
+			store[queryHash(query)])
+	}
+}
@@ -16,7 +16,19 @@
 
 package edgraph
 
-import "context"
+import (
+	"context"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/query"
+	"github.com/outcaste-io/outserv/schema"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/outserv/zero"
+)
 
 type ResetPasswordInput struct {
 	UserID    string
@@ -24,18 +36,90 @@ type ResetPasswordInput struct {
 	Namespace uint64
 }
 
+// CreateNamespace leases a new namespace from Zero, and sets it up with the initial schema, the
+// guardians group and the groot user with the given password. The GraphQL schema and the lambda
+// script of the namespace start out empty. Only the guardians of the galaxy can create namespaces.
 func CreateNamespace(ctx context.Context, passwd string) (uint64, error) {
-	return 0, nil
+	if !x.WorkerConfig.AclEnabled {
+		return 0, errors.Wrapf(errAclDisabled, "while creating namespace")
+	}
+	if err := AuthGuardianOfTheGalaxy(ctx); err != nil {
+		return 0, errors.Wrapf(err, "while creating namespace")
+	}
+
+	ids, err := zero.AssignNsids(ctx, 1)
+	if err != nil {
+		return 0, errors.Wrapf(err, "while leasing a namespace id")
+	}
+	ns := ids.StartId
+	glog.Infof("Got a lease for NsID: %#x", ns)
+
+	m := &pb.Mutations{Schema: schema.InitialSchema(ns)}
+	if _, err := query.ApplyMutations(x.AttachNamespace(ctx, ns), m); err != nil {
+		return 0, errors.Wrapf(err, "while applying the initial schema of namespace %#x", ns)
+	}
+	// The ACL queries need the index on dgraph.xid.
+	if err := worker.WaitForIndexing(ctx, true); err != nil {
+		return 0, err
+	}
+	if err := createGuardianAndGroot(ctx, ns, passwd); err != nil {
+		return 0, errors.Wrapf(err, "while creating the guardians and groot of namespace %#x", ns)
+	}
+	glog.V(2).Infof("Created namespace: %#x", ns)
+	return ns, nil
 }
 
+// DeleteNamespace drops all the data of the namespace across the cluster, including its schemas,
+// lambda script and ACL. Only the guardians of the galaxy can delete namespaces.
 func DeleteNamespace(ctx context.Context, namespace uint64) error {
-	return nil
+	if err := AuthGuardianOfTheGalaxy(ctx); err != nil {
+		return errors.Wrapf(err, "while deleting namespace")
+	}
+	if namespace == x.GalaxyNamespace {
+		return errors.New("Cannot delete default namespace.")
+	}
+
+	glog.Infof("Deleting namespace: %#x", namespace)
+	// Every Alpha drops the ACL state of the namespace, once it applies the deletion.
+	return worker.ProcessDeleteNsRequest(ctx, namespace)
+}
+
+// dropNamespaceAcl forgets the ACL rules, and the guardians and groot uids of the deleted
+// namespace.
+func dropNamespaceAcl(namespace uint64) {
+	aclCachePtr.delete(namespace)
+	x.GuardiansUid.Delete(namespace)
+	x.GrootUid.Delete(namespace)
 }
 
-func ResetPassword(ctx context.Context, ns *ResetPasswordInput) error {
-	return nil
+// ResetPassword sets the password of a user in any namespace. Only the guardians of the galaxy
+// can reset passwords, so they can recover the namespaces whose guardians lost their password.
+func ResetPassword(ctx context.Context, inp *ResetPasswordInput) error {
+	if err := AuthGuardianOfTheGalaxy(ctx); err != nil {
+		return errors.Wrapf(err, "while resetting password")
+	}
+	if inp == nil || inp.UserID == "" || inp.Password == "" {
+		return errors.New("userId and password must be provided to reset the password")
+	}
+
+	uid, err := getAclNode(ctx, inp.Namespace, aclTypeUser, inp.UserID)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		return errors.Errorf("user %q doesn't exist in namespace %#x", inp.UserID, inp.Namespace)
+	}
+	_, err = mutateAcl(ctx, inp.Namespace, &pb.Mutation{
+		Edges: []*pb.Edge{passwordEdge(uid, inp.Password)},
+	})
+	return err
 }
 
-func createGuardianAndGroot(ctx context.Context, namespace uint64) error {
-	return nil
+// createGuardianAndGroot makes sure that the guardians group and the groot user exist in the
+// namespace. The password is only set if groot gets created.
+func createGuardianAndGroot(ctx context.Context, namespace uint64, passwd string) error {
+	if err := upsertGuardian(ctx, namespace); err != nil {
+		return err
+	}
+	return upsertGroot(ctx, namespace, passwd)
 }
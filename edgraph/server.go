@@ -1145,6 +1145,7 @@ var serverOverloadErr = errors.New("429 Too Many Requests. Please throttle your
 
 func Init() {
 	maxPendingQueries = x.Config.Limit.GetInt64("max-pending-queries")
+	worker.OnNamespaceDeleted(dropNamespaceAcl)
 }
 
 func StopServingQueries() {
@@ -16,24 +16,184 @@
 
 package audit
 
-import "github.com/outcaste-io/outserv/x"
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 
-type AuditConf struct {
-	Dir string
+	"github.com/golang/glog"
+	"github.com/outcaste-io/ristretto/z"
+	"github.com/pkg/errors"
+
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+)
+
+const (
+	defaultAuditFilenameF = "audit_%d_%d.log"
+
+	UnauthorisedUser = "UnauthorisedUser"
+	UnknownUser      = "UnknownUser"
+	PoorManAuth      = "PoorManAuth"
+
+	Http      = "Http"
+	WebSocket = "Websocket"
+
+	redacted = "[REDACTED]"
+)
+
+var auditEnabled uint32
+
+// AuditEvent is a single entry of the audit log.
+type AuditEvent struct {
+	User       string
+	Namespace  uint64
+	ServerHost string
+	ClientHost string
+	Endpoint   string
+	ReqType    string
+	// Operation is the name of the GraphQL operation, or the type of the operation followed by
+	// its top level fields if the operation has no name.
+	Operation string
+	Variables map[string]interface{}
+	Status    string
+}
+
+type auditLogger struct {
+	log *x.Logger
+	// redact holds the lower-cased names of the variables whose values don't get logged.
+	redact map[string]struct{}
 }
 
+var auditor = &auditLogger{}
+
+// GetAuditConf parses the audit superflag. It returns nil if audit logging isn't enabled.
 func GetAuditConf(conf string) *x.LoggerConf {
-	return nil
+	if conf == "" || conf == worker.AuditDefaults {
+		return nil
+	}
+	auditFlag := z.NewSuperFlag(conf).MergeAndCheckDefault(worker.AuditDefaults)
+	out := auditFlag.GetPath("output")
+	x.AssertTruef(out != "", "output flag is not provided for the audit logs")
+	if out != "stdout" {
+		var err error
+		out, err = filepath.Abs(out)
+		x.Check(err)
+	}
+	encKey, err := readAuditEncKey(auditFlag)
+	x.Check(err)
+
+	var redact []string
+	for _, name := range strings.Split(auditFlag.GetString("redact"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			redact = append(redact, name)
+		}
+	}
+	return &x.LoggerConf{
+		Compress:      auditFlag.GetBool("compress"),
+		Output:        out,
+		EncryptionKey: encKey,
+		Days:          auditFlag.GetInt64("days"),
+		Size:          auditFlag.GetInt64("size"),
+		MessageKey:    "endpoint",
+		Redact:        redact,
+	}
+}
+
+func readAuditEncKey(conf *z.SuperFlag) ([]byte, error) {
+	encFile := conf.GetPath("encrypt-file")
+	if encFile == "" {
+		return nil, nil
+	}
+	encKey, err := ioutil.ReadFile(encFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading the audit encryption key")
+	}
+	switch len(encKey) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.Errorf("audit encryption key must be 16, 24 or 32 bytes long, got %d",
+			len(encKey))
+	}
+	return encKey, nil
 }
 
+// InitAuditorIfNecessary starts the audit logging if it's configured. The eeEnabled function is
+// unused and kept for compatibility.
 func InitAuditorIfNecessary(conf *x.LoggerConf, eeEnabled func() bool) error {
-	return nil
+	if conf == nil {
+		return nil
+	}
+	return InitAuditor(conf, uint64(worker.GroupId()), worker.NodeId())
 }
 
+// InitAuditor starts writing the audit logs of the node nId in group gId.
 func InitAuditor(conf *x.LoggerConf, gId, nId uint64) error {
+	log, err := x.InitLogger(conf, fmt.Sprintf(defaultAuditFilenameF, gId, nId))
+	if err != nil {
+		return errors.Wrapf(err, "while initializing the audit logger")
+	}
+	auditor.log = log
+	auditor.redact = make(map[string]struct{})
+	for _, name := range conf.Redact {
+		auditor.redact[strings.ToLower(name)] = struct{}{}
+	}
+	atomic.StoreUint32(&auditEnabled, 1)
+	glog.Infoln("audit logs are enabled")
 	return nil
 }
 
+// Close flushes the audit log and stops the logging.
 func Close() {
-	return
+	if !atomic.CompareAndSwapUint32(&auditEnabled, 1, 0) {
+		return
+	}
+	auditor.log.Sync()
+}
+
+func (a *auditLogger) Audit(event *AuditEvent) {
+	a.log.AuditI(event.Endpoint,
+		"level", "AUDIT",
+		"user", event.User,
+		"namespace", event.Namespace,
+		"server", event.ServerHost,
+		"client", event.ClientHost,
+		"req_type", event.ReqType,
+		"operation", event.Operation,
+		"variables", a.redactValues(event.Variables),
+		"status", event.Status)
+}
+
+// redactValues returns a copy of the variables, in which the values of the redacted names are
+// replaced, no matter how deep they are nested in input objects.
+func (a *auditLogger) redactValues(vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		if _, ok := a.redact[strings.ToLower(k)]; ok {
+			out[k] = redacted
+			continue
+		}
+		out[k] = a.redactValue(v)
+	}
+	return out
+}
+
+func (a *auditLogger) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return a.redactValues(val)
+	case []interface{}:
+		out := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			out = append(out, a.redactValue(item))
+		}
+		return out
+	default:
+		return v
+	}
 }
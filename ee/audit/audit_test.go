@@ -0,0 +1,45 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactValues(t *testing.T) {
+	a := &auditLogger{redact: map[string]struct{}{"password": {}}}
+	vars := map[string]interface{}{
+		"name":     "alice",
+		"Password": "secret",
+		"input": []interface{}{
+			map[string]interface{}{"name": "bob", "password": "hunter2"},
+		},
+	}
+	require.Equal(t, map[string]interface{}{
+		"name":     "alice",
+		"Password": redacted,
+		"input": []interface{}{
+			map[string]interface{}{"name": "bob", "password": redacted},
+		},
+	}, a.redactValues(vars))
+	// The variables of the request are left untouched.
+	require.Equal(t, "secret", vars["Password"])
+}
+
+func TestClientIP(t *testing.T) {
+	require.Equal(t, "10.0.0.1", clientIP("", "10.0.0.1:5080"))
+	require.Equal(t, "::1", clientIP("", "[::1]:5080"))
+	require.Equal(t, "10.0.0.1", clientIP("", "10.0.0.1"))
+	require.Equal(t, "203.0.113.7", clientIP("203.0.113.7, 10.0.0.2", "10.0.0.1:5080"))
+	require.Equal(t, "", clientIP("", ""))
+}
+
+func TestOperation(t *testing.T) {
+	require.Equal(t, "given", operation("given", "query named { a }"))
+	require.Equal(t, "named", operation("", "query named { a }"))
+	require.Equal(t, "mutation addPost deletePost",
+		operation("", "mutation { addPost(input: []) { numUids } deletePost { numUids } }"))
+	require.Equal(t, "", operation("", "{ invalid"))
+}
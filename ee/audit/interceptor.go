@@ -17,25 +17,235 @@
 package audit
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 
+	"github.com/gorilla/websocket"
+	"github.com/outcaste-io/gqlparser/v2/ast"
+	"github.com/outcaste-io/gqlparser/v2/parser"
 	"google.golang.org/grpc"
 
 	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/x"
 )
 
+// skipEPs are the endpoints which don't get audited, as they don't read or change any data.
+var skipEPs = map[string]bool{
+	"/":              true,
+	"/health":        true,
+	"/state":         true,
+	"/probe/graphql": true,
+	"/ui/keywords":   true,
+}
+
+// AuditRequestGRPC is a no-op. Alpha only serves the internal APIs over gRPC, which are not
+// audited.
 func AuditRequestGRPC(ctx context.Context, req interface{},
 	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	return handler(ctx, req)
 }
 
+// AuditRequestHttp writes an audit entry for every request served by next, once the response has
+// been written.
 func AuditRequestHttp(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r)
+		if atomic.LoadUint32(&auditEnabled) == 0 || skipEPs[r.URL.Path] ||
+			strings.HasPrefix(r.URL.Path, "/debug/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// GraphQL subscriptions only send their access token and query once the connection has
+		// been upgraded. They get audited by AuditWebSockets instead.
+		for _, subprotocol := range websocket.Subprotocols(r) {
+			if subprotocol == "graphql-ws" {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		rw := NewResponseWriter(w)
+		var buf bytes.Buffer
+		r.Body = ioutil.NopCloser(io.TeeReader(r.Body, &buf))
+		next.ServeHTTP(rw, r)
+		r.Body = ioutil.NopCloser(&buf)
+		auditHttp(rw, r)
 	})
 }
 
-func AuditWebSockets(ctx context.Context, req *schema.Request) {
-	return
+// RemoteAddrHeader carries the address of the client of a GraphQL subscription to
+// AuditWebSockets, as only the headers of the request get passed along with the subscription.
+// The server always sets it, overriding any sent by the client.
+const RemoteAddrHeader = "X-Outserv-Remote-Addr"
+
+// AuditWebSockets writes an audit entry for a GraphQL subscription. httpHeader is the header of the
+// HTTP request which opened the websocket.
+func AuditWebSockets(ctx context.Context, req *schema.Request, httpHeader http.Header) {
+	if atomic.LoadUint32(&auditEnabled) == 0 {
+		return
+	}
+
+	var namespace uint64
+	var user string
+	if token := req.Header.Get("X-Dgraph-AccessToken"); token != "" {
+		user = getUser(token, false)
+		namespace, _ = x.ExtractNamespaceFromJwt(token)
+	} else if token := req.Header.Get("X-Dgraph-AuthToken"); token != "" {
+		user = getUser(token, true)
+	} else {
+		user = getUser("", false)
+	}
+
+	auditor.Audit(&AuditEvent{
+		User:       user,
+		Namespace:  namespace,
+		ServerHost: x.WorkerConfig.MyAddr,
+		ClientHost: clientIP(httpHeader.Get("X-Forwarded-For"), httpHeader.Get(RemoteAddrHeader)),
+		Endpoint:   "/graphql",
+		ReqType:    WebSocket,
+		Operation:  operation(req.OperationName, req.Query),
+		Variables:  req.Variables,
+		Status:     http.StatusText(http.StatusOK),
+	})
+}
+
+func auditHttp(w *ResponseWriter, r *http.Request) {
+	var user string
+	if token := r.Header.Get("X-Dgraph-AccessToken"); token != "" {
+		user = getUser(token, false)
+	} else if token := r.Header.Get("X-Dgraph-AuthToken"); token != "" {
+		user = getUser(token, true)
+	} else {
+		user = getUser("", false)
+	}
+
+	req := getRequest(r)
+	auditor.Audit(&AuditEvent{
+		User:       user,
+		Namespace:  x.ExtractNamespaceHTTP(r),
+		ServerHost: x.WorkerConfig.MyAddr,
+		ClientHost: clientIP(r.Header.Get("X-Forwarded-For"), r.RemoteAddr),
+		Endpoint:   r.URL.Path,
+		ReqType:    Http,
+		Operation:  operation(req.OperationName, req.Query),
+		Variables:  req.Variables,
+		Status:     http.StatusText(w.statusCode),
+	})
+}
+
+// clientIP returns the IP of the client: the first one in forwardedFor, if the request went
+// through proxies, or else the one in remoteAddr.
+func clientIP(forwardedFor, remoteAddr string) string {
+	if ip := strings.TrimSpace(strings.Split(forwardedFor, ",")[0]); ip != "" {
+		return ip
+	}
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return ip
+}
+
+func getUser(token string, poorman bool) string {
+	if poorman {
+		return PoorManAuth
+	}
+	if token == "" {
+		if x.WorkerConfig.AclEnabled {
+			return UnauthorisedUser
+		}
+		return ""
+	}
+	user, err := x.ExtractUserName(token)
+	if err != nil {
+		return UnknownUser
+	}
+	return user
+}
+
+// auditRequest holds the parts of a GraphQL or DQL request which get audited. The query itself
+// isn't logged, as it might contain literal values which should be redacted.
+type auditRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// getRequest reads the request from the query parameters, or from the body if it's JSON.
+func getRequest(r *http.Request) *auditRequest {
+	req := &auditRequest{}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			_ = json.Unmarshal([]byte(vars), &req.Variables)
+		}
+		return req
+	}
+	if !strings.Contains(r.Header.Get("Content-Type"), "json") {
+		return req
+	}
+
+	var in io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return req
+		}
+		defer gz.Close()
+		in = gz
+	}
+	dec := json.NewDecoder(in)
+	dec.UseNumber()
+	_ = dec.Decode(req)
+	return req
+}
+
+// operation returns the name of the operation. If the operation has no name, it returns the type
+// of the first operation in the query followed by its top level fields, e.g. "mutation addPost".
+func operation(name, query string) string {
+	if name != "" || query == "" {
+		return name
+	}
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil || len(doc.Operations) == 0 {
+		return ""
+	}
+	op := doc.Operations[0]
+	if op.Name != "" {
+		return op.Name
+	}
+	parts := []string{string(op.Operation)}
+	for _, sel := range op.SelectionSet {
+		if f, ok := sel.(*ast.Field); ok {
+			parts = append(parts, f.Name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ResponseWriter records the status code of the response.
+type ResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	// WriteHeader(int) is not called if our response implicitly returns 200 OK, so
+	// we default to that status code.
+	return &ResponseWriter{w, http.StatusOK}
+}
+
+func (rw *ResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
 }
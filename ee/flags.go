@@ -55,7 +55,7 @@ const (
 )
 
 func RegisterAclAndEncFlags(flag *pflag.FlagSet) {
-	// registerAclFlag(flag)
+	registerAclFlag(flag)
 	registerEncFlag(flag)
 	// registerVaultFlag(flag, true, true)
 }
@@ -123,7 +123,7 @@ func registerVaultFlag(flag *pflag.FlagSet, aclEnabled, encEnabled bool) {
 
 func registerAclFlag(flag *pflag.FlagSet) {
 	helpText := z.NewSuperFlagHelp(AclDefaults).
-		Head("[Enterprise Feature] ACL options").
+		Head("ACL options").
 		Flag("secret-file",
 			"The file that stores the HMAC secret, which is used for signing the JWT and "+
 				"should have at least 32 ASCII characters. Required to enable ACLs.").
@@ -17,12 +17,45 @@
 package ee
 
 import (
+	"io/ioutil"
+
+	"github.com/outcaste-io/ristretto/z"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
 // GetKeys returns the ACL and encryption keys as configured by the user
-// through the --acl, --encryption, and --vault flags. On OSS builds,
-// this function always returns an error.
+// through the --acl and --encryption flags. The ACL key is nil if no secret
+// file was given, which keeps ACLs disabled.
 func GetKeys(config *viper.Viper) (*Keys, error) {
-	return &Keys{}, nil
+	aclFlag := z.NewSuperFlag(config.GetString(flagAcl)).MergeAndCheckDefault(AclDefaults)
+	encFlag := z.NewSuperFlag(config.GetString(flagEnc)).MergeAndCheckDefault(EncDefaults)
+
+	keys := &Keys{
+		AclAccessTtl:  aclFlag.GetDuration(flagAclAccessTtl),
+		AclRefreshTtl: aclFlag.GetDuration(flagAclRefreshTtl),
+	}
+	if path := aclFlag.GetPath(flagAclSecretFile); path != "" {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading ACL secret file: %s", path)
+		}
+		if len(key) < 32 {
+			return nil, errors.Errorf("ACL secret must have at least 32 bytes, got %d bytes",
+				len(key))
+		}
+		keys.AclKey = key
+	}
+	if path := encFlag.GetPath(flagEncKeyFile); path != "" {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading encryption key file: %s", path)
+		}
+		if l := len(key); l != 16 && l != 24 && l != 32 {
+			return nil, errors.Errorf("Encryption key must have 16, 24 or 32 bytes, got %d bytes",
+				l)
+		}
+		keys.EncKey = key
+	}
+	return keys, nil
 }
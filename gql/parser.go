@@ -201,6 +201,29 @@ func (f *Function) IsPasswordVerifier() bool {
 	return f.Name == "checkpwd"
 }
 
+// IsSearch returns true if the function scores or highlights the full-text matches of a node,
+// like bm25(name, "text") or highlight(name, "text"), or measures how far its vector is from
+// another, like vector_distance(embedding, "[0.1, 0.2]", "cosine").
+func (f *Function) IsSearch() bool {
+	return isSearchFunc(f.Name)
+}
+
+// SearchPredicates returns the predicates read by the search function f.
+func (f *Function) SearchPredicates() []string {
+	if f.Name == "vector_distance" {
+		return []string{f.Args[0].Value}
+	}
+	var preds []string
+	for i := 0; i < len(f.Args); i += 2 {
+		preds = append(preds, f.Args[i].Value)
+	}
+	return preds
+}
+
+func isSearchFunc(name string) bool {
+	return name == "bm25" || name == "highlight" || name == "vector_distance"
+}
+
 // DebugPrint is useful for debugging.
 func (gq *GraphQuery) DebugPrint(prefix string) {
 	glog.Infof("%s[%x %q %q]\n", prefix, gq.UID, gq.Attr, gq.Alias)
@@ -1567,7 +1590,8 @@ func validFuncName(name string) bool {
 
 	switch name {
 	case "regexp", "anyofterms", "allofterms", "alloftext", "anyoftext",
-		"has", "uid", "uid_in", "anyof", "allof", "type", "match":
+		"has", "uid", "uid_in", "anyof", "allof", "type", "match", "similar_to",
+		"starts_with":
 		return true
 	}
 	return false
@@ -2630,6 +2654,38 @@ func godeep(it *lex.ItemIterator, gq *GraphQuery) error {
 				gq.Children = append(gq.Children, child)
 				curp = nil
 				continue
+			case isSearchFunc(valLower) && peekIt[0].Typ == itemLeftRound:
+				if varName == "" && alias == "" {
+					return it.Errorf("Function %s should be used with a variable or have an alias",
+						valLower)
+				}
+				child := &GraphQuery{
+					Args:  make(map[string]string),
+					Var:   varName,
+					Alias: alias,
+				}
+				varName, alias = "", ""
+				it.Prev()
+				if child.Func, err = parseFunction(it, gq); err != nil {
+					return err
+				}
+				child.Func.Args = append([]Arg{{Value: child.Func.Attr}}, child.Func.Args...)
+				// The args of bm25 and highlight are pairs of a predicate and the text to search
+				// for in it.
+				n := len(child.Func.Args)
+				if valLower == "vector_distance" {
+					if n != 2 && n != 3 {
+						return it.Errorf("Function %s expects a predicate, a vector and "+
+							"optionally the distance", valLower)
+					}
+				} else if n%2 != 0 || (valLower == "highlight" && n != 2) {
+					return it.Errorf("Function %s expects a predicate and the text to search for",
+						valLower)
+				}
+				child.Attr = child.Func.Attr
+				gq.Children = append(gq.Children, child)
+				curp = nil
+				continue
 			case isAggregator(valLower):
 				child := &GraphQuery{
 					Attr:       valueFunc,
@@ -526,6 +526,89 @@ func TestParseCheckPwd(t *testing.T) {
 	require.Equal(t, "password", gq.Query[0].Children[0].Attr)
 }
 
+func TestParseSearchFuncs(t *testing.T) {
+	query := `{
+		me(func: anyoftext(title, "quick fox")) {
+			score as bm25(title, "quick fox", body, "fox")
+			snippet : highlight(body, "fox")
+			highlight
+		}
+	}
+`
+	gq, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	children := gq.Query[0].Children
+	require.Equal(t, "bm25", children[0].Func.Name)
+	require.Equal(t, "score", children[0].Var)
+	require.Equal(t, "title", children[0].Attr)
+	require.Equal(t, []Arg{{Value: "title"}, {Value: "quick fox"}, {Value: "body"},
+		{Value: "fox"}}, children[0].Func.Args)
+	require.Equal(t, "highlight", children[1].Func.Name)
+	require.Equal(t, "snippet", children[1].Alias)
+	require.Equal(t, "body", children[1].Attr)
+	// Without args, highlight is just a predicate.
+	require.Nil(t, children[2].Func)
+	require.Equal(t, "highlight", children[2].Attr)
+}
+
+func TestParseSearchFuncsWithoutAlias(t *testing.T) {
+	query := `{
+		me(func: uid(1)) {
+			bm25(title, "quick fox")
+		}
+	}
+`
+	_, err := Parse(Request{Str: query})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Function bm25 should be used with a variable or have an alias")
+}
+
+func TestParseVectorDistance(t *testing.T) {
+	query := `{
+		me(func: similar_to(embedding, 5, "[0.1, 0.2]", "cosine")) {
+			dist as vector_distance(embedding, "[0.1, 0.2]", "cosine")
+		}
+	}
+`
+	gq, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	require.Equal(t, "similar_to", gq.Query[0].Func.Name)
+	require.Equal(t, "embedding", gq.Query[0].Func.Attr)
+	child := gq.Query[0].Children[0]
+	require.Equal(t, "vector_distance", child.Func.Name)
+	require.Equal(t, "dist", child.Var)
+	require.Equal(t, []string{"embedding"}, child.Func.SearchPredicates())
+
+	query = `{
+		me(func: uid(1)) {
+			dist as vector_distance(embedding)
+		}
+	}
+`
+	_, err = Parse(Request{Str: query})
+	require.Error(t, err)
+	require.Contains(t, err.Error(),
+		"Function vector_distance expects a predicate, a vector and optionally the distance")
+}
+
+func TestParseStartsWith(t *testing.T) {
+	query := `{
+		me(func: starts_with(symbol, "ET")) @filter(starts_with(name, "Ether")) {
+			symbol
+		}
+	}
+`
+	gq, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	require.Equal(t, "starts_with", gq.Query[0].Func.Name)
+	require.Equal(t, "symbol", gq.Query[0].Func.Attr)
+	require.Equal(t, "ET", gq.Query[0].Func.Args[0].Value)
+	filter := gq.Query[0].Filter.Func
+	require.Equal(t, "starts_with", filter.Name)
+	require.Equal(t, "name", filter.Attr)
+	require.Equal(t, "Ether", filter.Args[0].Value)
+}
+
 func TestParseComments(t *testing.T) {
 	query := `
 	# Something
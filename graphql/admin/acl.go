@@ -0,0 +1,264 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/graphql/resolve"
+	"github.com/outcaste-io/outserv/graphql/schema"
+)
+
+type userInput struct {
+	Name         string
+	Password     string
+	Groups       []string
+	AddGroups    []string
+	RemoveGroups []string
+}
+
+type ruleInput struct {
+	Predicate  string
+	Permission int32
+}
+
+type groupInput struct {
+	Name        string
+	Rules       []ruleInput
+	SetRules    []ruleInput
+	RemoveRules []string
+}
+
+func aclRules(rules []ruleInput) []*edgraph.AclRule {
+	out := make([]*edgraph.AclRule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, &edgraph.AclRule{Predicate: r.Predicate, Permission: r.Permission})
+	}
+	return out
+}
+
+func getAclInput(m *schema.Field, input interface{}) error {
+	inputByts, err := json.Marshal(m.ArgValue(schema.InputArgName))
+	if err != nil {
+		return schema.GQLWrapf(err, "couldn't get input argument")
+	}
+	return schema.GQLWrapf(json.Unmarshal(inputByts, input), "couldn't get input argument")
+}
+
+func ruleData(rules []*edgraph.AclRule) []interface{} {
+	out := make([]interface{}, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, map[string]interface{}{
+			"predicate":  r.Predicate,
+			"permission": json.Number(strconv.Itoa(int(r.Permission))),
+		})
+	}
+	return out
+}
+
+func userData(u *edgraph.AclUser) map[string]interface{} {
+	groups := make([]interface{}, 0, len(u.Groups))
+	for _, g := range u.Groups {
+		groups = append(groups, map[string]interface{}{
+			"name":  g.Name,
+			"rules": ruleData(g.Rules),
+		})
+	}
+	return map[string]interface{}{
+		"name":   u.Name,
+		"groups": groups,
+	}
+}
+
+// groupData returns the group along with its members, which are looked up in users.
+func groupData(g *edgraph.AclGroup, users []*edgraph.AclUser) map[string]interface{} {
+	members := make([]interface{}, 0)
+	for _, u := range users {
+		for _, ug := range u.Groups {
+			if ug.Uid == g.Uid {
+				members = append(members, map[string]interface{}{"name": u.Name})
+				break
+			}
+		}
+	}
+	return map[string]interface{}{
+		"name":  g.Name,
+		"users": members,
+		"rules": ruleData(g.Rules),
+	}
+}
+
+// getUserData returns the user with the given name, or nil if there's no such user.
+func getUserData(ctx context.Context, name string) (interface{}, error) {
+	users, err := edgraph.QueryAclUsers(ctx, name)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return userData(users[0]), nil
+}
+
+// getGroupData returns the group with the given name, or nil if there's no such group.
+func getGroupData(ctx context.Context, name string) (interface{}, error) {
+	groups, err := edgraph.QueryAclGroups(ctx, name)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	users, err := edgraph.QueryAclUsers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return groupData(groups[0], users), nil
+}
+
+func resolveGetUser(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	name, _ := q.ArgValue("name").(string)
+	user, err := getUserData(ctx, name)
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	return resolve.DataResult(q, map[string]interface{}{q.Name(): user}, nil)
+}
+
+func resolveGetCurrentUser(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	user, err := edgraph.CurrentAclUser(ctx)
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	var data interface{}
+	if user != nil {
+		data = userData(user)
+	}
+	return resolve.DataResult(q, map[string]interface{}{q.Name(): data}, nil)
+}
+
+func resolveQueryUser(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	users, err := edgraph.QueryAclUsers(ctx, "")
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	out := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		out = append(out, userData(u))
+	}
+	return resolve.DataResult(q, map[string]interface{}{q.Name(): out}, nil)
+}
+
+func resolveGetGroup(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	name, _ := q.ArgValue("name").(string)
+	group, err := getGroupData(ctx, name)
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	return resolve.DataResult(q, map[string]interface{}{q.Name(): group}, nil)
+}
+
+func resolveQueryGroup(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	groups, err := edgraph.QueryAclGroups(ctx, "")
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	users, err := edgraph.QueryAclUsers(ctx, "")
+	if err != nil {
+		return resolve.EmptyResult(q, err)
+	}
+	out := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, groupData(g, users))
+	}
+	return resolve.DataResult(q, map[string]interface{}{q.Name(): out}, nil)
+}
+
+func resolveAddUser(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	var input userInput
+	if err := getAclInput(m, &input); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	if err := edgraph.AddAclUser(ctx, input.Name, input.Password, input.Groups); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return userResult(ctx, m, input.Name)
+}
+
+func resolveUpdateUser(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	var input userInput
+	if err := getAclInput(m, &input); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	err := edgraph.UpdateAclUser(ctx, input.Name, input.Password, input.AddGroups,
+		input.RemoveGroups)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return userResult(ctx, m, input.Name)
+}
+
+func userResult(ctx context.Context, m *schema.Field, name string) (*resolve.Resolved, bool) {
+	user, err := getUserData(ctx, name)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return resolve.DataResult(m,
+		map[string]interface{}{m.Name(): map[string]interface{}{"user": user}},
+		nil,
+	), true
+}
+
+func resolveDeleteUser(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	name, _ := m.ArgValue("name").(string)
+	if err := edgraph.DeleteAclUser(ctx, name); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return resolve.DataResult(m,
+		map[string]interface{}{m.Name(): response("Success", "Deleted user "+name)},
+		nil,
+	), true
+}
+
+func resolveAddGroup(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	var input groupInput
+	if err := getAclInput(m, &input); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	if err := edgraph.AddAclGroup(ctx, input.Name, aclRules(input.Rules)); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return groupResult(ctx, m, input.Name)
+}
+
+func resolveUpdateGroup(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	var input groupInput
+	if err := getAclInput(m, &input); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	err := edgraph.UpdateAclGroup(ctx, input.Name, aclRules(input.SetRules),
+		input.RemoveRules)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return groupResult(ctx, m, input.Name)
+}
+
+func groupResult(ctx context.Context, m *schema.Field, name string) (*resolve.Resolved, bool) {
+	group, err := getGroupData(ctx, name)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return resolve.DataResult(m,
+		map[string]interface{}{m.Name(): map[string]interface{}{"group": group}},
+		nil,
+	), true
+}
+
+func resolveDeleteGroup(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	name, _ := m.ArgValue("name").(string)
+	if err := edgraph.DeleteAclGroup(ctx, name); err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	return resolve.DataResult(m,
+		map[string]interface{}{m.Name(): response("Success", "Deleted group "+name)},
+		nil,
+	), true
+}
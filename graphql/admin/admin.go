@@ -81,6 +81,7 @@ var (
 		"health":          minimalAdminQryMWs, // dgraph checks Guardian auth for health
 		"state":           minimalAdminQryMWs, // dgraph checks Guardian auth for state
 		"config":          gogQryMWs,
+		"tasks":           gogQryMWs,
 		"getGQLSchema":    stdAdminQryMWs,
 		"getLambdaScript": stdAdminQryMWs,
 		// for queries and mutations related to User/Group, dgraph handles Guardian auth,
@@ -92,20 +93,24 @@ var (
 		"getGroup":       minimalAdminQryMWs,
 	}
 	adminMutationMWConfig = map[string]resolve.MutationMiddlewares{
-		"config":             gogMutMWs,
-		"draining":           gogMutMWs,
-		"export":             stdAdminMutMWs, // dgraph handles the export by GoG internally
-		"login":              minimalAdminMutMWs,
-		"shutdown":           gogMutMWs,
-		"removeNode":         gogMutMWs,
-		"moveTablet":         gogMutMWs,
-		"assign":             gogMutMWs,
-		"enterpriseLicense":  gogMutMWs,
-		"updateGQLSchema":    stdAdminMutMWs,
-		"updateLambdaScript": stdAdminMutMWs,
-		"addNamespace":       gogAclMutMWs,
-		"deleteNamespace":    gogAclMutMWs,
-		"resetPassword":      gogAclMutMWs,
+		"config":              gogMutMWs,
+		"draining":            gogMutMWs,
+		"export":              stdAdminMutMWs, // dgraph handles the export by GoG internally
+		"backup":              gogMutMWs,
+		"restore":             gogMutMWs,
+		"cancelTask":          gogMutMWs,
+		"login":               minimalAdminMutMWs,
+		"shutdown":            gogMutMWs,
+		"removeNode":          gogMutMWs,
+		"moveTablet":          gogMutMWs,
+		"assign":              gogMutMWs,
+		"enterpriseLicense":   gogMutMWs,
+		"updateGQLSchema":     stdAdminMutMWs,
+		"updateLambdaScript":  stdAdminMutMWs,
+		"addPersistedQueries": stdAdminMutMWs,
+		"addNamespace":        gogAclMutMWs,
+		"deleteNamespace":     gogAclMutMWs,
+		"resetPassword":       gogAclMutMWs,
 		// for queries and mutations related to User/Group, dgraph handles Guardian auth,
 		// so no need to apply GuardianAuth Middleware
 		"addUser":     minimalAdminMutMWs,
@@ -115,6 +120,16 @@ var (
 		"deleteUser":  minimalAdminMutMWs,
 		"deleteGroup": minimalAdminMutMWs,
 	}
+	// mainQueryMWs and mainMutationMWs are the middlewares applied to all the queries and
+	// mutations served by the main /graphql endpoint
+	mainQueryMWs = resolve.QueryMiddlewares{
+		resolve.CostLimitMW4Query,
+		resolve.RateLimitMW4Query,
+	}
+	mainMutationMWs = resolve.MutationMiddlewares{
+		resolve.CostLimitMW4Mutation,
+		resolve.RateLimitMW4Mutation,
+	}
 	// mainHealthStore stores the health of the main GraphQL server.
 	mainHealthStore = &GraphQLHealthStore{}
 	// adminServerVar stores a pointer to the adminServer. It is used for lazy loading schema.
@@ -190,6 +205,9 @@ func NewServers(withIntrospection bool, globalEpoch map[uint64]*uint64) (
 	resolvers := resolve.New(gqlSchema, resolverFactoryWithErrorMsg(errNoGraphQLSchema))
 	e := globalEpoch[x.GalaxyNamespace]
 	mainServer := NewServer()
+	mainServer.persistedOnly = x.Config.GraphQL.PersistedQueriesOnly
+	resolve.InitRateLimiter(x.ServerCloser)
+	resolve.InitResponseCache()
 	mainServer.Set(x.GalaxyNamespace, e, resolvers)
 
 	fns := &resolve.ResolverFns{
@@ -230,6 +248,8 @@ func newAdminResolver(
 		gqlServer:         defaultGqlServer,
 	}
 	adminServerVar = server // store the admin server in package variable
+	// Stop serving the GraphQL API of a deleted namespace on every Alpha.
+	worker.OnNamespaceDeleted(server.dropSchema)
 
 	// The subscribe for updates code is really ugly. Removing it for now.
 	server.initServer()
@@ -238,16 +258,33 @@ func newAdminResolver(
 }
 
 func newAdminResolverFactory() *resolve.ResolverFactory {
+	adminQueryResolvers := map[string]resolve.QueryResolverFunc{
+		"getCurrentUser": resolveGetCurrentUser,
+		"getGroup":       resolveGetGroup,
+		"getUser":        resolveGetUser,
+		"queryGroup":     resolveQueryGroup,
+		"queryUser":      resolveQueryUser,
+	}
 	adminMutationResolvers := map[string]resolve.MutationResolverFunc{
-		"addNamespace":       resolveAddNamespace,
-		"config":             resolveUpdateConfig,
-		"deleteNamespace":    resolveDeleteNamespace,
-		"draining":           resolveDraining,
-		"export":             resolveExport,
-		"login":              resolveLogin,
-		"resetPassword":      resolveResetPassword,
-		"shutdown":           resolveShutdown,
-		"updateLambdaScript": resolveUpdateLambda,
+		"addGroup":            resolveAddGroup,
+		"addNamespace":        resolveAddNamespace,
+		"addPersistedQueries": resolveAddPersistedQueries,
+		"addUser":             resolveAddUser,
+		"backup":              resolveBackup,
+		"cancelTask":          resolveCancelTask,
+		"config":              resolveUpdateConfig,
+		"deleteGroup":         resolveDeleteGroup,
+		"deleteNamespace":     resolveDeleteNamespace,
+		"deleteUser":          resolveDeleteUser,
+		"draining":            resolveDraining,
+		"export":              resolveExport,
+		"login":               resolveLogin,
+		"resetPassword":       resolveResetPassword,
+		"restore":             resolveRestore,
+		"shutdown":            resolveShutdown,
+		"updateGroup":         resolveUpdateGroup,
+		"updateLambdaScript":  resolveUpdateLambda,
+		"updateUser":          resolveUpdateUser,
 
 		"removeNode": resolveRemoveNode,
 		"moveTablet": resolveMoveTablet,
@@ -269,6 +306,9 @@ func newAdminResolverFactory() *resolve.ResolverFactory {
 		WithQueryResolver("task", func(q *schema.Field) resolve.QueryResolver {
 			return resolve.QueryResolverFunc(resolveTask)
 		}).
+		WithQueryResolver("tasks", func(q *schema.Field) resolve.QueryResolver {
+			return resolve.QueryResolverFunc(resolveTasks)
+		}).
 		WithQueryResolver("getLambdaScript", func(q *schema.Field) resolve.QueryResolver {
 			return resolve.QueryResolverFunc(resolveGetLambda)
 		}).
@@ -285,6 +325,13 @@ func newAdminResolverFactory() *resolve.ResolverFactory {
 						false
 				})
 		})
+	for gqlQuery, resolver := range adminQueryResolvers {
+		func(f resolve.QueryResolver) {
+			rf.WithQueryResolver(gqlQuery, func(q *schema.Field) resolve.QueryResolver {
+				return f
+			})
+		}(resolver)
+	}
 	for gqlMut, resolver := range adminMutationResolvers {
 		// gotta force go to evaluate the right function at each loop iteration
 		// otherwise you get variable capture issues
@@ -424,7 +471,8 @@ func (as *adminServer) resetSchema(ns uint64, gqlSchema *schema.Schema) {
 		gqlSchema, _ = schema.FromString("", ns)
 	} else {
 		resolverFactory = resolverFactoryWithErrorMsg(errResolverNotFound).
-			WithConventionResolvers(gqlSchema, as.fns)
+			WithConventionResolvers(gqlSchema, as.fns).
+			WithMiddlewares(mainQueryMWs, mainMutationMWs)
 		if as.withIntrospection {
 			resolverFactory.WithSchemaIntrospection()
 		}
@@ -468,6 +516,25 @@ func (as *adminServer) loadSchema(namespace uint64) error {
 	return nil
 }
 
+// dropSchema stops serving the GraphQL API of the deleted namespace, and terminates its
+// subscriptions.
+func (as *adminServer) dropSchema(ns uint64) {
+	as.mux.Lock()
+	defer as.mux.Unlock()
+	as.incrementSchemaUpdateCounter(ns)
+	as.gqlSchemas.Delete(ns)
+	as.gqlServer.Delete(ns)
+	worker.Lambda().Delete(ns)
+}
+
+// LazyLoadSchema loads the GraphQL schema of the namespace, unless it's already being served.
+func LazyLoadSchema(namespace uint64) error {
+	if _, ok := adminServerVar.gqlSchemas.GetCurrent(namespace); ok {
+		return nil
+	}
+	return LoadSchema(namespace)
+}
+
 func LoadSchema(namespace uint64) error {
 	if err := adminServerVar.loadSchema(namespace); err != nil {
 		return err
@@ -0,0 +1,62 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/outcaste-io/outserv/graphql/resolve"
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/worker"
+)
+
+type backupInput struct {
+	DestinationFields
+	ForceFull bool
+}
+
+func resolveBackup(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	glog.Info("Got backup request through GraphQL admin API")
+
+	input, err := getBackupInput(m)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	req := &worker.BackupRequest{
+		Destination:  input.Destination,
+		AccessKey:    input.AccessKey,
+		SecretKey:    input.SecretKey,
+		SessionToken: input.SessionToken,
+		Anonymous:    input.Anonymous,
+		ForceFull:    input.ForceFull,
+	}
+	taskId, err := worker.Tasks.Enqueue(req)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	msg := fmt.Sprintf("Backup queued with ID %#x", taskId)
+	data := response("Success", msg)
+	data["taskId"] = fmt.Sprintf("%#x", taskId)
+	return resolve.DataResult(
+		m,
+		map[string]interface{}{m.Name(): data},
+		nil,
+	), true
+}
+
+func getBackupInput(m *schema.Field) (*backupInput, error) {
+	inputArg := m.ArgValue(schema.InputArgName)
+	inputByts, err := json.Marshal(inputArg)
+	if err != nil {
+		return nil, schema.GQLWrapf(err, "couldn't get input argument")
+	}
+
+	var input backupInput
+	err = json.Unmarshal(inputByts, &input)
+	return &input, schema.GQLWrapf(err, "couldn't get input argument")
+}
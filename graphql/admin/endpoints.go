@@ -3,11 +3,235 @@
 
 package admin
 
-const adminTypes = ``
+const adminTypes = `
+	type LoginResponse {
 
-const adminMutations = ``
+		"""
+		JWT token that should be used in future requests after this login.
+		"""
+		accessJWT: String
+
+		"""
+		Refresh token that can be used to re-login after accessJWT expires.
+		"""
+		refreshJWT: String
+	}
+
+	type LoginPayload {
+		response: LoginResponse
+	}
+
+	type User {
+
+		"""
+		Username for the user.  Dgraph ensures that usernames are unique.
+		"""
+		name: String!
+
+		groups: [Group]
+	}
+
+	type Group {
+
+		"""
+		Name of the group.  Dgraph ensures uniqueness of group names.
+		"""
+		name: String!
+		users: [User]
+		rules: [Rule]
+	}
+
+	type Rule {
+
+		"""
+		Predicate, or GraphQL type, to which the rule applies. A rule on a type applies to all
+		the fields of the type, unless the field has a rule of its own for the same group.
+		"""
+		predicate: String!
+
+		"""
+		Permissions that apply for the rule.  Represented following the UNIX file permission
+		convention. That is, 4 (binary 100) represents READ, 2 (binary 010) represents WRITE,
+		and 1 (binary 001) represents MODIFY (the permission to change a predicate's schema).
+
+		The options are:
+		* 1 (binary 001) : MODIFY
+		* 2 (010) : WRITE
+		* 3 (011) : WRITE+MODIFY
+		* 4 (100) : READ
+		* 5 (101) : READ+MODIFY
+		* 6 (110) : READ+WRITE
+		* 7 (111) : READ+WRITE+MODIFY
+
+		Predicates without any rule for the groups of a user can't be accessed by that user,
+		same as with permission 0.
+		"""
+		permission: Int!
+	}
+
+	input AddUserInput {
+		name: String!
+		password: String!
+		groups: [String!]
+	}
+
+	input UpdateUserInput {
+		name: String!
+
+		"""
+		New password for the user. The password is left unchanged if it isn't given.
+		"""
+		password: String
+		addGroups: [String!]
+		removeGroups: [String!]
+	}
+
+	input RuleInput {
+		predicate: String!
+		permission: Int!
+	}
+
+	input AddGroupInput {
+		name: String!
+		rules: [RuleInput!]
+	}
+
+	input UpdateGroupInput {
+		name: String!
+
+		"""
+		Rules to add to the group. The permission of an existing rule for the same predicate
+		is replaced.
+		"""
+		setRules: [RuleInput!]
+
+		"""
+		Predicates whose rules should be removed from the group.
+		"""
+		removeRules: [String!]
+	}
+
+	type AddUserPayload {
+		user: User
+	}
+
+	type UpdateUserPayload {
+		user: User
+	}
 
-const adminQueries = ``
+	type DeleteUserPayload {
+		response: Response
+	}
+
+	type AddGroupPayload {
+		group: Group
+	}
+
+	type UpdateGroupPayload {
+		group: Group
+	}
+
+	type DeleteGroupPayload {
+		response: Response
+	}
+
+	input AddNamespaceInput {
+		"""
+		Password of the groot user of the new namespace. Defaults to "password".
+		"""
+		password: String
+	}
+
+	input DeleteNamespaceInput {
+		namespaceId: Int!
+	}
+
+	type NamespacePayload {
+		namespaceId: UInt64
+		message: String
+	}
+
+	input ResetPasswordInput {
+		userId: String!
+		password: String!
+		namespace: Int!
+	}
+
+	type ResetPasswordPayload {
+		userId: String
+		message: String
+		namespace: UInt64
+	}
+`
+
+const adminMutations = `
+
+		"""
+		Login to Dgraph.  Successful login results in a JWT that can be used in future requests.
+		If login is not successful an error is returned.
+		"""
+		login(userId: String, password: String, namespace: Int, refreshToken: String): LoginPayload
+
+		"""
+		Add a user.  Only guardians can add users.
+		"""
+		addUser(input: AddUserInput!): AddUserPayload
+
+		"""
+		Change the password and the groups of a user.  Only guardians can update users.
+		"""
+		updateUser(input: UpdateUserInput!): UpdateUserPayload
+
+		"""
+		Delete a user.  Only guardians can delete users.
+		"""
+		deleteUser(name: String!): DeleteUserPayload
+
+		"""
+		Add a group.  Only guardians can add groups.
+		"""
+		addGroup(input: AddGroupInput!): AddGroupPayload
+
+		"""
+		Set and remove the rules of a group.  Only guardians can update groups.
+		"""
+		updateGroup(input: UpdateGroupInput!): UpdateGroupPayload
+
+		"""
+		Delete a group along with its rules.  Only guardians can delete groups.
+		"""
+		deleteGroup(name: String!): DeleteGroupPayload
+
+		"""
+		Add a namespace, along with its guardians group and groot user.  Only guardians of the
+		galaxy can add namespaces.
+		"""
+		addNamespace(input: AddNamespaceInput): NamespacePayload
+
+		"""
+		Delete a namespace and all of its data.  Only guardians of the galaxy can delete namespaces.
+		"""
+		deleteNamespace(input: DeleteNamespaceInput!): NamespacePayload
+
+		"""
+		Reset the password of a user in any namespace.  Only guardians of the galaxy can reset
+		passwords.
+		"""
+		resetPassword(input: ResetPasswordInput!): ResetPasswordPayload
+`
+
+const adminQueries = `
+		getUser(name: String!): User
+		getGroup(name: String!): Group
+
+		"""
+		Get the currently logged in user.
+		"""
+		getCurrentUser: User
+
+		queryUser: [User]
+		queryGroup: [Group]
+`
 
 // GraphQL schema for /admin endpoint.
 const graphqlAdminSchema = `
@@ -207,6 +431,17 @@ const graphqlAdminSchema = `
 		script: String!
 	}
 
+	input AddPersistedQueriesInput {
+		queries: [String!]!
+	}
+
+	type AddPersistedQueriesPayload {
+		"""
+		The sha256 hashes of the queries, in the order they were given.
+		"""
+		sha256Hashes: [String]
+	}
+
 	input ExportInput {
 		"""
 		Data format for the export, e.g. "json" (default: "json")
@@ -245,6 +480,72 @@ const graphqlAdminSchema = `
 		anonymous: Boolean
 	}
 
+	input BackupInput {
+		"""
+		Destination for the backup: e.g. Minio or S3 bucket or /absolute/path
+		"""
+		destination: String!
+
+		"""
+		Access key credential for the destination.
+		"""
+		accessKey: String
+
+		"""
+		Secret key credential for the destination.
+		"""
+		secretKey: String
+
+		"""
+		AWS session token, if required.
+		"""
+		sessionToken: String
+
+		"""
+		Set to true to allow backing up to S3 or Minio bucket that requires no credentials.
+		"""
+		anonymous: Boolean
+
+		"""
+		Take a full backup, even if there are previous backups at the destination. By default,
+		only the changes since the last backup are written out.
+		"""
+		forceFull: Boolean
+	}
+
+	input RestoreInput {
+		"""
+		Location of the backups: e.g. Minio or S3 bucket or /absolute/path
+		"""
+		location: String!
+
+		"""
+		Number of the backup to restore up to, as recorded in the manifest of the location.
+		The latest backup is restored if it isn't given.
+		"""
+		backupNum: Int
+
+		"""
+		Access key credential for the location.
+		"""
+		accessKey: String
+
+		"""
+		Secret key credential for the location.
+		"""
+		secretKey: String
+
+		"""
+		AWS session token, if required.
+		"""
+		sessionToken: String
+
+		"""
+		Set to true to allow restoring from S3 or Minio bucket that requires no credentials.
+		"""
+		anonymous: Boolean
+	}
+
 	input TaskInput {
 		id: String!
 	}
@@ -259,6 +560,16 @@ const graphqlAdminSchema = `
 		taskId: String
 	}
 
+	type BackupPayload {
+		response: Response
+		taskId: String
+	}
+
+	type RestorePayload {
+		response: Response
+		taskId: String
+	}
+
 	type DrainingPayload {
 		response: Response
 	}
@@ -268,9 +579,34 @@ const graphqlAdminSchema = `
 	}
 
 	type TaskPayload {
+		id: String
 		kind: TaskKind
 		status: TaskStatus
 		lastUpdated: DateTime
+
+		"""
+		When the task started running.
+		"""
+		started: DateTime
+
+		"""
+		Percentage of the task that's done, if known.
+		"""
+		progress: Float
+
+		"""
+		When the task is estimated to be done, going by its progress so far.
+		"""
+		eta: DateTime
+
+		"""
+		Why the task failed.
+		"""
+		error: String
+	}
+
+	type CancelTaskPayload {
+		response: Response
 	}
 
 	enum TaskStatus {
@@ -278,11 +614,17 @@ const graphqlAdminSchema = `
 		Running
 		Failed
 		Success
+		Cancelled
 		Unknown
 	}
 
 	enum TaskKind {
 		Export
+		Backup
+		Restore
+		IndexRebuild
+		MovePredicate
+		Delete
 		Unknown
 	}
 
@@ -395,6 +737,11 @@ const graphqlAdminSchema = `
 		state: MembershipState
 		config: Config
 		task(input: TaskInput!): TaskPayload
+
+		"""
+		Tasks run by this Alpha in the last week, the most recently updated first.
+		"""
+		tasks: [TaskPayload]
 		` + adminQueries + `
 	}
 
@@ -411,6 +758,13 @@ const graphqlAdminSchema = `
 		"""
 		updateLambdaScript(input: UpdateLambdaScriptInput!) : UpdateLambdaScriptPayload
 
+		"""
+		Persist the given queries, so that clients of /graphql can send just their sha256Hash
+		via extensions.persistedQuery. If Alpha runs with --graphql persisted-queries-only,
+		these are the only queries that get executed.
+		"""
+		addPersistedQueries(input: AddPersistedQueriesInput!) : AddPersistedQueriesPayload
+
 		"""
 		Starts an export of all data in the cluster.  Export format should be 'rdf' (the default
 		if no format is given), or 'json'.
@@ -418,6 +772,23 @@ const graphqlAdminSchema = `
 		"""
 		export(input: ExportInput!): ExportPayload
 
+		"""
+		Starts a backup of all data in the cluster. The backup is incremental if there already
+		are backups at the destination, unless forceFull is set.
+		"""
+		backup(input: BackupInput!): BackupPayload
+
+		"""
+		Drops all data in the cluster, and restores it from the backups at the given location.
+		"""
+		restore(input: RestoreInput!): RestorePayload
+
+		"""
+		Cancel a queued or running task. Index rebuilds can't be cancelled, and deletes can't be
+		cancelled once they're running.
+		"""
+		cancelTask(input: TaskInput!): CancelTaskPayload
+
 		"""
 		Set (or unset) the cluster draining mode.  In draining mode no further requests are served.
 		"""
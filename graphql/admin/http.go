@@ -40,6 +40,10 @@ type GqlHandler struct {
 	poller      map[uint64]*subscription.Poller
 	resolverMux sync.RWMutex // protects resolver from RW races
 	pollerMux   sync.RWMutex // protects poller from RW races
+
+	// persistedOnly is set if only the persisted queries added via addPersistedQueries are
+	// served. It's only ever set for the main /graphql endpoint, never for /admin.
+	persistedOnly bool
 }
 
 // NewServer returns a new IServeGraphQL that can serve the given resolvers
@@ -59,7 +63,18 @@ func (gh *GqlHandler) Set(ns uint64, schemaEpoch *uint64, resolver *resolve.Requ
 	gh.resolverMux.Unlock()
 
 	gh.pollerMux.Lock()
-	gh.poller[ns] = subscription.NewPoller(schemaEpoch, resolver)
+	gh.poller[ns] = subscription.NewPoller(ns, schemaEpoch, resolver)
+	gh.pollerMux.Unlock()
+}
+
+// Delete stops serving the namespace ns.
+func (gh *GqlHandler) Delete(ns uint64) {
+	gh.resolverMux.Lock()
+	delete(gh.resolver, ns)
+	gh.resolverMux.Unlock()
+
+	gh.pollerMux.Lock()
+	delete(gh.poller, ns)
 	gh.pollerMux.Unlock()
 }
 
@@ -173,8 +188,15 @@ func (gs *graphqlSubscription) Subscribe(
 		Variables:     variableValues,
 		Header:        reqHeader,
 	}
+	if gs.graphqlHandler.persistedOnly {
+		hr := &http.Request{Header: reqHeader}
+		pctx := x.AttachJWTNamespace(x.AttachAccessJwt(ctx, hr))
+		if err = edgraph.ProcessPersistedQuery(pctx, req, true); err != nil {
+			return nil, err
+		}
+	}
 
-	audit.AuditWebSockets(ctx, req)
+	audit.AuditWebSockets(ctx, req, httpHeaders)
 	namespace := x.ExtractNamespaceHTTP(&http.Request{Header: reqHeader})
 	glog.Infof("namespace: %d. Got GraphQL request over websocket.", namespace)
 	// first load the schema, then do anything else
@@ -190,6 +212,17 @@ func (gs *graphqlSubscription) Subscribe(
 	poller := gs.graphqlHandler.poller[namespace]
 	gs.graphqlHandler.pollerMux.RUnlock()
 
+	// The subscriptions don't go through the rate limiting middlewares, so they're charged
+	// here, to the client identified by its address or the headers of the request.
+	gs.graphqlHandler.resolverMux.RLock()
+	resolver := gs.graphqlHandler.resolver[namespace]
+	gs.graphqlHandler.resolverMux.RUnlock()
+	hr := &http.Request{RemoteAddr: httpHeaders.Get(audit.RemoteAddrHeader)}
+	rctx := x.AttachNamespace(x.AttachRemoteIP(ctx, hr), namespace)
+	if err = resolver.RateLimitSubscription(rctx, req); err != nil {
+		return nil, err
+	}
+
 	res, err := poller.AddSubscriber(req)
 	if err != nil {
 		return nil, err
@@ -205,9 +238,15 @@ func (gs *graphqlSubscription) Subscribe(
 }
 
 func (gh *GqlHandler) Handler() http.Handler {
-	return graphqlws.NewHandlerFunc(&graphqlSubscription{
+	ws := graphqlws.NewHandlerFunc(&graphqlSubscription{
 		graphqlHandler: gh,
 	}, gh)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Subscribe only gets the headers of the request, so the address of the client is passed
+		// along as one.
+		r.Header.Set(audit.RemoteAddrHeader, r.RemoteAddr)
+		ws.ServeHTTP(w, r)
+	})
 }
 
 // ServeHTTP handles GraphQL queries and mutations that get resolved
@@ -248,7 +287,7 @@ func (gh *GqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = edgraph.ProcessPersistedQuery(ctx, gqlReq); err != nil {
+	if err = edgraph.ProcessPersistedQuery(ctx, gqlReq, gh.persistedOnly); err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
@@ -0,0 +1,53 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/golang/glog"
+	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/graphql/resolve"
+	"github.com/outcaste-io/outserv/graphql/schema"
+)
+
+type addPersistedQueriesInput struct {
+	Queries []string
+}
+
+func resolveAddPersistedQueries(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	input, err := getAddPersistedQueriesInput(m)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	glog.Infof("Got addPersistedQueries request with %d queries", len(input.Queries))
+
+	hashes, err := edgraph.AddPersistedQueries(ctx, input.Queries)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+	out := make([]interface{}, 0, len(hashes))
+	for _, hash := range hashes {
+		out = append(out, hash)
+	}
+	return resolve.DataResult(
+		m,
+		map[string]interface{}{m.Name(): map[string]interface{}{
+			"sha256Hashes": out,
+		}},
+		nil,
+	), true
+}
+
+func getAddPersistedQueriesInput(m *schema.Field) (*addPersistedQueriesInput, error) {
+	inputArg := m.ArgValue(schema.InputArgName)
+	inputByts, err := json.Marshal(inputArg)
+	if err != nil {
+		return nil, schema.GQLWrapf(err, "couldn't get input argument")
+	}
+
+	var input addPersistedQueriesInput
+	err = json.Unmarshal(inputByts, &input)
+	return &input, schema.GQLWrapf(err, "couldn't get input argument")
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/stretchr/testify/require"
+)
+
+func addPersistedQueriesField(t *testing.T, query string) *schema.Field {
+	adminSchema, err := schema.FromString(graphqlAdminSchema, x.GalaxyNamespace)
+	require.NoError(t, err)
+	op, err := adminSchema.Operation(&schema.Request{Query: query})
+	require.NoError(t, err)
+	require.Len(t, op.Mutations(), 1)
+	return op.Mutations()[0]
+}
+
+func TestAddPersistedQueriesInput(t *testing.T) {
+	m := addPersistedQueriesField(t, `mutation {
+		addPersistedQueries(input: {queries: ["query { a }", "query { b }"]}) {
+			sha256Hashes
+		}
+	}`)
+	input, err := getAddPersistedQueriesInput(m)
+	require.NoError(t, err)
+	require.Equal(t, []string{"query { a }", "query { b }"}, input.Queries)
+}
+
+func TestAddPersistedQueriesEmpty(t *testing.T) {
+	m := addPersistedQueriesField(t, `mutation {
+		addPersistedQueries(input: {queries: []}) { sha256Hashes }
+	}`)
+	resolved, ok := resolveAddPersistedQueries(context.Background(), m)
+	require.True(t, ok)
+	require.NoError(t, resolved.Err)
+	require.Contains(t, string(resolved.Data), `"sha256Hashes":[]`)
+
+	// Nothing gets persisted if any of the queries is empty.
+	m = addPersistedQueriesField(t, `mutation {
+		addPersistedQueries(input: {queries: [" "]}) { sha256Hashes }
+	}`)
+	resolved, ok = resolveAddPersistedQueries(context.Background(), m)
+	require.False(t, ok)
+	require.Contains(t, resolved.Err.Error(), "can't persist an empty query")
+}
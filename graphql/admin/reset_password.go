@@ -18,6 +18,7 @@ func resolveResetPassword(ctx context.Context, m *schema.Field) (*resolve.Resolv
 	inp, err := getPasswordInput(m)
 	if err != nil {
 		glog.Error("Failed to parse the reset password input")
+		return resolve.EmptyResult(m, err), false
 	}
 	if err = edgraph.ResetPassword(ctx, inp); err != nil {
 		return resolve.EmptyResult(m, err), false
@@ -0,0 +1,68 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/outcaste-io/outserv/graphql/resolve"
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/worker"
+)
+
+type restoreInput struct {
+	Location     string
+	BackupNum    int
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Anonymous    bool
+}
+
+func resolveRestore(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	glog.Info("Got restore request through GraphQL admin API")
+
+	input, err := getRestoreInput(m)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	req := &worker.RestoreRequest{
+		Location:     input.Location,
+		BackupNum:    input.BackupNum,
+		AccessKey:    input.AccessKey,
+		SecretKey:    input.SecretKey,
+		SessionToken: input.SessionToken,
+		Anonymous:    input.Anonymous,
+	}
+	taskId, err := worker.Tasks.Enqueue(req)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	// The GraphQL schema gets reloaded from the restored data on the next admin request, or
+	// /probe/graphql call.
+	msg := fmt.Sprintf("Restore queued with ID %#x", taskId)
+	data := response("Success", msg)
+	data["taskId"] = fmt.Sprintf("%#x", taskId)
+	return resolve.DataResult(
+		m,
+		map[string]interface{}{m.Name(): data},
+		nil,
+	), true
+}
+
+func getRestoreInput(m *schema.Field) (*restoreInput, error) {
+	inputArg := m.ArgValue(schema.InputArgName)
+	inputByts, err := json.Marshal(inputArg)
+	if err != nil {
+		return nil, schema.GQLWrapf(err, "couldn't get input argument")
+	}
+
+	var input restoreInput
+	err = json.Unmarshal(inputByts, &input)
+	return &input, schema.GQLWrapf(err, "couldn't get input argument")
+}
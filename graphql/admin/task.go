@@ -22,38 +22,96 @@ type taskInput struct {
 }
 
 func resolveTask(ctx context.Context, q *schema.Field) *resolve.Resolved {
-	// Get Task ID.
-	input, err := getTaskInput(q)
+	taskId, err := getTaskId(q)
 	if err != nil {
 		return resolve.EmptyResult(q, err)
 	}
-	if input.Id == "" {
-		return resolve.EmptyResult(q, fmt.Errorf("task ID is missing"))
-	}
-	taskId, err := strconv.ParseUint(input.Id, 0, 64)
-	if err != nil {
-		err = errors.Wrapf(err, "invalid task ID: %s", input.Id)
-		return resolve.EmptyResult(q, err)
-	}
 
-	// Get TaskMeta from network.
+	// Get the task from network.
 	req := &pb.TaskStatusRequest{TaskId: taskId}
-	resp, err := worker.TaskStatusOverNetwork(context.Background(), req)
+	task, err := worker.TaskStatusOverNetwork(context.Background(), req)
 	if err != nil {
 		return resolve.EmptyResult(q, err)
 	}
-	meta := worker.TaskMeta(resp.GetTaskMeta())
 	return resolve.DataResult(
 		q,
-		map[string]interface{}{q.Name(): map[string]interface{}{
-			"kind":        meta.Kind().String(),
-			"status":      meta.Status().String(),
-			"lastUpdated": meta.Timestamp().Format(time.RFC3339),
-		}},
+		map[string]interface{}{q.Name(): taskPayload(task)},
+		nil,
+	)
+}
+
+func resolveTasks(ctx context.Context, q *schema.Field) *resolve.Resolved {
+	var tasks []interface{}
+	for _, task := range worker.Tasks.List() {
+		tasks = append(tasks, taskPayload(task))
+	}
+	return resolve.DataResult(
+		q,
+		map[string]interface{}{q.Name(): tasks},
 		nil,
 	)
 }
 
+func resolveCancelTask(ctx context.Context, m *schema.Field) (*resolve.Resolved, bool) {
+	taskId, err := getTaskId(m)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	req := &pb.TaskStatusRequest{TaskId: taskId, Cancel: true}
+	task, err := worker.TaskStatusOverNetwork(ctx, req)
+	if err != nil {
+		return resolve.EmptyResult(m, err), false
+	}
+
+	msg := fmt.Sprintf("Cancelled %s task with ID %#x", task.Meta.Kind(), taskId)
+	if task.Meta.Status() == worker.TaskStatusRunning {
+		msg = fmt.Sprintf("Cancelling %s task with ID %#x", task.Meta.Kind(), taskId)
+	}
+	return resolve.DataResult(
+		m,
+		map[string]interface{}{m.Name(): response("Success", msg)},
+		nil,
+	), true
+}
+
+func taskPayload(task worker.Task) map[string]interface{} {
+	payload := map[string]interface{}{
+		"id":          fmt.Sprintf("%#x", task.Id),
+		"kind":        task.Meta.Kind().String(),
+		"status":      task.Meta.Status().String(),
+		"lastUpdated": task.Meta.Timestamp().Format(time.RFC3339),
+	}
+	if !task.Started.IsZero() {
+		payload["started"] = task.Started.Format(time.RFC3339)
+	}
+	if progress, ok := task.Progress(); ok {
+		payload["progress"] = progress
+	}
+	if eta, ok := task.Eta(); ok {
+		payload["eta"] = eta.Format(time.RFC3339)
+	}
+	if task.Error != "" {
+		payload["error"] = task.Error
+	}
+	return payload
+}
+
+func getTaskId(f *schema.Field) (uint64, error) {
+	input, err := getTaskInput(f)
+	if err != nil {
+		return 0, err
+	}
+	if input.Id == "" {
+		return 0, fmt.Errorf("task ID is missing")
+	}
+	taskId, err := strconv.ParseUint(input.Id, 0, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid task ID: %s", input.Id)
+	}
+	return taskId, nil
+}
+
 func getTaskInput(q *schema.Field) (*taskInput, error) {
 	inputArg := q.ArgValue(schema.InputArgName)
 	inputBytes, err := json.Marshal(inputArg)
@@ -73,6 +73,9 @@ func writeQuery(b *strings.Builder, query *gql.GraphQuery, prefix string) {
 
 	if query.IsCount {
 		x.Check2(b.WriteString(fmt.Sprintf("count(%s)", query.Attr)))
+	} else if query.Func != nil && query.Func.IsSearch() {
+		// bm25(Post.title, "text") - the predicate is the first of the args.
+		writeFilterFunction(b, query.Func)
 	} else if query.Attr != "val" {
 		x.Check2(b.WriteString(query.Attr))
 	} else if isAggregateFn(query.Func) {
@@ -89,7 +92,7 @@ func writeQuery(b *strings.Builder, query *gql.GraphQuery, prefix string) {
 		glog.Warningf("MathExp is not being handled")
 	}
 
-	if query.Func != nil {
+	if query.Func != nil && !query.Func.IsSearch() {
 		writeRoot(b, query)
 		x.Check2(b.WriteRune(')'))
 	}
@@ -297,7 +300,8 @@ func writeFilter(b *strings.Builder, ft *gql.FilterTree) {
 func hasOrderOrPage(q *gql.GraphQuery) bool {
 	_, hasFirst := q.Args["first"]
 	_, hasOffset := q.Args["offset"]
-	return len(q.Order) > 0 || hasFirst || hasOffset
+	_, hasAfter := q.Args["after"]
+	return len(q.Order) > 0 || hasFirst || hasOffset || hasAfter
 }
 
 func IsValueVar(attr string, q *gql.GraphQuery) bool {
@@ -310,7 +314,7 @@ func IsValueVar(attr string, q *gql.GraphQuery) bool {
 }
 
 func writeOrderAndPage(b *strings.Builder, query *gql.GraphQuery, root bool) {
-	var wroteOrder, wroteFirst bool
+	var wroteOrder, wroteFirst, wroteOffset bool
 
 	for _, ord := range query.Order {
 		if root || wroteOrder {
@@ -346,5 +350,14 @@ func writeOrderAndPage(b *strings.Builder, query *gql.GraphQuery, root bool) {
 		}
 		x.Check2(b.WriteString("offset: "))
 		x.Check2(b.WriteString(offset))
+		wroteOffset = true
+	}
+
+	if after, ok := query.Args["after"]; ok {
+		if root || wroteOrder || wroteFirst || wroteOffset {
+			x.Check2(b.WriteString(", "))
+		}
+		x.Check2(b.WriteString("after: "))
+		x.Check2(b.WriteString(after))
 	}
 }
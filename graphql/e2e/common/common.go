@@ -840,6 +840,7 @@ func RunAll(t *testing.T) {
 	t.Run("filter in queries with array for AND/OR", filterInQueriesWithArrayForAndOr)
 	t.Run("query geo near filter", queryGeoNearFilter)
 	t.Run("persisted query", persistedQuery)
+	t.Run("add persisted queries", addPersistedQueries)
 	t.Run("query aggregate without filter", queryAggregateWithoutFilter)
 	t.Run("query aggregate with filter", queryAggregateWithFilter)
 	t.Run("query aggregate on empty data", queryAggregateOnEmptyData)
@@ -18,6 +18,8 @@ package common
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -3124,6 +3126,47 @@ func persistedQuery(t *testing.T) {
 	RequireNoGQLErrors(t, gqlResponse)
 }
 
+func addPersistedQueries(t *testing.T) {
+	query := `query ($countryName: String){
+			queryCountry(filter: {name: {eq: $countryName}}) {
+				name
+			}
+		}`
+	addParams := &GraphQLParams{
+		Query: `mutation ($queries: [String!]!) {
+			addPersistedQueries(input: {queries: $queries}) {
+				sha256Hashes
+			}
+		}`,
+		Variables: map[string]interface{}{"queries": []string{query}},
+	}
+	gqlResponse := addParams.ExecuteAsPost(t, GraphqlAdminURL)
+	RequireNoGQLErrors(t, gqlResponse)
+
+	var resp struct {
+		AddPersistedQueries struct {
+			Sha256Hashes []string
+		}
+	}
+	require.NoError(t, json.Unmarshal(gqlResponse.Data, &resp))
+	hash := sha256.Sum256([]byte(query))
+	sha256Hash := hex.EncodeToString(hash[:])
+	require.Equal(t, []string{sha256Hash}, resp.AddPersistedQueries.Sha256Hashes)
+
+	// Adding the same query again is a no-op.
+	gqlResponse = addParams.ExecuteAsPost(t, GraphqlAdminURL)
+	RequireNoGQLErrors(t, gqlResponse)
+
+	queryCountryParams := &GraphQLParams{
+		Variables: map[string]interface{}{"countryName": "Bangladesh"},
+		Extensions: &schema.RequestExtensions{PersistedQuery: schema.PersistedQuery{
+			Sha256Hash: sha256Hash,
+		}},
+	}
+	gqlResponse = queryCountryParams.ExecuteAsPost(t, GraphqlURL)
+	RequireNoGQLErrors(t, gqlResponse)
+}
+
 func queryAggregateWithFilter(t *testing.T) {
 	queryPostParams := &GraphQLParams{
 		Query: `query {
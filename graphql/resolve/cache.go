@@ -0,0 +1,205 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/ristretto"
+)
+
+// responseCache caches the responses of the queries with @cacheControl, if --graphql cache-mb
+// is set.
+var responseCache *ristretto.Cache
+
+// resolverIDs hands out the ids of the RequestResolvers.
+var resolverIDs uint64
+
+// InitResponseCache sets up the cache for the responses of the queries with @cacheControl, as
+// configured via --graphql cache-mb.
+func InitResponseCache() {
+	size := x.Config.GraphQL.CacheMb << 20
+	if size <= 0 {
+		return
+	}
+	var err error
+	responseCache, err = ristretto.NewCache(&ristretto.Config{
+		// Assuming responses of about 1KB, keep 10 counters per response.
+		NumCounters: size / 100,
+		MaxCost:     size,
+		BufferItems: 64,
+	})
+	x.Check(err)
+}
+
+// cacheEntry is a response in the responseCache.
+type cacheEntry struct {
+	key  string
+	data []byte
+	// preds are the namespaced predicates read by the query, and version their CommitVersion
+	// before it got resolved. The entry is stale once any of them gets changed.
+	preds   []string
+	version uint64
+	expiry  time.Time
+}
+
+// fromCache returns the data of the cached response to the query operation op, if there's one
+// still valid. Otherwise, it returns the entry to cache the response in via toCache, once it's
+// resolved. The entry is nil if the response can't be cached.
+func (r *RequestResolver) fromCache(ctx context.Context, gqlReq *schema.Request,
+	op *schema.Operation) ([]byte, *cacheEntry) {
+
+	maxAge := op.CacheMaxAge()
+	if responseCache == nil || maxAge == 0 {
+		return nil, nil
+	}
+	key, err := r.cacheKey(ctx, gqlReq)
+	if err != nil {
+		return nil, nil
+	}
+	if val, ok := responseCache.Get(key); ok {
+		entry := val.(*cacheEntry)
+		// Once a predicate has moved to another group, its commits aren't seen anymore.
+		if time.Now().Before(entry.expiry) && worker.ServesAllPredicates(entry.preds) &&
+			worker.CommitVersion(entry.preds) == entry.version {
+			return entry.data, nil
+		}
+		responseCache.Del(key)
+	}
+
+	preds, ok := operationPredicates(ctx, op)
+	if !ok {
+		return nil, nil
+	}
+	ns, _ := x.ExtractNamespace(ctx)
+	for i, pred := range preds {
+		preds[i] = x.NamespaceAttr(ns, pred)
+	}
+	// The commits done by other groups aren't seen, so they couldn't invalidate the response.
+	if !worker.ServesAllPredicates(preds) {
+		return nil, nil
+	}
+	return nil, &cacheEntry{
+		key:   key,
+		preds: preds,
+		// Read the version before resolving the query, so that the commits done meanwhile
+		// invalidate the response.
+		version: worker.CommitVersion(preds),
+		expiry:  time.Now().Add(maxAge),
+	}
+}
+
+// toCache caches the response resp in the entry returned by fromCache, unless it has errors.
+func (r *RequestResolver) toCache(entry *cacheEntry, resp *schema.Response) {
+	if entry == nil || len(resp.Errors) > 0 || resp.Data.Len() == 0 {
+		return
+	}
+	entry.data = append([]byte{}, resp.Data.Bytes()...)
+	responseCache.Set(entry.key, entry, int64(len(entry.data)+len(entry.key)))
+}
+
+// cacheKey returns the key of the response to the request gqlReq in the responseCache. Besides
+// the resolver, and so the schema, the response depends upon the query, its variables and the
+// claims of the JWT it came with. With ACL, the users could also be allowed to read different
+// predicates.
+func (r *RequestResolver) cacheKey(ctx context.Context, gqlReq *schema.Request) (string, error) {
+	claims, err := r.schema.Meta().AuthMeta().ExtractCustomClaims(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Maps get marshalled with their keys sorted.
+	authVars, err := json.Marshal(claims.AuthVariables)
+	if err != nil {
+		return "", err
+	}
+	vars, err := json.Marshal(gqlReq.Variables)
+	if err != nil {
+		return "", err
+	}
+	var accessJwt string
+	if x.WorkerConfig.AclEnabled {
+		accessJwt, _ = x.ExtractJwt(ctx)
+	}
+	return strings.Join([]string{
+		strconv.FormatUint(r.id, 10),
+		gqlReq.OperationName,
+		normalizeQuery(gqlReq.Query),
+		string(vars),
+		string(authVars),
+		accessJwt,
+	}, "\x00"), nil
+}
+
+// normalizeQuery strips the comments, commas and whitespace from the GraphQL query q, except the
+// whitespace needed to separate names and numbers. Strings are kept as they are. So, queries
+// which only differ in how they are formatted share their cached responses.
+func normalizeQuery(q string) string {
+	var sb strings.Builder
+	var last byte
+	space := false
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch c {
+		case '#':
+			for i < len(q) && q[i] != '\n' && q[i] != '\r' {
+				i++
+			}
+			space = true
+			continue
+		case ' ', '\t', '\n', '\r', ',':
+			space = true
+			continue
+		}
+		if space && isNameByte(last) && isNameByte(c) {
+			sb.WriteByte(' ')
+		}
+		space = false
+		if c == '"' {
+			end := stringEnd(q, i)
+			sb.WriteString(q[i:end])
+			i = end - 1
+			last = '"'
+			continue
+		}
+		sb.WriteByte(c)
+		last = c
+	}
+	return sb.String()
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// stringEnd returns the index right after the end of the string starting at q[start]. It's
+// either a block string, which can only escape its closing quotes, or a string with escapes.
+func stringEnd(q string, start int) int {
+	if strings.HasPrefix(q[start:], `"""`) {
+		for i := start + 3; i < len(q); i++ {
+			if strings.HasPrefix(q[i:], `\"""`) {
+				i += 3
+				continue
+			}
+			if strings.HasPrefix(q[i:], `"""`) {
+				return i + 3
+			}
+		}
+		return len(q)
+	}
+	for i := start + 1; i < len(q); i++ {
+		switch q[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(q)
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tcases := []struct {
+		query      string
+		normalized string
+	}{
+		{
+			query: `query {
+				queryAuthor(first: 10, offset: 5) {
+					name
+				}
+			}`,
+			normalized: `query{queryAuthor(first:10 offset:5){name}}`,
+		},
+		{
+			query: `query  Q($name: String)  # the authors
+				{ queryAuthor(filter: {name: {eq: $name}}) { name, dob } }`,
+			normalized: `query Q($name:String){queryAuthor(filter:{name:{eq:$name}}){name dob}}`,
+		},
+		{
+			query:      `{ queryPost(filter: {title: {anyofterms: "a,  b # c"}}) { title } }`,
+			normalized: `{queryPost(filter:{title:{anyofterms:"a,  b # c"}}){title}}`,
+		},
+		{
+			query:      `{ q(s: "say \"hi,  there\"", b: """ x,  \""" y """) { f } }`,
+			normalized: `{q(s:"say \"hi,  there\""b:""" x,  \""" y """){f}}`,
+		},
+		{
+			query:      `{ ... on Author { name } }`,
+			normalized: `{...on Author{name}}`,
+		},
+	}
+	for _, tcase := range tcases {
+		require.Equal(t, tcase.normalized, normalizeQuery(tcase.query))
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/x"
+)
+
+// defaultListSize is the number of results assumed for a list field without a first argument.
+const defaultListSize = 100
+
+// operationCost returns the estimated cost of all the queries or mutations of op. The cost is
+// computed statically, before any of them gets rewritten or executed.
+func operationCost(op *schema.Operation) int64 {
+	var cost int64
+	for _, q := range op.Queries() {
+		cost = addCost(cost, fieldCost(q))
+	}
+	for _, m := range op.Mutations() {
+		cost = addCost(cost, mutationCost(m))
+	}
+	return cost
+}
+
+// fieldCost returns the estimated cost of resolving the field f. Every field costs 1, or
+// --graphql custom-cost if it's resolved via @custom or @lambda. The cost of the fields selected
+// under a list field is multiplied by its first argument, or by defaultListSize. So, the cost of
+// nested lists grows exponentially with their depth. Introspection fields don't cost anything.
+func fieldCost(f *schema.Field) int64 {
+	return costWithListSize(f, listSize(f))
+}
+
+// mutationCost returns the estimated cost of the mutation m. Each of the nodes given as input
+// costs 1, and the lists in the payload are assumed to have that many nodes.
+func mutationCost(m *schema.Field) int64 {
+	if m.IsCustomHTTP() || m.HasLambdaDirective() {
+		return fieldCost(m)
+	}
+	input, isList := m.ArgValue(schema.InputArgName).([]interface{})
+	n := int64(1)
+	if isList && len(input) > 0 {
+		n = int64(len(input))
+	}
+
+	cost := n
+	for _, f := range m.SelectionSet() {
+		size := listSize(f)
+		if isList && f.Type().ListType() != nil {
+			size = n
+		}
+		cost = addCost(cost, costWithListSize(f, size))
+	}
+	return cost
+}
+
+func costWithListSize(f *schema.Field, size int64) int64 {
+	if strings.HasPrefix(f.Name(), "__") {
+		return 0
+	}
+	cost := int64(1)
+	if f.IsCustomHTTP() || f.HasLambdaDirective() {
+		cost = x.Config.GraphQL.CustomCost
+	}
+	var children int64
+	for _, child := range f.SelectionSet() {
+		children = addCost(children, fieldCost(child))
+	}
+	return addCost(cost, mulCost(children, size))
+}
+
+// listSize returns the number of results assumed for the field f.
+func listSize(f *schema.Field) int64 {
+	if f.Type().ListType() == nil {
+		return 1
+	}
+	if f.IsSimilarQuery() {
+		return int64(f.SimilarTopK())
+	}
+	if first := f.ArgValue("first"); first != nil {
+		if n, err := strconv.ParseInt(fmt.Sprintf("%v", first), 10, 64); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultListSize
+}
+
+// addCost returns a + b, or math.MaxInt64 if that overflows.
+func addCost(a, b int64) int64 {
+	sum, carry := bits.Add64(uint64(a), uint64(b), 0)
+	if carry != 0 || sum > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(sum)
+}
+
+// mulCost returns a * b, or math.MaxInt64 if that overflows.
+func mulCost(a, b int64) int64 {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	if hi != 0 || lo > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(lo)
+}
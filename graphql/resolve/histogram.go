@@ -0,0 +1,301 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outcaste-io/outserv/codec"
+	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/graphql/dgraph"
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/posting"
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/tok"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/sroar"
+	"github.com/pkg/errors"
+	otrace "go.opencensus.io/trace"
+)
+
+// NewHistogramQueryResolver creates a resolver for the generated histogram<Type> queries. The
+// buckets are read directly from the index of the field the histogram is asked for, so the
+// values of that field are never loaded. As the index read skips the ACLs applied to DQL
+// queries, the user must be allowed to read all the predicates used. The nodes matching the
+// query are only fetched, to restrict the buckets to them, if there's a filter or the type has
+// @auth rules.
+func NewHistogramQueryResolver(qr *QueryRewriter, ex DgraphExecutor) QueryResolver {
+	return &histogramResolver{queryRewriter: qr, executor: ex}
+}
+
+type histogramResolver struct {
+	queryRewriter *QueryRewriter
+	executor      DgraphExecutor
+}
+
+// histogramBucket holds the nodes whose value falls in the bucket starting at start.
+type histogramBucket struct {
+	start string
+	uids  *sroar.Bitmap
+}
+
+func (hr *histogramResolver) Resolve(ctx context.Context, query *schema.Field) *Resolved {
+	span := otrace.FromContext(ctx)
+	stop := x.SpanTimer(span, "resolveHistogramQuery")
+	defer stop()
+
+	buckets, err := hr.histogram(ctx, query)
+	if err != nil {
+		return EmptyResult(query, err)
+	}
+	return DataResult(query, map[string]interface{}{query.Name(): buckets}, nil)
+}
+
+func (hr *histogramResolver) histogram(ctx context.Context,
+	query *schema.Field) ([]interface{}, error) {
+
+	typ := query.ConstructedFor()
+	fld := typ.Field(query.HistogramField())
+	pred := typ.DgraphPredicate(fld.Name())
+	interval := query.HistogramInterval()
+	bucketStart, err := histogramBucketStart(fld.Type().Name(), interval)
+	if err != nil {
+		return nil, err
+	}
+
+	preds := []string{pred}
+	for _, f := range query.SelectionSet() {
+		if strings.HasSuffix(f.Name(), "Sum") {
+			preds = append(preds, typ.DgraphPredicate(strings.TrimSuffix(f.Name(), "Sum")))
+		}
+	}
+	if err := edgraph.AuthorizeRead(ctx, preds...); err != nil {
+		return nil, err
+	}
+
+	var uidList *pb.List
+	// The index of a predicate shared with other types, like one of an interface, has the
+	// nodes of those types too. So, only keep the nodes of this type.
+	_, hasFilter := query.ArgValue("filter").(map[string]interface{})
+	if hasFilter || typ.HasAuthRules() || !strings.HasPrefix(pred, typ.DgraphName()+".") {
+		uids, err := hr.matchingUids(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if uids.GetCardinality() == 0 {
+			return []interface{}{}, nil
+		}
+		uidList = codec.ToList(uids)
+	}
+
+	ns, _ := x.ExtractNamespace(ctx)
+	readTs := posting.ReadTimestamp()
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  readTs,
+		Attr:    x.NamespaceAttr(ns, pred),
+		SrcFunc: &pb.SrcFunction{Name: "histogram", Args: []string{interval}},
+		UidList: uidList,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "while calling ProcessTaskOverNetwork")
+	}
+	if len(result.ValueMatrix) != len(result.UidMatrix) {
+		return nil, errors.Errorf("got %d tokens for %d buckets of %s",
+			len(result.ValueMatrix), len(result.UidMatrix), pred)
+	}
+
+	// The tokens come sorted, and so do the buckets they fall in. Tokens of a finer
+	// interval than the one asked for, like days for months, are merged into one bucket.
+	var buckets []*histogramBucket
+	for i, row := range result.UidMatrix {
+		uids := codec.FromList(row)
+		if uids.GetCardinality() == 0 || len(result.ValueMatrix[i].Values) == 0 {
+			continue
+		}
+		start, err := bucketStart(string(result.ValueMatrix[i].Values[0].Val))
+		if err != nil {
+			return nil, err
+		}
+		if n := len(buckets); n > 0 && buckets[n-1].start == start {
+			buckets[n-1].uids.Or(uids)
+			continue
+		}
+		buckets = append(buckets, &histogramBucket{start: start, uids: uids})
+	}
+
+	out := make([]interface{}, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, map[string]interface{}{
+			"bucket": b.start,
+			"count":  json.Number(strconv.FormatUint(b.uids.GetCardinality(), 10)),
+		})
+	}
+
+	for _, f := range query.SelectionSet() {
+		if !strings.HasSuffix(f.Name(), "Sum") {
+			continue
+		}
+		sumFld := typ.Field(strings.TrimSuffix(f.Name(), "Sum"))
+		sums, err := histogramSums(ctx, readTs, x.NamespaceAttr(ns, typ.DgraphPredicate(
+			sumFld.Name())), sumFld.Type().Name(), buckets)
+		if err != nil {
+			return nil, err
+		}
+		for i, sum := range sums {
+			out[i].(map[string]interface{})[f.Name()] = sum
+		}
+	}
+	return out, nil
+}
+
+// histogramBucketStart returns a function giving the start of the bucket that an index token
+// of a field of the given type falls in. For DateTime fields, the interval is one of hour, day,
+// month or year, and the start is in RFC3339. For Int and Int64 fields, the interval is the
+// width of the buckets.
+func histogramBucketStart(typ, interval string) (func(token string) (string, error), error) {
+	if typ == "DateTime" {
+		switch interval {
+		case "hour", "day", "month", "year":
+		default:
+			return nil, errors.Errorf("interval for a DateTime field should be one of "+
+				"hour, day, month or year, got: %q", interval)
+		}
+		return func(token string) (string, error) {
+			t, err := tok.DecodeTimeToken(token)
+			if err != nil {
+				return "", err
+			}
+			switch interval {
+			case "year":
+				t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+			case "month":
+				t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+			case "day":
+				t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			}
+			return t.Format(time.RFC3339), nil
+		}, nil
+	}
+
+	width, err := strconv.ParseInt(interval, 10, 64)
+	if err != nil || width <= 0 {
+		return nil, errors.Errorf("interval for an %s field should be a positive integer, "+
+			"got: %q", typ, interval)
+	}
+	return func(token string) (string, error) {
+		val, err := tok.DecodeIntToken(token)
+		if err != nil {
+			return "", err
+		}
+		// Round down towards negative infinity, so -1 falls in [-width, 0).
+		start := val / width
+		if val%width != 0 && val < 0 {
+			start--
+		}
+		return strconv.FormatInt(start*width, 10), nil
+	}, nil
+}
+
+// matchingUids returns the uids of the nodes of the type of the histogram query, which match
+// its filter.
+func (hr *histogramResolver) matchingUids(ctx context.Context,
+	query *schema.Field) (*sroar.Bitmap, error) {
+
+	dgQuery, err := hr.queryRewriter.Rewrite(ctx, query)
+	if err != nil {
+		return nil, schema.GQLWrapf(err, "couldn't rewrite query %s", query.ResponseName())
+	}
+	resp, err := hr.executor.Execute(ctx, &edgraph.Request{
+		Req: &pb.Request{Query: dgraph.AsString(dgQuery), ReadOnly: true},
+	})
+	if err != nil {
+		return nil, schema.GQLWrapf(err, "Dgraph query failed")
+	}
+
+	var res map[string][]struct {
+		Uid string `json:"uid"`
+	}
+	if err := json.Unmarshal(resp.GetJson(), &res); err != nil {
+		return nil, errors.Wrapf(err, "while unmarshalling the matching nodes")
+	}
+	bm := sroar.NewBitmap()
+	for _, node := range res[query.DgraphAlias()] {
+		uid, err := strconv.ParseUint(node.Uid, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		bm.Set(uid)
+	}
+	return bm, nil
+}
+
+// histogramSums returns the sum of the values of the predicate pred, of GraphQL type typ, over
+// the nodes in each of the buckets. Only the values of pred are read, for the nodes in the
+// buckets.
+func histogramSums(ctx context.Context, readTs uint64, pred, typ string,
+	buckets []*histogramBucket) ([]interface{}, error) {
+
+	sums := make([]interface{}, len(buckets))
+	if len(buckets) == 0 {
+		return sums, nil
+	}
+	all := sroar.NewBitmap()
+	for _, b := range buckets {
+		all.Or(b.uids)
+	}
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  readTs,
+		Attr:    pred,
+		UidList: codec.ToList(all),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "while calling ProcessTaskOverNetwork")
+	}
+
+	tid := types.TypeInt64
+	if typ == "Float" {
+		tid = types.TypeFloat
+	}
+	// The rows of the ValueMatrix are in the order of the uids.
+	uids := all.ToArray()
+	vals := make(map[uint64][]types.Val, len(uids))
+	for i, row := range result.ValueMatrix {
+		if i >= len(uids) {
+			break
+		}
+		for _, tv := range row.Values {
+			val, err := types.Convert(tv.Val, tid)
+			if err != nil {
+				return nil, err
+			}
+			vals[uids[i]] = append(vals[uids[i]], val)
+		}
+	}
+
+	for i, b := range buckets {
+		var sumInt int64
+		var sumFloat float64
+		for _, uid := range b.uids.ToArray() {
+			for _, val := range vals[uid] {
+				switch v := val.Value.(type) {
+				case int64:
+					sumInt += v
+				case float64:
+					sumFloat += v
+				}
+			}
+		}
+		if tid == types.TypeFloat {
+			sums[i] = json.Number(strconv.FormatFloat(sumFloat, 'f', -1, 64))
+		} else {
+			sums[i] = json.Number(strconv.FormatInt(sumInt, 10))
+		}
+	}
+	return sums, nil
+}
@@ -5,14 +5,33 @@ package resolve
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/outcaste-io/outserv/edgraph"
 	"github.com/outcaste-io/outserv/graphql/schema"
 	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/ristretto/z"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/peer"
 )
 
+// rateLimiter limits the cost that each client can spend, if --graphql rate-limit is set.
+var rateLimiter *x.RateLimiter
+
+// InitRateLimiter starts limiting the cost that each client can spend per --graphql
+// rate-interval, if --graphql rate-limit is set. It's applied via RateLimitMW4Query and
+// RateLimitMW4Mutation.
+func InitRateLimiter(closer *z.Closer) {
+	if x.Config.GraphQL.RateLimit <= 0 {
+		return
+	}
+	rateLimiter = x.NewRateLimiter(x.Config.GraphQL.RateLimit, x.Config.GraphQL.RateInterval,
+		closer)
+}
+
 // QueryMiddleware represents a middleware for queries
 type QueryMiddleware func(resolver QueryResolver) QueryResolver
 
@@ -208,3 +227,141 @@ func AclOnlyMW4Mutation(resolver MutationResolver) MutationResolver {
 		return resolver.Resolve(ctx, mutation)
 	})
 }
+
+// resolveCostLimit returns a Resolved with error if the estimated cost of the operation of f is
+// over --graphql max-cost, otherwise it returns nil
+func resolveCostLimit(f *schema.Field) *Resolved {
+	maxCost := x.Config.GraphQL.MaxCost
+	if maxCost <= 0 {
+		return nil
+	}
+	if cost := operationCost(f.Operation()); cost > maxCost {
+		return EmptyResult(f, errors.Errorf("The estimated cost of the operation is %d, which "+
+			"is over the limit of %d. Ask for fewer results via first, or for fewer fields.",
+			cost, maxCost))
+	}
+	return nil
+}
+
+// resolveRateLimit returns a Resolved with error if the client doesn't have enough left of its
+// --graphql rate-limit to pay for the estimated cost of f, otherwise it returns nil
+func resolveRateLimit(ctx context.Context, f *schema.Field) *Resolved {
+	if rateLimiter == nil {
+		return nil
+	}
+	key := rateLimitKey(ctx, f)
+	if key == "" {
+		// Requests made internally, like the ones of subscriptions, aren't limited.
+		return nil
+	}
+	cost := fieldCost(f)
+	if f.Kind == schema.MutationKind {
+		cost = mutationCost(f)
+	}
+	if !rateLimiter.Allow(key, cost) {
+		return EmptyResult(f, errors.Errorf("Rate limit exceeded for %s. Try again in %s.",
+			f.ResponseName(), x.Config.GraphQL.RateInterval))
+	}
+	return nil
+}
+
+// RateLimitSubscription returns an error if the client doesn't have enough left of its --graphql
+// rate-limit to pay for the estimated cost of the subscription req. Subscriptions are re-run
+// without the client, so they're only charged once, when the client adds them.
+func (r *RequestResolver) RateLimitSubscription(ctx context.Context, req *schema.Request) error {
+	if rateLimiter == nil {
+		return nil
+	}
+	op, err := r.schema.Operation(req)
+	if err != nil {
+		return err
+	}
+	ctx, err = r.schema.Meta().AuthMeta().AttachAuthorizationJwt(ctx, req.Header)
+	if err != nil {
+		return err
+	}
+	for _, q := range op.Queries() {
+		if resolved := resolveRateLimit(ctx, q); resolved != nil {
+			return resolved.Err
+		}
+	}
+	return nil
+}
+
+// rateLimitKey returns the key identifying the client making the request, as set via --graphql
+// rate-key. The header is only used if --graphql rate-trust-header is set, as clients can set it
+// to anything. It falls back to the IP of the client if the header or the JWT claim isn't used,
+// and returns an empty key if the IP isn't known either.
+func rateLimitKey(ctx context.Context, f *schema.Field) string {
+	ns, _ := x.ExtractNamespace(ctx)
+	rateKey := x.Config.GraphQL.RateKey
+	switch {
+	case strings.HasPrefix(rateKey, "header:") && x.Config.GraphQL.RateTrustHeader:
+		name := strings.TrimPrefix(rateKey, "header:")
+		if val := f.Operation().Header().Get(name); val != "" {
+			return fmt.Sprintf("%#x-header-%s", ns, val)
+		}
+	case strings.HasPrefix(rateKey, "claim:"):
+		name := strings.TrimPrefix(rateKey, "claim:")
+		claims, err := f.GetAuthMeta().ExtractCustomClaims(ctx)
+		if err == nil && claims.AuthVariables[name] != nil {
+			return fmt.Sprintf("%#x-claim-%v", ns, claims.AuthVariables[name])
+		}
+	}
+
+	peerInfo, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip, _, err := net.SplitHostPort(peerInfo.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%#x-ip-%s", ns, ip)
+}
+
+// CostLimitMW4Query blocks the resolution of resolverFunc if the estimated cost of the operation
+// is over --graphql max-cost, otherwise it lets the resolverFunc resolve the query.
+func CostLimitMW4Query(resolver QueryResolver) QueryResolver {
+	return QueryResolverFunc(func(ctx context.Context, query *schema.Field) *Resolved {
+		if resolved := resolveCostLimit(query); resolved != nil {
+			return resolved
+		}
+		return resolver.Resolve(ctx, query)
+	})
+}
+
+// RateLimitMW4Query blocks the resolution of resolverFunc if the client has spent its --graphql
+// rate-limit, otherwise it lets the resolverFunc resolve the query.
+func RateLimitMW4Query(resolver QueryResolver) QueryResolver {
+	return QueryResolverFunc(func(ctx context.Context, query *schema.Field) *Resolved {
+		if resolved := resolveRateLimit(ctx, query); resolved != nil {
+			return resolved
+		}
+		return resolver.Resolve(ctx, query)
+	})
+}
+
+// CostLimitMW4Mutation blocks the resolution of resolverFunc if the estimated cost of the
+// operation is over --graphql max-cost, otherwise it lets the resolverFunc resolve the mutation.
+func CostLimitMW4Mutation(resolver MutationResolver) MutationResolver {
+	return MutationResolverFunc(func(ctx context.Context, mutation *schema.Field) (*Resolved,
+		bool) {
+		if resolved := resolveCostLimit(mutation); resolved != nil {
+			return resolved, false
+		}
+		return resolver.Resolve(ctx, mutation)
+	})
+}
+
+// RateLimitMW4Mutation blocks the resolution of resolverFunc if the client has spent its
+// --graphql rate-limit, otherwise it lets the resolverFunc resolve the mutation.
+func RateLimitMW4Mutation(resolver MutationResolver) MutationResolver {
+	return MutationResolverFunc(func(ctx context.Context, mutation *schema.Field) (*Resolved,
+		bool) {
+		if resolved := resolveRateLimit(ctx, mutation); resolved != nil {
+			return resolved, false
+		}
+		return resolver.Resolve(ctx, mutation)
+	})
+}
@@ -18,10 +18,15 @@ package resolve
 
 import (
 	"context"
+	"net"
+	"net/http"
 	"testing"
 
 	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/graphql/test"
+	"github.com/outcaste-io/outserv/x"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
 )
 
 func TestQueryMiddlewares_Then_ExecutesMiddlewaresInOrder(t *testing.T) {
@@ -95,3 +100,95 @@ func TestMutationMiddlewares_Then_ExecutesMiddlewaresInOrder(t *testing.T) {
 	require.Equal(t, &Resolved{Extensions: &schema.Extensions{TouchedUids: 1}}, resolved)
 	require.Equal(t, []int{1, 2, 3, 4, 5}, array)
 }
+
+func TestOperationCost(t *testing.T) {
+	x.Config.GraphQL.CustomCost = 10
+	gqlSchema := test.LoadSchemaFromFile(t, "schema.graphql")
+
+	tcases := []struct {
+		name  string
+		query string
+		cost  int64
+	}{
+		{
+			name:  "single node",
+			query: `query { getAuthor(id: "0x1") { name } }`,
+			cost:  2,
+		},
+		{
+			name:  "nested list without first",
+			query: `query { queryAuthor(first: 10) { name posts { title } } }`,
+			cost:  1 + 10*(1+(1+100*1)),
+		},
+		{
+			name:  "nested list with first",
+			query: `query { queryAuthor(first: 10) { name posts(first: 2) { title } } }`,
+			cost:  1 + 10*(1+(1+2*1)),
+		},
+		{
+			name:  "multiple queries",
+			query: `query { getAuthor(id: "0x1") { name } queryPost(first: 5) { title } }`,
+			cost:  2 + (1 + 5*1),
+		},
+		{
+			name:  "custom field",
+			query: `query { myFavoriteMovies(id: "0x1", name: "x") { id name } }`,
+			cost:  10 + 100*2,
+		},
+		{
+			name:  "introspection",
+			query: `query { __schema { types { name } } }`,
+			cost:  0,
+		},
+		{
+			name: "mutation with a list of inputs",
+			query: `mutation {
+				addPost(input: [{title: "a", author: {id: "0x1"}},
+						{title: "b", author: {id: "0x1"}}]) {
+					post { title }
+				}
+			}`,
+			cost: 2 + (1 + 2*1),
+		},
+	}
+	for _, tcase := range tcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			op, err := gqlSchema.Operation(&schema.Request{Query: tcase.query})
+			require.NoError(t, err)
+			require.Equal(t, tcase.cost, operationCost(op))
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	defer func(key string, trust bool) {
+		x.Config.GraphQL.RateKey, x.Config.GraphQL.RateTrustHeader = key, trust
+	}(x.Config.GraphQL.RateKey, x.Config.GraphQL.RateTrustHeader)
+	gqlSchema := test.LoadSchemaFromFile(t, "schema.graphql")
+
+	header := http.Header{}
+	header.Set("X-Api-Key", "client-1")
+	op, err := gqlSchema.Operation(&schema.Request{
+		Query:  `query { getAuthor(id: "0x1") { name } }`,
+		Header: header,
+	})
+	require.NoError(t, err)
+	f := op.Queries()[0]
+	ctx := x.AttachNamespace(context.Background(), x.GalaxyNamespace)
+	ctx = peer.NewContext(ctx, &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+	})
+
+	x.Config.GraphQL.RateKey = "ip"
+	require.Equal(t, "0x0-ip-10.0.0.1", rateLimitKey(ctx, f))
+
+	// The header can be set to anything by the clients, so it's only used if it's trusted.
+	x.Config.GraphQL.RateKey, x.Config.GraphQL.RateTrustHeader = "header:X-Api-Key", false
+	require.Equal(t, "0x0-ip-10.0.0.1", rateLimitKey(ctx, f))
+	x.Config.GraphQL.RateTrustHeader = true
+	require.Equal(t, "0x0-header-client-1", rateLimitKey(ctx, f))
+
+	x.Config.GraphQL.RateKey = "header:X-Other-Key"
+	require.Equal(t, "0x0-ip-10.0.0.1", rateLimitKey(ctx, f))
+	require.Equal(t, "", rateLimitKey(context.Background(), f))
+}
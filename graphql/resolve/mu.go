@@ -61,7 +61,9 @@ func extractVal(xidVal interface{}, xid *schema.FieldDefinition) (string, error)
 	}
 }
 
-func UidsFromManyXids(ctx context.Context, obj map[string]interface{},
+// uidsFromManyXids returns the UIDs of the nodes of type typ, which have all the @id values
+// given in obj. The values are looked up via the loader l.
+func uidsFromManyXids(ctx context.Context, l *objectLoader, obj map[string]interface{},
 	typ *schema.Type, useDgraphNames bool) ([]uint64, error) {
 
 	var bms []*sroar.Bitmap
@@ -80,17 +82,16 @@ func UidsFromManyXids(ctx context.Context, obj map[string]interface{},
 			return nil, errors.Wrapf(err, "while extractVal")
 		}
 
-		// TODO: Check if we can pass UIDs to this to filter quickly.
-		bm, err := UidsForXid(ctx, xid.DgraphAlias(), xidString)
+		uids, err := l.uidsForXid(ctx, xid.DgraphAlias(), xidString)
 		if err != nil {
 			// TODO(mrjn): Wrap up errors to ensure GraphQL compliance.
 			return nil, err
 		}
-		bms = append(bms, bm)
-		if bm.GetCardinality() == 0 {
+		if len(uids) == 0 {
 			// No need to proceed, if we couldn't find any.
 			return nil, nil
 		}
+		bms = append(bms, sroar.FromSortedList(uids))
 	}
 	bm := sroar.FastAnd(bms...)
 	return bm.ToArray(), nil
@@ -98,10 +99,23 @@ func UidsFromManyXids(ctx context.Context, obj map[string]interface{},
 
 type Object map[string]interface{}
 
+// storedValue returns val, the value given for the field f, the way it's stored. The value of an
+// @embedding field is stored as one vector, like "[0.1,0.2]", rather than as a list of floats.
+func storedValue(f *schema.FieldDefinition, val interface{}) (interface{}, error) {
+	if !f.IsEmbedding() || val == nil {
+		return val, nil
+	}
+	vec, err := schema.EmbeddingVector(val)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading the value of %s", f.Name())
+	}
+	return types.FormatVFloat(vec), nil
+}
+
 var objCounter uint64
 var upsertFlag int = 0x1
 
-func gatherObjects(ctx context.Context, src Object, typ *schema.Type,
+func gatherObjects(ctx context.Context, l *objectLoader, src Object, typ *schema.Type,
 	flags int) ([]Object, error) {
 
 	var idVal uint64
@@ -115,12 +129,9 @@ func gatherObjects(ctx context.Context, src Object, typ *schema.Type,
 		}
 	}
 
-	// TODO(mrjn): Optimization for later. We should query all of them in a
-	// single call to make this more efficient. Or, run gatherObjects via
-	// goroutines.
-	uids, err := UidsFromManyXids(ctx, src, typ, false)
+	uids, err := uidsFromManyXids(ctx, l, src, typ, false)
 	if err != nil {
-		return nil, errors.Wrapf(err, "UidsFromManyXids")
+		return nil, errors.Wrapf(err, "uidsFromManyXids")
 	}
 
 	dst := make(map[string]interface{})
@@ -162,7 +173,9 @@ func gatherObjects(ctx context.Context, src Object, typ *schema.Type,
 			continue
 		}
 		if f.Type().IsInbuiltOrEnumType() {
-			dst[f.DgraphAlias()] = val
+			if dst[f.DgraphAlias()], err = storedValue(f, val); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -170,7 +183,7 @@ func gatherObjects(ctx context.Context, src Object, typ *schema.Type,
 		if vlist, ok := val.([]interface{}); ok {
 			for _, elem := range vlist {
 				e := elem.(map[string]interface{})
-				objs, err := gatherObjects(ctx, e, f.Type(), flags)
+				objs, err := gatherObjects(ctx, l, e, f.Type(), flags)
 				if err != nil {
 					return nil, errors.Wrapf(err, "while nesting into %s", f.Name())
 				}
@@ -178,7 +191,7 @@ func gatherObjects(ctx context.Context, src Object, typ *schema.Type,
 			}
 
 		} else if vmap, ok := val.(map[string]interface{}); ok {
-			objs, err := gatherObjects(ctx, vmap, f.Type(), flags)
+			objs, err := gatherObjects(ctx, l, vmap, f.Type(), flags)
 			if err != nil {
 				return nil, errors.Wrapf(err, "while nesting into %s", f.Name())
 			}
@@ -237,10 +250,12 @@ func handleAdd(ctx context.Context, m *schema.Field) ([]uint64, error) {
 
 	start := time.Now()
 	typ := m.MutatedType()
+	l := newObjectLoader()
+	l.loadXids(ctx, typ, val)
 	var res []Object
 	for _, i := range val {
 		obj := i.(map[string]interface{})
-		objs, err := gatherObjects(ctx, obj, typ, flags)
+		objs, err := gatherObjects(ctx, l, obj, typ, flags)
 		if err != nil {
 			return nil, errors.Wrapf(err, "while gathering objects")
 		}
@@ -273,7 +288,7 @@ func handleAdd(ctx context.Context, m *schema.Field) ([]uint64, error) {
 	}
 
 	start = time.Now()
-	nquads, err := handleInverses(ctx, typ, res)
+	nquads, err := handleInverses(ctx, l, typ, res)
 	if err != nil {
 		return nil, errors.Wrapf(err, "handleAdd.handleInverses")
 	}
@@ -370,47 +385,15 @@ func getUidsFromFilter(ctx0 context.Context, m *schema.Field) ([]uint64, error)
 	return uids, nil
 }
 
-func getChildrenUids(ctx context.Context, uid, pred string) ([]string, error) {
-	// We need to get the UID for the object. So, the field in
-	// getObject is really a query.
-	field := fmt.Sprintf("%s {uid}", pred)
-	obj, err := getObject(ctx, uid, field)
-	if err != nil {
-		return nil, fmt.Errorf("While getting %s: %+v", pred, err)
-	}
-	childObj := obj[pred]
-	if childObj == nil {
-		return nil, nil
-	}
-
-	// Delete in the reverse direction.
-	var children []string
-	if co, has := childObj.(map[string]interface{}); has {
-		childUid, ok := co["uid"].(string)
-		if !ok {
-			glog.Errorf("uid is not string. getObject with uid: %s field: %s childObj: %+v"+
-				" co[uid]: %+v", uid, field, childObj, co["uid"])
-			panic("getChildrenUids: Invalid childUid")
-		}
-		children = append(children, childUid)
-	} else if clist, has := childObj.([]interface{}); has {
-		for _, co := range clist {
-			cm := co.(map[string]interface{})
-			childUid := cm["uid"].(string)
-			children = append(children, childUid)
-		}
-	} else {
-		return nil, fmt.Errorf("getChildrenUids is unable to parse: %+v", childObj)
-	}
-	return children, nil
-}
-
 func handleDelete(ctx context.Context, m *schema.Field) ([]uint64, error) {
 	uids, err := getUidsFromFilter(ctx, m)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getUidsFromFilter")
 	}
 
+	l := newObjectLoader()
+	l.loadInverseEdges(ctx, m.MutatedType(), uids)
+
 	mu := &pb.Mutation{}
 	accountForInverse := func(uidHex string, f *schema.FieldDefinition) {
 		inv := f.Inverse()
@@ -420,7 +403,7 @@ func handleDelete(ctx context.Context, m *schema.Field) ([]uint64, error) {
 
 		// Find all the children and send deletion markers, so they no longer
 		// point to the parent.
-		cuids, err := getChildrenUids(ctx, uidHex, f.DgraphAlias())
+		cuids, err := l.children(ctx, uidHex, f.DgraphAlias())
 		if err != nil {
 			glog.Errorf("While getting %s.%s: %+v", f.Type().Name(), f.Name(), err)
 			return
@@ -474,6 +457,175 @@ func handleDelete(ctx context.Context, m *schema.Field) ([]uint64, error) {
 	return uids, nil
 }
 
+// getUidsFromXidArgs returns the UIDs of the nodes matching any of the @id values passed
+// as arguments to the mutation. They're looked up through l, which checks the ACLs.
+func getUidsFromXidArgs(ctx context.Context, l *objectLoader, m *schema.Field,
+) ([]uint64, error) {
+	res := sroar.NewBitmap()
+	for _, xid := range m.MutatedType().XIDFields() {
+		vals, _ := m.ArgValue(xid.Name()).([]interface{})
+		for _, val := range vals {
+			xidString, err := extractVal(val, xid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while extractVal")
+			}
+			uids, err := l.uidsForXid(ctx, xid.DgraphAlias(), xidString)
+			if err != nil {
+				return nil, err
+			}
+			res.SetMany(uids)
+		}
+	}
+	return res.ToArray(), nil
+}
+
+// handleRollback deletes the nodes with the given @id values, along with the nodes they
+// own. A node owns its children via a list field with @hasInverse, if the inverse field
+// on the child is not a list. So, the child can only belong to this node, like the
+// transactions of a block. The owned children are deleted recursively. The edges
+// pointing back to the deleted nodes from the nodes they don't own are deleted too, so
+// nothing refers to the deleted nodes. All of it happens in a single transaction.
+func handleRollback(ctx context.Context, m *schema.Field) ([]uint64, error) {
+	l := newObjectLoader()
+	uids, err := getUidsFromXidArgs(ctx, l, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getUidsFromXidArgs")
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	l.loadInverseEdges(ctx, m.MutatedType(), uids)
+	mu, err := rollbackMutation(ctx, l, m.MutatedType(), uids)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.Request{}
+	req.Mutations = append(req.Mutations, mu)
+	ereq := &edgraph.Request{Req: req, GqlField: m}
+
+	resp, err := edgraph.QueryGraphQL(ctx, ereq)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while executing rollback")
+	}
+	glog.V(2).Infof("Rolled back %d nodes with %d edges. Got response: %s\n",
+		len(uids), len(mu.Edges), resp.Json)
+	return uids, nil
+}
+
+// nodeReader reads the nodes a rollback walks through. It's implemented by objectLoader.
+type nodeReader interface {
+	children(ctx context.Context, uidStr, pred string) ([]string, error)
+	loadTypes(ctx context.Context, uids []uint64)
+	typeNames(ctx context.Context, uidStr string) ([]string, error)
+}
+
+// rollbackMutation builds the mutation for handleRollback, which deletes the nodes with the given
+// uids, of type typ, and the nodes they own. A node is deleted as its concrete type, so that the
+// fields which are only in the type implementing an interface get deleted too.
+func rollbackMutation(ctx context.Context, r nodeReader, typ *schema.Type,
+	uids []uint64) (*pb.Mutation, error) {
+
+	mu := &pb.Mutation{}
+	deleted := make(map[string]struct{})
+	var deleteNode func(uidHex string, typ *schema.Type) error
+	deleteNode = func(uidHex string, typ *schema.Type) error {
+		if _, has := deleted[uidHex]; has {
+			return nil
+		}
+		deleted[uidHex] = struct{}{}
+
+		typ, err := concreteType(ctx, r, uidHex, typ)
+		if err != nil {
+			return err
+		}
+		for _, f := range typ.Fields() {
+			if strings.HasSuffix(f.DgraphAlias(), "Aggregate") {
+				continue
+			}
+			if f.IsID() {
+				continue
+			}
+			if inv := f.Inverse(); inv != nil {
+				cuids, err := r.children(ctx, uidHex, f.DgraphAlias())
+				if err != nil {
+					return errors.Wrapf(err, "while getting %s.%s", typ.Name(), f.Name())
+				}
+				owned := f.Type().ListType() != nil && inv.Type().ListType() == nil
+				if owned && f.Type().IsInterface() {
+					children := make([]uint64, 0, len(cuids))
+					for _, childUid := range cuids {
+						children = append(children, x.FromHex(childUid))
+					}
+					r.loadTypes(ctx, children)
+				}
+				for _, childUid := range cuids {
+					if owned {
+						if err := deleteNode(childUid, f.Type()); err != nil {
+							return err
+						}
+						continue
+					}
+					mu.Edges = append(mu.Edges, &pb.Edge{
+						Subject:   childUid,
+						Predicate: inv.DgraphAlias(),
+						ObjectId:  uidHex,
+						Op:        pb.Edge_DEL,
+					})
+				}
+			}
+			mu.Edges = append(mu.Edges, &pb.Edge{
+				Subject:     uidHex,
+				Predicate:   f.DgraphAlias(),
+				ObjectValue: types.StringToBinary(x.Star),
+				Op:          pb.Edge_DEL,
+			})
+		}
+		for _, name := range append([]string{typ.DgraphName()}, typ.Interfaces()...) {
+			mu.Edges = append(mu.Edges, &pb.Edge{
+				Subject:     uidHex,
+				Predicate:   "dgraph.type",
+				ObjectValue: types.StringToBinary(name),
+				Op:          pb.Edge_DEL,
+			})
+		}
+		return nil
+	}
+
+	if typ.IsInterface() {
+		r.loadTypes(ctx, uids)
+	}
+	for _, uid := range uids {
+		if err := deleteNode(x.ToHexString(uid), typ); err != nil {
+			return nil, err
+		}
+	}
+	return mu, nil
+}
+
+// concreteType returns the type of the node uidHex, which is a node of type typ. If typ is an
+// interface, that's the type implementing it, as per the dgraph.type of the node.
+func concreteType(ctx context.Context, r nodeReader, uidHex string,
+	typ *schema.Type) (*schema.Type, error) {
+
+	if !typ.IsInterface() {
+		return typ, nil
+	}
+	names, err := r.typeNames(ctx, uidHex)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while getting the type of %s", uidHex)
+	}
+	for _, impl := range typ.ImplementingTypes() {
+		for _, name := range names {
+			if impl.DgraphName() == name {
+				return impl, nil
+			}
+		}
+	}
+	return typ, nil
+}
+
 func getObject(ctx0 context.Context, uid string, fields ...string) (map[string]interface{}, error) {
 	ctx := otrace.NewContext(ctx0, nil)
 
@@ -499,7 +651,7 @@ func getObject(ctx0 context.Context, uid string, fields ...string) (map[string]i
 // checkIfDuplicateExists ensures that there's no other object like dst. That
 // dst's XIDs are unique when put together (invidually they can still have
 // multiple results).
-func checkIfDuplicateExists(ctx context.Context,
+func checkIfDuplicateExists(ctx context.Context, l *objectLoader,
 	typ *schema.Type, dst map[string]interface{}) error {
 
 	u, has := dst["uid"]
@@ -526,9 +678,9 @@ func checkIfDuplicateExists(ctx context.Context,
 	for key, val := range dst {
 		src[key] = val
 	}
-	uids, err := UidsFromManyXids(ctx, src, typ, true)
+	uids, err := uidsFromManyXids(ctx, l, src, typ, true)
 	if err != nil {
-		return errors.Wrapf(err, "UidsFromManyXids")
+		return errors.Wrapf(err, "uidsFromManyXids")
 	}
 	if len(uids) == 0 {
 		// No duplicates found.
@@ -548,7 +700,7 @@ func checkIfDuplicateExists(ctx context.Context,
 	return fmt.Errorf("Duplicate entries exist for these unique ids: %v", xids)
 }
 
-func deletePreviousEdge(ctx context.Context, uidStr string,
+func deletePreviousEdge(ctx context.Context, l *objectLoader, uidStr string,
 	f *schema.FieldDefinition) (*pb.Edge, error) {
 
 	if strings.HasPrefix(uidStr, "_:") {
@@ -560,7 +712,7 @@ func deletePreviousEdge(ctx context.Context, uidStr string,
 		// delete anything from before.
 		return nil, nil
 	}
-	cuids, err := getChildrenUids(ctx, uidStr, f.DgraphAlias())
+	cuids, err := l.children(ctx, uidStr, f.DgraphAlias())
 	if err != nil {
 		return nil, errors.Wrapf(err,
 			"while getting %s for %s", f.DgraphAlias(), uidStr)
@@ -586,8 +738,19 @@ func deletePreviousEdge(ctx context.Context, uidStr string,
 // forward edge from parent -> child already exist. It parses these edges and
 // creates reverse edges. If the parent can only have one child, it queries what
 // the previous child was, and creates delete reverse edges for the previous
-// child.
-func handleInverses(ctx context.Context, typ *schema.Type, objs []Object) ([]*pb.Edge, error) {
+// child. The previous children of all the objects are read upfront.
+func handleInverses(ctx context.Context, l *objectLoader, typ *schema.Type,
+	objs []Object) ([]*pb.Edge, error) {
+
+	edges := make(map[string][]uint64)
+	collectPreviousEdges(typ, objs, edges)
+	l.loadEdges(ctx, edges)
+	return inverseEdges(ctx, l, typ, objs)
+}
+
+func inverseEdges(ctx context.Context, l *objectLoader, typ *schema.Type,
+	objs []Object) ([]*pb.Edge, error) {
+
 	var nquads []*pb.Edge
 	for _, f := range typ.Fields() {
 		inv := f.Inverse()
@@ -609,7 +772,7 @@ func handleInverses(ctx context.Context, typ *schema.Type, objs []Object) ([]*pb
 				panic(fmt.Sprintf("Unhandled type of val: %+v type: %T", val, val))
 			}
 
-			childQuads, err := handleInverses(ctx, f.Type(), children)
+			childQuads, err := inverseEdges(ctx, l, f.Type(), children)
 			if err != nil {
 				return nil, errors.Wrapf(err, "handleInverses.recurse")
 			}
@@ -628,7 +791,7 @@ func handleInverses(ctx context.Context, typ *schema.Type, objs []Object) ([]*pb
 				}
 				// If the parent can only have one child, we need to delete the edge
 				// from that previous child -> parent.
-				prevChildNq, err := deletePreviousEdge(ctx, parentUid, f)
+				prevChildNq, err := deletePreviousEdge(ctx, l, parentUid, f)
 				if err != nil {
 					return nil, errors.Wrapf(err, "handleInverses.deletePreviousChild")
 				}
@@ -643,7 +806,7 @@ func handleInverses(ctx context.Context, typ *schema.Type, objs []Object) ([]*pb
 					nquads = append(nquads, prevChildNq)
 				}
 
-				prevParentNq, err := deletePreviousEdge(ctx, childUid, inv)
+				prevParentNq, err := deletePreviousEdge(ctx, l, childUid, inv)
 				if err != nil {
 					return nil, errors.Wrapf(err, "handleInverses.deletePreviousChild.parent")
 				}
@@ -671,6 +834,7 @@ func handleUpdate(ctx context.Context, m *schema.Field) ([]uint64, error) {
 		defs[f.DgraphAlias()] = f
 	}
 
+	l := newObjectLoader()
 	mu := &pb.Mutation{}
 	parseObjects := func(src map[string]interface{}, forAdd bool) error {
 		var dstObjs []Object
@@ -685,10 +849,15 @@ func handleUpdate(ctx context.Context, m *schema.Field) ([]uint64, error) {
 				continue
 			}
 			if f.Type().IsInbuiltOrEnumType() {
-				templateObj[f.DgraphAlias()] = val
+				stored, err := storedValue(f, val)
+				if err != nil {
+					return err
+				}
+				templateObj[f.DgraphAlias()] = stored
 				continue
 			}
-			objs, err := gatherObjects(ctx, val.(map[string]interface{}), f.Type(), upsertFlag)
+			l.loadXids(ctx, f.Type(), []interface{}{val})
+			objs, err := gatherObjects(ctx, l, val.(map[string]interface{}), f.Type(), upsertFlag)
 			if err != nil {
 				return errors.Wrapf(err, "while gathering object for %q", f.Name())
 			}
@@ -721,14 +890,14 @@ func handleUpdate(ctx context.Context, m *schema.Field) ([]uint64, error) {
 			if forAdd {
 				// We need to ensure that we're not modifying an object which
 				// would violate the XID uniqueness constraints.
-				if err := checkIfDuplicateExists(ctx, typ, dst); err != nil {
+				if err := checkIfDuplicateExists(ctx, l, typ, dst); err != nil {
 					return err
 				}
 			}
 			dstObjs = append(dstObjs, dst)
 		}
 
-		nquads, err := handleInverses(ctx, typ, dstObjs)
+		nquads, err := handleInverses(ctx, l, typ, dstObjs)
 		if err != nil {
 			return errors.Wrapf(err, "handleUpdate.handleInverses")
 		}
@@ -780,6 +949,8 @@ func rewriteQueries(ctx context.Context, m *schema.Field) ([]uint64, error) {
 		return handleAdd(ctx, m)
 	case schema.DeleteMutation:
 		return handleDelete(ctx, m)
+	case schema.RollbackMutation:
+		return handleRollback(ctx, m)
 	case schema.UpdateMutation:
 		return handleUpdate(ctx, m)
 	default:
@@ -787,11 +958,15 @@ func rewriteQueries(ctx context.Context, m *schema.Field) ([]uint64, error) {
 	}
 }
 
+// UidsForXid returns the uids of the nodes whose @id field pred has the given value. The eq
+// function it runs picks the hash_ci or exact_ci index of pred if it has one, so then the values
+// which only differ in their case, like checksummed and lowercase addresses, are the same id.
+// The lookup is done within the namespace of ctx.
 func UidsForXid(ctx context.Context, pred, value string) (*sroar.Bitmap, error) {
+	ns, _ := x.ExtractNamespace(ctx)
 	q := &pb.Query{
 		ReadTs: posting.ReadTimestamp(),
-		// TODO(mrjn): Namespace 0 is hardcoded here. We should allow for other namespaces later.
-		Attr: x.NamespaceAttr(0, pred),
+		Attr:   x.NamespaceAttr(ns, pred),
 		SrcFunc: &pb.SrcFunction{
 			Name: "eq",
 			Args: []string{value},
@@ -30,6 +30,7 @@ import (
 	"github.com/outcaste-io/outserv/graphql/dgraph"
 	"github.com/outcaste-io/outserv/graphql/schema"
 	"github.com/outcaste-io/outserv/graphql/test"
+	"github.com/outcaste-io/outserv/protos/pb"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -318,6 +319,101 @@ func mutationRewriting(t *testing.T, file string, rewriterFactory func() Mutatio
 	}
 }
 
+const rollbackSchema = `
+type Block {
+	number: String! @id
+	transactions: [Transaction] @hasInverse(field: block)
+}
+
+type Transaction {
+	hash: String! @id
+	block: Block
+	from: Account @hasInverse(field: sent)
+	events: [Event] @hasInverse(field: transaction)
+}
+
+type Account {
+	address: String! @id
+	sent: [Transaction]
+}
+
+interface Event {
+	transaction: Transaction
+}
+
+type Transfer implements Event {
+	amount: Int
+	to: Account
+}
+`
+
+// rollbackCase is a test case of rollback_mutation_test.yaml. The children of the nodes are
+// keyed by "<uid> <predicate>", and the expected edges are written as
+// "<subject> <predicate> <object>", with * for all the values.
+type rollbackCase struct {
+	Name        string
+	Explanation string
+	Type        string
+	Uids        []string
+	Children    map[string][]string
+	Types       map[string][]string
+	DGEdges     []string
+}
+
+// testNodeReader serves the nodes of a rollbackCase.
+type testNodeReader struct {
+	edges map[string][]string
+	types map[string][]string
+}
+
+func (r *testNodeReader) children(_ context.Context, uidStr, pred string) ([]string, error) {
+	return r.edges[uidStr+" "+pred], nil
+}
+
+func (r *testNodeReader) loadTypes(context.Context, []uint64) {}
+
+func (r *testNodeReader) typeNames(_ context.Context, uidStr string) ([]string, error) {
+	return r.types[uidStr], nil
+}
+
+func TestRollbackMutation(t *testing.T) {
+	b, err := ioutil.ReadFile("rollback_mutation_test.yaml")
+	require.NoError(t, err, "Unable to read test file")
+
+	var tests []rollbackCase
+	err = yaml.Unmarshal(b, &tests)
+	require.NoError(t, err, "Unable to unmarshal tests to yaml.")
+
+	gqlSchema := test.LoadSchemaFromString(t, rollbackSchema)
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			var uids []uint64
+			for _, uid := range tcase.Uids {
+				uids = append(uids, x.FromHex(uid))
+			}
+			r := &testNodeReader{edges: tcase.Children, types: tcase.Types}
+
+			mu, err := rollbackMutation(context.Background(), r, gqlSchema.Type(tcase.Type), uids)
+			require.NoError(t, err)
+
+			var edges []string
+			for _, edge := range mu.Edges {
+				require.Equal(t, pb.Edge_DEL, edge.Op)
+				obj := edge.ObjectId
+				if obj == "" {
+					obj = "*"
+					if !x.IsStarAll(edge.ObjectValue) {
+						obj = string(edge.ObjectValue[1:])
+					}
+				}
+				edges = append(edges, fmt.Sprintf("%s %s %s", edge.Subject, edge.Predicate, obj))
+			}
+			require.Equal(t, tcase.DGEdges, edges)
+		})
+	}
+}
+
 func TestMutationQueryRewriting(t *testing.T) {
 	testTypes := map[string]struct {
 		mut         string
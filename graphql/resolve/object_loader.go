@@ -0,0 +1,425 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/outcaste-io/outserv/codec"
+	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/posting"
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/sroar"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxConcurrentLoads bounds the lookups an objectLoader runs at once.
+	maxConcurrentLoads = 64
+	// edgeBatchSize is the number of nodes whose edges, or of @id values whose nodes, are read
+	// in one lookup.
+	edgeBatchSize = 1000
+)
+
+// objectLoader looks up the objects a mutation needs, before it's run: the UIDs of the nodes
+// with the given @id values, the children of nodes via an edge, and the types of nodes. Each
+// lookup is done once per mutation, so the nested objects referring to the same nodes share them.
+// The lookups for all of the input can be loaded upfront, which runs them concurrently, and reads
+// the edges of many nodes, or the nodes of many @id values, at once.
+//
+// The mutation isn't run until all the lookups are done, so what's loaded stays valid for it. The
+// lookups don't go through a DQL query, so they're checked against the ACLs of the user here.
+type objectLoader struct {
+	sem chan struct{}
+
+	sync.Mutex
+	// loads are keyed by xidKey, edgeKey or typeKey.
+	loads map[interface{}]*objectLoad
+}
+
+type xidKey struct {
+	pred, value string
+}
+
+type edgeKey struct {
+	uid  uint64
+	pred string
+}
+
+type typeKey struct {
+	uid uint64
+}
+
+// objectLoad is a lookup done by an objectLoader. Its result is set once done is closed. The
+// lookups of types set names, the other ones set uids.
+type objectLoad struct {
+	done  chan struct{}
+	uids  []uint64
+	names []string
+	err   error
+}
+
+func newObjectLoader() *objectLoader {
+	return &objectLoader{
+		sem:   make(chan struct{}, maxConcurrentLoads),
+		loads: make(map[interface{}]*objectLoad),
+	}
+}
+
+// load returns the lookup for key, and whether it's new. The caller has to do a new lookup.
+func (l *objectLoader) load(key interface{}) (*objectLoad, bool) {
+	l.Lock()
+	defer l.Unlock()
+	if ld, ok := l.loads[key]; ok {
+		return ld, false
+	}
+	ld := &objectLoad{done: make(chan struct{})}
+	l.loads[key] = ld
+	return ld, true
+}
+
+// uidsForXid returns the UIDs of the nodes whose @id field pred has the given value.
+func (l *objectLoader) uidsForXid(ctx context.Context, pred, value string) ([]uint64, error) {
+	ld, ok := l.load(xidKey{pred: pred, value: value})
+	if ok {
+		l.fetchXids(ctx, pred, []string{value}, []*objectLoad{ld})
+	}
+	<-ld.done
+	return ld.uids, ld.err
+}
+
+// children returns the UIDs, as hex strings, of the children of the node uidStr via pred.
+func (l *objectLoader) children(ctx context.Context, uidStr, pred string) ([]string, error) {
+	uid := x.FromHex(uidStr)
+	ld, ok := l.load(edgeKey{uid: uid, pred: pred})
+	if ok {
+		l.fetchEdges(ctx, pred, []uint64{uid}, []*objectLoad{ld})
+	}
+	<-ld.done
+	if ld.err != nil {
+		return nil, ld.err
+	}
+	children := make([]string, 0, len(ld.uids))
+	for _, child := range ld.uids {
+		children = append(children, x.ToHexString(child))
+	}
+	return children, nil
+}
+
+// typeNames returns the names in the dgraph.type of the node uidStr.
+func (l *objectLoader) typeNames(ctx context.Context, uidStr string) ([]string, error) {
+	uid := x.FromHex(uidStr)
+	ld, ok := l.load(typeKey{uid: uid})
+	if ok {
+		l.fetchTypes(ctx, []uint64{uid}, []*objectLoad{ld})
+	}
+	<-ld.done
+	return ld.names, ld.err
+}
+
+// loadXids looks up the UIDs for all the @id values in objs, which are the input objects of
+// type typ, and the objects nested in them. The errors are returned by uidsForXid later.
+func (l *objectLoader) loadXids(ctx context.Context, typ *schema.Type, objs []interface{}) {
+	keys := make(map[xidKey]struct{})
+	collectXids(typ, objs, keys)
+	xids := make(map[string][]string)
+	for key := range keys {
+		xids[key.pred] = append(xids[key.pred], key.value)
+	}
+
+	var wg sync.WaitGroup
+	for pred, values := range xids {
+		sort.Strings(values)
+		var batch []string
+		var loads []*objectLoad
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			wg.Add(1)
+			go func(pred string, batch []string, loads []*objectLoad) {
+				defer wg.Done()
+				l.fetchXids(ctx, pred, batch, loads)
+			}(pred, batch, loads)
+			batch, loads = nil, nil
+		}
+		for _, value := range values {
+			ld, ok := l.load(xidKey{pred: pred, value: value})
+			if !ok {
+				continue
+			}
+			batch = append(batch, value)
+			loads = append(loads, ld)
+			if len(batch) == edgeBatchSize {
+				flush()
+			}
+		}
+		flush()
+	}
+	wg.Wait()
+}
+
+// loadEdges reads the children of the nodes in edges, keyed by the predicate of the edge. The
+// errors are returned by children later.
+func (l *objectLoader) loadEdges(ctx context.Context, edges map[string][]uint64) {
+	var wg sync.WaitGroup
+	for pred, uids := range edges {
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+		var batch []uint64
+		var loads []*objectLoad
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			wg.Add(1)
+			go func(pred string, batch []uint64, loads []*objectLoad) {
+				defer wg.Done()
+				l.fetchEdges(ctx, pred, batch, loads)
+			}(pred, batch, loads)
+			batch, loads = nil, nil
+		}
+		for i, uid := range uids {
+			if i > 0 && uid == uids[i-1] {
+				continue
+			}
+			ld, ok := l.load(edgeKey{uid: uid, pred: pred})
+			if !ok {
+				continue
+			}
+			batch = append(batch, uid)
+			loads = append(loads, ld)
+			if len(batch) == edgeBatchSize {
+				flush()
+			}
+		}
+		flush()
+	}
+	wg.Wait()
+}
+
+// loadTypes reads the dgraph.type of the nodes with the given uids. The errors are returned by
+// typeNames later.
+func (l *objectLoader) loadTypes(ctx context.Context, uids []uint64) {
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	var batch []uint64
+	var loads []*objectLoad
+	for i, uid := range uids {
+		if i > 0 && uid == uids[i-1] {
+			continue
+		}
+		if ld, ok := l.load(typeKey{uid: uid}); ok {
+			batch = append(batch, uid)
+			loads = append(loads, ld)
+		}
+	}
+	for len(batch) > 0 {
+		n := len(batch)
+		if n > edgeBatchSize {
+			n = edgeBatchSize
+		}
+		l.fetchTypes(ctx, batch[:n], loads[:n])
+		batch, loads = batch[n:], loads[n:]
+	}
+}
+
+// loadInverseEdges reads the children of the nodes with the given uids, of type typ, via all
+// the fields which have an inverse.
+func (l *objectLoader) loadInverseEdges(ctx context.Context, typ *schema.Type, uids []uint64) {
+	edges := make(map[string][]uint64)
+	for _, f := range typ.Fields() {
+		if f.Inverse() != nil {
+			edges[f.DgraphAlias()] = append([]uint64{}, uids...)
+		}
+	}
+	l.loadEdges(ctx, edges)
+}
+
+// fetchXids looks up the nodes with the given values of the @id field pred into their loads. All
+// of the values are looked up in a single read of the index of pred.
+func (l *objectLoader) fetchXids(ctx context.Context, pred string, values []string,
+	loads []*objectLoad) {
+
+	l.sem <- struct{}{}
+	defer func() { <-l.sem }()
+	defer func() {
+		for _, ld := range loads {
+			close(ld.done)
+		}
+	}()
+
+	var result *pb.Result
+	err := edgraph.AuthorizeRead(ctx, pred)
+	if err == nil {
+		// eq returns a list of nodes for each of its values, in the order of the values.
+		ns, _ := x.ExtractNamespace(ctx)
+		result, err = worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+			ReadTs:  posting.ReadTimestamp(),
+			Attr:    x.NamespaceAttr(ns, pred),
+			SrcFunc: &pb.SrcFunction{Name: "eq", Args: values},
+		})
+	}
+	// There are no lists at all if nothing has been stored in pred yet.
+	if err == nil && len(result.UidMatrix) > 0 && len(result.UidMatrix) != len(values) {
+		err = errors.Errorf("got %d lists for %d values of %s",
+			len(result.UidMatrix), len(values), pred)
+	}
+	for i, ld := range loads {
+		switch {
+		case err != nil:
+			ld.err = errors.Wrapf(err, "while looking up %s", pred)
+		case len(result.UidMatrix) > 0:
+			ld.uids = codec.GetUids(result.UidMatrix[i])
+		}
+	}
+}
+
+// fetchEdges reads the children via pred of the nodes with the given sorted uids, straight from
+// the posting lists, into their loads.
+func (l *objectLoader) fetchEdges(ctx context.Context, pred string, uids []uint64,
+	loads []*objectLoad) {
+
+	l.sem <- struct{}{}
+	defer func() { <-l.sem }()
+	defer func() {
+		for _, ld := range loads {
+			close(ld.done)
+		}
+	}()
+
+	err := edgraph.AuthorizeRead(ctx, pred)
+	if err != nil {
+		for _, ld := range loads {
+			ld.err = err
+		}
+		return
+	}
+	ns, _ := x.ExtractNamespace(ctx)
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  posting.ReadTimestamp(),
+		Attr:    x.NamespaceAttr(ns, pred),
+		UidList: codec.ToList(sroar.FromSortedList(uids)),
+	})
+	if err == nil && len(result.UidMatrix) != len(uids) {
+		err = errors.Errorf("got %d lists for %d nodes of %s",
+			len(result.UidMatrix), len(uids), pred)
+	}
+	for i, ld := range loads {
+		if err != nil {
+			ld.err = errors.Wrapf(err, "while reading %s", pred)
+			continue
+		}
+		ld.uids = codec.GetUids(result.UidMatrix[i])
+	}
+}
+
+// fetchTypes reads the dgraph.type of the nodes with the given sorted uids into their loads.
+func (l *objectLoader) fetchTypes(ctx context.Context, uids []uint64, loads []*objectLoad) {
+	l.sem <- struct{}{}
+	defer func() { <-l.sem }()
+	defer func() {
+		for _, ld := range loads {
+			close(ld.done)
+		}
+	}()
+
+	ns, _ := x.ExtractNamespace(ctx)
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  posting.ReadTimestamp(),
+		Attr:    x.NamespaceAttr(ns, "dgraph.type"),
+		UidList: codec.ToList(sroar.FromSortedList(uids)),
+	})
+	if err == nil && len(result.ValueMatrix) != len(uids) {
+		err = errors.Errorf("got %d lists of types for %d nodes",
+			len(result.ValueMatrix), len(uids))
+	}
+	for i, ld := range loads {
+		if err != nil {
+			ld.err = errors.Wrapf(err, "while reading dgraph.type")
+			continue
+		}
+		for _, tv := range result.ValueMatrix[i].Values {
+			val, err := types.FromBinary(tv.Val)
+			if err != nil {
+				ld.err = errors.Wrapf(err, "while reading dgraph.type")
+				break
+			}
+			if name, ok := val.Value.(string); ok {
+				ld.names = append(ld.names, name)
+			}
+		}
+	}
+}
+
+// collectXids adds the @id values of objs, the input objects of type typ, and of the objects
+// nested in them, to keys. The objects missing an @id value, or having an invalid one, are left
+// for gatherObjects to report.
+func collectXids(typ *schema.Type, objs []interface{}, keys map[xidKey]struct{}) {
+	for _, o := range objs {
+		obj, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, xid := range typ.XIDFields() {
+			val := obj[xid.Name()]
+			if val == nil {
+				continue
+			}
+			if xidString, err := extractVal(val, xid); err == nil {
+				keys[xidKey{pred: xid.DgraphAlias(), value: xidString}] = struct{}{}
+			}
+		}
+		for _, f := range typ.Fields() {
+			val, has := obj[f.Name()]
+			if !has || f.Type().IsInbuiltOrEnumType() {
+				continue
+			}
+			if vlist, ok := val.([]interface{}); ok {
+				collectXids(f.Type(), vlist, keys)
+			} else {
+				collectXids(f.Type(), []interface{}{val}, keys)
+			}
+		}
+	}
+}
+
+// collectPreviousEdges adds the edges which handleInverses reads for objs, the gathered objects
+// of type typ, to edges. Those are the edges of the existing nodes via the fields, which aren't
+// lists and have an inverse, so they can only have one child.
+func collectPreviousEdges(typ *schema.Type, objs []Object, edges map[string][]uint64) {
+	add := func(uidStr string, f *schema.FieldDefinition) {
+		if strings.HasPrefix(uidStr, "_:") || f.Type().ListType() != nil {
+			return
+		}
+		edges[f.DgraphAlias()] = append(edges[f.DgraphAlias()], x.FromHex(uidStr))
+	}
+	for _, f := range typ.Fields() {
+		inv := f.Inverse()
+		if inv == nil {
+			continue
+		}
+		for _, obj := range objs {
+			var children []Object
+			switch val := obj[f.DgraphAlias()].(type) {
+			case []Object:
+				children = val
+			case Object:
+				children = []Object{val}
+			default:
+				continue
+			}
+			collectPreviousEdges(f.Type(), children, edges)
+
+			add(obj["uid"].(string), f)
+			for _, child := range children {
+				add(child["uid"].(string), inv)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/outcaste-io/outserv/graphql/test"
+	"github.com/stretchr/testify/require"
+)
+
+const loaderSchema = `
+type Country {
+	id: ID!
+	name: String! @id
+	states: [State] @hasInverse(field: country)
+}
+
+type State {
+	code: String! @id
+	country: Country
+}
+`
+
+func TestCollectXids(t *testing.T) {
+	sch := test.LoadSchemaFromString(t, loaderSchema)
+	objs := []interface{}{
+		map[string]interface{}{
+			"name": "India",
+			"states": []interface{}{
+				map[string]interface{}{"code": "KA"},
+				map[string]interface{}{"code": "MH"},
+			},
+		},
+		map[string]interface{}{
+			"name":   "Nepal",
+			"states": []interface{}{map[string]interface{}{"code": "KA"}},
+		},
+		// The missing @id value is left for gatherObjects to report.
+		map[string]interface{}{"states": []interface{}{}},
+	}
+
+	keys := make(map[xidKey]struct{})
+	collectXids(sch.Type("Country"), objs, keys)
+	require.Equal(t, map[xidKey]struct{}{
+		{pred: "Country.name", value: "India"}: {},
+		{pred: "Country.name", value: "Nepal"}: {},
+		{pred: "State.code", value: "KA"}:      {},
+		{pred: "State.code", value: "MH"}:      {},
+	}, keys)
+}
+
+func TestCollectPreviousEdges(t *testing.T) {
+	sch := test.LoadSchemaFromString(t, loaderSchema)
+	objs := []Object{
+		{
+			"uid": "0x1",
+			"Country.states": []Object{
+				{"uid": "0x2"},
+				{"uid": "_:State-1"},
+			},
+		},
+		{
+			"uid":            "_:Country-2",
+			"Country.states": []Object{{"uid": "0x3"}},
+		},
+	}
+
+	// Only a state can have one country, which the existing states could already have.
+	edges := make(map[string][]uint64)
+	collectPreviousEdges(sch.Type("Country"), objs, edges)
+	require.Equal(t, map[string][]uint64{"State.country": {0x2, 0x3}}, edges)
+
+	edges = make(map[string][]uint64)
+	collectPreviousEdges(sch.Type("State"), []Object{
+		{"uid": "0x2", "State.country": Object{"uid": "0x1"}},
+	}, edges)
+	require.Equal(t, map[string][]uint64{"State.country": {0x2}}, edges)
+}
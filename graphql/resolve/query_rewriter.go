@@ -14,6 +14,7 @@ import (
 	"github.com/outcaste-io/outserv/gql"
 	"github.com/outcaste-io/outserv/graphql/schema"
 	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/types"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/pkg/errors"
 )
@@ -88,11 +89,19 @@ func (qr *QueryRewriter) Rewrite(
 		return dgQuery, nil
 
 	case schema.FilterQuery:
-		return rewriteAsQuery(gqlQuery), nil
+		if gqlQuery.Type().IsConnection() {
+			return connectionQuery(gqlQuery)
+		}
+		if gqlQuery.IsSimilarQuery() {
+			return similarQuery(gqlQuery)
+		}
+		return rewriteAsQuery(gqlQuery)
 	case schema.PasswordQuery:
 		return passwordQuery(gqlQuery)
 	case schema.AggregateQuery:
 		return aggregateQuery(gqlQuery), nil
+	case schema.HistogramQuery:
+		return histogramQuery(gqlQuery), nil
 	case schema.EntitiesQuery:
 		return entitiesQuery(gqlQuery)
 	case schema.DQLQuery:
@@ -193,10 +202,17 @@ func aggregateQuery(query *schema.Field) []*gql.GraphQuery {
 	// Add selection set to mainQuery and finalMainQuery.
 	isAggregateVarAdded := make(map[string]bool)
 	isCountVarAdded := false
+	var groupsQueries []*gql.GraphQuery
 
 	for _, f := range query.SelectionSet() {
 		// fldName stores Name of the field f.
 		fldName := f.Name()
+		if f.IsAggregateGroups() {
+			if q := aggregateGroupsQuery(query, f, mainType, mainQuery); q != nil {
+				groupsQueries = append(groupsQueries, q)
+			}
+			continue
+		}
 		if fldName == "count" {
 			if !isCountVarAdded {
 				child := &gql.GraphQuery{
@@ -256,7 +272,110 @@ func aggregateQuery(query *schema.Field) []*gql.GraphQuery {
 		}
 	}
 
-	return append([]*gql.GraphQuery{finalMainQuery}, dgQuery...)
+	if len(groupsQueries) > 0 && len(finalMainQuery.Children) == 0 {
+		// The results for groups are looked up after those of the aggregate query. So, make
+		// sure that the aggregate query returns something, even if only groups were asked for.
+		if !isCountVarAdded {
+			mainQuery.Children = append(mainQuery.Children, &gql.GraphQuery{
+				Var:  "countVar",
+				Attr: "count(uid)",
+			})
+		}
+		finalMainQuery.Children = append(finalMainQuery.Children, &gql.GraphQuery{
+			Alias: query.Type().Name() + ".count",
+			Attr:  "max(val(countVar))",
+		})
+	}
+
+	dgQueries := append([]*gql.GraphQuery{finalMainQuery}, groupsQueries...)
+	return append(dgQueries, dgQuery...)
+}
+
+// aggregateGroupsQuery rewrites the groups field of an aggregate query into a DQL query using
+// @groupby. The values of the fields given in the groupBy argument, and the aggregates for each
+// group, are aliased by the name of the field in <Type>AggregateGroup. For example,
+// aggregateTweets(groupBy: [author]) { groups { author scoreMax } } gets rewritten to
+//
+//	TweetsAggregateResult.groups(func: type(Tweets))
+//	    @groupby(TweetsAggregateGroup.author : Tweets.author) {
+//	  TweetsAggregateGroup.scoreMax : max(Tweets.score)
+//	}
+//
+// The nodes to group are the same as those of mainQuery, the var block of the aggregate query.
+// It returns nil if the query wasn't asked to group its results.
+func aggregateGroupsQuery(query, groups *schema.Field, mainType *schema.Type,
+	mainQuery *gql.GraphQuery) *gql.GraphQuery {
+	groupBy := query.GroupBy()
+	if len(groupBy) == 0 {
+		return nil
+	}
+
+	dgQuery := &gql.GraphQuery{
+		Attr:   groups.DgraphAlias(),
+		Func:   mainQuery.Func,
+		Filter: mainQuery.Filter,
+	}
+
+	groupTypeName := groups.Type().Name()
+	isGroupByField := make(map[string]bool)
+	dgQuery.IsGroupby = true
+	for _, fld := range groupBy {
+		isGroupByField[fld] = true
+		dgQuery.GroupbyAttrs = append(dgQuery.GroupbyAttrs, gql.GroupByAttr{
+			Attr:  mainType.DgraphPredicate(fld),
+			Alias: groupTypeName + "." + fld,
+		})
+	}
+
+	for _, f := range groups.SelectionSet() {
+		fldName := f.Name()
+		if isGroupByField[fldName] {
+			// Values of the fields being grouped by are returned by @groupby itself.
+			continue
+		}
+		if fldName == "count" {
+			dgQuery.Children = append(dgQuery.Children, &gql.GraphQuery{
+				Alias: f.DgraphAlias(),
+				Attr:  "count(uid)",
+			})
+			continue
+		}
+		for _, function := range []string{"Max", "Min", "Sum", "Avg"} {
+			if !strings.HasSuffix(fldName, function) {
+				continue
+			}
+			pred := mainType.DgraphPredicate(fldName[:len(fldName)-3])
+			if pred == "" {
+				// A field named like an aggregate, e.g. highScoreMax, which isn't grouped by.
+				break
+			}
+			dgQuery.Children = append(dgQuery.Children, &gql.GraphQuery{
+				Alias: f.DgraphAlias(),
+				Attr:  strings.ToLower(function) + "(" + pred + ")",
+			})
+			break
+		}
+	}
+	if len(dgQuery.Children) == 0 {
+		// DQL needs at least one aggregate within @groupby.
+		dgQuery.Children = append(dgQuery.Children, &gql.GraphQuery{
+			Alias: groupTypeName + ".count",
+			Attr:  "count(uid)",
+		})
+	}
+	return dgQuery
+}
+
+// histogramQuery rewrites the histogram query into a query for the uids of the nodes it
+// counts. Those are the nodes of its type, matching its filter.
+func histogramQuery(query *schema.Field) []*gql.GraphQuery {
+	mainType := query.ConstructedFor()
+	dgQuery := addCommonRules(query, mainType)
+
+	filter, _ := query.ArgValue("filter").(map[string]interface{})
+	_ = addFilter(dgQuery[0], mainType, filter)
+	dgQuery[0].Children = []*gql.GraphQuery{{Attr: "uid"}}
+	return dgQuery
 }
 
 func passwordQuery(m *schema.Field) ([]*gql.GraphQuery, error) {
@@ -297,10 +416,12 @@ func passwordQuery(m *schema.Field) ([]*gql.GraphQuery, error) {
 		Op: "and",
 		Child: []*gql.FilterTree{{
 			Func: &gql.Function{
-				Name: "eq",
+				Name:       "eq",
+				IsValueVar: true,
 				Args: []gql.Arg{
 					{
-						Value: "val(pwd)",
+						Value:      "val(pwd)",
+						IsValueVar: true,
 					},
 					{
 						Value: "1",
@@ -411,7 +532,7 @@ func addArgumentsToField(dgQuery *gql.GraphQuery, field *schema.Field) {
 	filter, _ := field.ArgValue("filter").(map[string]interface{})
 
 	_ = addFilter(dgQuery, field.Type(), filter)
-	addOrder(dgQuery, field)
+	addOrder(dgQuery, field, field.Type())
 	addPagination(dgQuery, field)
 }
 
@@ -626,20 +747,149 @@ func addCommonRules(
 	return []*gql.GraphQuery{dgQuery}
 }
 
-func rewriteAsQuery(field *schema.Field) []*gql.GraphQuery {
+func rewriteAsQuery(field *schema.Field) ([]*gql.GraphQuery, error) {
 	// addCommonRules would always add a type func, unless UIDs are provided.
 	dgQuery := addCommonRules(field, field.Type())
 
 	addArgumentsToField(dgQuery[0], field)
+	cursorVars, err := addCursor(dgQuery[0], field, field.Type())
+	if err != nil {
+		return nil, err
+	}
+	dgQuery = append(dgQuery, cursorVars...)
 
 	selectionAuth := addSelectionSetFrom(dgQuery[0], field)
 	addUID(dgQuery[0])
 	addCascadeDirective(dgQuery[0], field)
+	scoreVar, err := addRelevanceOrder(dgQuery[0], field)
+	if err != nil {
+		return nil, err
+	}
+	if scoreVar != nil {
+		dgQuery = append(dgQuery, scoreVar)
+	}
 	if len(selectionAuth) > 0 {
-		return append(dgQuery, selectionAuth...)
+		return append(dgQuery, selectionAuth...), nil
 	}
 	dgQuery = rootQueryOptimization(dgQuery)
-	return dgQuery
+	return dgQuery, nil
+}
+
+// connectionQuery rewrites a query<Type>Connection query. It is rewritten like query<Type>, using
+// the selection set of edges { node { ... } } for the nodes. It fetches one node more than asked
+// for, so that the encoder can tell whether there is a next page. The uid and the value of the
+// order field are also fetched for every node, to build the cursors.
+func connectionQuery(field *schema.Field) ([]*gql.GraphQuery, error) {
+	nodeType := field.ConstructedFor()
+	dgQuery := addCommonRules(field, nodeType)
+	q := dgQuery[0]
+
+	filter, _ := field.ArgValue("filter").(map[string]interface{})
+	_ = addFilter(q, nodeType, filter)
+	addOrder(q, field, nodeType)
+	addPagination(q, field)
+	if first, ok := q.Args["first"]; ok {
+		if n, err := strconv.Atoi(first); err == nil {
+			q.Args["first"] = strconv.Itoa(n + 1)
+		}
+	}
+	cursorVars, err := addCursor(q, field, nodeType)
+	if err != nil {
+		return nil, err
+	}
+	dgQuery = append(dgQuery, cursorVars...)
+
+	var selectionAuth []*gql.GraphQuery
+	if node := field.ConnectionNodeField(); node != nil {
+		selectionAuth = addSelectionSetFrom(q, node)
+		addUID(q)
+	}
+	q.Children = append(q.Children, &gql.GraphQuery{
+		Attr:  "uid",
+		Alias: schema.CursorUidAlias,
+	})
+	if len(q.Order) > 0 {
+		q.Children = append(q.Children, &gql.GraphQuery{
+			Attr:  q.Order[0].Attr,
+			Alias: schema.CursorValueAlias,
+		})
+	}
+	return append(dgQuery, selectionAuth...), nil
+}
+
+// similarQuery rewrites a querySimilar<Type> query. The topK nodes whose embedding is the closest
+// to the vector are found, and their distance measured, in a var block like
+//
+//	var(func: similar_to(Post.embedding, 10, "[0.1,0.2]", "cosine")) @filter(type(Post)) {
+//	  Post_distance as vector_distance(Post.embedding, "[0.1,0.2]", "cosine")
+//	}
+//
+// and the query then works off uid(Post_distance), ordered by val(Post_distance). With a filter,
+// the nodes are filtered first, in a Post_candidates var block, and similar_to picks the closest
+// of them as a filter on uid(Post_candidates). That way, there are still topK nodes found when
+// enough of them match the filter.
+func similarQuery(field *schema.Field) ([]*gql.GraphQuery, error) {
+	typ := field.Type()
+	vec, err := field.SimilarVector()
+	if err != nil {
+		return nil, err
+	}
+	topK := field.SimilarTopK()
+	if topK <= 0 {
+		return nil, errors.Errorf("topK of %s must be positive, but got %d", field.Name(), topK)
+	}
+	pred := typ.DgraphPredicate(field.SimilarBy())
+	vecArg := strconv.Quote(types.FormatVFloat(vec))
+	distanceArg := strconv.Quote(field.SimilarDistance())
+
+	dgQuery := addCommonRules(field, typ)
+	q := dgQuery[0]
+	filter, _ := field.ArgValue("filter").(map[string]interface{})
+	_ = addFilter(q, typ, filter)
+
+	varName := typ.Name() + "_distance"
+	similar := &gql.Function{
+		Name: "similar_to",
+		Args: []gql.Arg{{Value: pred}, {Value: strconv.Itoa(topK)}, {Value: vecArg},
+			{Value: distanceArg}},
+	}
+	distanceVar := &gql.GraphQuery{
+		Attr: "var",
+		Children: []*gql.GraphQuery{{
+			Var:  varName,
+			Attr: pred,
+			Func: &gql.Function{
+				Name: "vector_distance",
+				Args: []gql.Arg{{Value: pred}, {Value: vecArg}, {Value: distanceArg}},
+			},
+		}},
+	}
+	if q.Filter == nil && q.Func.Name == "type" {
+		distanceVar.Func = similar
+		distanceVar.Filter = &gql.FilterTree{Func: q.Func}
+	} else {
+		candidates := typ.Name() + "_candidates"
+		candidatesVar := &gql.GraphQuery{
+			Var:    candidates,
+			Attr:   "var",
+			Func:   q.Func,
+			Filter: q.Filter,
+		}
+		rootQueryOptimization([]*gql.GraphQuery{candidatesVar})
+		dgQuery = append(dgQuery, candidatesVar)
+		distanceVar.Func = buildUIDVarFunc(candidates)
+		distanceVar.Filter = &gql.FilterTree{Func: similar}
+	}
+	dgQuery = append(dgQuery, distanceVar)
+
+	q.Func = buildUIDVarFunc(varName)
+	q.Filter = nil
+	q.Order = []*pb.Order{{Attr: varName}}
+	q.NeedsVar = append(q.NeedsVar, gql.VarContext{Name: varName, Typ: gql.ValueVar})
+	selectionAuth := addSelectionSetFrom(q, field)
+	addUID(q)
+	addCascadeDirective(q, field)
+	return append(dgQuery, selectionAuth...), nil
 }
 
 func rootQueryOptimization(dgQuery []*gql.GraphQuery) []*gql.GraphQuery {
@@ -735,6 +985,20 @@ func buildTypeFunc(typ string) *gql.Function {
 	}
 }
 
+func buildPredFunc(fn, dgPred, val string) *gql.Function {
+	return &gql.Function{
+		Name: fn,
+		Args: []gql.Arg{{Value: dgPred}, {Value: val}},
+	}
+}
+
+func buildUIDVarFunc(varName string) *gql.Function {
+	return &gql.Function{
+		Name: "uid",
+		Args: []gql.Arg{{Value: varName}},
+	}
+}
+
 // buildAggregateFields builds DQL queries for aggregate fields like count, avg, max etc.
 // It returns related DQL fields and Auth Queries which are then added to the final DQL query
 // by the caller.
@@ -899,6 +1163,31 @@ func addSelectionSetFrom(
 			continue
 		}
 
+		// _distance is only known for the nodes found by a similarity query, from its var.
+		if f.Name() == schema.DistanceField {
+			if field.IsSimilarQuery() {
+				q.Children = append(q.Children, &gql.GraphQuery{
+					Alias: f.DgraphAlias(),
+					Attr:  "val",
+					NeedsVar: []gql.VarContext{{
+						Name: field.Type().Name() + "_distance",
+						Typ:  gql.ValueVar,
+					}},
+				})
+			}
+			fieldAdded[f.DgraphAlias()] = true
+			continue
+		}
+
+		// _score and _highlight are computed from the fulltext filters of the nodes.
+		if f.Name() == schema.ScoreField || f.Name() == schema.HighlightField {
+			if child := searchChild(q, f, field.Type()); child != nil {
+				q.Children = append(q.Children, child)
+			}
+			fieldAdded[f.DgraphAlias()] = true
+			continue
+		}
+
 		// Handle aggregation queries
 		if f.IsAggregateField() {
 			aggregateChildren, aggregateAuthQueries := buildAggregateFields(f)
@@ -928,7 +1217,7 @@ func addSelectionSetFrom(
 			continue
 		}
 
-		addOrder(child, f)
+		addOrder(child, f, f.Type())
 		addPagination(child, f)
 		addCascadeDirective(child, f)
 
@@ -971,6 +1260,127 @@ func addSelectionSetFrom(
 	return authQueries
 }
 
+// fullTextArgs returns the predicates and the texts of the alloftext and anyoftext functions in
+// the filter of q, as pairs of args, like the search functions take them. Functions under a not
+// don't count, as the nodes found don't match them.
+func fullTextArgs(q *gql.GraphQuery) []gql.Arg {
+	var args []gql.Arg
+	var walk func(ft *gql.FilterTree)
+	walk = func(ft *gql.FilterTree) {
+		if ft == nil || ft.Op == "not" {
+			return
+		}
+		if fn := ft.Func; fn != nil && (fn.Name == "alloftext" || fn.Name == "anyoftext") &&
+			len(fn.Args) == 2 && !strings.Contains(fn.Args[0].Value, "@") {
+			args = append(args, fn.Args...)
+		}
+		for _, child := range ft.Child {
+			walk(child)
+		}
+	}
+	walk(q.Filter)
+	return args
+}
+
+// searchChild returns the child of q which computes the _score or _highlight field f of the
+// nodes of type typ. _score is rewritten as
+//
+//	Post._score : bm25(Post.title, "text", Post.text, "other text")
+//
+// with all the fulltext filters of q, and _highlight(field: "text") as
+//
+//	Post._highlight : highlight(Post.text, "other text")
+//
+// with the filters on the given field. It returns nil if there are no such filters, and then f
+// resolves to null.
+func searchChild(q *gql.GraphQuery, f *schema.Field, typ *schema.Type) *gql.GraphQuery {
+	args := fullTextArgs(q)
+	fn := "bm25"
+	if f.Name() == schema.HighlightField {
+		fn = "highlight"
+		fld, _ := f.ArgValue(schema.HighlightFieldArg).(string)
+		if typ.Field(fld) == nil {
+			return nil
+		}
+		pred := typ.DgraphPredicate(fld)
+		var texts []string
+		for i := 0; i < len(args); i += 2 {
+			if args[i].Value != pred {
+				continue
+			}
+			if text, err := strconv.Unquote(args[i+1].Value); err == nil {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) == 0 {
+			return nil
+		}
+		args = []gql.Arg{{Value: pred}, {Value: strconv.Quote(strings.Join(texts, " "))}}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return &gql.GraphQuery{
+		Alias: f.DgraphAlias(),
+		Attr:  args[0].Value,
+		Func:  &gql.Function{Name: fn, Args: args},
+	}
+}
+
+// addRelevanceOrder orders the results of the query q for field by their BM25 relevance, if it
+// has order: { relevance: ASC|DESC }. The nodes found by q are scored in a var block like
+//
+//	var(func: type(Post)) @filter(anyoftext(Post.title, "text")) {
+//	  Post_score as bm25(Post.title, "text")
+//	}
+//
+// which is returned, and q then works off uid(Post_score), ordered by val(Post_score). The
+// relevance comes before the asc or desc given along with it, and is only taken at the top level
+// of the order. Without fulltext filters, there's nothing to order by, and it's ignored.
+func addRelevanceOrder(q *gql.GraphQuery, field *schema.Field) (*gql.GraphQuery, error) {
+	order, _ := field.ArgValue("order").(map[string]interface{})
+	dir, ok := order[schema.RelevanceOrder].(string)
+	if !ok {
+		return nil, nil
+	}
+	args := fullTextArgs(q)
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if field.ArgValue(schema.AfterArgName) != nil || field.ArgValue(schema.BeforeArgName) != nil {
+		return nil, errors.Errorf("cursors can't be used along with the relevance order of %s",
+			field.Name())
+	}
+
+	varName := field.Type().Name() + "_score"
+	scoreVar := &gql.GraphQuery{
+		Attr:   "var",
+		Func:   q.Func,
+		Filter: q.Filter,
+		Children: []*gql.GraphQuery{{
+			Var:  varName,
+			Attr: args[0].Value,
+			Func: &gql.Function{Name: "bm25", Args: args},
+		}},
+	}
+	rootQueryOptimization([]*gql.GraphQuery{scoreVar})
+
+	valVar := gql.VarContext{Name: varName, Typ: gql.ValueVar}
+	q.Func = buildUIDVarFunc(varName)
+	q.Filter = nil
+	q.Order = append([]*pb.Order{{Attr: varName, Desc: dir == "DESC"}}, q.Order...)
+	q.NeedsVar = append(q.NeedsVar, valVar)
+	// The scores are already there, in the var.
+	for _, child := range q.Children {
+		if child.Func != nil && child.Func.Name == "bm25" {
+			child.Attr = "val"
+			child.Func = nil
+			child.NeedsVar = []gql.VarContext{valVar}
+		}
+	}
+	return scoreVar, nil
+}
+
 // Todo: Currently it doesn't work for fields with
 // @dgraph predicate in the GraphQL schema because
 // it doesn't enforce the Type.FieldName syntax.
@@ -982,7 +1392,9 @@ func getFieldName(attr string) string {
 	return fldSplit[1]
 }
 
-func addOrder(q *gql.GraphQuery, field *schema.Field) {
+// addOrder adds the asc and desc orders of field to q. The relevance order isn't on a predicate,
+// and is added by addRelevanceOrder instead.
+func addOrder(q *gql.GraphQuery, field *schema.Field, typ *schema.Type) {
 	orderArg := field.ArgValue("order")
 	order, ok := orderArg.(map[string]interface{})
 	for ok {
@@ -992,16 +1404,215 @@ func addOrder(q *gql.GraphQuery, field *schema.Field) {
 
 		if asc, ok := ascArg.(string); ok {
 			q.Order = append(q.Order,
-				&pb.Order{Attr: field.Type().DgraphPredicate(asc)})
+				&pb.Order{Attr: typ.DgraphPredicate(asc)})
 		} else if desc, ok := descArg.(string); ok {
 			q.Order = append(q.Order,
-				&pb.Order{Attr: field.Type().DgraphPredicate(desc), Desc: true})
+				&pb.Order{Attr: typ.DgraphPredicate(desc), Desc: true})
 		}
 
 		order, ok = thenArg.(map[string]interface{})
 	}
 }
 
+// addCursor turns the after and before arguments of field into a seek on the order of the query q.
+// Results are sorted by the order field, with ties broken by uid. So, for order: { asc: title }
+// the nodes after a cursor are the ones with
+//
+//	title > cursor.title OR (title == cursor.title AND uid > cursor.uid)
+//
+// Nodes without a title are sorted last by Dgraph, ordered by uid. Their cursors hold a null
+// title, and the nodes after such a cursor are the ones without a title, and a larger uid. So, if
+// title is nullable, the nodes after a cursor with a title also include all the ones without it.
+//
+// If title has an index that supports inequalities, the root function becomes
+// ge(Post.title, cursor.title), so that we don't have to go over all the pages before the cursor.
+// The nodes without a title don't show up in the index, so if title is nullable, the root
+// becomes the union of that seek and of the nodes without a title. Otherwise, the root stays
+// type(Post), and the cost of a page grows with the number of nodes before it. The ties, and the
+// nodes without a title, are picked up with var blocks, which are returned to be added to the
+// query. Without an order, results are sorted by uid, and after can be passed on to Dgraph as is.
+func addCursor(q *gql.GraphQuery, field *schema.Field, typ *schema.Type) ([]*gql.GraphQuery,
+	error) {
+	after, err := field.CursorArgValue(schema.AfterArgName)
+	if err != nil {
+		return nil, err
+	}
+	before, err := field.CursorArgValue(schema.BeforeArgName)
+	if err != nil {
+		return nil, err
+	}
+	if after == nil && before == nil {
+		return nil, nil
+	}
+
+	var vars []*gql.GraphQuery
+	if len(q.Order) == 0 {
+		if after != nil {
+			q.Args["after"] = fmt.Sprintf("%#x", after.UID)
+		}
+		if before != nil {
+			// Dgraph can only seek forward on uids. So, we find the nodes from the before cursor
+			// onwards and filter them out.
+			varName := typ.Name() + "_CursorBefore"
+			vars = append(vars, &gql.GraphQuery{
+				Var:  varName,
+				Attr: "var",
+				Func: buildTypeFunc(typ.DgraphName()),
+				Args: map[string]string{"after": fmt.Sprintf("%#x", before.UID-1)},
+			})
+			addToFilterTree(q, &gql.FilterTree{
+				Op:    "not",
+				Child: []*gql.FilterTree{{Func: buildUIDVarFunc(varName)}},
+			})
+		}
+		return vars, nil
+	}
+
+	if len(q.Order) > 1 {
+		return nil, errors.Errorf("cursors can only be used with a single order field, "+
+			"but %s has %d", field.Name(), len(q.Order))
+	}
+	order := q.Order[0]
+	orderField := typ.Field(orderFieldName(field))
+	nullable := orderField.Type().Nullable()
+
+	if after != nil && after.Null {
+		varName := typ.Name() + "_CursorAfter"
+		// The nodes without a value after the cursor uid.
+		vars = append(vars, buildNullsVar(varName, typ, order.Attr, after.UID))
+		addToFilterTree(q, &gql.FilterTree{Func: buildUIDVarFunc(varName)})
+	} else if after != nil {
+		if after.Value == nil {
+			return nil, errors.Errorf("after cursor doesn't hold a value for the order of %s",
+				field.Name())
+		}
+		val := schema.MaybeQuoteArg("eq", after.Value)
+		varName := typ.Name() + "_CursorAfter"
+		// The ties after the cursor uid.
+		vars = append(vars, &gql.GraphQuery{
+			Var:  varName,
+			Attr: "var",
+			Func: buildPredFunc("eq", order.Attr, val),
+			Args: map[string]string{"after": fmt.Sprintf("%#x", after.UID)},
+		})
+		cmp, seek := "gt", "ge"
+		if order.Desc {
+			cmp, seek = "lt", "le"
+		}
+		if q.Func.Name == "type" && orderField.HasSortableIndex() {
+			q.Func = buildPredFunc(seek, order.Attr, val)
+			if nullable {
+				// The nodes without a value don't show up in the index, so they're added to
+				// the ones found by the seek.
+				seekVar, nullsVar := typ.Name()+"_CursorSeek", typ.Name()+"_CursorNulls"
+				vars = append(vars,
+					&gql.GraphQuery{Var: seekVar, Attr: "var", Func: q.Func},
+					buildNullsVar(nullsVar, typ, order.Attr, 0))
+				q.Func = &gql.Function{
+					Name: "uid",
+					Args: []gql.Arg{{Value: seekVar}, {Value: nullsVar}},
+				}
+			}
+			addTypeFilter(q, typ)
+		}
+		ft := &gql.FilterTree{
+			Op: "or",
+			Child: []*gql.FilterTree{
+				{Func: buildPredFunc(cmp, order.Attr, val)},
+				{Func: buildUIDVarFunc(varName)},
+			},
+		}
+		if nullable {
+			ft.Child = append(ft.Child, buildNotHasFilter(order.Attr))
+		}
+		addToFilterTree(q, ft)
+	}
+
+	if before != nil && before.Null {
+		varName := typ.Name() + "_CursorBefore"
+		// The nodes without a value from the cursor uid onwards, which need to be left out.
+		vars = append(vars, buildNullsVar(varName, typ, order.Attr, before.UID-1))
+		addToFilterTree(q, &gql.FilterTree{
+			Op:    "not",
+			Child: []*gql.FilterTree{{Func: buildUIDVarFunc(varName)}},
+		})
+	} else if before != nil {
+		if before.Value == nil {
+			return nil, errors.Errorf("before cursor doesn't hold a value for the order of %s",
+				field.Name())
+		}
+		val := schema.MaybeQuoteArg("eq", before.Value)
+		varName := typ.Name() + "_CursorBefore"
+		// The ties from the cursor uid onwards, which need to be left out.
+		vars = append(vars, &gql.GraphQuery{
+			Var:  varName,
+			Attr: "var",
+			Func: buildPredFunc("eq", order.Attr, val),
+			Args: map[string]string{"after": fmt.Sprintf("%#x", before.UID-1)},
+		})
+		cmp, seek := "lt", "le"
+		if order.Desc {
+			cmp, seek = "gt", "ge"
+		}
+		if q.Func.Name == "type" && orderField.HasSortableIndex() {
+			q.Func = buildPredFunc(seek, order.Attr, val)
+			addTypeFilter(q, typ)
+		}
+		addToFilterTree(q, &gql.FilterTree{
+			Op: "or",
+			Child: []*gql.FilterTree{
+				{Func: buildPredFunc(cmp, order.Attr, val)},
+				{
+					Op: "and",
+					Child: []*gql.FilterTree{
+						{Func: buildPredFunc("eq", order.Attr, val)},
+						{
+							Op:    "not",
+							Child: []*gql.FilterTree{{Func: buildUIDVarFunc(varName)}},
+						},
+					},
+				},
+			},
+		})
+	}
+	return vars, nil
+}
+
+// buildNullsVar builds the var block varName, holding the nodes of type typ which don't have a
+// value for pred, and whose uid is larger than after, if it's set.
+func buildNullsVar(varName string, typ *schema.Type, pred string, after uint64) *gql.GraphQuery {
+	q := &gql.GraphQuery{
+		Var:    varName,
+		Attr:   "var",
+		Func:   buildTypeFunc(typ.DgraphName()),
+		Filter: buildNotHasFilter(pred),
+	}
+	if after > 0 {
+		q.Args = map[string]string{"after": fmt.Sprintf("%#x", after)}
+	}
+	return q
+}
+
+// buildNotHasFilter builds the filter NOT (has(pred)).
+func buildNotHasFilter(pred string) *gql.FilterTree {
+	return &gql.FilterTree{
+		Op: "not",
+		Child: []*gql.FilterTree{{
+			Func: &gql.Function{Name: "has", Args: []gql.Arg{{Value: pred}}},
+		}},
+	}
+}
+
+// orderFieldName returns the name of the first field in the order argument of field.
+func orderFieldName(field *schema.Field) string {
+	order, _ := field.ArgValue("order").(map[string]interface{})
+	if asc, ok := order["asc"].(string); ok {
+		return asc
+	}
+	desc, _ := order["desc"].(string)
+	return desc
+}
+
 func addPagination(q *gql.GraphQuery, field *schema.Field) {
 	q.Args = make(map[string]string)
 
@@ -1240,6 +1851,21 @@ func buildFilter(typ *schema.Type, filter map[string]interface{}) *gql.FilterTre
 					vals := val.(map[string]interface{})
 					args = append(args, gql.Arg{Value: schema.MaybeQuoteArg(fn, vals["min"])},
 						gql.Arg{Value: schema.MaybeQuoteArg(fn, vals["max"])})
+				case "fuzzy":
+					// name: { fuzzy: { text: "vitalik", distance: 2 }} -> match(name, "vitalik", 2)
+					// The distance is the Levenshtein distance, and it's 1 if it isn't given.
+					fuzzy := val.(map[string]interface{})
+					distance := interface{}(1)
+					if d, ok := fuzzy["distance"]; ok && d != nil {
+						distance = d
+					}
+					fn = "match"
+					args = append(args, gql.Arg{Value: schema.MaybeQuoteArg(fn, fuzzy["text"])},
+						gql.Arg{Value: fmt.Sprintf("%v", distance)})
+				case "startsWith":
+					// symbol: { startsWith: "ET" } -> starts_with(symbol, "ET")
+					fn = "starts_with"
+					args = append(args, gql.Arg{Value: schema.MaybeQuoteArg(fn, val)})
 				case "near":
 					// For Geo type we have `near` filter which is written as follows:
 					// { near: { distance: 33.33, coordinate: { latitude: 11.11, longitude: 22.22 } } }
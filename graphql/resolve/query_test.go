@@ -23,12 +23,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	_ "github.com/outcaste-io/gqlparser/v2/validator/rules" // make gql validator init() all rules
 	"github.com/outcaste-io/outserv/graphql/dgraph"
 	"github.com/outcaste-io/outserv/graphql/schema"
 	"github.com/outcaste-io/outserv/graphql/test"
 	"github.com/outcaste-io/outserv/testutil"
+	"github.com/outcaste-io/outserv/tok"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -167,3 +169,46 @@ func TestCustomHTTPQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestHistogramBucketStart(t *testing.T) {
+	dt, err := time.Parse(time.RFC3339, "2022-05-17T13:45:00Z")
+	require.NoError(t, err)
+	hour, ok := tok.GetTokenizer("hour")
+	require.True(t, ok)
+	tokens, err := tok.BuildTokens(dt, hour)
+	require.NoError(t, err)
+
+	// Hour tokens can be rolled up into any of the intervals.
+	expected := map[string]string{
+		"hour":  "2022-05-17T13:00:00Z",
+		"day":   "2022-05-17T00:00:00Z",
+		"month": "2022-05-01T00:00:00Z",
+		"year":  "2022-01-01T00:00:00Z",
+	}
+	for interval, exp := range expected {
+		bucketStart, err := histogramBucketStart("DateTime", interval)
+		require.NoError(t, err)
+		start, err := bucketStart(tokens[0])
+		require.NoError(t, err)
+		require.Equal(t, exp, start, interval)
+	}
+	_, err = histogramBucketStart("DateTime", "week")
+	require.Error(t, err)
+
+	intTok, ok := tok.GetTokenizer("int")
+	require.True(t, ok)
+	bucketStart, err := histogramBucketStart("Int64", "100")
+	require.NoError(t, err)
+	for val, exp := range map[int64]string{0: "0", 99: "0", 100: "100", 250: "200",
+		-1: "-100", -100: "-100", -101: "-200"} {
+		tokens, err := tok.BuildTokens(val, intTok)
+		require.NoError(t, err)
+		start, err := bucketStart(tokens[0])
+		require.NoError(t, err)
+		require.Equal(t, exp, start, "value %d", val)
+	}
+	for _, interval := range []string{"0", "-10", "day"} {
+		_, err = histogramBucketStart("Int", interval)
+		require.Error(t, err)
+	}
+}
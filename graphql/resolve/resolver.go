@@ -10,10 +10,13 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/gql"
 	"github.com/outcaste-io/outserv/graphql/api"
+	"github.com/outcaste-io/outserv/graphql/dgraph"
 	"github.com/outcaste-io/outserv/protos/pb"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/pkg/errors"
@@ -45,6 +48,9 @@ const (
 type RequestResolver struct {
 	schema    *schema.Schema
 	resolvers *ResolverFactory
+	// id tells apart the responses cached by this resolver from the ones cached by the resolvers
+	// of the earlier schemas.
+	id uint64
 }
 
 // A ResolverFactory is the main implementation of ResolverFactory.  It stores a
@@ -58,6 +64,10 @@ type ResolverFactory struct {
 
 	queryMiddlewareConfig    map[string]QueryMiddlewares
 	mutationMiddlewareConfig map[string]MutationMiddlewares
+	// queryMiddlewares and mutationMiddlewares apply to all the queries and mutations, before
+	// the ones configured for them by name.
+	queryMiddlewares    QueryMiddlewares
+	mutationMiddlewares MutationMiddlewares
 
 	// returned if the factory gets asked for resolver for a field that it doesn't
 	// know about.
@@ -183,6 +193,12 @@ func (rf *ResolverFactory) WithConventionResolvers(
 		})
 	}
 
+	for _, q := range s.Queries(schema.HistogramQuery) {
+		rf.WithQueryResolver(q, func(q *schema.Field) QueryResolver {
+			return NewHistogramQueryResolver(fns.Qrw, fns.Ex)
+		})
+	}
+
 	for _, q := range s.Queries(schema.EntitiesQuery) {
 		rf.WithQueryResolver(q, func(q *schema.Field) QueryResolver {
 			return NewEntitiesQueryResolver(fns.Qrw, fns.Ex)
@@ -220,6 +236,12 @@ func (rf *ResolverFactory) WithConventionResolvers(
 		})
 	}
 
+	for _, m := range s.Mutations(schema.RollbackMutation) {
+		rf.WithMutationResolver(m, func(m *schema.Field) MutationResolver {
+			return NewDgraphResolver()
+		})
+	}
+
 	for _, m := range s.Mutations(schema.HTTPMutation) {
 		rf.WithMutationResolver(m, func(m *schema.Field) MutationResolver {
 			return NewHTTPMutationResolver(nil)
@@ -245,6 +267,15 @@ func (rf *ResolverFactory) WithMutationMiddlewareConfig(
 	return rf
 }
 
+// WithMiddlewares applies the given middlewares to all the queries and mutations, before the
+// ones configured for them via WithQueryMiddlewareConfig and WithMutationMiddlewareConfig.
+func (rf *ResolverFactory) WithMiddlewares(
+	qmws QueryMiddlewares, mmws MutationMiddlewares) *ResolverFactory {
+	rf.queryMiddlewares = qmws
+	rf.mutationMiddlewares = mmws
+	return rf
+}
+
 // NewResolverFactory returns a ResolverFactory that resolves requests via
 // query/mutation rewriting and execution through Dgraph.  If the factory gets asked
 // to resolve a query/mutation it doesn't know how to rewrite, it uses
@@ -366,7 +397,8 @@ func noopCompletion(ctx context.Context, resolved *Resolved) {}
 func (rf *ResolverFactory) queryResolverFor(query *schema.Field) QueryResolver {
 	rf.RLock()
 	defer rf.RUnlock()
-	mws := rf.queryMiddlewareConfig[query.Name()]
+	mws := append(append(QueryMiddlewares{}, rf.queryMiddlewares...),
+		rf.queryMiddlewareConfig[query.Name()]...)
 	if resolver, ok := rf.queryResolvers[query.Name()]; ok {
 		return mws.Then(resolver(query))
 	}
@@ -376,7 +408,8 @@ func (rf *ResolverFactory) queryResolverFor(query *schema.Field) QueryResolver {
 func (rf *ResolverFactory) mutationResolverFor(mutation *schema.Field) MutationResolver {
 	rf.RLock()
 	defer rf.RUnlock()
-	mws := rf.mutationMiddlewareConfig[mutation.Name()]
+	mws := append(append(MutationMiddlewares{}, rf.mutationMiddlewares...),
+		rf.mutationMiddlewareConfig[mutation.Name()]...)
 	if resolver, ok := rf.mutationResolvers[mutation.Name()]; ok {
 		return mws.Then(resolver(mutation))
 	}
@@ -388,6 +421,7 @@ func New(s *schema.Schema, resolverFactory *ResolverFactory) *RequestResolver {
 	return &RequestResolver{
 		schema:    s,
 		resolvers: resolverFactory,
+		id:        atomic.AddUint64(&resolverIDs, 1),
 	}
 }
 
@@ -499,7 +533,13 @@ func (r *RequestResolver) Resolve(ctx context.Context, gqlReq *schema.Request) (
 			resp.Header.Set(schema.CacheControlHeader, op.CacheControl())
 			resp.Header.Set("Vary", "Accept-Encoding")
 		}
+		data, entry := r.fromCache(ctx, gqlReq, op)
+		if data != nil {
+			x.Check2(resp.Data.Write(data))
+			return resp
+		}
 		resolveQueries()
+		r.toCache(entry, resp)
 	case op.IsMutation():
 		// A mutation operation can contain any number of mutation fields.  Those should be executed
 		// serially.
@@ -558,6 +598,111 @@ func (r *RequestResolver) ValidateSubscription(req *schema.Request) error {
 	return nil
 }
 
+// SubscriptionPredicates returns the predicates read by the given subscription, as found in the
+// DQL queries it gets rewritten to. ok is false if they can't be determined, in which case the
+// subscription needs to be polled.
+func (r *RequestResolver) SubscriptionPredicates(ctx context.Context,
+	req *schema.Request) (preds []string, ok bool) {
+	op, err := r.schema.Operation(req)
+	if err != nil || !op.IsSubscription() {
+		return nil, false
+	}
+	return operationPredicates(ctx, op)
+}
+
+// operationPredicates returns the predicates read by the queries of the operation op, as found
+// in the DQL queries they get rewritten to. ok is false if they can't be determined.
+func operationPredicates(ctx context.Context, op *schema.Operation) (preds []string, ok bool) {
+	seen := make(map[string]struct{})
+	rewriter := NewQueryRewriter()
+	for _, q := range op.Queries() {
+		switch q.QueryType() {
+		case schema.GetQuery, schema.FilterQuery, schema.AggregateQuery:
+		default:
+			return nil, false
+		}
+		dgQueries, err := rewriter.Rewrite(ctx, q)
+		if err != nil {
+			return nil, false
+		}
+		for _, dgQuery := range dgQueries {
+			collectPredicates(dgQuery, seen)
+		}
+	}
+
+	for pred := range seen {
+		preds = append(preds, pred)
+	}
+	sort.Strings(preds)
+	return preds, true
+}
+
+// collectPredicates adds the predicates read by the DQL query block q, and its children, to preds.
+// The attribute of q itself is the name of the block, and isn't a predicate.
+func collectPredicates(q *gql.GraphQuery, preds map[string]struct{}) {
+	add := func(attr string) {
+		attr = strings.TrimPrefix(attr, "~")
+		switch attr {
+		case "", "uid", "val", "count":
+			return
+		}
+		preds[attr] = struct{}{}
+	}
+	addFunc := func(fn *gql.Function) {
+		if fn == nil {
+			return
+		}
+		if fn.Name == "type" {
+			add("dgraph.type")
+		}
+		add(fn.Attr)
+		// The functions built by the rewriter leave Attr empty, and take their predicate as the
+		// first argument instead, unless they're applied to a value variable.
+		if fn.Attr == "" && !fn.IsValueVar && fn.Name != "uid" && fn.Name != "type" &&
+			len(fn.Args) > 0 {
+			add(fn.Args[0].Value)
+		}
+		if fn.IsSearch() {
+			for _, pred := range fn.SearchPredicates() {
+				add(pred)
+			}
+		}
+	}
+	var addFilter func(ft *gql.FilterTree)
+	addFilter = func(ft *gql.FilterTree) {
+		if ft == nil {
+			return
+		}
+		addFunc(ft.Func)
+		for _, child := range ft.Child {
+			addFilter(child)
+		}
+	}
+
+	addFunc(q.Func)
+	addFilter(q.Filter)
+	for _, order := range q.Order {
+		// Like the relevance, an order can be on a value variable instead of a predicate.
+		if !dgraph.IsValueVar(order.Attr, q) {
+			add(order.Attr)
+		}
+	}
+	for _, gb := range q.GroupbyAttrs {
+		add(gb.Attr)
+	}
+	for _, child := range q.Children {
+		attr := child.Attr
+		if q.IsGroupby {
+			// Aggregates within @groupby are applied directly to predicates, like max(pred).
+			if i := strings.IndexByte(attr, '('); i >= 0 {
+				attr = strings.TrimSuffix(attr[i+1:], ")")
+			}
+		}
+		add(attr)
+		collectPredicates(child, preds)
+	}
+}
+
 func (r *RequestResolver) Schema() *schema.Schema {
 	return r.schema
 }
@@ -17,6 +17,7 @@
 package resolve
 
 import (
+	"context"
 	"testing"
 
 	"github.com/outcaste-io/outserv/graphql/schema"
@@ -489,3 +490,32 @@ func TestResponseOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectPredicates(t *testing.T) {
+	gqlSchema := test.LoadSchemaFromFile(t, "schema.graphql")
+	op, err := gqlSchema.Operation(&schema.Request{
+		Query: `query {
+			queryAuthor(filter: { name: { eq: "A.N. Author" } }, order: { asc: dob }) {
+				reputation
+				posts { title }
+			}
+		}`,
+	})
+	require.NoError(t, err)
+
+	dgQueries, err := NewQueryRewriter().Rewrite(context.Background(), test.GetQuery(t, op))
+	require.NoError(t, err)
+
+	preds := make(map[string]struct{})
+	for _, dgQuery := range dgQueries {
+		collectPredicates(dgQuery, preds)
+	}
+	require.Equal(t, map[string]struct{}{
+		"dgraph.type":       {},
+		"Author.name":       {},
+		"Author.dob":        {},
+		"Author.posts":      {},
+		"Author.reputation": {},
+		"Post.title":        {},
+	}, preds)
+}
@@ -96,7 +96,7 @@ func sendWebhookEvent(ctx context.Context, m *schema.Field, commitTs uint64, roo
 			SetPatch:    inp["set"],
 			RemovePatch: inp["remove"],
 		}
-	case schema.DeleteMutation:
+	case schema.DeleteMutation, schema.RollbackMutation:
 		payload.Event.Delete = &deleteEvent{RootUIDs: rootUIDs}
 	}
 
@@ -0,0 +1,92 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CursorUidAlias and CursorValueAlias are the aliases under which connection queries fetch
+	// the uid and the order value of every node, to build the cursor for it.
+	CursorUidAlias   = "dgraph.cursor.uid"
+	CursorValueAlias = "dgraph.cursor.value"
+)
+
+// Cursor is the decoded form of the opaque cursors handed out by the connection queries, and
+// accepted by the after and before arguments of query<Type> and query<Type>Connection.
+//
+// A cursor holds the uid of the node it points to, and the value of the order field of that node,
+// if the query was ordered. Together they identify the position of the node in the result, even
+// if other nodes get added or removed in between two page fetches. A node without a value for the
+// order field gets a null value in its cursor.
+type Cursor struct {
+	UID uint64
+	// Value is the value of the order field for the node, as decoded from JSON. Numbers are kept
+	// as json.Number so that Int64 values don't lose precision. It is nil if the query had no
+	// order, or if the node has no value for the order field.
+	Value interface{}
+	// Null is set if the query was ordered, and the node has no value for the order field.
+	Null bool
+}
+
+// EncodeCursor builds an opaque cursor from the JSON encoded uid (e.g. "0x1a") and the JSON
+// encoded value of the order field. val should be nil if the query had no order, and null if the
+// node has no value for the order field.
+func EncodeCursor(uid, val []byte) string {
+	var buf bytes.Buffer
+	buf.WriteRune('[')
+	buf.Write(uid)
+	if val != nil {
+		buf.WriteRune(',')
+		buf.Write(val)
+	}
+	buf.WriteRune(']')
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+// DecodeCursor parses a cursor built by EncodeCursor.
+func DecodeCursor(cursor string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Errorf("invalid cursor %q", cursor)
+	}
+
+	var parts []interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&parts); err != nil || len(parts) == 0 || len(parts) > 2 {
+		return nil, errors.Errorf("invalid cursor %q", cursor)
+	}
+
+	uidStr, ok := parts[0].(string)
+	if !ok {
+		return nil, errors.Errorf("invalid cursor %q", cursor)
+	}
+	uid, err := strconv.ParseUint(uidStr, 0, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid cursor %q", cursor)
+	}
+
+	c := &Cursor{UID: uid}
+	if len(parts) == 2 {
+		c.Value = parts[1]
+		c.Null = parts[1] == nil
+	}
+	return c, nil
+}
+
+// CursorArgValue returns the decoded cursor given as the argument name (after or before) of f, or
+// nil if the argument wasn't given.
+func (f *Field) CursorArgValue(name string) (*Cursor, error) {
+	arg, ok := f.ArgValue(name).(string)
+	if !ok {
+		return nil, nil
+	}
+	return DecodeCursor(arg)
+}
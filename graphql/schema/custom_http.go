@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -73,14 +72,9 @@ func (fconf *FieldHTTPConfig) MakeAndDecodeHTTPRequest(client *http.Client, url
 		}
 	}
 
-	// Make the request to external HTTP endpoint using the URL and body
-	resp, err := MakeHttpRequest(client, fconf.Method, url, fconf.ForwardHeaders, b)
-	if err != nil {
-		return nil, nil, x.GqlErrorList{externalRequestError(err, field)}
-	}
-
-	defer resp.Body.Close()
-	b, err = ioutil.ReadAll(resp.Body)
+	// Make the request to external HTTP endpoint using the URL and body. Identical requests
+	// in flight, or cached, share their response.
+	statusCode, b, err := fconf.loader.do(client, fconf.Method, url, fconf.ForwardHeaders, b)
 	if err != nil {
 		return nil, nil, x.GqlErrorList{externalRequestError(err, field)}
 	}
@@ -104,7 +98,7 @@ func (fconf *FieldHTTPConfig) MakeAndDecodeHTTPRequest(client *http.Client, url
 		}
 	} else {
 		// this was a REST request
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if statusCode >= 200 && statusCode < 300 {
 			// if this was a successful request, lets try to unmarshal the response
 			if err = Unmarshal(b, &response); err != nil {
 				return nil, nil, x.GqlErrorList{jsonUnmarshalError(err, field)}
@@ -114,7 +108,7 @@ func (fconf *FieldHTTPConfig) MakeAndDecodeHTTPRequest(client *http.Client, url
 			// if we get unsuccessful response from the REST api, lets try to see if
 			// it sent any errors in the form expected for GraphQL errors.
 			if err = Unmarshal(b, &graphqlResp); err != nil {
-				err = fmt.Errorf("unexpected error with: %v", resp.StatusCode)
+				err = fmt.Errorf("unexpected error with: %v", statusCode)
 				return nil, nil, x.GqlErrorList{externalRequestError(err, field)}
 			} else {
 				return nil, nil, graphqlResp.Errors
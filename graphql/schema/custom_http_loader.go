@@ -0,0 +1,161 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCachedResponses bounds the number of responses cached for a @custom field.
+const maxCachedResponses = 10000
+
+// httpLoader makes the HTTP requests for a @custom field. Identical requests made while one of
+// them is in flight are coalesced into that one, and their responses are cached for cacheMaxAge,
+// if that's set. With maxConcurrency, at most that many requests are made at once.
+//
+// The requests for the fields of Mutation are neither coalesced nor cached, as they could have
+// side effects.
+type httpLoader struct {
+	reads  bool
+	maxAge time.Duration
+	// sem is nil if the number of requests isn't bounded.
+	sem chan struct{}
+
+	sync.Mutex
+	inflight map[string]*httpCall
+	cache    map[string]*httpCall
+}
+
+// httpCall is a request made by an httpLoader. Its response is set once done is closed.
+type httpCall struct {
+	done   chan struct{}
+	status int
+	body   []byte
+	err    error
+	expiry time.Time
+}
+
+// loaderFor returns the httpLoader of the @custom field typ.field in the schema. The schema is
+// per namespace, and is replaced on every update, so its loaders are never shared across
+// namespaces and go away along with the schema.
+func (s *Schema) loaderFor(typ, field string, maxAge time.Duration,
+	maxConcurrency int) *httpLoader {
+
+	key := fmt.Sprintf("%s.%s|%s|%d", typ, field, maxAge, maxConcurrency)
+	if l, ok := s.httpLoaders.Load(key); ok {
+		return l.(*httpLoader)
+	}
+	l := &httpLoader{
+		reads:    typ != "Mutation",
+		maxAge:   maxAge,
+		inflight: make(map[string]*httpCall),
+		cache:    make(map[string]*httpCall),
+	}
+	if maxConcurrency > 0 {
+		l.sem = make(chan struct{}, maxConcurrency)
+	}
+	actual, _ := s.httpLoaders.LoadOrStore(key, l)
+	return actual.(*httpLoader)
+}
+
+// do sends the HTTP request given by method, url, header and body, unless an identical one is
+// in flight or has its response cached. It returns the status code and the body of the response,
+// which must not be modified as it can be shared by many callers.
+func (l *httpLoader) do(client *http.Client, method, url string, header http.Header,
+	body []byte) (int, []byte, error) {
+
+	if l == nil || !l.reads {
+		return l.fetch(client, method, url, header, body)
+	}
+
+	key := requestKey(method, url, header, body)
+	l.Lock()
+	if c, ok := l.cache[key]; ok {
+		if time.Now().Before(c.expiry) {
+			l.Unlock()
+			return c.status, c.body, nil
+		}
+		delete(l.cache, key)
+	}
+	if c, ok := l.inflight[key]; ok {
+		l.Unlock()
+		<-c.done
+		return c.status, c.body, c.err
+	}
+	c := &httpCall{done: make(chan struct{})}
+	l.inflight[key] = c
+	l.Unlock()
+
+	c.status, c.body, c.err = l.fetch(client, method, url, header, body)
+
+	l.Lock()
+	delete(l.inflight, key)
+	if l.maxAge > 0 && c.err == nil && c.status >= 200 && c.status < 300 {
+		c.expiry = time.Now().Add(l.maxAge)
+		l.cacheLocked(key, c)
+	}
+	l.Unlock()
+	close(c.done)
+	return c.status, c.body, c.err
+}
+
+// fetch sends the HTTP request, waiting for its turn if the requests are bounded.
+func (l *httpLoader) fetch(client *http.Client, method, url string, header http.Header,
+	body []byte) (int, []byte, error) {
+
+	if l != nil && l.sem != nil {
+		l.sem <- struct{}{}
+		defer func() { <-l.sem }()
+	}
+	resp, err := MakeHttpRequest(client, method, url, header, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, b, nil
+}
+
+// cacheLocked caches the call c under key. If the cache is full, the expired responses are
+// evicted first, and then arbitrary ones. l must be locked.
+func (l *httpLoader) cacheLocked(key string, c *httpCall) {
+	if len(l.cache) >= maxCachedResponses {
+		now := time.Now()
+		for k, cached := range l.cache {
+			if !now.Before(cached.expiry) {
+				delete(l.cache, k)
+			}
+		}
+	}
+	for k := range l.cache {
+		if len(l.cache) < maxCachedResponses {
+			break
+		}
+		delete(l.cache, k)
+	}
+	l.cache[key] = c
+}
+
+// requestKey identifies an HTTP request by its method, url, headers and body. The headers
+// carry the ones forwarded from the GraphQL request, so the responses aren't shared across
+// requests which forward different headers.
+func requestKey(method, url string, header http.Header, body []byte) string {
+	var buf bytes.Buffer
+	buf.WriteString(method)
+	buf.WriteByte(0)
+	buf.WriteString(url)
+	buf.WriteByte(0)
+	// The headers are written in the order of their keys.
+	_ = header.Write(&buf)
+	buf.WriteByte(0)
+	buf.Write(body)
+	return buf.String()
+}
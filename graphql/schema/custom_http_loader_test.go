@@ -0,0 +1,94 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpLoader(t *testing.T) {
+	var hits, inflight, maxInflight int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+				break
+			}
+		}
+		<-release
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	run := func(l *httpLoader, paths []string) []string {
+		res := make([]string, len(paths))
+		var wg sync.WaitGroup
+		for i, path := range paths {
+			wg.Add(1)
+			go func(i int, path string) {
+				defer wg.Done()
+				status, b, err := l.do(nil, http.MethodGet, srv.URL+path, header, nil)
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, status)
+				res[i] = string(b)
+			}(i, path)
+		}
+		// Let the requests pile up before any of them gets a response.
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		release = make(chan struct{})
+		return res
+	}
+
+	paths := []string{"/a", "/b", "/a", "/c", "/a", "/b"}
+
+	// The identical requests in flight are coalesced, and at most 2 are made at once.
+	sch := &Schema{}
+	l := sch.loaderFor("Account", "price", 0, 2)
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	require.Equal(t, int32(2), atomic.LoadInt32(&maxInflight))
+	// Nothing is cached without cacheMaxAge.
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(6), atomic.LoadInt32(&hits))
+
+	// With cacheMaxAge, the responses are cached.
+	atomic.StoreInt32(&hits, 0)
+	l = sch.loaderFor("Account", "price", time.Minute, 0)
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	// Requests with different headers don't share their responses.
+	header.Set("Authorization", "token")
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(6), atomic.LoadInt32(&hits))
+
+	// A new schema, like the one of another namespace or an update, doesn't share the cache.
+	require.Same(t, l, sch.loaderFor("Account", "price", time.Minute, 0))
+	atomic.StoreInt32(&hits, 0)
+	l = (&Schema{}).loaderFor("Account", "price", time.Minute, 0)
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	// The requests of the fields of Mutation are always made.
+	atomic.StoreInt32(&hits, 0)
+	l = sch.loaderFor("Mutation", "pay", time.Minute, 0)
+	require.Equal(t, paths, run(l, paths))
+	require.Equal(t, int32(len(paths)), atomic.LoadInt32(&hits))
+}
@@ -49,6 +49,7 @@ const (
 	lambdaDirective         = "lambda"
 	lambdaOnMutateDirective = "lambdaOnMutate"
 	defaultDirective        = "default"
+	embeddingDirective      = "embedding"
 
 	generateDirective       = "generate"
 	generateQueryArg        = "query"
@@ -56,10 +57,14 @@ const (
 	generateQueryField      = "query"
 	generatePasswordField   = "password"
 	generateAggregateField  = "aggregate"
+	generateConnectionField = "connection"
+	generateGroupByField    = "groupBy"
+	generateHistogramField  = "histogram"
 	generateMutationArg     = "mutation"
 	generateAddField        = "add"
 	generateUpdateField     = "update"
 	generateDeleteField     = "delete"
+	generateRollbackField   = "rollback"
 	generateSubscriptionArg = "subscription"
 
 	cascadeDirective = "cascade"
@@ -68,6 +73,35 @@ const (
 	cacheControlDirective = "cacheControl"
 	CacheControlHeader    = "Cache-Control"
 
+	// types and fields generated for Relay style connections
+	connectionSuffix = "Connection"
+	edgeSuffix       = "Edge"
+	pageInfoType     = "PageInfo"
+	edgesField       = "edges"
+	pageInfoField    = "pageInfo"
+	cursorField      = "cursor"
+	nodeField        = "node"
+
+	// types, fields and arguments generated for groupBy on aggregate queries
+	aggregateGroupSuffix = "AggregateGroup"
+	groupableSuffix      = "Groupable"
+	groupsField          = "groups"
+	groupByArg           = "groupBy"
+
+	// types, fields and arguments generated for histogram queries
+	histogramBucketSuffix = "HistogramBucket"
+	histogrammableSuffix  = "Histogrammable"
+	bucketField           = "bucket"
+	histogramFieldArg     = "field"
+	histogramIntervalArg  = "interval"
+
+	// types and arguments generated for similarity queries
+	embeddingSuffix    = "Embedding"
+	similarByArg       = "by"
+	similarVectorArg   = "vector"
+	similarTopKArg     = "topK"
+	similarDistanceArg = "distance"
+
 	// Directives to support Apollo Federation
 	apolloKeyDirective      = "key"
 	apolloKeyArg            = "fields"
@@ -77,15 +111,17 @@ const (
 	apolloProvidesDirective = "provides"
 
 	// custom directive args and fields
-	dqlArg      = "dql"
-	httpArg     = "http"
-	httpUrl     = "url"
-	httpMethod  = "method"
-	httpBody    = "body"
-	httpGraphql = "graphql"
-	mode        = "mode"
-	BATCH       = "BATCH"
-	SINGLE      = "SINGLE"
+	dqlArg             = "dql"
+	httpArg            = "http"
+	httpUrl            = "url"
+	httpMethod         = "method"
+	httpBody           = "body"
+	httpGraphql        = "graphql"
+	mode               = "mode"
+	httpCacheMaxAge    = "cacheMaxAge"
+	httpMaxConcurrency = "maxConcurrency"
+	BATCH              = "BATCH"
+	SINGLE             = "SINGLE"
 
 	// geo type names and fields
 	Point        = "Point"
@@ -103,6 +139,16 @@ const (
 
 	Typename = "__typename"
 
+	// The fields and the order added for fulltext search. See addSearchFields.
+	ScoreField        = "_score"
+	HighlightField    = "_highlight"
+	HighlightFieldArg = "field"
+	RelevanceOrder    = "relevance"
+
+	// DistanceField is the distance of a node found by a similarity query from the vector
+	// searched for. See addSimilarQuery.
+	DistanceField = "_distance"
+
 	// schemaExtras is everything that gets added to an input schema to make it
 	// GraphQL valid and for the completion algorithm to use to build in search
 	// capability into the schema.
@@ -162,6 +208,11 @@ input StringRange{
 	max: String!
 }
 
+input FuzzyFilter {
+	text: String!
+	distance: Int
+}
+
 enum DgraphIndex {
 	int
 	int64
@@ -169,6 +220,8 @@ enum DgraphIndex {
 	bool
 	hash
 	exact
+	hash_ci
+	exact_ci
 	term
 	fulltext
 	trigram
@@ -210,6 +263,8 @@ input CustomHTTP {
 	secretHeaders: [String!]
 	introspectionHeaders: [String!]
 	skipIntrospection: Boolean
+	cacheMaxAge: Int
+	maxConcurrency: Int
 }
 
 input DgraphDefault {
@@ -286,12 +341,16 @@ input GenerateQueryParams {
 	query: Boolean
 	password: Boolean
 	aggregate: Boolean
+	connection: Boolean
+	groupBy: Boolean
+	histogram: Boolean
 }
 
 input GenerateMutationParams {
 	add: Boolean
 	update: Boolean
 	delete: Boolean
+	rollback: Boolean
 }
 `
 	directiveDefs = `
@@ -314,6 +373,7 @@ directive @remoteResponse(name: String) on FIELD_DEFINITION
 directive @cascade(fields: [String]) on FIELD
 directive @lambda on FIELD_DEFINITION
 directive @lambdaOnMutate(add: Boolean, update: Boolean, delete: Boolean) on OBJECT | INTERFACE
+directive @embedding on FIELD_DEFINITION
 directive @cacheControl(maxAge: Int!) on QUERY
 directive @generate(
 	query: GenerateQueryParams,
@@ -338,6 +398,7 @@ directive @remote on OBJECT | INTERFACE | UNION | INPUT_OBJECT | ENUM
 directive @remoteResponse(name: String) on FIELD_DEFINITION
 directive @lambda on FIELD_DEFINITION
 directive @lambdaOnMutate(add: Boolean, update: Boolean, delete: Boolean) on OBJECT | INTERFACE
+directive @embedding on FIELD_DEFINITION
 `
 	filterInputs = `
 input IntFilter {
@@ -397,6 +458,7 @@ input StringTermFilter {
 
 input StringRegExpFilter {
 	regexp: String
+	fuzzy: FuzzyFilter
 }
 
 input StringFullTextFilter {
@@ -404,6 +466,16 @@ input StringFullTextFilter {
 	anyoftext: String
 }
 
+enum OrderDirection {
+	ASC
+	DESC
+}
+
+enum VectorDistance {
+	EUCLIDEAN
+	COSINE
+}
+
 input StringExactFilter {
 	eq: String
 	in: [String]
@@ -412,6 +484,7 @@ input StringExactFilter {
 	ge: String
 	gt: String
 	between: StringRange
+	startsWith: String
 }
 
 input StringHashFilter {
@@ -481,6 +554,8 @@ var supportedSearches = map[string]searchTypeIndex{
 	"bool":         {"Boolean", "bool"},
 	"hash":         {"String", "hash"},
 	"exact":        {"String", "exact"},
+	"hash_ci":      {"String", "hash_ci"},
+	"exact_ci":     {"String", "exact_ci"},
 	"term":         {"String", "term"},
 	"fulltext":     {"String", "fulltext"},
 	"trigram":      {"String", "trigram"},
@@ -567,6 +642,8 @@ var builtInFilters = map[string]string{
 	"fulltext":     "StringFullTextFilter",
 	"exact":        "StringExactFilter",
 	"hash":         "StringHashFilter",
+	"exact_ci":     "StringExactFilter",
+	"hash_ci":      "StringHashFilter",
 	"point":        "PointGeoFilter",
 	"polygon":      "PolygonGeoFilter",
 	"multiPolygon": "PolygonGeoFilter",
@@ -619,6 +696,7 @@ var directiveValidators = map[string]directiveValidator{
 	apolloRequiresDirective: apolloRequiresValidation,
 	apolloProvidesDirective: apolloProvidesValidation,
 	remoteResponseDirective: remoteResponseValidation,
+	embeddingDirective:      embeddingValidation,
 }
 
 // directiveLocationMap stores the directives and their locations for the ones which can be
@@ -644,30 +722,39 @@ var directiveLocationMap = map[string]map[ast.DefinitionKind]bool{
 	apolloProvidesDirective: nil,
 	remoteResponseDirective: nil,
 	cascadeDirective:        nil,
+	embeddingDirective:      nil,
 }
 
 // Struct to store parameters of @generate directive
 type GenerateDirectiveParams struct {
-	generateGetQuery       bool
-	generateFilterQuery    bool
-	generatePasswordQuery  bool
-	generateAggregateQuery bool
-	generateAddMutation    bool
-	generateUpdateMutation bool
-	generateDeleteMutation bool
-	generateSubscription   bool
+	generateGetQuery         bool
+	generateFilterQuery      bool
+	generatePasswordQuery    bool
+	generateAggregateQuery   bool
+	generateConnectionQuery  bool
+	generateGroupByQuery     bool
+	generateHistogramQuery   bool
+	generateAddMutation      bool
+	generateUpdateMutation   bool
+	generateDeleteMutation   bool
+	generateRollbackMutation bool
+	generateSubscription     bool
 }
 
 func parseGenerateDirectiveParams(defn *ast.Definition) *GenerateDirectiveParams {
 	ret := &GenerateDirectiveParams{
-		generateGetQuery:       true,
-		generateFilterQuery:    true,
-		generatePasswordQuery:  true,
-		generateAggregateQuery: true,
-		generateAddMutation:    true,
-		generateUpdateMutation: true,
-		generateDeleteMutation: true,
-		generateSubscription:   false,
+		generateGetQuery:         true,
+		generateFilterQuery:      true,
+		generatePasswordQuery:    true,
+		generateAggregateQuery:   true,
+		generateConnectionQuery:  false,
+		generateGroupByQuery:     false,
+		generateHistogramQuery:   false,
+		generateAddMutation:      true,
+		generateUpdateMutation:   true,
+		generateDeleteMutation:   true,
+		generateRollbackMutation: false,
+		generateSubscription:     false,
 	}
 
 	if dir := defn.Directives.ForName(generateDirective); dir != nil {
@@ -693,6 +780,21 @@ func parseGenerateDirectiveParams(defn *ast.Definition) *GenerateDirectiveParams
 					ret.generateAggregateQuery = aggregateFieldVal.(bool)
 				}
 			}
+			if fld := queryArg.Value.Children.ForName(generateConnectionField); fld != nil {
+				if val, err := fld.Value(nil); err == nil {
+					ret.generateConnectionQuery = val.(bool)
+				}
+			}
+			if fld := queryArg.Value.Children.ForName(generateGroupByField); fld != nil {
+				if val, err := fld.Value(nil); err == nil {
+					ret.generateGroupByQuery = val.(bool)
+				}
+			}
+			if fld := queryArg.Value.Children.ForName(generateHistogramField); fld != nil {
+				if val, err := fld.Value(nil); err == nil {
+					ret.generateHistogramQuery = val.(bool)
+				}
+			}
 		}
 
 		if mutationArg := dir.Arguments.ForName(generateMutationArg); mutationArg != nil {
@@ -711,6 +813,11 @@ func parseGenerateDirectiveParams(defn *ast.Definition) *GenerateDirectiveParams
 					ret.generateDeleteMutation = deleteFieldVal.(bool)
 				}
 			}
+			if fld := mutationArg.Value.Children.ForName(generateRollbackField); fld != nil {
+				if val, err := fld.Value(nil); err == nil {
+					ret.generateRollbackMutation = val.(bool)
+				}
+			}
 		}
 
 		if subscriptionArg := dir.Arguments.ForName(generateSubscriptionArg); subscriptionArg != nil {
@@ -1039,7 +1146,7 @@ func completeSchema(sch *ast.Schema, definitions []string, providesFieldsMap map
 			addUpdatePayloadType(sch, defn, providesTypeMap)
 		}
 
-		if params.generateDeleteMutation {
+		if params.generateDeleteMutation || params.generateRollbackMutation {
 			addDeletePayloadType(sch, defn, providesTypeMap)
 		}
 
@@ -1053,6 +1160,9 @@ func completeSchema(sch *ast.Schema, definitions []string, providesFieldsMap map
 			if params.generateDeleteMutation {
 				addDeleteMutation(sch, defn)
 			}
+			if params.generateRollbackMutation {
+				addRollbackMutation(sch, defn)
+			}
 
 		case ast.Object:
 			// types and inputs needed for mutations
@@ -1075,6 +1185,8 @@ func completeSchema(sch *ast.Schema, definitions []string, providesFieldsMap map
 		// We need to call this at last as aggregateFields
 		// should not be part of HasFilter or UpdatePayloadType etc.
 		addAggregateFields(sch, defn)
+		addSearchFields(sch, defn)
+		addDistanceField(sch, defn)
 	}
 }
 
@@ -1407,6 +1519,68 @@ func addAggregateFields(schema *ast.Schema, defn *ast.Definition) {
 	}
 }
 
+// addSearchFields adds the _score and _highlight fields to types with a fulltext field. _score
+// is the BM25 relevance of a node to the alloftext and anyoftext filters it was found by, and
+// _highlight(field) a snippet of the given fulltext field with the matches of those filters
+// marked. Like aggregate fields, they aren't stored, and so must be added last.
+func addSearchFields(schema *ast.Schema, defn *ast.Definition) {
+	if !hasFullText(defn) {
+		return
+	}
+	if defn.Fields.ForName(ScoreField) == nil {
+		defn.Fields = append(defn.Fields, &ast.FieldDefinition{
+			Name: ScoreField,
+			Type: &ast.Type{NamedType: "Float"},
+		})
+	}
+	if defn.Fields.ForName(HighlightField) == nil {
+		defn.Fields = append(defn.Fields, &ast.FieldDefinition{
+			Name: HighlightField,
+			Arguments: ast.ArgumentDefinitionList{
+				{Name: HighlightFieldArg, Type: &ast.Type{NamedType: "String", NonNull: true}},
+			},
+			Type: &ast.Type{NamedType: "String"},
+		})
+	}
+}
+
+// addDistanceField adds the _distance field to types with an @embedding field. It is the
+// distance of a node found by querySimilarT from the vector searched for, and null elsewhere.
+func addDistanceField(schema *ast.Schema, defn *ast.Definition) {
+	if !hasEmbeddings(defn) || defn.Fields.ForName(DistanceField) != nil {
+		return
+	}
+	defn.Fields = append(defn.Fields, &ast.FieldDefinition{
+		Name: DistanceField,
+		Type: &ast.Type{NamedType: "Float"},
+	})
+}
+
+func hasEmbeddings(defn *ast.Definition) bool {
+	return fieldAny(defn.Fields, isEmbedding)
+}
+
+func isEmbedding(fld *ast.FieldDefinition) bool {
+	return fld.Directives.ForName(embeddingDirective) != nil
+}
+
+// hasFullText returns true if defn has a field with a fulltext index.
+func hasFullText(defn *ast.Definition) bool {
+	return fieldAny(defn.Fields, isFullTextField)
+}
+
+func isFullTextField(fld *ast.FieldDefinition) bool {
+	if hasCustomOrLambda(fld) || isMultiLangField(fld, false) {
+		return false
+	}
+	for _, index := range getSearchArgs(fld) {
+		if index == "fulltext" {
+			return true
+		}
+	}
+	return false
+}
+
 func addFilterArgument(schema *ast.Schema, fld *ast.FieldDefinition) {
 	addFilterArgumentForField(schema, fld, fld.Type.Name())
 }
@@ -1481,6 +1655,17 @@ func addPaginationArguments(fld *ast.FieldDefinition) {
 	)
 }
 
+// addCursorArguments adds the after and before arguments used for cursor based
+// pagination. Cursors are opaque strings handed out by the generated connection
+// queries, and are turned into a seek on the order field (plus uid) during query
+// rewriting. So, they are only added to top level queries.
+func addCursorArguments(fld *ast.FieldDefinition) {
+	fld.Arguments = append(fld.Arguments,
+		&ast.ArgumentDefinition{Name: AfterArgName, Type: &ast.Type{NamedType: "String"}},
+		&ast.ArgumentDefinition{Name: BeforeArgName, Type: &ast.Type{NamedType: "String"}},
+	)
+}
+
 // getFilterTypes converts search arguments of a field to graphql filter types.
 func getFilterTypes(schema *ast.Schema, fld *ast.FieldDefinition, filterName string) []string {
 	searchArgs := getSearchArgs(fld)
@@ -1497,6 +1682,10 @@ func getFilterTypes(schema *ast.Schema, fld *ast.FieldDefinition, filterName str
 			var l ast.FieldList
 
 			for _, i := range schema.Types[stringFilterName].Fields {
+				// The prefix of an enum value isn't a value of the enum.
+				if i.Name == "startsWith" {
+					continue
+				}
 				enumTypeName := fld.Type.Name()
 				var typ *ast.Type
 
@@ -1672,6 +1861,43 @@ func isOrderable(fld *ast.FieldDefinition, defn *ast.Definition,
 	return isKeyField(fld, defn) || providesTypeMap[fld.Name]
 }
 
+func hasGroupables(sch *ast.Schema, defn *ast.Definition, providesTypeMap map[string]bool) bool {
+	return fieldAny(defn.Fields, func(fld *ast.FieldDefinition) bool {
+		return isOrderable(fld, defn, providesTypeMap) || isGroupableEdge(sch, fld, defn)
+	})
+}
+
+// Returns true if the nodes of defn can be grouped by the node fld links them to, i.e. by its
+// uid. That's the case for an edge to a single node, as long as it is stored in Dgraph and
+// isn't the reverse of another edge.
+func isGroupableEdge(sch *ast.Schema, fld *ast.FieldDefinition, defn *ast.Definition) bool {
+	// lists have an empty NamedType
+	child := sch.Types[fld.Type.NamedType]
+	if child == nil || (child.Kind != ast.Object && child.Kind != ast.Interface) ||
+		hasExternal(fld) || hasCustomOrLambda(fld) {
+		return false
+	}
+	fname := fieldName(fld, defn.Name)
+	return !strings.HasPrefix(fname, "~") && !strings.HasPrefix(fname, "<~")
+}
+
+func hasHistogrammables(defn *ast.Definition) bool {
+	return fieldAny(defn.Fields, isHistogrammable)
+}
+
+// Returns true if a histogram can be computed over the field from its index. That's the case
+// for DateTime fields, whose index has a token per year, month, day or hour, and for Int and
+// Int64 fields, whose index has a token per value.
+func isHistogrammable(fld *ast.FieldDefinition) bool {
+	switch fld.Type.NamedType {
+	case "DateTime", "Int", "Int64":
+	default:
+		// lists have an empty NamedType
+		return false
+	}
+	return !hasExternal(fld) && !hasCustomOrLambda(fld) && len(getSearchArgs(fld)) > 0
+}
+
 // Returns true if the field is of type which can be summed. Eg: int, int64, bigint, float
 func isSummable(fld *ast.FieldDefinition, defn *ast.Definition, providesTypeMap map[string]bool) bool {
 	if externalAndNonKeyField(fld, defn, providesTypeMap) {
@@ -1713,8 +1939,10 @@ func addHashIfRequired(fld *ast.FieldDefinition, indexes []string) []string {
 	id := fld.Directives.ForName(idDirective)
 	if id != nil {
 		// If @id directive is applied along with @search, we check if the search has hash as an
-		// arg. If it doesn't and there is no exact arg, then we add hash in it.
-		if !x.HasString(indexes, "hash") && !x.HasString(indexes, "exact") {
+		// arg. If it doesn't and there is no exact arg, then we add hash in it. The ids are
+		// case-insensitive if the search is by hash_ci or exact_ci, so hash isn't added then.
+		if !x.HasString(indexes, "hash") && !x.HasString(indexes, "exact") &&
+			!x.HasString(indexes, "hash_ci") && !x.HasString(indexes, "exact_ci") {
 			indexes = append(indexes, "hash")
 		}
 	}
@@ -1802,6 +2030,15 @@ func addTypeOrderable(schema *ast.Schema, defn *ast.Definition, providesTypeMap
 			&ast.FieldDefinition{Name: "then", Type: &ast.Type{NamedType: orderName}},
 		},
 	}
+	// Nodes found by fulltext filters can be ordered by how relevant they are.
+	if hasFullText(defn) {
+		schema.Types[orderName].Fields = append(schema.Types[orderName].Fields[:2],
+			&ast.FieldDefinition{
+				Name: RelevanceOrder,
+				Type: &ast.Type{NamedType: "OrderDirection"},
+			},
+			schema.Types[orderName].Fields[2])
+	}
 
 	order := &ast.Definition{
 		Kind: ast.Enum,
@@ -2035,6 +2272,7 @@ func addFilterQuery(schema *ast.Schema, defn *ast.Definition, providesTypeMap ma
 	addFilterArgument(schema, qry)
 	addOrderArgument(schema, qry, providesTypeMap)
 	addPaginationArguments(qry)
+	addCursorArguments(qry)
 
 	schema.Query.Fields = append(schema.Query.Fields, qry)
 	subs := defn.Directives.ForName(subscriptionDirective)
@@ -2044,6 +2282,82 @@ func addFilterQuery(schema *ast.Schema, defn *ast.Definition, providesTypeMap ma
 
 }
 
+// addConnectionQuery adds a Relay style connection query for defn. For a type T, it generates
+//
+// type TEdge {
+// 	cursor: String!
+// 	node: T
+// }
+//
+// type TConnection {
+// 	edges: [TEdge!]!
+// 	pageInfo: PageInfo!
+// }
+//
+// along with the query
+// queryTConnection(filter: TFilter, order: TOrder, first: Int, after: String,
+//     before: String): TConnection
+//
+// The cursor of an edge is built from the value of the order field and the uid of the node, so
+// fetching the next page is a seek in the index instead of skipping over all the previous pages.
+func addConnectionQuery(schema *ast.Schema, defn *ast.Definition, providesTypeMap map[string]bool) {
+	if schema.Types[pageInfoType] == nil {
+		schema.Types[pageInfoType] = &ast.Definition{
+			Kind: ast.Object,
+			Name: pageInfoType,
+			Fields: ast.FieldList{
+				{Name: "hasNextPage", Type: &ast.Type{NamedType: "Boolean", NonNull: true}},
+				{Name: "hasPreviousPage", Type: &ast.Type{NamedType: "Boolean", NonNull: true}},
+				{Name: "startCursor", Type: &ast.Type{NamedType: "String"}},
+				{Name: "endCursor", Type: &ast.Type{NamedType: "String"}},
+			},
+		}
+	}
+
+	edgeName := defn.Name + edgeSuffix
+	schema.Types[edgeName] = &ast.Definition{
+		Kind: ast.Object,
+		Name: edgeName,
+		Fields: ast.FieldList{
+			{Name: cursorField, Type: &ast.Type{NamedType: "String", NonNull: true}},
+			{Name: nodeField, Type: &ast.Type{NamedType: defn.Name}},
+		},
+	}
+
+	connectionName := defn.Name + connectionSuffix
+	schema.Types[connectionName] = &ast.Definition{
+		Kind: ast.Object,
+		Name: connectionName,
+		Fields: ast.FieldList{
+			{Name: edgesField, Type: &ast.Type{
+				Elem:    &ast.Type{NamedType: edgeName, NonNull: true},
+				NonNull: true,
+			}},
+			{Name: pageInfoField, Type: &ast.Type{NamedType: pageInfoType, NonNull: true}},
+		},
+	}
+
+	qry := &ast.FieldDefinition{
+		Name: "query" + connectionName,
+		Type: &ast.Type{
+			NamedType: connectionName,
+		},
+	}
+	addFilterArgumentForField(schema, qry, defn.Name)
+	if hasOrderables(defn, providesTypeMap) {
+		qry.Arguments = append(qry.Arguments,
+			&ast.ArgumentDefinition{
+				Name: "order",
+				Type: &ast.Type{NamedType: defn.Name + "Order"},
+			})
+	}
+	qry.Arguments = append(qry.Arguments,
+		&ast.ArgumentDefinition{Name: "first", Type: &ast.Type{NamedType: "Int"}})
+	addCursorArguments(qry)
+
+	schema.Query.Fields = append(schema.Query.Fields, qry)
+}
+
 func addAggregationQuery(schema *ast.Schema, defn *ast.Definition, generateSubscription bool) {
 	qry := &ast.FieldDefinition{
 		Name: "aggregate" + defn.Name,
@@ -2061,6 +2375,193 @@ func addAggregationQuery(schema *ast.Schema, defn *ast.Definition, generateSubsc
 
 }
 
+// addAggregationGroupBy lets the aggregate query of a type T group its results by the
+// orderable fields of T, and by the fields linking T to a single node. It adds the enum
+// TGroupable, listing those fields, and the type TAggregateGroup, holding the values of those
+// fields along with the aggregates of TAggregateResult for each group. The nodes linked by an
+// edge are given by their ID in TAggregateGroup. The aggregate query is changed to
+// aggregateT(filter: TFilter, groupBy: [TGroupable!]): TAggregateResult
+// where TAggregateResult now has a field groups: [TAggregateGroup].
+func addAggregationGroupBy(schema *ast.Schema, defn *ast.Definition,
+	providesTypeMap map[string]bool) {
+	if !hasGroupables(schema, defn, providesTypeMap) {
+		return
+	}
+	result := schema.Types[defn.Name+"AggregateResult"]
+	qry := schema.Query.Fields.ForName("aggregate" + defn.Name)
+	if result == nil || qry == nil {
+		return
+	}
+
+	groupableName := defn.Name + groupableSuffix
+	groupable := &ast.Definition{
+		Kind: ast.Enum,
+		Name: groupableName,
+	}
+	groupTypeName := defn.Name + aggregateGroupSuffix
+	var groupFields ast.FieldList
+	for _, fld := range defn.Fields {
+		var typ string
+		switch {
+		case isOrderable(fld, defn, providesTypeMap):
+			typ = fld.Type.NamedType
+		case isGroupableEdge(schema, fld, defn):
+			typ = IDType
+		default:
+			continue
+		}
+		groupable.EnumValues = append(groupable.EnumValues,
+			&ast.EnumValueDefinition{Name: fld.Name})
+		groupFields = append(groupFields, &ast.FieldDefinition{
+			Name: fld.Name,
+			Type: &ast.Type{NamedType: typ},
+		})
+	}
+	for _, fld := range result.Fields {
+		if groupFields.ForName(fld.Name) != nil {
+			// A field named like an aggregate, e.g. scoreMax, shadows that aggregate.
+			continue
+		}
+		groupFields = append(groupFields, &ast.FieldDefinition{
+			Name: fld.Name,
+			Type: fld.Type,
+		})
+	}
+	schema.Types[groupableName] = groupable
+	schema.Types[groupTypeName] = &ast.Definition{
+		Kind:   ast.Object,
+		Name:   groupTypeName,
+		Fields: groupFields,
+	}
+
+	result.Fields = append(result.Fields, &ast.FieldDefinition{
+		Name: groupsField,
+		Type: &ast.Type{Elem: &ast.Type{NamedType: groupTypeName}},
+	})
+	qry.Arguments = append(qry.Arguments, &ast.ArgumentDefinition{
+		Name: groupByArg,
+		Type: &ast.Type{
+			Elem: &ast.Type{NamedType: groupableName, NonNull: true},
+		},
+	})
+}
+
+// addHistogramQuery adds a query which counts the nodes of type T in buckets of the values of
+// one of its DateTime, Int or Int64 fields with @search, directly from the index of that field.
+// It adds the enum THistogrammable, listing those fields, and the type THistogramBucket,
+// holding the start of a bucket along with the count and the sums of the Int, Int64 and Float
+// fields of the nodes in it. The query is
+// histogramT(field: THistogrammable!, interval: String!, filter: TFilter): [THistogramBucket]
+// The interval is one of hour, day, month or year for DateTime fields, and the width of the
+// buckets for Int and Int64 fields.
+func addHistogramQuery(schema *ast.Schema, defn *ast.Definition,
+	providesTypeMap map[string]bool) {
+	if !hasHistogrammables(defn) {
+		return
+	}
+
+	histogrammableName := defn.Name + histogrammableSuffix
+	histogrammable := &ast.Definition{
+		Kind: ast.Enum,
+		Name: histogrammableName,
+	}
+	bucketName := defn.Name + histogramBucketSuffix
+	bucketFields := ast.FieldList{
+		{Name: bucketField, Type: &ast.Type{NamedType: "String"}},
+		{Name: "count", Type: &ast.Type{NamedType: "Int"}},
+	}
+	for _, fld := range defn.Fields {
+		if isHistogrammable(fld) {
+			histogrammable.EnumValues = append(histogrammable.EnumValues,
+				&ast.EnumValueDefinition{Name: fld.Name})
+		}
+		// BigInt isn't summed, as its values can't be summed without loss of precision
+		// in JSON.
+		if isSummable(fld, defn, providesTypeMap) && fld.Type.NamedType != "BigInt" {
+			bucketFields = append(bucketFields, &ast.FieldDefinition{
+				Name: fld.Name + "Sum",
+				Type: &ast.Type{NamedType: fld.Type.NamedType},
+			})
+		}
+	}
+	schema.Types[histogrammableName] = histogrammable
+	schema.Types[bucketName] = &ast.Definition{
+		Kind:   ast.Object,
+		Name:   bucketName,
+		Fields: bucketFields,
+	}
+
+	qry := &ast.FieldDefinition{
+		Name: "histogram" + defn.Name,
+		Type: &ast.Type{Elem: &ast.Type{NamedType: bucketName}},
+		Arguments: ast.ArgumentDefinitionList{
+			{
+				Name: histogramFieldArg,
+				Type: &ast.Type{NamedType: histogrammableName, NonNull: true},
+			},
+			{
+				Name: histogramIntervalArg,
+				Type: &ast.Type{NamedType: "String", NonNull: true},
+			},
+		},
+	}
+	addFilterArgumentForField(schema, qry, defn.Name)
+	schema.Query.Fields = append(schema.Query.Fields, qry)
+}
+
+// addSimilarQuery adds a query which finds the nodes of type T whose @embedding field is the
+// closest to a vector, using the LSH index of the field. It adds the enum TEmbedding, listing
+// those fields. The query is
+// querySimilarT(by: TEmbedding!, vector: [Float!]!, topK: Int, distance: VectorDistance,
+// filter: TFilter): [T]
+// The nodes come ordered from the closest, and the distance is EUCLIDEAN by default.
+func addSimilarQuery(schema *ast.Schema, defn *ast.Definition) {
+	if !hasEmbeddings(defn) {
+		return
+	}
+
+	embeddingName := defn.Name + embeddingSuffix
+	embedding := &ast.Definition{
+		Kind: ast.Enum,
+		Name: embeddingName,
+	}
+	for _, fld := range defn.Fields {
+		if isEmbedding(fld) {
+			embedding.EnumValues = append(embedding.EnumValues,
+				&ast.EnumValueDefinition{Name: fld.Name})
+		}
+	}
+	schema.Types[embeddingName] = embedding
+
+	qry := &ast.FieldDefinition{
+		Name: "querySimilar" + defn.Name,
+		Type: &ast.Type{Elem: &ast.Type{NamedType: defn.Name}},
+		Arguments: ast.ArgumentDefinitionList{
+			{
+				Name: similarByArg,
+				Type: &ast.Type{NamedType: embeddingName, NonNull: true},
+			},
+			{
+				Name: similarVectorArg,
+				Type: &ast.Type{
+					Elem:    &ast.Type{NamedType: "Float", NonNull: true},
+					NonNull: true,
+				},
+			},
+			{
+				Name: similarTopKArg,
+				Type: &ast.Type{NamedType: "Int"},
+			},
+			{
+				Name: similarDistanceArg,
+				Type: &ast.Type{NamedType: "VectorDistance"},
+			},
+		},
+	}
+	addFilterArgumentForField(schema, qry, defn.Name)
+	schema.Query.Fields = append(schema.Query.Fields, qry)
+}
+
 func addPasswordQuery(schema *ast.Schema, defn *ast.Definition, providesTypeMap map[string]bool) {
 	hasIDField := hasID(defn)
 	hasXIDField := hasXID(defn)
@@ -2114,6 +2615,21 @@ func addQueries(schema *ast.Schema, defn *ast.Definition, providesTypeMap map[st
 
 	if params.generateAggregateQuery {
 		addAggregationQuery(schema, defn, params.generateSubscription)
+		if params.generateGroupByQuery {
+			addAggregationGroupBy(schema, defn, providesTypeMap)
+		}
+	}
+
+	if params.generateConnectionQuery {
+		addConnectionQuery(schema, defn, providesTypeMap)
+	}
+
+	if params.generateHistogramQuery {
+		addHistogramQuery(schema, defn, providesTypeMap)
+	}
+
+	if params.generateFilterQuery {
+		addSimilarQuery(schema, defn)
 	}
 }
 
@@ -2196,6 +2712,35 @@ func addDeleteMutation(schema *ast.Schema, defn *ast.Definition) {
 	schema.Mutation.Fields = append(schema.Mutation.Fields, del)
 }
 
+// addRollbackMutation adds a mutation which deletes the nodes with the given @id values,
+// along with the child nodes they own via @hasInverse edges. For a type T with an @id
+// field xid of type String, it generates
+// rollbackT(xid: [String!]): DeleteTPayload
+func addRollbackMutation(schema *ast.Schema, defn *ast.Definition) {
+	if !hasXID(defn) {
+		return
+	}
+
+	rb := &ast.FieldDefinition{
+		Name: "rollback" + defn.Name,
+		Type: &ast.Type{
+			NamedType: "Delete" + defn.Name + "Payload",
+		},
+	}
+	for _, fld := range defn.Fields {
+		if !hasIDDirective(fld) {
+			continue
+		}
+		rb.Arguments = append(rb.Arguments, &ast.ArgumentDefinition{
+			Name: fld.Name,
+			Type: &ast.Type{
+				Elem: &ast.Type{NamedType: fld.Type.Name(), NonNull: true},
+			},
+		})
+	}
+	schema.Mutation.Fields = append(schema.Mutation.Fields, rb)
+}
+
 func addMutations(schema *ast.Schema, defn *ast.Definition, params *GenerateDirectiveParams) {
 	if params.generateAddMutation {
 		addAddMutation(schema, defn)
@@ -2206,6 +2751,9 @@ func addMutations(schema *ast.Schema, defn *ast.Definition, params *GenerateDire
 	if params.generateDeleteMutation {
 		addDeleteMutation(schema, defn)
 	}
+	if params.generateRollbackMutation {
+		addRollbackMutation(schema, defn)
+	}
 }
 
 func createField(schema *ast.Schema, fld *ast.FieldDefinition) *ast.FieldDefinition {
@@ -330,6 +330,28 @@ func typeNameValidation(schema *ast.SchemaDocument) gqlerror.List {
 			forbiddenTypeNames[defName+"Filter"] = true
 			forbiddenTypeNames[defName+"Order"] = true
 			forbiddenTypeNames[defName+"Orderable"] = true
+
+			if hasEmbeddings(defn) {
+				forbiddenTypeNames[defName+embeddingSuffix] = true
+				// querySimilarT would clash with the query of a type SimilarT.
+				forbiddenTypeNames["Similar"+defName] = true
+			}
+
+			if parseGenerateDirectiveParams(defn).generateGroupByQuery {
+				forbiddenTypeNames[defName+aggregateGroupSuffix] = true
+				forbiddenTypeNames[defName+groupableSuffix] = true
+			}
+
+			if parseGenerateDirectiveParams(defn).generateHistogramQuery {
+				forbiddenTypeNames[defName+histogramBucketSuffix] = true
+				forbiddenTypeNames[defName+histogrammableSuffix] = true
+			}
+
+			if parseGenerateDirectiveParams(defn).generateConnectionQuery {
+				forbiddenTypeNames[defName+connectionSuffix] = true
+				forbiddenTypeNames[defName+edgeSuffix] = true
+				forbiddenTypeNames[pageInfoType] = true
+			}
 		}
 	}
 
@@ -1376,6 +1398,36 @@ func defaultDirectiveValidation(sch *ast.Schema,
 	return nil
 }
 
+func embeddingValidation(sch *ast.Schema,
+	typ *ast.Definition,
+	field *ast.FieldDefinition,
+	dir *ast.Directive,
+	secrets map[string]x.Sensitive) gqlerror.List {
+	if typ.Directives.ForName(remoteDirective) != nil {
+		return []*gqlerror.Error{gqlerror.ErrorPosf(
+			dir.Position,
+			"Type %s; Field %s: cannot use @embedding directive on a @remote type",
+			typ.Name, field.Name)}
+	}
+	if field.Type.Elem == nil || field.Type.Elem.Elem != nil || field.Type.Name() != "Float" {
+		return []*gqlerror.Error{gqlerror.ErrorPosf(
+			dir.Position,
+			"Type %s; Field %s: @embedding directive can only be used on fields of type "+
+				"[Float!] or [Float], but the field has type %s",
+			typ.Name, field.Name, field.Type.String())}
+	}
+	for _, other := range []string{searchDirective, idDirective, customDirective,
+		lambdaDirective, defaultDirective} {
+		if field.Directives.ForName(other) != nil {
+			return []*gqlerror.Error{gqlerror.ErrorPosf(
+				dir.Position,
+				"Type %s; Field %s: cannot use @embedding directive on field with @%s directive",
+				typ.Name, field.Name, other)}
+		}
+	}
+	return nil
+}
+
 func lambdaOnMutateValidation(sch *ast.Schema, typ *ast.Definition) gqlerror.List {
 	dir := typ.Directives.ForName(lambdaOnMutateDirective)
 	if dir == nil {
@@ -1464,6 +1516,46 @@ func generateDirectiveValidation(schema *ast.Schema, typ *ast.Definition) gqlerr
 					"only be true/false, found: `%s",
 				typ.Name, aggregateField.Raw))
 		}
+
+		groupByField := queryArg.Value.Children.ForName(generateGroupByField)
+		if groupByField != nil && groupByField.Kind != ast.BooleanValue {
+			errs = append(errs, gqlerror.ErrorPosf(
+				groupByField.Position,
+				"Type %s; groupBy field inside query argument of @generate directive can "+
+					"only be true/false, found: `%s",
+				typ.Name, groupByField.Raw))
+		}
+		if groupByField != nil && groupByField.Raw == "true" {
+			if aggregateField != nil && aggregateField.Raw == "false" {
+				errs = append(errs, gqlerror.ErrorPosf(
+					groupByField.Position,
+					"Type %s; groupBy can't be generated without the aggregate query.",
+					typ.Name))
+			} else if !hasGroupables(schema, typ, nil) {
+				errs = append(errs, gqlerror.ErrorPosf(
+					groupByField.Position,
+					"Type %s; groupBy can only be generated for a type with fields of type "+
+						"Int, Int64, BigInt, Float, String or DateTime, or with edges to a "+
+						"single node.",
+					typ.Name))
+			}
+		}
+
+		histogramField := queryArg.Value.Children.ForName(generateHistogramField)
+		if histogramField != nil && histogramField.Kind != ast.BooleanValue {
+			errs = append(errs, gqlerror.ErrorPosf(
+				histogramField.Position,
+				"Type %s; histogram field inside query argument of @generate directive can "+
+					"only be true/false, found: `%s",
+				typ.Name, histogramField.Raw))
+		}
+		if histogramField != nil && histogramField.Raw == "true" && !hasHistogrammables(typ) {
+			errs = append(errs, gqlerror.ErrorPosf(
+				histogramField.Position,
+				"Type %s; histogram can only be generated for a type with a field of type "+
+					"DateTime, Int or Int64 with @search.",
+				typ.Name))
+		}
 	}
 
 	mutationArg := dir.Arguments.ForName(generateMutationArg)
@@ -1501,6 +1593,21 @@ func generateDirectiveValidation(schema *ast.Schema, typ *ast.Definition) gqlerr
 					"only be true/false, found: `%s",
 				typ.Name, deleteField.Raw))
 		}
+
+		rollbackField := mutationArg.Value.Children.ForName(generateRollbackField)
+		if rollbackField != nil && rollbackField.Kind != ast.BooleanValue {
+			errs = append(errs, gqlerror.ErrorPosf(
+				rollbackField.Position,
+				"Type %s; rollback field inside mutation argument of @generate directive can "+
+					"only be true/false, found: `%s",
+				typ.Name, rollbackField.Raw))
+		}
+		if rollbackField != nil && rollbackField.Raw == "true" && !hasXID(typ) {
+			errs = append(errs, gqlerror.ErrorPosf(
+				rollbackField.Position,
+				"Type %s; rollback mutation can only be generated for a type with an @id field.",
+				typ.Name))
+		}
 	}
 
 	subscriptionArg := dir.Arguments.ForName(generateSubscriptionArg)
@@ -2041,6 +2148,28 @@ func customDirectiveValidation(sch *ast.Schema,
 		}
 	}
 
+	// 11. Validating cacheMaxAge and maxConcurrency
+	if maxAge := httpArg.Value.Children.ForName(httpCacheMaxAge); maxAge != nil {
+		if typ.Name == "Mutation" {
+			errs = append(errs, gqlerror.ErrorPosf(maxAge.Position,
+				"Type %s; Field %s; cacheMaxAge inside @custom directive can't be present "+
+					"on Mutation.", typ.Name, field.Name))
+		}
+		if n, err := strconv.Atoi(maxAge.Raw); err != nil || n < 0 {
+			errs = append(errs, gqlerror.ErrorPosf(maxAge.Position,
+				"Type %s; Field %s; cacheMaxAge inside @custom directive should be a "+
+					"non-negative number of seconds, found `%s`.", typ.Name, field.Name,
+				maxAge.Raw))
+		}
+	}
+	if maxConc := httpArg.Value.Children.ForName(httpMaxConcurrency); maxConc != nil {
+		if n, err := strconv.Atoi(maxConc.Raw); err != nil || n <= 0 {
+			errs = append(errs, gqlerror.ErrorPosf(maxConc.Position,
+				"Type %s; Field %s; maxConcurrency inside @custom directive should be a "+
+					"positive integer, found `%s`.", typ.Name, field.Name, maxConc.Raw))
+		}
+	}
+
 	// 12. Finally validate the given graphql operation on remote server, when all locally doable
 	// validations have finished
 	var skip bool
@@ -640,6 +640,12 @@ func genDgSchema(gqlSch *ast.Schema, definitions []string,
 						}
 					}
 
+					// An embedding is stored as a single vector, indexed to find its neighbours.
+					if isEmbedding(f) {
+						typStr = "vfloat"
+						indexes = append(indexes, "lsh")
+					}
+
 					id := f.Directives.ForName(idDirective)
 					if id != nil || f.Type.Name() == "ID" {
 						upsertStr = "@upsert "
@@ -647,7 +653,9 @@ func genDgSchema(gqlSch *ast.Schema, definitions []string,
 						case "Int", "Int64":
 							indexes = append(indexes, "int")
 						case "String", "ID":
-							if !x.HasString(indexes, "exact") {
+							if !x.HasString(indexes, "exact") &&
+								!x.HasString(indexes, "exact_ci") &&
+								!x.HasString(indexes, "hash_ci") {
 								indexes = append(indexes, "hash")
 							}
 						}
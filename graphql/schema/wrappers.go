@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -20,6 +21,7 @@ import (
 	"github.com/outcaste-io/outserv/graphql/authorization"
 
 	"github.com/outcaste-io/gqlparser/v2/ast"
+	"github.com/outcaste-io/outserv/types"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/pkg/errors"
 )
@@ -61,6 +63,14 @@ type FieldHTTPConfig struct {
 	// the GraphqlBatchModeArgument would be sinput, we use it to know the GraphQL variable that
 	// we should send the data in.
 	GraphqlBatchModeArgument string
+
+	// CacheMaxAge is how long the responses are cached for, if it's set via cacheMaxAge.
+	CacheMaxAge time.Duration
+	// MaxConcurrency bounds the requests made at once for the field, if it's set via
+	// maxConcurrency.
+	MaxConcurrency int
+
+	loader *httpLoader
 }
 
 // EntityRepresentations is the parsed form of the `representations` argument in `_entities` query
@@ -78,6 +88,7 @@ const (
 	GetQuery             QueryType    = "get"
 	FilterQuery          QueryType    = "query"
 	AggregateQuery       QueryType    = "aggregate"
+	HistogramQuery       QueryType    = "histogram"
 	SchemaQuery          QueryType    = "schema"
 	EntitiesQuery        QueryType    = "entities"
 	PasswordQuery        QueryType    = "checkPassword"
@@ -87,12 +98,15 @@ const (
 	AddMutation          MutationType = "add"
 	UpdateMutation       MutationType = "update"
 	DeleteMutation       MutationType = "delete"
+	RollbackMutation     MutationType = "rollback"
 	HTTPMutation         MutationType = "http"
 	NotSupportedMutation MutationType = "notsupported"
 	IDType                            = "ID"
 	InputArgName                      = "input"
 	UpsertArgName                     = "upsert"
 	FilterArgName                     = "filter"
+	AfterArgName                      = "after"
+	BeforeArgName                     = "before"
 )
 
 // A Type is a GraphQL type like: Float, T, T! and [T!]!.  If it's not a list, then
@@ -139,6 +153,9 @@ type Schema struct {
 	remoteResponse map[string]map[string]string
 	// meta is the meta information extracted from input schema
 	meta *metaInfo
+	// httpLoaders holds the httpLoader of each @custom field. They live as long as the schema,
+	// so a schema update starts new loaders, dropping the cached responses of the old ones.
+	httpLoaders sync.Map
 }
 
 // An Operation is a single valid GraphQL operation.  It contains either
@@ -252,6 +269,11 @@ func (o *Operation) Schema() *Schema {
 	return o.inSchema
 }
 
+// Header returns the HTTP headers of the request the operation came in.
+func (o *Operation) Header() http.Header {
+	return o.header
+}
+
 func (o *Operation) Queries() (qs []*Field) {
 	if o.IsMutation() {
 		return
@@ -287,6 +309,19 @@ func (o *Operation) CacheControl() string {
 	return "public,max-age=" + o.op.Directives.ForName(cacheControlDirective).Arguments[0].Value.Raw
 }
 
+// CacheMaxAge returns the maxAge set via @cacheControl on the operation, or zero if it isn't set.
+func (o *Operation) CacheMaxAge() time.Duration {
+	dir := o.op.Directives.ForName(cacheControlDirective)
+	if dir == nil {
+		return 0
+	}
+	maxAge, err := strconv.ParseInt(dir.Arguments[0].Value.Raw, 10, 64)
+	if err != nil || maxAge <= 0 {
+		return 0
+	}
+	return time.Duration(maxAge) * time.Second
+}
+
 // parentInterface returns the name of an interface that a field belonging to a type definition
 // typDef inherited from. If there is no such interface, then it returns an empty string.
 //
@@ -474,6 +509,8 @@ func mutatedTypeMapping(s *Schema,
 			mutatedTypeName = strings.TrimPrefix(field.Name, "update")
 		case strings.HasPrefix(field.Name, "delete"):
 			mutatedTypeName = strings.TrimPrefix(field.Name, "delete")
+		case strings.HasPrefix(field.Name, "rollback"):
+			mutatedTypeName = strings.TrimPrefix(field.Name, "rollback")
 		default:
 		}
 		// This is a convoluted way of getting the type for mutatedTypeName. We get the definition
@@ -661,6 +698,16 @@ func (fd *FieldDefinition) IsExternal() bool {
 	return hasExternal(fd.fieldDef)
 }
 
+// IsEmbedding returns true if f is an @embedding field, which is stored as one vector rather
+// than as a list of floats.
+func (f *Field) IsEmbedding() bool {
+	return f.field.Definition != nil && isEmbedding(f.field.Definition)
+}
+
+func (fd *FieldDefinition) IsEmbedding() bool {
+	return fd.fieldDef != nil && isEmbedding(fd.fieldDef)
+}
+
 func hasCustomOrLambda(f *ast.FieldDefinition) bool {
 	for _, dir := range f.Directives {
 		if dir.Name == customDirective || dir.Name == lambdaDirective {
@@ -869,6 +916,100 @@ func (f *Field) IsAggregateField() bool {
 	return strings.HasSuffix(f.Name(), "Aggregate") && f.Type().IsAggregateResult()
 }
 
+// IsAggregateGroups tells whether f is the groups field of a <Type>AggregateResult, which holds
+// the results of the aggregate query grouped by its groupBy argument.
+func (f *Field) IsAggregateGroups() bool {
+	return f.Name() == groupsField && f.field.ObjectDefinition != nil &&
+		strings.HasSuffix(f.field.ObjectDefinition.Name, "AggregateResult")
+}
+
+// GroupBy returns the names of the fields given in the groupBy argument of the aggregate
+// query f, in that order.
+func (f *Field) GroupBy() []string {
+	var groupBy []string
+	switch vals := f.ArgValue(groupByArg).(type) {
+	case string:
+		// A single value is allowed in place of a list.
+		groupBy = append(groupBy, vals)
+	case []interface{}:
+		for _, v := range vals {
+			if name, ok := v.(string); ok {
+				groupBy = append(groupBy, name)
+			}
+		}
+	}
+	return groupBy
+}
+
+// HistogramField returns the name of the field given in the field argument of the histogram
+// query f.
+func (f *Field) HistogramField() string {
+	name, _ := f.ArgValue(histogramFieldArg).(string)
+	return name
+}
+
+// HistogramInterval returns the interval argument of the histogram query f.
+func (f *Field) HistogramInterval() string {
+	interval, _ := f.ArgValue(histogramIntervalArg).(string)
+	return interval
+}
+
+// defaultSimilarTopK is the number of nodes found by a similarity query without a topK.
+const defaultSimilarTopK = 10
+
+// IsSimilarQuery returns true if f is a querySimilarT query, finding the nodes whose embedding
+// is the closest to a vector.
+func (f *Field) IsSimilarQuery() bool {
+	return f.field.Definition != nil && f.QueryType() == FilterQuery &&
+		f.field.Definition.Arguments.ForName(similarVectorArg) != nil
+}
+
+// SimilarBy returns the name of the @embedding field given in the by argument of the similarity
+// query f.
+func (f *Field) SimilarBy() string {
+	name, _ := f.ArgValue(similarByArg).(string)
+	return name
+}
+
+// SimilarVector returns the vector argument of the similarity query f.
+func (f *Field) SimilarVector() ([]float32, error) {
+	return EmbeddingVector(f.ArgValue(similarVectorArg))
+}
+
+// SimilarTopK returns the number of nodes asked for by the similarity query f.
+func (f *Field) SimilarTopK() int {
+	if topK, err := strconv.Atoi(fmt.Sprint(f.ArgValue(similarTopKArg))); err == nil {
+		return topK
+	}
+	return defaultSimilarTopK
+}
+
+// SimilarDistance returns the distance of the similarity query f, euclidean or cosine.
+func (f *Field) SimilarDistance() string {
+	if distance, ok := f.ArgValue(similarDistanceArg).(string); ok {
+		return strings.ToLower(distance)
+	}
+	return types.EuclideanDistance
+}
+
+// EmbeddingVector returns the vector of val, the value given for an @embedding field or for the
+// vector of a similarity query.
+func EmbeddingVector(val interface{}) ([]float32, error) {
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, errors.Errorf("expected a non-empty list of Float for a vector, got %v", val)
+	}
+	vec := make([]float32, len(list))
+	for i, v := range list {
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading a vector")
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
 func (f *Field) GqlErrorf(path []interface{}, message string, args ...interface{}) *x.GqlError {
 	pathCopy := make([]interface{}, len(path))
 	copy(pathCopy, path)
@@ -1299,6 +1440,17 @@ func getCustomHTTPConfig(f *Field, isQueryOrMutation bool, ns uint64) (*FieldHTT
 		fconf.Mode = op.Raw
 	}
 
+	// Both are validated to be non-negative integers.
+	if maxAge := httpArg.Value.Children.ForName(httpCacheMaxAge); maxAge != nil {
+		secs, _ := strconv.Atoi(maxAge.Raw)
+		fconf.CacheMaxAge = time.Duration(secs) * time.Second
+	}
+	if maxConc := httpArg.Value.Children.ForName(httpMaxConcurrency); maxConc != nil {
+		fconf.MaxConcurrency, _ = strconv.Atoi(maxConc.Raw)
+	}
+	fconf.loader = f.op.inSchema.loaderFor(f.GetObjectName(), f.Name(), fconf.CacheMaxAge,
+		fconf.MaxConcurrency)
+
 	// both body and graphql can't be present together
 	bodyArg := httpArg.Value.Children.ForName(httpBody)
 	graphqlArg := httpArg.Value.Children.ForName(httpGraphql)
@@ -1582,6 +1734,20 @@ func (f *Field) ConstructedFor() *Type {
 	// if !f.IsAggregateField() {
 	// 	return f.Type()
 	// }
+	if f.Type().IsConnection() {
+		return f.Type().ConnectionNodeType()
+	}
+	if f.QueryType() == HistogramQuery &&
+		strings.HasSuffix(f.Type().Name(), histogramBucketSuffix) {
+		// f has type of the form [<SomeTypeName>HistogramBucket]
+		return &Type{
+			typ: &ast.Type{
+				NamedType: strings.TrimSuffix(f.Type().Name(), histogramBucketSuffix),
+			},
+			inSchema:        f.op.inSchema,
+			dgraphPredicate: f.op.inSchema.dgraphPredicate,
+		}
+	}
 	if !f.IsAggregateField() && f.QueryType() != AggregateQuery {
 		return f.Type()
 	}
@@ -1681,11 +1847,29 @@ func queryType(name string, custom *ast.Directive) QueryType {
 		return PasswordQuery
 	case strings.HasPrefix(name, "aggregate"):
 		return AggregateQuery
+	case strings.HasPrefix(name, "histogram"):
+		return HistogramQuery
 	default:
 		return NotSupportedQuery
 	}
 }
 
+// ConnectionNodeField returns the node field requested inside edges of the connection query f,
+// or nil if no node was requested.
+func (f *Field) ConnectionNodeField() *Field {
+	for _, edges := range f.SelectionSet() {
+		if edges.Name() != edgesField {
+			continue
+		}
+		for _, node := range edges.SelectionSet() {
+			if node.Name() == nodeField {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
 func (m *Field) QueryField() *Field {
 	for _, f := range m.SelectionSet() {
 		if f.Name() == NumUid || f.Name() == Typename || f.Name() == Msg {
@@ -1742,6 +1926,8 @@ func mutationType(name string, custom *ast.Directive) MutationType {
 		return UpdateMutation
 	case strings.HasPrefix(name, "delete"):
 		return DeleteMutation
+	case strings.HasPrefix(name, "rollback"):
+		return RollbackMutation
 	default:
 		return NotSupportedMutation
 	}
@@ -1755,6 +1941,32 @@ func (t *Type) IsAggregateResult() bool {
 	return strings.HasSuffix(t.Name(), "AggregateResult")
 }
 
+// IsConnection tells whether t is a <Type>Connection generated for connection queries.
+func (t *Type) IsConnection() bool {
+	name := t.Name()
+	if t.ListType() != nil || !strings.HasSuffix(name, connectionSuffix) {
+		return false
+	}
+	defn := t.inSchema.schema.Types[name]
+	if defn == nil || defn.Fields.ForName(pageInfoField) == nil {
+		return false
+	}
+	edges := defn.Fields.ForName(edgesField)
+	return edges != nil &&
+		edges.Type.Name() == strings.TrimSuffix(name, connectionSuffix)+edgeSuffix
+}
+
+// ConnectionNodeType returns the type of the nodes in the connection type t.
+func (t *Type) ConnectionNodeType() *Type {
+	return &Type{
+		typ: &ast.Type{
+			NamedType: strings.TrimSuffix(t.Name(), connectionSuffix),
+		},
+		inSchema:        t.inSchema,
+		dgraphPredicate: t.dgraphPredicate,
+	}
+}
+
 func (t *Type) Field(name string) *FieldDefinition {
 	return &FieldDefinition{
 		// this ForName lookup is a loop in the underlying schema :-(
@@ -1827,6 +2039,21 @@ func getDefaultValue(fd *ast.FieldDefinition, action string) interface{} {
 	return value.Raw
 }
 
+// HasSortableIndex tells whether fd has an index which can serve inequality functions like ge and
+// le at root. Such an index lets a query seek to a value, instead of going over all the nodes.
+func (fd *FieldDefinition) HasSortableIndex() bool {
+	if fd.fieldDef == nil {
+		return false
+	}
+	for _, search := range getSearchArgs(fd.fieldDef) {
+		switch search {
+		case "int", "int64", "float", "exact", "year", "month", "day", "hour":
+			return true
+		}
+	}
+	return false
+}
+
 func (fd *FieldDefinition) HasIDDirective() bool {
 	if fd.fieldDef == nil {
 		return false
@@ -2167,6 +2394,20 @@ func (t *Type) Interfaces() []string {
 	return names
 }
 
+// HasAuthRules tells whether the type, or any of the interfaces it implements, has @auth rules.
+func (t *Type) HasAuthRules() bool {
+	defn := t.inSchema.schema.Types[t.typ.Name()]
+	if defn.Directives.ForName(authDirective) != nil {
+		return true
+	}
+	for _, intr := range defn.Interfaces {
+		if t.inSchema.schema.Types[intr].Directives.ForName(authDirective) != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Type) ImplementingTypes() []*Type {
 	objects := t.inSchema.schema.PossibleTypes[t.typ.Name()]
 	if len(objects) == 0 {
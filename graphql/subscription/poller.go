@@ -31,24 +31,32 @@ import (
 	"github.com/golang/glog"
 	"github.com/outcaste-io/outserv/graphql/resolve"
 	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/worker"
 	"github.com/outcaste-io/outserv/x"
 )
 
 // Poller is used to poll user subscription query.
+//
+// If push subscriptions are enabled, a subscription query is only re-run when a commit touches
+// one of the predicates it reads. The poll interval is then only used to expire subscriptions,
+// and to poll the subscriptions whose predicates can't be determined, or are served by another
+// group.
 type Poller struct {
 	sync.RWMutex
 	resolver       *resolve.RequestResolver
 	pollRegistry   map[uint64]map[uint64]subscriber
 	subscriptionID uint64
 	globalEpoch    *uint64
+	namespace      uint64
 }
 
 // NewPoller returns Poller.
-func NewPoller(globalEpoch *uint64, resolver *resolve.RequestResolver) *Poller {
+func NewPoller(namespace uint64, globalEpoch *uint64, resolver *resolve.RequestResolver) *Poller {
 	return &Poller{
 		resolver:     resolver,
 		pollRegistry: make(map[uint64]map[uint64]subscriber),
 		globalEpoch:  globalEpoch,
+		namespace:    namespace,
 	}
 }
 
@@ -153,6 +161,14 @@ func (p *Poller) AddSubscriber(req *schema.Request) (*SubscriberResponse, error)
 		authVariables: customClaims.AuthVariables,
 		localEpoch:    localEpoch,
 	}
+	if x.Config.GraphQL.PushSubscriptions {
+		if preds, ok := resolver.SubscriptionPredicates(ctx, req); ok {
+			pollR.push = true
+			for _, pred := range preds {
+				pollR.preds = append(pollR.preds, x.NamespaceAttr(p.namespace, pred))
+			}
+		}
+	}
 	go p.poll(pollR)
 
 	return &SubscriberResponse{
@@ -168,6 +184,10 @@ type pollRequest struct {
 	bucketID      uint64
 	localEpoch    uint64
 	authVariables map[string]interface{}
+	// push is true if the subscription gets re-run on the commits touching preds, instead of
+	// every poll interval.
+	push  bool
+	preds []string
 }
 
 func (p *Poller) poll(req *pollRequest) {
@@ -175,10 +195,30 @@ func (p *Poller) poll(req *pollRequest) {
 	resolver := p.resolver
 	p.RUnlock()
 
+	var watcher *worker.CommitWatcher
+	if req.push {
+		watcher = worker.WatchCommits(req.preds)
+		defer worker.StopWatchingCommits(watcher)
+	}
+	ticker := time.NewTicker(x.Config.GraphQL.PollInterval)
+	defer ticker.Stop()
+
 	pollID := uint64(0)
 	for {
 		pollID++
-		time.Sleep(x.Config.GraphQL.PollInterval)
+		resolveReq := true
+		if watcher == nil {
+			<-ticker.C
+		} else {
+			select {
+			case <-watcher.C():
+			case <-ticker.C:
+				// Nothing read by this subscription got committed here. We still have to poll
+				// if some of its predicates are served by another group, as we don't get
+				// notified of the commits over there.
+				resolveReq = !worker.ServesAllPredicates(req.preds)
+			}
+		}
 
 		globalEpoch := atomic.LoadUint64(p.globalEpoch)
 		if req.localEpoch != globalEpoch || globalEpoch == math.MaxUint64 {
@@ -188,6 +228,13 @@ func (p *Poller) poll(req *pollRequest) {
 			p.terminateSubscriptions(req.bucketID)
 		}
 
+		if !resolveReq {
+			if !p.expireSubscriptions(req.bucketID) {
+				return
+			}
+			continue
+		}
+
 		ctx := x.AttachAccessJwt(context.Background(), &http.Request{Header: req.graphqlReq.Header})
 		res := resolver.Resolve(ctx, req.graphqlReq)
 
@@ -200,20 +247,9 @@ func (p *Poller) poll(req *pollRequest) {
 			}
 			// Every second poll, we'll check if there is any active subscription for the
 			// current goroutine. If not we'll terminate this poll.
-			p.Lock()
-			subscribers, ok := p.pollRegistry[req.bucketID]
-			if !ok || len(subscribers) == 0 {
-				delete(p.pollRegistry, req.bucketID)
-				p.Unlock()
+			if !p.expireSubscriptions(req.bucketID) {
 				return
 			}
-			for subscriberID, subscriber := range subscribers {
-				if !subscriber.expiry.IsZero() && time.Now().After(subscriber.expiry) {
-					p.terminateSubscription(req.bucketID, subscriberID)
-				}
-
-			}
-			p.Unlock()
 			continue
 		}
 		req.prevHash = currentHash
@@ -241,6 +277,24 @@ func (p *Poller) poll(req *pollRequest) {
 	}
 }
 
+// expireSubscriptions terminates the expired subscriptions of the given bucketID. It returns false
+// if there is no active subscription left for the bucket, in which case the bucket is removed.
+func (p *Poller) expireSubscriptions(bucketID uint64) bool {
+	p.Lock()
+	defer p.Unlock()
+	subscribers, ok := p.pollRegistry[bucketID]
+	if !ok || len(subscribers) == 0 {
+		delete(p.pollRegistry, bucketID)
+		return false
+	}
+	for subscriberID, subscriber := range subscribers {
+		if !subscriber.expiry.IsZero() && time.Now().After(subscriber.expiry) {
+			p.terminateSubscription(bucketID, subscriberID)
+		}
+	}
+	return true
+}
+
 // TerminateSubscriptions will terminate all the subscriptions of the given bucketID.
 func (p *Poller) terminateSubscriptions(bucketID uint64) {
 	p.Lock()
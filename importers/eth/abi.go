@@ -0,0 +1,243 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Apache License v2.0.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// Log is an event emitted by a contract, decoded via its ABI.
+type Log struct {
+	Uid       string   `json:"uid,omitempty"`
+	Id        string   `json:"id"`
+	LogIndex  int      `json:"logIndex"`
+	Contract  Account  `json:"contract"`
+	Name      string   `json:"name"`
+	Signature string   `json:"signature"`
+	Topics    []string `json:"topics,omitempty"`
+	Data      string   `json:"data,omitempty"`
+	Params    []Param  `json:"params,omitempty"`
+}
+
+// Param is a parameter of an event, or an argument of a contract call. Depending upon
+// its ABI type, exactly one of the value fields is set.
+type Param struct {
+	Uid         string   `json:"uid,omitempty"`
+	Id          string   `json:"id"`
+	Index       int      `json:"index"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Account     *Account `json:"account,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	StringValue string   `json:"stringValue,omitempty"`
+	BytesValue  string   `json:"bytesValue,omitempty"`
+}
+
+// Decoder decodes logs and contract calls using the events and methods of the loaded
+// ABIs.
+type Decoder struct {
+	// events are keyed by the event ID (topic 0) and the number of indexed parameters.
+	// ERC-20 and ERC-721 both define Transfer(address,address,uint256) for example, but
+	// only the latter has the third parameter indexed.
+	events map[string]abi.Event
+	// methods are keyed by their 4 byte selector.
+	methods map[string]abi.Method
+}
+
+var decoder *Decoder
+
+func eventKey(id common.Hash, numIndexed int) string {
+	return fmt.Sprintf("%s-%d", id.Hex(), numIndexed)
+}
+
+// LoadABIs reads the ABI JSON files at the given paths. A directory path loads all the
+// .json files in it.
+func LoadABIs(paths []string) (*Decoder, error) {
+	d := &Decoder{
+		events:  make(map[string]abi.Event),
+		methods: make(map[string]abi.Method),
+	}
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := abi.JSON(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while parsing ABI %s", file)
+		}
+		for _, ev := range parsed.Events {
+			if ev.Anonymous {
+				// Anonymous events don't have their ID as the first topic.
+				continue
+			}
+			var numIndexed int
+			for _, arg := range ev.Inputs {
+				if arg.Indexed {
+					numIndexed++
+				}
+			}
+			d.events[eventKey(ev.ID, numIndexed)] = ev
+		}
+		for _, m := range parsed.Methods {
+			d.methods[hexutil.Encode(m.ID)] = m
+		}
+	}
+	fmt.Printf("Loaded %d events and %d methods from %d ABI files\n",
+		len(d.events), len(d.methods), len(files))
+	return d, nil
+}
+
+// DecodeLog returns the decoded log, or nil if the log doesn't match any of the events
+// in the loaded ABIs.
+func (d *Decoder) DecodeLog(txnHash string, index int, contract string,
+	topics []common.Hash, data []byte) *Log {
+
+	if len(topics) == 0 {
+		return nil
+	}
+	ev, ok := d.events[eventKey(topics[0], len(topics)-1)]
+	if !ok {
+		return nil
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range ev.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	vals := make(map[string]interface{})
+	if err := abi.ParseTopicsIntoMap(vals, indexed, topics[1:]); err != nil {
+		return nil
+	}
+	unpacked, err := ev.Inputs.NonIndexed().Unpack(data)
+	if err != nil {
+		// Another event with the same signature, but different parameters.
+		return nil
+	}
+
+	id := fmt.Sprintf("%s-%d", txnHash, index)
+	log := &Log{
+		Uid:       fmt.Sprintf("_:Log.%s", id),
+		Id:        id,
+		LogIndex:  index,
+		Contract:  newAccount(contract),
+		Name:      ev.RawName,
+		Signature: ev.Sig,
+		Data:      hexutil.Encode(data),
+	}
+	for _, t := range topics {
+		log.Topics = append(log.Topics, t.Hex())
+	}
+
+	var nonIndexed int
+	for i, arg := range ev.Inputs {
+		var val interface{}
+		if arg.Indexed {
+			val = vals[arg.Name]
+		} else {
+			val = unpacked[nonIndexed]
+			nonIndexed++
+		}
+		log.Params = append(log.Params, newParam(id, i, arg, val))
+	}
+	return log
+}
+
+// DecodeInput returns the name and the arguments of the method called by the input data
+// of a transaction. It returns an empty name if the method isn't in the loaded ABIs.
+func (d *Decoder) DecodeInput(txnHash string, input []byte) (string, []Param) {
+	if len(input) < 4 {
+		return "", nil
+	}
+	m, ok := d.methods[hexutil.Encode(input[:4])]
+	if !ok {
+		return "", nil
+	}
+	vals, err := m.Inputs.Unpack(input[4:])
+	if err != nil {
+		return "", nil
+	}
+	var params []Param
+	for i, arg := range m.Inputs {
+		params = append(params, newParam(txnHash, i, arg, vals[i]))
+	}
+	return m.RawName, params
+}
+
+func newParam(parentId string, index int, arg abi.Argument, val interface{}) Param {
+	p := Param{
+		Id:    fmt.Sprintf("%s-%d", parentId, index),
+		Index: index,
+		Name:  arg.Name,
+		Type:  arg.Type.String(),
+	}
+	p.Uid = fmt.Sprintf("_:Param.%s", p.Id)
+	if p.Name == "" {
+		p.Name = fmt.Sprintf("arg%d", index)
+	}
+
+	switch v := val.(type) {
+	case common.Address:
+		acc := newAccount(v.Hex())
+		p.Account = &acc
+	case *big.Int:
+		p.IntValue = v.String()
+	case uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		p.IntValue = fmt.Sprintf("%d", v)
+	case bool:
+		p.BoolValue = &v
+	case string:
+		p.StringValue = v
+	case []byte:
+		p.BytesValue = hexutil.Encode(v)
+	case common.Hash:
+		// Indexed parameters of dynamic types only have their hash in the topic.
+		p.BytesValue = v.Hex()
+	default:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+			// Fixed size bytes, like bytes32.
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			p.BytesValue = hexutil.Encode(b)
+			break
+		}
+		// Arrays and tuples are stored as JSON.
+		data, err := json.Marshal(val)
+		if err == nil {
+			p.StringValue = string(data)
+		}
+	}
+	return p
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Apache License v2.0.
+
+package main
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const erc20ABI = `[
+	{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+		{"name": "from", "type": "address", "indexed": true},
+		{"name": "to", "type": "address", "indexed": true},
+		{"name": "value", "type": "uint256", "indexed": false}]},
+	{"type": "function", "name": "transfer", "stateMutability": "nonpayable", "inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}],
+	 "outputs": [{"name": "", "type": "bool"}]}
+]`
+
+const erc721ABI = `[
+	{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+		{"name": "from", "type": "address", "indexed": true},
+		{"name": "to", "type": "address", "indexed": true},
+		{"name": "tokenId", "type": "uint256", "indexed": true}]}
+]`
+
+var (
+	transferID = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	// EIP-55 checksummed addresses, which must be stored lower cased.
+	alice = common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	bob   = common.HexToAddress("0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+)
+
+func loadTestABIs(t *testing.T) *Decoder {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("erc20.json", erc20ABI)
+	write("erc721.json", erc721ABI)
+	write("README.md", "not an ABI")
+
+	d, err := LoadABIs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func checkAccount(t *testing.T, acc *Account, addr common.Address) {
+	t.Helper()
+	want := strings.ToLower(addr.Hex())
+	if acc == nil || acc.Address != want || acc.Uid != "_:Account."+want {
+		t.Fatalf("expected account %s, got %+v", want, acc)
+	}
+}
+
+func TestLoadABIs(t *testing.T) {
+	d := loadTestABIs(t)
+	// Both Transfer events share the same ID, but differ in the indexed parameters.
+	if len(d.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(d.events))
+	}
+	if len(d.methods) != 1 {
+		t.Fatalf("expected 1 method, got %d", len(d.methods))
+	}
+
+	file := filepath.Join(t.TempDir(), "erc20.json")
+	if err := ioutil.WriteFile(file, []byte(erc20ABI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadABIs([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.events) != 1 || len(d.methods) != 1 {
+		t.Fatalf("expected 1 event and 1 method, got %d and %d", len(d.events), len(d.methods))
+	}
+
+	if _, err := LoadABIs([]string{filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Fatal("expected an error for a missing path")
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	d := loadTestABIs(t)
+	from := common.BytesToHash(alice.Bytes())
+	to := common.BytesToHash(bob.Bytes())
+	amount := common.LeftPadBytes(big.NewInt(100).Bytes(), 32)
+
+	t.Run("erc20", func(t *testing.T) {
+		log := d.DecodeLog("0xabc", 3, bob.Hex(), []common.Hash{transferID, from, to}, amount)
+		if log == nil {
+			t.Fatal("expected the log to be decoded")
+		}
+		if log.Id != "0xabc-3" || log.Name != "Transfer" || log.LogIndex != 3 {
+			t.Fatalf("unexpected log: %+v", log)
+		}
+		checkAccount(t, &log.Contract, bob)
+		if len(log.Params) != 3 {
+			t.Fatalf("expected 3 params, got %d", len(log.Params))
+		}
+		checkAccount(t, log.Params[0].Account, alice)
+		checkAccount(t, log.Params[1].Account, bob)
+		if p := log.Params[2]; p.Name != "value" || p.IntValue != "100" || p.Id != "0xabc-3-2" {
+			t.Fatalf("unexpected param: %+v", p)
+		}
+	})
+
+	t.Run("erc721", func(t *testing.T) {
+		tokenId := common.BigToHash(big.NewInt(7))
+		log := d.DecodeLog("0xabc", 4, bob.Hex(),
+			[]common.Hash{transferID, from, to, tokenId}, nil)
+		if log == nil {
+			t.Fatal("expected the log to be decoded")
+		}
+		if p := log.Params[2]; p.Name != "tokenId" || p.IntValue != "7" {
+			t.Fatalf("unexpected param: %+v", p)
+		}
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		unknown := crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+		if log := d.DecodeLog("0xabc", 5, bob.Hex(),
+			[]common.Hash{unknown, from, to}, amount); log != nil {
+			t.Fatalf("expected no log, got %+v", log)
+		}
+		if log := d.DecodeLog("0xabc", 5, bob.Hex(), nil, amount); log != nil {
+			t.Fatalf("expected no log, got %+v", log)
+		}
+	})
+}
+
+func TestDecodeInput(t *testing.T) {
+	d := loadTestABIs(t)
+	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := parsed.Pack("transfer", bob, big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, args := d.DecodeInput("0xabc", input)
+	if name != "transfer" || len(args) != 2 {
+		t.Fatalf("unexpected method %q with %d args", name, len(args))
+	}
+	checkAccount(t, args[0].Account, bob)
+	if p := args[1]; p.Name != "value" || p.IntValue != "42" || p.Id != "0xabc-1" {
+		t.Fatalf("unexpected arg: %+v", p)
+	}
+
+	if name, _ := d.DecodeInput("0xabc", []byte{1, 2, 3, 4, 5}); name != "" {
+		t.Fatalf("expected no method, got %q", name)
+	}
+	if name, _ := d.DecodeInput("0xabc", []byte{1, 2}); name != "" {
+		t.Fatalf("expected no method, got %q", name)
+	}
+}
@@ -35,6 +35,9 @@ var outIPC = flag.String("ipc", "", "Output IPC dir for loader to read directly
 var dryRun = flag.Bool("dry", false, "If true, don't send txns to GraphQL endpoint")
 var numGo = flag.Int("gor", 4, "Number of goroutines to use")
 var startBlock = flag.Int64("start", 14900000, "Start at block")
+var abiPaths = flag.String("abi", "", "Comma separated list of ABI JSON files, or directories"+
+	" containing them. If set, the logs and the input data of the transactions are decoded"+
+	" against these ABIs, and stored as Log and Param nodes.")
 
 var txnMu = `
 mutation($Txns: [AddTxnInput!]!) {
@@ -268,6 +271,11 @@ func main() {
 		}
 		fmt.Printf("Latest: %08d\n", header.Number)
 	}
+	if len(*abiPaths) > 0 {
+		var err error
+		decoder, err = LoadABIs(strings.Split(*abiPaths, ","))
+		check(err)
+	}
 	fmt.Printf("Using %d goroutines.\n", *numGo)
 
 	chain := Chain{
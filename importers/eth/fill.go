@@ -12,8 +12,11 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -21,6 +24,15 @@ type Account struct {
 	Uid     string `json:"uid,omitempty"`
 	Address string `json:"address"`
 }
+
+// newAccount returns the account with the given address. Geth's GraphQL API returns
+// addresses in lower case, while common.Address.Hex returns them EIP-55 checksummed. So,
+// the address is lower cased here, for every source to refer to the same account node.
+func newAccount(addr string) Account {
+	addr = strings.ToLower(addr)
+	return Account{Uid: fmt.Sprintf("_:Account.%s", addr), Address: addr}
+}
+
 type Txn struct {
 	Uid         string  `json:"uid,omitempty"`
 	Hash        string  `json:"hash"`
@@ -32,10 +44,40 @@ type Txn struct {
 	To          Account `json:"to"`
 	From        Account `json:"from"`
 
+	// The following fields are only set if ABIs are loaded via --abi.
+	Method string  `json:"method,omitempty"`
+	Args   []Param `json:"args,omitempty"`
+	Logs   []Log   `json:"logs,omitempty"`
+
 	// The following fields are used by ETH. But, not part of Outserv's GraphQL Schema.
-	ValueStr string `json:"value_str,omitempty"`
-	GasUsed  int64  `json:"gasUsed,omitempty"`
-	GasPrice string `json:"gasPrice,omitempty"`
+	ValueStr  string    `json:"value_str,omitempty"`
+	GasUsed   int64     `json:"gasUsed,omitempty"`
+	GasPrice  string    `json:"gasPrice,omitempty"`
+	InputData string    `json:"input_data,omitempty"`
+	RawLogs   []gethLog `json:"raw_logs,omitempty"`
+}
+
+// gethLog is a log as returned by the GraphQL API of Geth.
+type gethLog struct {
+	Index   int      `json:"index"`
+	Account Account  `json:"account"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// decode fills in the called method and the emitted events of the transaction, as far
+// as they're found in the loaded ABIs.
+func (txn *Txn) decode(input []byte, logs []*types.Log) {
+	if decoder == nil {
+		return
+	}
+	txn.Method, txn.Args = decoder.DecodeInput(txn.Hash, input)
+	for _, l := range logs {
+		if log := decoder.DecodeLog(txn.Hash, int(l.Index), l.Address.Hex(), l.Topics,
+			l.Data); log != nil {
+			txn.Logs = append(txn.Logs, *log)
+		}
+	}
 }
 
 type Block struct {
@@ -53,6 +95,14 @@ type Block struct {
 // To adapt this importer to your needs, please start with modifying here, and
 // the Txn struct.
 func (b *Block) fastFillViaGraphQL() {
+	var abiFields string
+	if decoder != nil {
+		// The logs come from the receipts, which are only worth fetching if we can
+		// decode them.
+		abiFields = `
+			input_data: inputData
+			raw_logs: logs { index account { address } topics data }`
+	}
 	q := fmt.Sprintf(`
 {
 	block(number: %d) {
@@ -64,10 +114,10 @@ func (b *Block) fastFillViaGraphQL() {
 			to { address }
 			value_str: value
 			gasPrice
-			gasUsed
+			gasUsed%s
 		}
 	}
-}`, b.Number)
+}`, b.Number, abiFields)
 
 	type httpReq struct {
 		Query string
@@ -112,8 +162,8 @@ func (b *Block) fastFillViaGraphQL() {
 		if len(txn.To.Address) == 0 || len(txn.From.Address) == 0 {
 			continue
 		}
-		txn.To.Uid = fmt.Sprintf("_:Account.%s", txn.To.Address)
-		txn.From.Uid = fmt.Sprintf("_:Account.%s", txn.From.Address)
+		txn.To = newAccount(txn.To.Address)
+		txn.From = newAccount(txn.From.Address)
 
 		price, ok := new(big.Int).SetString(txn.GasPrice, 0)
 		if !ok {
@@ -128,11 +178,30 @@ func (b *Block) fastFillViaGraphQL() {
 		txn.Block = Block{Number: b.Number, Uid: fmt.Sprintf("_:Block.%08d", b.Number)}
 		txn.Uid = fmt.Sprintf("_:Txn.%s", txn.Hash)
 
+		if decoder != nil {
+			input, _ := hexutil.Decode(txn.InputData)
+			var logs []*types.Log
+			for _, rl := range txn.RawLogs {
+				l := &types.Log{
+					Address: common.HexToAddress(rl.Account.Address),
+					Index:   uint(rl.Index),
+				}
+				l.Data, _ = hexutil.Decode(rl.Data)
+				for _, t := range rl.Topics {
+					l.Topics = append(l.Topics, common.HexToHash(t))
+				}
+				logs = append(logs, l)
+			}
+			txn.decode(input, logs)
+		}
+
 		// Zero out the following fields, so they don't get marshalled when
 		// sending to Outserv.
 		txn.ValueStr = ""
 		txn.GasUsed = 0
 		txn.GasPrice = ""
+		txn.InputData = ""
+		txn.RawLogs = nil
 		b.Transactions = append(b.Transactions, txn)
 	}
 }
@@ -158,11 +227,11 @@ func (b *Block) slowFillViaClient() {
 
 		var to, from Account
 		if msg, err := tx.AsMessage(types.NewEIP155Signer(chainID), nil); err == nil {
-			from.Address = msg.From().Hex()
+			from = newAccount(msg.From().Hex())
 		}
 
 		if tx.To() != nil {
-			to.Address = tx.To().Hex()
+			to = newAccount(tx.To().Hex())
 		}
 
 		valGwei := new(big.Int).Div(tx.Value(), gwei)
@@ -180,6 +249,7 @@ func (b *Block) slowFillViaClient() {
 		if len(txn.To.Address) == 0 || len(txn.From.Address) == 0 {
 			continue
 		}
+		txn.decode(tx.Data(), receipt.Logs)
 		b.Transactions = append(b.Transactions, txn)
 	}
 }
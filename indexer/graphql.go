@@ -0,0 +1,275 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/pkg/errors"
+)
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors x.GqlErrorList  `json:"errors"`
+}
+
+// graphQLSource reads blocks via the GraphQL API exposed by Geth, which is a lot
+// faster than JSON-RPC as it doesn't need a separate call to get the receipts.
+type graphQLSource struct {
+	url    string
+	client *http.Client
+}
+
+func newGraphQLSource(url string) *graphQLSource {
+	return &graphQLSource{url: url, client: &http.Client{Timeout: time.Minute}}
+}
+
+func (s *graphQLSource) query(ctx context.Context, q string, vars map[string]interface{},
+	out interface{}) error {
+
+	var resp gqlResponse
+	req := schema.Request{Query: q, Variables: vars}
+	if err := httpPost(ctx, s.client, s.url, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+func (s *graphQLSource) Head(ctx context.Context) (int64, error) {
+	var out struct {
+		Block struct {
+			Number quantity
+		}
+	}
+	err := s.query(ctx, `{ block { number } }`, nil, &out)
+	return out.Block.Number.Int64(), err
+}
+
+func (s *graphQLSource) Hash(ctx context.Context, number int64) (string, error) {
+	var out struct {
+		Block *struct {
+			Hash string
+		}
+	}
+	q := `query($n: Long!) { block(number: $n) { hash } }`
+	if err := s.query(ctx, q, map[string]interface{}{"n": number}, &out); err != nil {
+		return "", err
+	}
+	if out.Block == nil {
+		return "", errors.Errorf("block %d not found", number)
+	}
+	return out.Block.Hash, nil
+}
+
+const gethBlockQuery = `
+query($n: Long!) {
+	block(number: $n) {
+		number
+		hash
+		parent { hash }
+		timestamp
+		transactions {
+			hash
+			from { address }
+			to { address }
+			value
+			gasPrice
+			effectiveGasPrice
+			gasUsed
+		}
+	}
+}`
+
+func (s *graphQLSource) Block(ctx context.Context, number int64) (*Block, error) {
+	type account struct {
+		Address string
+	}
+	var out struct {
+		Block *struct {
+			Number quantity
+			Hash   string
+			Parent *struct {
+				Hash string
+			}
+			Timestamp    quantity
+			Transactions []struct {
+				Hash              string
+				From              *account
+				To                *account
+				Value             quantity
+				GasPrice          quantity
+				EffectiveGasPrice *quantity
+				GasUsed           quantity
+			}
+		}
+	}
+	err := s.query(ctx, gethBlockQuery, map[string]interface{}{"n": number}, &out)
+	if err != nil {
+		return nil, err
+	}
+	blk := out.Block
+	if blk == nil {
+		return nil, errors.Errorf("block %d not found", number)
+	}
+	if blk.Number.Int64() != number {
+		return nil, errors.Errorf("asked for block %d, got %d", number, blk.Number.Int64())
+	}
+
+	res := &Block{
+		Number:    number,
+		Hash:      blk.Hash,
+		Timestamp: blk.Timestamp.Int64(),
+	}
+	if blk.Parent != nil {
+		res.ParentHash = blk.Parent.Hash
+	}
+	for _, txn := range blk.Transactions {
+		if txn.From == nil || txn.To == nil {
+			// Contract creation.
+			continue
+		}
+		price := &txn.GasPrice.Int
+		if txn.EffectiveGasPrice != nil {
+			price = &txn.EffectiveGasPrice.Int
+		}
+		res.Transactions = append(res.Transactions, Txn{
+			Hash:  txn.Hash,
+			From:  txn.From.Address,
+			To:    txn.To.Address,
+			Value: toGwei(&txn.Value.Int),
+			Fee:   fee(&txn.GasUsed.Int, price),
+		})
+	}
+	return res, nil
+}
+
+// ResolveFunc resolves a GraphQL request against Outserv.
+type ResolveFunc func(ctx context.Context, req *schema.Request) *schema.Response
+
+// gqlStore writes blocks into Outserv, using the types in importers/eth/schema.graphql.
+type gqlStore struct {
+	resolve ResolveFunc
+}
+
+// NewGraphQLStore returns a Store which writes the blocks via the GraphQL API of
+// Outserv. The schema must contain the Block, Txn and Account types defined in
+// importers/eth/schema.graphql.
+func NewGraphQLStore(resolve ResolveFunc) Store {
+	return &gqlStore{resolve: resolve}
+}
+
+func (s *gqlStore) do(ctx context.Context, q string, vars map[string]interface{},
+	out interface{}) error {
+
+	// Round trip the variables via JSON, so they are typed the same way as the ones
+	// received over HTTP.
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return err
+	}
+	req := &schema.Request{Query: q}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&req.Variables); err != nil {
+		return err
+	}
+
+	resp := s.resolve(ctx, req)
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data.Bytes(), out)
+}
+
+func (s *gqlStore) Last(ctx context.Context) (*BlockRef, error) {
+	var out struct {
+		QueryBlock []BlockRef
+	}
+	q := `{ queryBlock(order: {desc: number}, first: 1) { number hash } }`
+	if err := s.do(ctx, q, nil, &out); err != nil {
+		return nil, err
+	}
+	if len(out.QueryBlock) == 0 {
+		return nil, nil
+	}
+	return &out.QueryBlock[0], nil
+}
+
+func (s *gqlStore) Hash(ctx context.Context, number int64) (string, error) {
+	var out struct {
+		GetBlock *BlockRef
+	}
+	q := `query($n: Int64!) { getBlock(number: $n) { number hash } }`
+	if err := s.do(ctx, q, map[string]interface{}{"n": number}, &out); err != nil {
+		return "", err
+	}
+	if out.GetBlock == nil {
+		return "", nil
+	}
+	return out.GetBlock.Hash, nil
+}
+
+const addBlockMutation = `
+mutation($blk: [AddBlockInput!]!) {
+	addBlock(input: $blk, upsert: true) { numUids }
+}`
+
+func (s *gqlStore) Add(ctx context.Context, blk *Block) error {
+	ts := time.Unix(blk.Timestamp, 0).UTC().Format(time.RFC3339)
+	txns := make([]interface{}, 0, len(blk.Transactions))
+	for _, txn := range blk.Transactions {
+		txns = append(txns, map[string]interface{}{
+			"hash":        txn.Hash,
+			"value":       txn.Value,
+			"fee":         txn.Fee,
+			"timestamp":   ts,
+			"blockNumber": blk.Number,
+			"from":        map[string]interface{}{"address": txn.From},
+			"to":          map[string]interface{}{"address": txn.To},
+		})
+	}
+	// A single mutation, so the block and all its transactions are written in one
+	// transaction. That's what makes the last indexed block a safe checkpoint.
+	vars := map[string]interface{}{
+		"blk": []interface{}{map[string]interface{}{
+			"number":       blk.Number,
+			"hash":         blk.Hash,
+			"parentHash":   blk.ParentHash,
+			"timestamp":    blk.Timestamp,
+			"transactions": txns,
+		}},
+	}
+	return s.do(ctx, addBlockMutation, vars, nil)
+}
+
+func (s *gqlStore) Rollback(ctx context.Context, from int64) error {
+	var out struct {
+		QueryBlock []BlockRef
+	}
+	q := `query($from: Int64!) { queryBlock(filter: {number: {ge: $from}}) { number } }`
+	if err := s.do(ctx, q, map[string]interface{}{"from": from}, &out); err != nil {
+		return err
+	}
+	if len(out.QueryBlock) == 0 {
+		return nil
+	}
+	nums := make([]int64, 0, len(out.QueryBlock))
+	for _, b := range out.QueryBlock {
+		nums = append(nums, b.Number)
+	}
+	// rollbackBlock deletes the blocks along with their transactions in one transaction.
+	m := `mutation($nums: [Int64!]) { rollbackBlock(number: $nums) { numUids } }`
+	return s.do(ctx, m, map[string]interface{}{"nums": nums}, nil)
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+// Package indexer follows the head of an Ethereum chain and keeps the blocks and
+// transactions stored in Outserv up to date with it. The last indexed block is
+// stored alongside the data itself, so the indexer picks up where it left off after
+// a restart. Blocks which get orphaned by a chain reorganization are deleted and
+// replaced with the ones on the canonical chain.
+package indexer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/outcaste-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// Txn is a transaction, with the value and fee in Gwei.
+type Txn struct {
+	Hash  string
+	From  string
+	To    string
+	Value int64
+	Fee   int64
+}
+
+// Block is a block along with its transactions.
+type Block struct {
+	Number       int64
+	Hash         string
+	ParentHash   string
+	Timestamp    int64
+	Transactions []Txn
+}
+
+// BlockRef identifies a block on the chain.
+type BlockRef struct {
+	Number int64  `json:"number"`
+	Hash   string `json:"hash"`
+}
+
+// Source is the chain the blocks are read from.
+type Source interface {
+	// Head returns the number of the latest block.
+	Head(ctx context.Context) (int64, error)
+	// Hash returns the hash of the block with the given number.
+	Hash(ctx context.Context, number int64) (string, error)
+	// Block returns the block with the given number, along with its transactions.
+	Block(ctx context.Context, number int64) (*Block, error)
+}
+
+// Store is where the indexed blocks are written to.
+type Store interface {
+	// Last returns the indexed block with the highest number, or nil if there's none.
+	Last(ctx context.Context) (*BlockRef, error)
+	// Hash returns the hash of the indexed block with the given number, or an empty
+	// string if there's no such block.
+	Hash(ctx context.Context, number int64) (string, error)
+	// Add atomically writes the block along with its transactions.
+	Add(ctx context.Context, blk *Block) error
+	// Rollback deletes all the blocks from the given number onwards, along with
+	// their transactions.
+	Rollback(ctx context.Context, from int64) error
+}
+
+// Options configure the Indexer.
+type Options struct {
+	// Start is the first block to index if nothing has been indexed yet.
+	Start int64
+	// Confirmations is the number of blocks the indexer stays behind the head.
+	Confirmations int64
+	// PollInterval is how often the head of the chain is checked once caught up.
+	PollInterval time.Duration
+	// MaxReorgDepth is the maximum number of blocks rolled back on a reorg.
+	MaxReorgDepth int64
+}
+
+// Progress is the state of the Indexer, as shown in /state.
+type Progress struct {
+	Source    string    `json:"source"`
+	Head      int64     `json:"head"`
+	Indexed   *BlockRef `json:"indexed,omitempty"`
+	Reorgs    int64     `json:"reorgs"`
+	LastError string    `json:"lastError,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Indexer copies blocks from the Source into the Store.
+type Indexer struct {
+	src   Source
+	store Store
+	opts  Options
+
+	// last is the last indexed block. It's loaded from the store on the first sync.
+	last *BlockRef
+
+	mu       sync.RWMutex
+	progress Progress
+}
+
+// New returns an Indexer reading from src and writing to store. The name identifies
+// the source in the progress.
+func New(name string, src Source, store Store, opts Options) *Indexer {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.MaxReorgDepth <= 0 {
+		opts.MaxReorgDepth = 128
+	}
+	return &Indexer{
+		src:      src,
+		store:    store,
+		opts:     opts,
+		progress: Progress{Source: name},
+	}
+}
+
+// NewSource returns a Source for the given endpoint. Endpoints ending in /graphql are
+// treated as the GraphQL API exposed by Geth, others as JSON-RPC.
+func NewSource(url string) Source {
+	if strings.HasSuffix(strings.TrimSuffix(url, "/"), "/graphql") {
+		return newGraphQLSource(url)
+	}
+	return newRPCSource(url)
+}
+
+// Progress returns a copy of the current progress.
+func (ix *Indexer) Progress() Progress {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	p := ix.progress
+	if p.Indexed != nil {
+		ref := *p.Indexed
+		p.Indexed = &ref
+	}
+	return p
+}
+
+func (ix *Indexer) updateProgress(fn func(p *Progress)) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	fn(&ix.progress)
+	ix.progress.UpdatedAt = time.Now()
+}
+
+// Run keeps the Store in sync with the Source until the closer is signalled.
+func (ix *Indexer) Run(closer *z.Closer) {
+	defer closer.Done()
+
+	ticker := time.NewTicker(ix.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		err := ix.Sync(closer.Ctx())
+		if err != nil && closer.Ctx().Err() == nil {
+			glog.Warningf("While indexing %s: %v", ix.progress.Source, err)
+		}
+		ix.updateProgress(func(p *Progress) {
+			p.LastError = ""
+			if err != nil {
+				p.LastError = err.Error()
+			}
+		})
+
+		select {
+		case <-closer.HasBeenClosed():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync indexes all the blocks up to the current head of the chain, minus the
+// configured number of confirmations.
+func (ix *Indexer) Sync(ctx context.Context) error {
+	if ix.last == nil {
+		last, err := ix.store.Last(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "while reading the last indexed block")
+		}
+		if last == nil {
+			last = &BlockRef{Number: ix.opts.Start - 1}
+		}
+		ix.last = last
+		ix.setIndexed()
+	}
+
+	head, err := ix.src.Head(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "while reading the head of the chain")
+	}
+	ix.updateProgress(func(p *Progress) { p.Head = head })
+
+	for ix.last.Number < head-ix.opts.Confirmations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blk, err := ix.src.Block(ctx, ix.last.Number+1)
+		if err != nil {
+			return errors.Wrapf(err, "while reading block %d", ix.last.Number+1)
+		}
+		if ix.last.Hash != "" && blk.ParentHash != ix.last.Hash {
+			// The block we indexed last is no longer part of the chain.
+			if err := ix.rollback(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ix.store.Add(ctx, blk); err != nil {
+			return errors.Wrapf(err, "while indexing block %d", blk.Number)
+		}
+		ix.last = &BlockRef{Number: blk.Number, Hash: blk.Hash}
+		ix.setIndexed()
+	}
+	return nil
+}
+
+// rollback finds the latest indexed block which is still part of the chain, and
+// deletes all the blocks after it.
+func (ix *Indexer) rollback(ctx context.Context) error {
+	num := ix.last.Number
+	var hash string
+	for depth := int64(0); ; depth++ {
+		if depth > ix.opts.MaxReorgDepth {
+			return errors.Errorf("reorg at block %d is deeper than %d blocks",
+				ix.last.Number, ix.opts.MaxReorgDepth)
+		}
+		var err error
+		hash, err = ix.store.Hash(ctx, num)
+		if err != nil {
+			return errors.Wrapf(err, "while reading indexed block %d", num)
+		}
+		if hash == "" {
+			// Either nothing was indexed at this height, or it was indexed without a
+			// hash. Either way, there's nothing to compare against.
+			break
+		}
+		canonical, err := ix.src.Hash(ctx, num)
+		if err != nil {
+			return errors.Wrapf(err, "while reading the hash of block %d", num)
+		}
+		if canonical == hash {
+			break
+		}
+		num--
+	}
+
+	glog.Infof("Chain reorg detected. Rolling back indexed blocks from %d to %d",
+		num+1, ix.last.Number)
+	if err := ix.store.Rollback(ctx, num+1); err != nil {
+		return errors.Wrapf(err, "while rolling back blocks from %d", num+1)
+	}
+	ix.last = &BlockRef{Number: num, Hash: hash}
+	ix.setIndexed()
+	ix.updateProgress(func(p *Progress) { p.Reorgs++ })
+	return nil
+}
+
+func (ix *Indexer) setIndexed() {
+	ref := *ix.last
+	ix.updateProgress(func(p *Progress) {
+		if ref.Number < ix.opts.Start {
+			p.Indexed = nil
+			return
+		}
+		p.Indexed = &ref
+	})
+}
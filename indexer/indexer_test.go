@@ -0,0 +1,219 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedCall struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+// rpcStandIn replays the JSON-RPC responses recorded in a file under testdata.
+type rpcStandIn struct {
+	sync.Mutex
+	calls map[string]json.RawMessage
+}
+
+func callKey(method string, params json.RawMessage) string {
+	var p interface{}
+	if err := json.Unmarshal(params, &p); err != nil {
+		panic(err)
+	}
+	data, _ := json.Marshal(p)
+	return method + string(data)
+}
+
+func (s *rpcStandIn) load(t *testing.T, file string) {
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	var calls []recordedCall
+	require.NoError(t, json.Unmarshal(data, &calls))
+
+	s.Lock()
+	defer s.Unlock()
+	s.calls = make(map[string]json.RawMessage)
+	for _, c := range calls {
+		s.calls[callKey(c.Method, c.Params)] = c.Result
+	}
+}
+
+func (s *rpcStandIn) result(t *testing.T, method string, params ...interface{}) json.RawMessage {
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	s.Lock()
+	defer s.Unlock()
+	return s.calls[callKey(method, data)]
+}
+
+func (s *rpcStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var reqs []rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	resps := make([]interface{}, 0, len(reqs))
+	// Respond in the reverse order, as nodes are free to reorder a batch.
+	for i := len(reqs) - 1; i >= 0; i-- {
+		req := reqs[i]
+		params, _ := json.Marshal(req.Params)
+		result, ok := s.calls[callKey(req.Method, params)]
+		if !ok {
+			resps = append(resps, map[string]interface{}{
+				"jsonrpc": "2.0", "id": req.Id,
+				"error": rpcError{Code: -32000, Message: "no recorded response"},
+			})
+			continue
+		}
+		resps = append(resps, map[string]interface{}{
+			"jsonrpc": "2.0", "id": req.Id, "result": result,
+		})
+	}
+	_ = json.NewEncoder(w).Encode(resps)
+}
+
+// memStore keeps the indexed blocks in memory.
+type memStore struct {
+	blocks map[int64]*Block
+}
+
+func (s *memStore) Last(ctx context.Context) (*BlockRef, error) {
+	var last *BlockRef
+	for _, b := range s.blocks {
+		if last == nil || b.Number > last.Number {
+			last = &BlockRef{Number: b.Number, Hash: b.Hash}
+		}
+	}
+	return last, nil
+}
+
+func (s *memStore) Hash(ctx context.Context, number int64) (string, error) {
+	if b, ok := s.blocks[number]; ok {
+		return b.Hash, nil
+	}
+	return "", nil
+}
+
+func (s *memStore) Add(ctx context.Context, blk *Block) error {
+	s.blocks[blk.Number] = blk
+	return nil
+}
+
+func (s *memStore) Rollback(ctx context.Context, from int64) error {
+	for num := range s.blocks {
+		if num >= from {
+			delete(s.blocks, num)
+		}
+	}
+	return nil
+}
+
+func (s *memStore) numbers() []int64 {
+	var nums []int64
+	for num := range s.blocks {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	return nums
+}
+
+func blockHash(t *testing.T, rpc *rpcStandIn, hexNum string) string {
+	var blk struct{ Hash string }
+	require.NoError(t, json.Unmarshal(rpc.result(t, "eth_getBlockByNumber", hexNum, false), &blk))
+	require.NotEmpty(t, blk.Hash)
+	return blk.Hash
+}
+
+func TestIndexerFollowsReorg(t *testing.T) {
+	rpc := &rpcStandIn{}
+	rpc.load(t, "testdata/rpc_main.json")
+	srv := httptest.NewServer(rpc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := &memStore{blocks: make(map[int64]*Block)}
+	ix := New("rpc", NewSource(srv.URL), store, Options{Start: 100})
+
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []int64{100, 101, 102}, store.numbers())
+	require.Equal(t, blockHash(t, rpc, "0x66"), store.blocks[102].Hash)
+	require.Equal(t, store.blocks[101].Hash, store.blocks[102].ParentHash)
+
+	// The contract creation in block 102 is skipped.
+	require.Len(t, store.blocks[101].Transactions, 1)
+	require.Len(t, store.blocks[102].Transactions, 0)
+	txn := store.blocks[101].Transactions[0]
+	require.Equal(t, int64(1e9), txn.Value)
+	// 21000 gas at 20 Gwei.
+	require.Equal(t, int64(420000), txn.Fee)
+
+	p := ix.Progress()
+	require.Equal(t, int64(102), p.Head)
+	require.Equal(t, &BlockRef{Number: 102, Hash: store.blocks[102].Hash}, p.Indexed)
+	require.Equal(t, int64(0), p.Reorgs)
+
+	// Nothing changes if the chain doesn't move.
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []int64{100, 101, 102}, store.numbers())
+
+	// Blocks 101 and 102 get replaced by a longer chain.
+	block100 := store.blocks[100].Hash
+	rpc.load(t, "testdata/rpc_reorg.json")
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []int64{100, 101, 102, 103}, store.numbers())
+	require.Equal(t, block100, store.blocks[100].Hash)
+	require.Equal(t, block100, store.blocks[101].ParentHash)
+	for _, num := range []string{"0x65", "0x66", "0x67"} {
+		hash := blockHash(t, rpc, num)
+		found := false
+		for _, b := range store.blocks {
+			found = found || b.Hash == hash
+		}
+		require.True(t, found, "block %s wasn't indexed", num)
+	}
+
+	require.Len(t, store.blocks[101].Transactions, 1)
+	txn = store.blocks[101].Transactions[0]
+	require.Equal(t, int64(2e9), txn.Value)
+	// The effective gas price of 1 Gwei from the receipt takes precedence.
+	require.Equal(t, int64(21000), txn.Fee)
+
+	p = ix.Progress()
+	require.Equal(t, int64(103), p.Head)
+	require.Equal(t, int64(103), p.Indexed.Number)
+	require.Equal(t, int64(1), p.Reorgs)
+}
+
+func TestIndexerResumes(t *testing.T) {
+	rpc := &rpcStandIn{}
+	rpc.load(t, "testdata/rpc_main.json")
+	srv := httptest.NewServer(rpc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := &memStore{blocks: make(map[int64]*Block)}
+	ix := New("rpc", NewSource(srv.URL), store, Options{Start: 100, Confirmations: 1})
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []int64{100, 101}, store.numbers())
+
+	// A new indexer picks up from the last block in the store, even though it was
+	// asked to start earlier.
+	delete(store.blocks, 100)
+	ix = New("rpc", NewSource(srv.URL), store, Options{Start: 100})
+	require.NoError(t, ix.Sync(ctx))
+	require.Equal(t, []int64{101, 102}, store.numbers())
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var gwei = big.NewInt(1e9)
+
+// quantity is a number encoded either as a JSON number, or as a decimal or hex string.
+// JSON-RPC uses hex strings, while Geth's GraphQL API uses all of them across versions.
+type quantity struct {
+	big.Int
+}
+
+func (q *quantity) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		q.SetInt64(0)
+		return nil
+	}
+	if _, ok := q.SetString(s, 0); !ok {
+		return errors.Errorf("invalid quantity: %s", data)
+	}
+	return nil
+}
+
+// toGwei converts an amount in Wei to Gwei.
+func toGwei(wei *big.Int) int64 {
+	return new(big.Int).Div(wei, gwei).Int64()
+}
+
+// fee returns gasUsed * gasPrice in Gwei.
+func fee(gasUsed, gasPrice *big.Int) int64 {
+	return toGwei(new(big.Int).Mul(gasUsed, gasPrice))
+}
+
+// httpPost sends the request as JSON to url, and decodes the response into out.
+func httpPost(ctx context.Context, client *http.Client, url string,
+	req interface{}, out interface{}) error {
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s returned status %d: %s", url, resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+type rpcRequest struct {
+	Version string        `json:"jsonrpc"`
+	Id      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcTxn struct {
+	Hash     string   `json:"hash"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Value    quantity `json:"value"`
+	GasPrice quantity `json:"gasPrice"`
+}
+
+type rpcBlock struct {
+	Number       quantity `json:"number"`
+	Hash         string   `json:"hash"`
+	ParentHash   string   `json:"parentHash"`
+	Timestamp    quantity `json:"timestamp"`
+	Transactions []rpcTxn `json:"transactions"`
+}
+
+type rpcReceipt struct {
+	GasUsed quantity `json:"gasUsed"`
+	// EffectiveGasPrice is only set since the London fork. Before that, the gas price
+	// of the transaction applies.
+	EffectiveGasPrice *quantity `json:"effectiveGasPrice"`
+}
+
+// rpcSource reads blocks via the Ethereum JSON-RPC API.
+type rpcSource struct {
+	url    string
+	client *http.Client
+}
+
+func newRPCSource(url string) *rpcSource {
+	return &rpcSource{url: url, client: &http.Client{Timeout: time.Minute}}
+}
+
+// call sends the given calls as a single batch, and decodes the result of each call
+// into the corresponding entry of results.
+func (s *rpcSource) call(ctx context.Context, calls []rpcRequest, results []interface{}) error {
+	for i := range calls {
+		calls[i].Version = "2.0"
+		calls[i].Id = i
+	}
+	var resps []rpcResponse
+	if err := httpPost(ctx, s.client, s.url, calls, &resps); err != nil {
+		return err
+	}
+	if len(resps) != len(calls) {
+		return errors.Errorf("got %d responses for %d calls", len(resps), len(calls))
+	}
+	// The responses to a batch can come in any order.
+	for _, resp := range resps {
+		if resp.Id < 0 || resp.Id >= len(calls) {
+			return errors.Errorf("got response with unknown id %d", resp.Id)
+		}
+		if resp.Error != nil {
+			return errors.Errorf("%s failed with code %d: %s",
+				calls[resp.Id].Method, resp.Error.Code, resp.Error.Message)
+		}
+		if err := json.Unmarshal(resp.Result, results[resp.Id]); err != nil {
+			return errors.Wrapf(err, "while decoding the result of %s", calls[resp.Id].Method)
+		}
+	}
+	return nil
+}
+
+func (s *rpcSource) Head(ctx context.Context) (int64, error) {
+	var head quantity
+	calls := []rpcRequest{{Method: "eth_blockNumber", Params: []interface{}{}}}
+	err := s.call(ctx, calls, []interface{}{&head})
+	return head.Int64(), err
+}
+
+// getBlock decodes the block with the given number into out, which must be a pointer
+// to a pointer, so it's left nil if there's no such block. If full is false, the
+// transactions are only returned as hashes.
+func (s *rpcSource) getBlock(ctx context.Context, number int64, full bool,
+	out interface{}) error {
+
+	calls := []rpcRequest{{
+		Method: "eth_getBlockByNumber",
+		Params: []interface{}{"0x" + strconv.FormatInt(number, 16), full},
+	}}
+	return s.call(ctx, calls, []interface{}{out})
+}
+
+func (s *rpcSource) Hash(ctx context.Context, number int64) (string, error) {
+	var blk *struct {
+		Hash string `json:"hash"`
+	}
+	if err := s.getBlock(ctx, number, false, &blk); err != nil {
+		return "", err
+	}
+	if blk == nil {
+		return "", errors.Errorf("block %d not found", number)
+	}
+	return blk.Hash, nil
+}
+
+func (s *rpcSource) Block(ctx context.Context, number int64) (*Block, error) {
+	var blk *rpcBlock
+	if err := s.getBlock(ctx, number, true, &blk); err != nil {
+		return nil, err
+	}
+	if blk == nil {
+		return nil, errors.Errorf("block %d not found", number)
+	}
+	if blk.Number.Int64() != number {
+		return nil, errors.Errorf("asked for block %d, got %d", number, blk.Number.Int64())
+	}
+
+	// The gas used by a transaction is only part of its receipt. Fetch them all in one go.
+	calls := make([]rpcRequest, 0, len(blk.Transactions))
+	results := make([]interface{}, 0, len(blk.Transactions))
+	receipts := make([]rpcReceipt, len(blk.Transactions))
+	for i, txn := range blk.Transactions {
+		calls = append(calls, rpcRequest{
+			Method: "eth_getTransactionReceipt",
+			Params: []interface{}{txn.Hash},
+		})
+		results = append(results, &receipts[i])
+	}
+	if len(calls) > 0 {
+		if err := s.call(ctx, calls, results); err != nil {
+			return nil, errors.Wrapf(err, "while reading receipts of block %d", number)
+		}
+	}
+
+	out := &Block{
+		Number:     number,
+		Hash:       blk.Hash,
+		ParentHash: blk.ParentHash,
+		Timestamp:  blk.Timestamp.Int64(),
+	}
+	for i, txn := range blk.Transactions {
+		if len(txn.To) == 0 || len(txn.From) == 0 {
+			// Contract creation.
+			continue
+		}
+		price := &txn.GasPrice.Int
+		if r := receipts[i]; r.EffectiveGasPrice != nil {
+			price = &r.EffectiveGasPrice.Int
+		}
+		out.Transactions = append(out.Transactions, Txn{
+			Hash:  txn.Hash,
+			From:  txn.From,
+			To:    txn.To,
+			Value: toGwei(&txn.Value.Int),
+			Fee:   fee(&receipts[i].GasUsed.Int, price),
+		})
+	}
+	return out, nil
+}
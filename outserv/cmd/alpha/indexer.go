@@ -0,0 +1,69 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package alpha
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/golang/glog"
+	"github.com/outcaste-io/outserv/edgraph"
+	"github.com/outcaste-io/outserv/graphql/admin"
+	"github.com/outcaste-io/outserv/graphql/schema"
+	"github.com/outcaste-io/outserv/indexer"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/ristretto/z"
+)
+
+// ethIndexer is set if this Alpha indexes an Ethereum chain, as configured via --index.
+var ethIndexer *indexer.Indexer
+
+// setupIndexer starts indexing the chain set via --index into the GraphQL types defined
+// in importers/eth/schema.graphql, in the given namespace.
+func setupIndexer(mainServer *admin.GqlHandler, closer *z.Closer) {
+	conf := z.NewSuperFlag(Alpha.Conf.GetString("index")).MergeAndCheckDefault(
+		worker.IndexDefaults)
+	url := conf.GetString("eth")
+	if url == "" {
+		return
+	}
+
+	ns := conf.GetUint64("namespace")
+	resolve := func(ctx context.Context, req *schema.Request) *schema.Response {
+		if err := admin.LazyLoadSchema(ns); err != nil {
+			return schema.ErrorResponse(err)
+		}
+		// The indexer runs on behalf of the Alpha, not a user. So, skip the ACL checks.
+		ctx = context.WithValue(x.AttachNamespace(ctx, ns), edgraph.Authorize, false)
+		return mainServer.ResolveWithNs(ctx, ns, req)
+	}
+	ethIndexer = indexer.New(url, indexer.NewSource(url), indexer.NewGraphQLStore(resolve),
+		indexer.Options{
+			Start:         conf.GetInt64("start"),
+			Confirmations: conf.GetInt64("confirmations"),
+			PollInterval:  conf.GetDuration("poll-interval"),
+			MaxReorgDepth: conf.GetInt64("max-reorg"),
+		})
+
+	glog.Infof("Indexing Ethereum chain at %s into namespace %#x", url, ns)
+	closer.AddRunning(1)
+	go ethIndexer.Run(closer)
+}
+
+// addIndexerState adds the progress of the indexer, if any, to the state JSON.
+func addIndexerState(state []byte) ([]byte, error) {
+	if ethIndexer == nil {
+		return state, nil
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(state, &out); err != nil {
+		return nil, err
+	}
+	progress, err := json.Marshal(ethIndexer.Progress())
+	if err != nil {
+		return nil, err
+	}
+	out["indexer"] = progress
+	return json.Marshal(out)
+}
@@ -164,6 +164,20 @@ func init() {
 			"Number of pending mutation proposals. Useful for rate limiting.").
 		String())
 
+	flag.String("rebalance", worker.RebalanceDefaults, z.NewSuperFlagHelp(worker.RebalanceDefaults).
+		Head("Tablet rebalancing options").
+		Flag("interval",
+			"Interval at which Zero checks the sizes of the groups, and moves a tablet from the "+
+				"largest group to the smallest one. Set to 0 to disable rebalancing.").
+		Flag("ratio",
+			"Move a tablet only if the largest group is at least this many times the size of "+
+				"the smallest one.").
+		Flag("min-size-mb",
+			"Move a tablet only if the largest group is at least this large.").
+		Flag("dry-run",
+			"Only log the tablet moves that would be made, without making them.").
+		String())
+
 	flag.String("security", worker.SecurityDefaults, z.NewSuperFlagHelp(worker.SecurityDefaults).
 		Head("Security options").
 		Flag("token",
@@ -221,6 +235,57 @@ func init() {
 			"Enables extensions in GraphQL response body.").
 		Flag("poll-interval",
 			"The polling interval for GraphQL subscription.").
+		Flag("push-subscriptions",
+			"Re-run a GraphQL subscription only when a commit touches the predicates it reads. "+
+				"The poll-interval is then used as a fallback, for the subscriptions whose "+
+				"predicates are served by another group.").
+		Flag("persisted-queries-only",
+			"Only execute the queries on /graphql which were added via the addPersistedQueries "+
+				"admin mutation, either by their sha256Hash or by their text. Unknown queries "+
+				"are rejected, instead of being persisted automatically.").
+		Flag("max-cost",
+			"Reject operations whose estimated cost is above this, if non-zero. Every field "+
+				"costs 1, or custom-cost. The cost of the fields under a list is multiplied by "+
+				"its first argument, or by 100 if it isn't set.").
+		Flag("custom-cost",
+			"The cost of a field resolved via @custom or @lambda.").
+		Flag("rate-limit",
+			"The estimated cost that a client can spend per rate-interval, if non-zero. Further "+
+				"queries and mutations are rejected till the interval is over.").
+		Flag("rate-interval",
+			"The interval after which the clients get their rate-limit back.").
+		Flag("rate-key",
+			"What identifies a client for rate-limit: ip, header:<name> like header:X-Api-Key, "+
+				"or claim:<name> for a claim of the JWT used for @auth. The IP is used if the "+
+				"header or the claim isn't set.").
+		Flag("rate-trust-header",
+			"Identify the clients by the header of rate-key. Set it only if that header is set "+
+				"by a proxy in front of Outserv, as clients can change it to get a fresh "+
+				"rate-limit. Otherwise the IP is used instead.").
+		Flag("cache-mb",
+			"The size of the cache for the responses of the queries with @cacheControl, in MB. "+
+				"A response is served from the cache for up to its maxAge, till a commit "+
+				"touches any of the predicates it read. Set it to 0 to disable the cache.").
+		String())
+
+	flag.String("index", worker.IndexDefaults, z.NewSuperFlagHelp(worker.IndexDefaults).
+		Head("Ethereum indexer options").
+		Flag("eth",
+			"The JSON-RPC endpoint of an Ethereum node to follow, or its GraphQL endpoint if "+
+				"the URL ends in /graphql. The indexer is disabled if this isn't set. Set it on "+
+				"a single Alpha only.").
+		Flag("namespace",
+			"The namespace to index into. Its GraphQL schema must contain the types in "+
+				"importers/eth/schema.graphql.").
+		Flag("start",
+			"The first block to index, if nothing has been indexed yet. Otherwise, indexing "+
+				"resumes from the last indexed block.").
+		Flag("confirmations",
+			"The number of blocks to stay behind the head of the chain.").
+		Flag("poll-interval",
+			"How often to check for new blocks, once caught up with the chain.").
+		Flag("max-reorg",
+			"The maximum number of blocks rolled back on a chain reorganization.").
 		String())
 
 	flag.String("lambda", worker.LambdaDefaults, z.NewSuperFlagHelp(worker.LambdaDefaults).
@@ -238,41 +303,50 @@ func init() {
 			"Restarts the lambda server after given duration of unresponsiveness").
 		String())
 
-	// flag.String("cdc", worker.CDCDefaults, z.NewSuperFlagHelp(worker.CDCDefaults).
-	// 	Head("Change Data Capture options").
-	// 	Flag("file",
-	// 		"The path where audit logs will be stored.").
-	// 	Flag("kafka",
-	// 		"A comma separated list of Kafka hosts.").
-	// 	Flag("sasl-user",
-	// 		"The SASL username for Kafka.").
-	// 	Flag("sasl-password",
-	// 		"The SASL password for Kafka.").
-	// 	Flag("sasl-mechanism",
-	// 		"The SASL mechanism for Kafka (PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)").
-	// 	Flag("ca-cert",
-	// 		"The path to CA cert file for TLS encryption.").
-	// 	Flag("client-cert",
-	// 		"The path to client cert file for TLS encryption.").
-	// 	Flag("client-key",
-	// 		"The path to client key file for TLS encryption.").
-	// 	String())
-
-	// flag.String("audit", worker.AuditDefaults, z.NewSuperFlagHelp(worker.AuditDefaults).
-	// 	Head("Audit options").
-	// 	Flag("output",
-	// 		`[stdout, /path/to/dir] This specifies where audit logs should be output to.
-	// 		"stdout" is for standard output. You can also specify the directory where audit logs
-	// 		will be saved. When stdout is specified as output other fields will be ignored.`).
-	// 	Flag("compress",
-	// 		"Enables the compression of old audit logs.").
-	// 	Flag("encrypt-file",
-	// 		"The path to the key file to be used for audit log encryption.").
-	// 	Flag("days",
-	// 		"The number of days audit logs will be preserved.").
-	// 	Flag("size",
-	// 		"The audit log max size in MB after which it will be rolled over.").
-	// 	String())
+	flag.String("cdc", worker.CDCDefaults, z.NewSuperFlagHelp(worker.CDCDefaults).
+		Head("Change Data Capture options").
+		Flag("file",
+			"The directory where the change data capture events will be stored.").
+		Flag("kafka",
+			"A comma separated list of Kafka hosts.").
+		Flag("sasl-user",
+			"The SASL username for Kafka.").
+		Flag("sasl-password",
+			"The SASL password for Kafka.").
+		Flag("sasl-mechanism",
+			"The SASL mechanism for Kafka (PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)").
+		Flag("tls",
+			"Use TLS to connect to Kafka, with the system CA pool if no ca-cert is given.").
+		Flag("ca-cert",
+			"The path to CA cert file for TLS encryption.").
+		Flag("client-cert",
+			"The path to client cert file for TLS encryption.").
+		Flag("client-key",
+			"The path to client key file for TLS encryption.").
+		Flag("max-pending-mb",
+			"The size of the events kept in memory while they can't be sent. New mutations "+
+				"are rejected once they exceed it, till the sink catches up.").
+		String())
+
+	flag.String("audit", worker.AuditDefaults, z.NewSuperFlagHelp(worker.AuditDefaults).
+		Head("Audit options").
+		Flag("output",
+			`[stdout, /path/to/dir] This specifies where audit logs should be output to.
+			"stdout" is for standard output. You can also specify the directory where audit logs
+			will be saved. When stdout is specified as output other fields will be ignored.`).
+		Flag("compress",
+			"Enables the compression of old audit logs.").
+		Flag("encrypt-file",
+			"The path to the key file to be used for audit log encryption. The encrypted logs "+
+				"can be read back with the decrypt command.").
+		Flag("days",
+			"The number of days audit logs will be preserved.").
+		Flag("size",
+			"The audit log max size in MB after which it will be rolled over.").
+		Flag("redact",
+			"Comma separated names of the GraphQL variables whose values are left out of the "+
+				"audit logs, at any depth of nesting.").
+		String())
 
 	flag.String("wallet", billing.WalletDefaults, z.NewSuperFlagHelp(billing.WalletDefaults).
 		Head("Wallet options").
@@ -432,7 +506,12 @@ func stateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err = w.Write(aResp.Json); err != nil {
+	state, err := addIndexerState(aResp.Json)
+	if err != nil {
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	if _, err = w.Write(state); err != nil {
 		x.SetStatus(w, x.Error, err.Error())
 		return
 	}
@@ -638,8 +717,12 @@ func setupServer() {
 	baseMux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
 		namespace := x.ExtractNamespaceHTTP(r)
 		r.Header.Set("resolver", strconv.FormatUint(namespace, 10))
-		// No need to load schema here. It should be loaded when schema is
-		// updated, or /probe/graphql is called.
+		// The schema gets loaded when it's updated, or /probe/graphql is called. A namespace
+		// created via another Alpha might not have been loaded yet, though.
+		if err := admin.LazyLoadSchema(namespace); err != nil {
+			admin.WriteErrorResponse(w, r, err)
+			return
+		}
 		mainServer.HTTPHandler().ServeHTTP(w, r)
 	})
 	baseMux.Handle("/probe/graphql", graphqlProbeHandler(gqlHealthStore, globalEpoch))
@@ -669,6 +752,7 @@ func setupServer() {
 
 	// Initialize the lambda server
 	setupLambdaServer(x.ServerCloser)
+	setupIndexer(mainServer, x.ServerCloser)
 	// Initialize the servers.
 	x.RegisterExporters(Alpha.Conf, "outserv.graphql")
 	x.ServerCloser.AddRunning(2)
@@ -689,7 +773,6 @@ func setupServer() {
 
 	// Audit needs groupId and nodeId to initialize audit files
 	// Therefore we wait for the cluster initialization to be done.
-	// Audit is enterprise feature.
 	x.Check(audit.InitAuditorIfNecessary(worker.Config.Audit, nil))
 }
 
@@ -763,9 +846,12 @@ func run() {
 	x.Check(err)
 
 	raft := z.NewSuperFlag(Alpha.Conf.GetString("raft")).MergeAndCheckDefault(worker.RaftDefaults)
+	rebalance := z.NewSuperFlag(Alpha.Conf.GetString("rebalance")).MergeAndCheckDefault(
+		worker.RebalanceDefaults)
 	x.WorkerConfig = x.WorkerOptions{
 		PeerAddr:            strings.Split(Alpha.Conf.GetString("peer"), ","),
 		Raft:                raft,
+		Rebalance:           rebalance,
 		WhiteListedIPRanges: ips,
 		StrictMutations:     opts.MutationsMode == worker.StrictMutations,
 		AclEnabled:          keys.AclKey != nil,
@@ -807,6 +893,33 @@ func run() {
 		Debug:         graphql.GetBool("debug"),
 		Extensions:    graphql.GetBool("extensions"),
 		PollInterval:  graphql.GetDuration("poll-interval"),
+
+		PushSubscriptions:    graphql.GetBool("push-subscriptions"),
+		PersistedQueriesOnly: graphql.GetBool("persisted-queries-only"),
+		MaxCost:              graphql.GetInt64("max-cost"),
+		CustomCost:           graphql.GetInt64("custom-cost"),
+		RateLimit:            graphql.GetInt64("rate-limit"),
+		RateInterval:         graphql.GetDuration("rate-interval"),
+		RateKey:              graphql.GetString("rate-key"),
+		RateTrustHeader:      graphql.GetBool("rate-trust-header"),
+		CacheMb:              graphql.GetInt64("cache-mb"),
+	}
+	switch key := x.Config.GraphQL.RateKey; {
+	case key == "ip", strings.HasPrefix(key, "header:"), strings.HasPrefix(key, "claim:"):
+	default:
+		glog.Errorf("expecting --graphql rate-key to be ip, header:<name> or claim:<name>, "+
+			"got: %s", key)
+		return
+	}
+	if strings.HasPrefix(x.Config.GraphQL.RateKey, "header:") &&
+		!x.Config.GraphQL.RateTrustHeader {
+		glog.Warningf("--graphql rate-key is %s but rate-trust-header isn't set, so the "+
+			"clients are identified by their IP.", x.Config.GraphQL.RateKey)
+	}
+	if x.Config.GraphQL.RateLimit > 0 && x.Config.GraphQL.RateInterval <= 0 {
+		glog.Errorf("expecting --graphql rate-interval to be positive, got: %s",
+			x.Config.GraphQL.RateInterval)
+		return
 	}
 	lambda := z.NewSuperFlag(Alpha.Conf.GetString("lambda")).MergeAndCheckDefault(
 		worker.LambdaDefaults)
@@ -927,7 +1040,7 @@ func run() {
 		glog.Errorf("Grpc serve returned with error: %+v", err)
 	}()
 
-	updaters := z.NewCloser(2)
+	updaters := z.NewCloser(4)
 	billing.Run(updaters)
 	zero.Run(updaters, bindall)
 
@@ -935,27 +1048,26 @@ func run() {
 	// that's needed before GraphQL schema can be loaded in setupServer.
 	worker.StartRaftNodes(worker.State.WALstore, bindall)
 
-	// writeUIDFile in boot loader.
-	data, err := ioutil.ReadFile(path.Join(x.WorkerConfig.Dir.Posting, "max_uid"))
-	if err == nil {
-		str := strings.TrimSpace(string(data))
-		maxUid, err := strconv.ParseUint(str, 0, 64)
-		if err == nil {
-			glog.Infof("Found Max UID in p directory: %#x\n", maxUid)
-			x.Check(zero.BumpMaxUid(context.Background(), maxUid))
-		} else {
-			glog.Infof("Unable to parse max_uid. Got error: %v", err)
-		}
-	} else {
-		glog.Infof("No max_uid file found. Got error: %v\n", err)
+	// writeUIDFile in boot loader, and writeLeases in restore.
+	if maxUid, ok := readLease("max_uid"); ok {
+		glog.Infof("Found Max UID in p directory: %#x\n", maxUid)
+		x.Check(zero.BumpMaxUid(context.Background(), maxUid))
+	}
+	if maxNsid, ok := readLease("max_nsid"); ok {
+		glog.Infof("Found Max namespace ID in p directory: %#x\n", maxNsid)
+		x.Check(zero.BumpMaxNsid(context.Background(), maxNsid))
+	}
+	if maxTs, ok := readLease("max_ts"); ok {
+		glog.Infof("Found Max timestamp in p directory: %#x\n", maxTs)
+		x.Check(worker.BumpTimestamp(context.Background(), maxTs))
 	}
 
 	atomic.AddUint32(&initDone, 1)
 
 	// initialization of the admin account can only be done after raft nodes are running
 	// and health check passes
-	// edgraph.ResetAcl(updaters)
-	// edgraph.RefreshAcls(updaters)
+	go edgraph.ResetAcl(updaters)
+	go edgraph.RefreshAcls(updaters)
 
 	// This must be called here, because setupServer does DQL queries, which
 	// would return error if health check fails.
@@ -992,3 +1104,19 @@ func run() {
 
 	glog.Infoln("Server shutdown. Bye!")
 }
+
+// readLease reads the lease written to the file name in the postings directory, if any.
+func readLease(name string) (uint64, bool) {
+	data, err := ioutil.ReadFile(path.Join(x.WorkerConfig.Dir.Posting, name))
+	if err != nil {
+		glog.Infof("No %s file found. Got error: %v\n", name, err)
+		return 0, false
+	}
+	str := strings.TrimSpace(string(data))
+	lease, err := strconv.ParseUint(str, 0, 64)
+	if err != nil {
+		glog.Infof("Unable to parse %s. Got error: %v", name, err)
+		return 0, false
+	}
+	return lease, true
+}
@@ -34,6 +34,7 @@ type options struct {
 	keyfile x.Sensitive
 	file    string
 	output  string
+	audit   bool
 }
 
 var Decrypt x.SubCommand
@@ -42,7 +43,8 @@ func init() {
 	Decrypt.Cmd = &cobra.Command{
 		Use:   "decrypt",
 		Short: "Run the Outserv decryption tool",
-		Long:  "A tool to decrypt an export file created by an encrypted Outserv cluster",
+		Long: "A tool to decrypt an export file created by an encrypted Outserv cluster, " +
+			"or an encrypted audit log.",
 		Run: func(cmd *cobra.Command, args []string) {
 			run()
 		},
@@ -53,6 +55,8 @@ func init() {
 	flag := Decrypt.Cmd.Flags()
 	flag.StringP("file", "f", "", "Path to file to decrypt.")
 	flag.StringP("out", "o", "", "Path to the decrypted file.")
+	flag.Bool("audit", false, "The file is an audit log, encrypted with the encrypt-file of "+
+		"the audit flag. The decrypted file holds the JSON lines of the log.")
 	ee.RegisterEncFlag(flag)
 }
 func run() {
@@ -66,6 +70,11 @@ func run() {
 		file:    Decrypt.Conf.GetString("file"),
 		output:  Decrypt.Conf.GetString("out"),
 		keyfile: keys.EncKey,
+		audit:   Decrypt.Conf.GetBool("audit"),
+	}
+	if opts.audit {
+		decryptAuditLog(opts)
+		return
 	}
 
 	f, err := os.Open(opts.file)
@@ -98,3 +107,28 @@ func run() {
 	x.Check(err)
 	glog.Infof("Done.")
 }
+
+func decryptAuditLog(opts options) {
+	f, err := os.Open(opts.file)
+	if err != nil {
+		glog.Fatalf("Error opening file: %v\n", err)
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	// The rotated audit logs get compressed after they're encrypted.
+	if strings.HasSuffix(strings.ToLower(opts.file), ".gz") {
+		gz, err := gzip.NewReader(f)
+		x.Check(err)
+		defer gz.Close()
+		reader = gz
+	}
+	outf, err := os.OpenFile(opts.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		glog.Fatalf("Error while opening output file: %v\n", err)
+	}
+	glog.Infof("Decrypting audit log %s\n", opts.file)
+	glog.Infof("Writing to %v\n", opts.output)
+	x.Checkf(x.DecryptLog(opts.keyfile, reader, outf), "while decrypting the audit log")
+	x.Check(outf.Close())
+	glog.Infof("Done.")
+}
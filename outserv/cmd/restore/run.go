@@ -0,0 +1,173 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package restore
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/outcaste-io/outserv/badger"
+	bpb "github.com/outcaste-io/outserv/badger/pb"
+	"github.com/outcaste-io/outserv/ee"
+	"github.com/outcaste-io/outserv/posting"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+)
+
+var Restore x.SubCommand
+
+func init() {
+	Restore.Cmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Run the Outserv restore tool",
+		Long: `
+Restore rebuilds the postings directories of all the groups from the backups at a location,
+up to the chosen backup. The full backup the chain starts from, and all the incremental
+backups after it are applied in order. The postings directory of group N is written to
+<postings>/pN, and must not exist yet. The cluster needs to be started with the same
+groups as the one that was backed up. The uids, namespaces and timestamps used by the
+restored data are written next to it, and Alpha moves its leases past them on start.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer x.StartProfile(Restore.Conf).Stop()
+			if err := run(); err != nil {
+				x.Fatalf("Error while restoring: %v", err)
+			}
+		},
+		Annotations: map[string]string{"group": "data-load"},
+	}
+	Restore.EnvPrefix = "OUTSERV_RESTORE"
+	Restore.Cmd.SetHelpTemplate(x.NonRootTemplate)
+
+	flag := Restore.Cmd.Flags()
+	flag.StringP("location", "l", "",
+		"Location of the backups: e.g. Minio or S3 bucket or /absolute/path.")
+	flag.StringP("postings", "p", "",
+		"Directory under which the postings directories of the groups are written.")
+	flag.Int("backup-num", 0,
+		"Number of the backup to restore up to. The latest backup is restored if set to zero.")
+	flag.String("access-key", "", "Access key credential for the location.")
+	flag.String("secret-key", "", "Secret key credential for the location.")
+	flag.String("session-token", "", "AWS session token, if required.")
+	flag.Bool("anonymous", false,
+		"Set to true to allow restoring from S3 or Minio bucket that requires no credentials.")
+	ee.RegisterEncFlag(flag)
+}
+
+func run() error {
+	keys, err := ee.GetKeys(Restore.Conf)
+	if err != nil {
+		return err
+	}
+	location := Restore.Conf.GetString("location")
+	postings := Restore.Conf.GetString("postings")
+	if location == "" || postings == "" {
+		return errors.Errorf("Both --location and --postings must be set")
+	}
+
+	handler, err := worker.NewBackupHandler(location, &x.MinioCredentials{
+		AccessKey:    Restore.Conf.GetString("access-key"),
+		SecretKey:    Restore.Conf.GetString("secret-key"),
+		SessionToken: Restore.Conf.GetString("session-token"),
+		Anonymous:    Restore.Conf.GetBool("anonymous"),
+	})
+	if err != nil {
+		return err
+	}
+	master, err := worker.ReadMasterManifest(handler)
+	if err != nil {
+		return err
+	}
+	chain, err := worker.BackupChain(master.Manifests, Restore.Conf.GetInt("backup-num"))
+	if err != nil {
+		return err
+	}
+	for _, m := range chain {
+		if m.Encrypted && len(keys.EncKey) == 0 {
+			return errors.Errorf("Backup %d is encrypted, but no encryption key is set",
+				m.BackupNum)
+		}
+	}
+
+	var gids []uint32
+	for gid := range chain[0].Groups {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	for _, gid := range gids {
+		dir := filepath.Join(postings, fmt.Sprintf("p%d", gid))
+		if _, err := os.Stat(dir); err == nil {
+			return errors.Errorf("Postings directory %s already exists", dir)
+		}
+		if err := restoreGroup(handler, chain, gid, dir, keys.EncKey); err != nil {
+			return errors.Wrapf(err, "while restoring group %d", gid)
+		}
+		if err := writeLeases(dir, chain[len(chain)-1]); err != nil {
+			return errors.Wrapf(err, "while writing the leases of group %d", gid)
+		}
+	}
+	glog.Infof("Restored backups %d to %d into %s", chain[0].BackupNum,
+		chain[len(chain)-1].BackupNum, postings)
+	return nil
+}
+
+func restoreGroup(handler x.UriHandler, chain []*worker.Manifest, gid uint32, dir string,
+	key x.Sensitive) error {
+	opt := badger.DefaultOptions(dir).
+		WithNumVersionsToKeep(math.MaxInt32).
+		WithNamespaceOffset(x.NamespaceOffset).
+		WithExternalMagic(x.MagicVersion).
+		WithLogger(&x.ToGlog{}).
+		WithEncryptionKey(key)
+	db, err := badger.Open(opt)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, m := range chain {
+		path, ok := m.Groups[gid]
+		if !ok {
+			continue
+		}
+		glog.Infof("Restoring %s into %s", path, dir)
+		writer := posting.NewTxnWriter(db)
+		var count int
+		err := worker.ReadBackup(handler, path, key, func(kv *bpb.KV) error {
+			count++
+			return writer.Write(&bpb.KVList{Kv: []*bpb.KV{kv}})
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		glog.Infof("Restored %d keys from %s", count, path)
+	}
+	return nil
+}
+
+// writeLeases writes the leases the restored data needs into the postings directory. Alpha moves
+// the leases of the cluster past them on start, before serving any request.
+func writeLeases(dir string, m *worker.Manifest) error {
+	leases := map[string]uint64{
+		"max_uid":  m.MaxUid,
+		"max_nsid": m.MaxNsID,
+		"max_ts":   x.Max(m.MaxTs, m.ReadTs),
+	}
+	for name, val := range leases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("%#x\n", val)), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
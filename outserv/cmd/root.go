@@ -22,6 +22,7 @@ import (
 	"github.com/outcaste-io/outserv/outserv/cmd/debug"
 	"github.com/outcaste-io/outserv/outserv/cmd/debuginfo"
 	"github.com/outcaste-io/outserv/outserv/cmd/decrypt"
+	"github.com/outcaste-io/outserv/outserv/cmd/restore"
 	"github.com/outcaste-io/outserv/outserv/cmd/version"
 	"github.com/outcaste-io/outserv/outserv/cmd/wallet"
 	"github.com/outcaste-io/outserv/x"
@@ -60,7 +61,7 @@ var rootConf = viper.New()
 var subcommands = []*x.SubCommand{
 	&cert.Cert,
 	&alpha.Alpha, &version.Version, &debug.Debug, &boot.Boot,
-	&debuginfo.Profile, &decrypt.Decrypt, &wallet.Wallet,
+	&debuginfo.Profile, &decrypt.Decrypt, &wallet.Wallet, &restore.Restore,
 }
 
 func initCmds() {
@@ -373,6 +373,23 @@ type rebuilder struct {
 	fn func(uid uint64, pl *List, txn *Txn) error
 }
 
+// countKeys returns the number of keys with the prefix, as of readTs.
+func countKeys(prefix []byte, readTs uint64) uint64 {
+	txn := pstore.NewTransactionAt(readTs, false)
+	defer txn.Discard()
+	iopts := badger.DefaultIteratorOptions
+	iopts.PrefetchValues = false
+	iopts.Prefix = prefix
+	itr := txn.NewIterator(iopts)
+	defer itr.Close()
+
+	var count uint64
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		count++
+	}
+	return count
+}
+
 func (r *rebuilder) Run(ctx context.Context) error {
 	if r.startTs == 0 {
 		glog.Infof("maxassigned is 0, no indexing work for predicate %s", r.attr)
@@ -415,6 +432,10 @@ func (r *rebuilder) Run(ctx context.Context) error {
 	// We set it to 1 in case there are no keys found and NewStreamAt is called with ts=0.
 	var counter uint64 = 1
 
+	// The keys are counted first, to report the progress of the rebuild.
+	total := countKeys(r.prefix, r.startTs)
+	var done uint64
+
 	tmpWriter := tmpDB.NewWriteBatch()
 	stream := pstore.NewStreamAt(r.startTs)
 	stream.LogPrefix = fmt.Sprintf("Rebuilding index for predicate %s (1/2):", r.attr)
@@ -444,6 +465,9 @@ func (r *rebuilder) Run(ctx context.Context) error {
 		if err := r.fn(pk.Uid, l, txn); err != nil {
 			return nil, err
 		}
+		if n := atomic.AddUint64(&done, 1); n%1000 == 0 {
+			x.ReportProgress(ctx, n, total)
+		}
 
 		// Convert data into deltas.
 		txn.Update(ctx, nil)
@@ -554,12 +578,32 @@ const (
 	indexRebuild         = iota // Index should be deleted and rebuilt.
 )
 
+// rebuildsOnline returns true if the tokenizer index gets rebuilt in the background, while the
+// old one keeps serving queries till the new one is switched in. That's the case unless the value
+// type changes, as the tokens of the old index would then collide with the ones of the new index.
+func (rb *IndexRebuild) rebuildsOnline() bool {
+	old := rb.OldSchema
+	if old == nil {
+		old = &pb.SchemaUpdate{}
+	}
+	return rb.needsTokIndexRebuild().op == indexRebuild &&
+		old.ValueType == rb.CurrentSchema.ValueType
+}
+
 // GetQuerySchema returns the schema that can be served while indexes are getting built.
-// Query schema is defined as current schema minus tokens to delete from current schema.
+// Query schema is defined as current schema minus tokens to delete from current schema. If the
+// index is rebuilt online, the old tokenizers keep being served instead.
 func (rb *IndexRebuild) GetQuerySchema() *pb.SchemaUpdate {
 	// Copy the current schema.
 	querySchema := *rb.CurrentSchema
 	info := rb.needsTokIndexRebuild()
+	if rb.rebuildsOnline() {
+		querySchema.Tokenizer = append([]string{}, rb.OldSchema.Tokenizer...)
+		if rb.needsCountIndexRebuild() == indexRebuild {
+			querySchema.Count = false
+		}
+		return &querySchema
+	}
 
 	// Compute old.Tokenizer minus info.tokenizersToDelete.
 	interimTokenizers := make([]string, 0)
@@ -583,9 +627,30 @@ func (rb *IndexRebuild) GetQuerySchema() *pb.SchemaUpdate {
 	return &querySchema
 }
 
-// DropIndexes drops the indexes that need to be rebuilt.
+// GetMutSchema returns the schema that mutations are applied with while indexes are getting
+// built. If the index is rebuilt online, it has the tokenizers of both the old and the current
+// schema, so that the old index stays up to date till the new one is switched in.
+func (rb *IndexRebuild) GetMutSchema() *pb.SchemaUpdate {
+	if !rb.rebuildsOnline() {
+		return rb.CurrentSchema
+	}
+	mutSchema := *rb.CurrentSchema
+	mutSchema.Tokenizer = append(append([]string{}, rb.CurrentSchema.Tokenizer...),
+		rb.needsTokIndexRebuild().tokenizersToDelete...)
+	return &mutSchema
+}
+
+// DropIndexes drops the indexes that need to be rebuilt. If the index is rebuilt online, the
+// indexes of the deleted tokenizers are kept, to be dropped by DropOldIndexes.
 func (rb *IndexRebuild) DropIndexes(ctx context.Context) error {
-	prefixes, err := prefixesForTokIndexes(ctx, rb)
+	var prefixes [][]byte
+	var err error
+	if rb.rebuildsOnline() {
+		toks := rb.needsTokIndexRebuild().tokenizersToRebuild
+		prefixes, err = prefixesForTokenizers(rb.Attr, toks)
+	} else {
+		prefixes, err = prefixesForTokIndexes(ctx, rb)
+	}
 	if err != nil {
 		return err
 	}
@@ -594,6 +659,24 @@ func (rb *IndexRebuild) DropIndexes(ctx context.Context) error {
 	return pstore.DropPrefix(prefixes...)
 }
 
+// DropOldIndexes drops the indexes of the deleted tokenizers, once the indexes rebuilt online
+// have been switched in.
+func (rb *IndexRebuild) DropOldIndexes(ctx context.Context) error {
+	if !rb.rebuildsOnline() {
+		return nil
+	}
+	tokenizers := rb.needsTokIndexRebuild().tokenizersToDelete
+	if len(tokenizers) == 0 {
+		return nil
+	}
+	prefixes, err := prefixesForTokenizers(rb.Attr, tokenizers)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Deleting old indexes for attr %s and tokenizers %s", rb.Attr, tokenizers)
+	return pstore.DropPrefix(prefixes...)
+}
+
 // BuildData updates data.
 func (rb *IndexRebuild) BuildData(ctx context.Context) error {
 	return rebuildListType(ctx, rb)
@@ -606,7 +689,8 @@ func (rb *IndexRebuild) NeedIndexRebuild() bool {
 		rb.needsCountIndexRebuild() == indexRebuild
 }
 
-// BuildIndexes builds indexes.
+// BuildIndexes builds indexes. They're built as of StartTs, while the mutations committed after
+// it write to them as well, via the mutation schema.
 func (rb *IndexRebuild) BuildIndexes(ctx context.Context) error {
 	if err := rebuildTokIndex(ctx, rb); err != nil {
 		return err
@@ -690,35 +774,32 @@ func (rb *IndexRebuild) needsTokIndexRebuild() indexRebuildInfo {
 
 func prefixesForTokIndexes(ctx context.Context, rb *IndexRebuild) ([][]byte, error) {
 	rebuildInfo := rb.needsTokIndexRebuild()
-	prefixes := [][]byte{}
-
 	if rebuildInfo.op == indexNoop {
-		return prefixes, nil
+		return [][]byte{}, nil
 	}
 
 	glog.Infof("Computing prefix index for attr %s and tokenizers %s", rb.Attr,
 		rebuildInfo.tokenizersToDelete)
-	for _, tokenizer := range rebuildInfo.tokenizersToDelete {
-		prefixesNonLang, err := prefixesToDeleteTokensFor(rb.Attr, tokenizer, false)
-		if err != nil {
-			return nil, err
-		}
-		prefixes = append(prefixes, prefixesNonLang...)
-		if tokenizer != "exact" {
-			continue
-		}
-		prefixesWithLang, err := prefixesToDeleteTokensFor(rb.Attr, tokenizer, true)
-		if err != nil {
-			return nil, err
-		}
-		prefixes = append(prefixes, prefixesWithLang...)
+	prefixes, err := prefixesForTokenizers(rb.Attr, rebuildInfo.tokenizersToDelete)
+	if err != nil {
+		return nil, err
 	}
 
 	glog.Infof("Deleting index for attr %s and tokenizers %s", rb.Attr,
 		rebuildInfo.tokenizersToRebuild)
 	// Before rebuilding, the existing index needs to be deleted.
-	for _, tokenizer := range rebuildInfo.tokenizersToRebuild {
-		prefixesNonLang, err := prefixesToDeleteTokensFor(rb.Attr, tokenizer, false)
+	rebuildPrefixes, err := prefixesForTokenizers(rb.Attr, rebuildInfo.tokenizersToRebuild)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefixes, rebuildPrefixes...), nil
+}
+
+// prefixesForTokenizers returns the prefixes of the index keys of the given tokenizers.
+func prefixesForTokenizers(attr string, tokenizers []string) ([][]byte, error) {
+	prefixes := [][]byte{}
+	for _, tokenizer := range tokenizers {
+		prefixesNonLang, err := prefixesToDeleteTokensFor(attr, tokenizer, false)
 		if err != nil {
 			return nil, err
 		}
@@ -726,13 +807,12 @@ func prefixesForTokIndexes(ctx context.Context, rb *IndexRebuild) ([][]byte, err
 		if tokenizer != "exact" {
 			continue
 		}
-		prefixesWithLang, err := prefixesToDeleteTokensFor(rb.Attr, tokenizer, true)
+		prefixesWithLang, err := prefixesToDeleteTokensFor(attr, tokenizer, true)
 		if err != nil {
 			return nil, err
 		}
 		prefixes = append(prefixes, prefixesWithLang...)
 	}
-
 	return prefixes, nil
 }
 
@@ -972,10 +1052,22 @@ func DeletePredicateBlocking(ctx context.Context, attr string, ts uint64) error
 	return schema.State().Delete(attr, ts)
 }
 
-// DeleteNamespace bans the namespace and deletes its predicates/types from the schema.
+// DeleteNamespace drops all the data, indices and schema of the namespace, and deletes its
+// predicates from the schema. The namespace then gets banned, so no stray write can bring it back.
 func DeleteNamespace(ns uint64) error {
 	// TODO: We should only delete cache for certain keys, not all the keys.
 	ResetCache()
 	schema.State().DeletePredsForNs(ns)
+
+	var prefixes [][]byte
+	for _, b := range []byte{x.DefaultPrefix, x.ByteSchema, x.ByteSplit} {
+		prefix := make([]byte, 1+8)
+		prefix[0] = b
+		binary.BigEndian.PutUint64(prefix[1:], ns)
+		prefixes = append(prefixes, prefix)
+	}
+	if err := pstore.DropPrefix(prefixes...); err != nil {
+		return err
+	}
 	return pstore.BanNamespace(ns)
 }
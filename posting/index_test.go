@@ -490,6 +490,31 @@ func TestNeedsTokIndexRebuild(t *testing.T) {
 	require.Equal(t, []string(nil), rebuildInfo.tokenizersToRebuild)
 }
 
+func TestRebuildsOnline(t *testing.T) {
+	rb := IndexRebuild{}
+	rb.OldSchema = &pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX,
+		Tokenizer: []string{"term"}}
+	rb.CurrentSchema = &pb.SchemaUpdate{ValueType: pb.Posting_STRING,
+		Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"exact"}, Count: true}
+	require.True(t, rb.rebuildsOnline())
+	// The old index serves queries, and mutations update both indexes till the switch.
+	querySchema := rb.GetQuerySchema()
+	require.Equal(t, []string{"term"}, querySchema.Tokenizer)
+	require.False(t, querySchema.Count)
+	require.Equal(t, []string{"exact", "term"}, rb.GetMutSchema().Tokenizer)
+
+	// The old index can't be kept if the value type changes.
+	rb.CurrentSchema = &pb.SchemaUpdate{ValueType: pb.Posting_FLOAT,
+		Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"float"}}
+	require.False(t, rb.rebuildsOnline())
+	require.Equal(t, []string{}, rb.GetQuerySchema().Tokenizer)
+	require.Equal(t, []string{"float"}, rb.GetMutSchema().Tokenizer)
+
+	// Nothing is rebuilt if the index is just deleted.
+	rb.CurrentSchema = &pb.SchemaUpdate{ValueType: pb.Posting_STRING}
+	require.False(t, rb.rebuildsOnline())
+}
+
 func TestNeedsCountIndexRebuild(t *testing.T) {
 	rb := IndexRebuild{}
 	rb.OldSchema = &pb.SchemaUpdate{ValueType: pb.Posting_UID}
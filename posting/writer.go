@@ -33,9 +33,20 @@ func NewTxnWriter(db *badger.DB) *TxnWriter {
 	}
 }
 
-// Write stores the given key-value pairs in badger.
+// BitDelete is set in KV.Meta for the keys which should be written as deleted. It's the same bit
+// which badger's Stream sets for the delete markers.
+const BitDelete byte = 1
+
+// Write stores the given key-value pairs in badger. The key-value pairs marked as deleted are
+// written as delete markers.
 func (w *TxnWriter) Write(kvs *pb.KVList) error {
 	for _, kv := range kvs.Kv {
+		if len(kv.Meta) > 0 && kv.Meta[0]&BitDelete > 0 {
+			if err := w.wb.DeleteAt(kv.Key, kv.Version); err != nil {
+				return err
+			}
+			continue
+		}
 		var meta byte
 		if len(kv.UserMeta) > 0 {
 			meta = kv.UserMeta[0]
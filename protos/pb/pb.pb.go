@@ -1723,6 +1723,10 @@ type Proposal struct {
 	ExpectedChecksum uint64           `protobuf:"varint,10,opt,name=expected_checksum,json=expectedChecksum,proto3" json:"expected_checksum,omitempty"`
 	CdcState         *CDCState        `protobuf:"bytes,11,opt,name=cdc_state,json=cdcState,proto3" json:"cdc_state,omitempty"`
 	DeleteNs         *DeleteNsRequest `protobuf:"bytes,12,opt,name=delete_ns,json=deleteNs,proto3" json:"delete_ns,omitempty"`
+	// Reject the mutations on the predicate being moved to another group.
+	ReadOnlyPredicate string `protobuf:"bytes,13,opt,name=read_only_predicate,json=readOnlyPredicate,proto3" json:"read_only_predicate,omitempty"`
+	// Accept the mutations on the predicate again, after its move ended.
+	WritablePredicate string `protobuf:"bytes,14,opt,name=writable_predicate,json=writablePredicate,proto3" json:"writable_predicate,omitempty"`
 }
 
 func (m *Proposal) Reset()         { *m = Proposal{} }
@@ -1842,6 +1846,20 @@ func (m *Proposal) GetDeleteNs() *DeleteNsRequest {
 	return nil
 }
 
+func (m *Proposal) GetReadOnlyPredicate() string {
+	if m != nil {
+		return m.ReadOnlyPredicate
+	}
+	return ""
+}
+
+func (m *Proposal) GetWritablePredicate() string {
+	if m != nil {
+		return m.WritablePredicate
+	}
+	return ""
+}
+
 type CDCState struct {
 	SentTs uint64 `protobuf:"varint,1,opt,name=sent_ts,json=sentTs,proto3" json:"sent_ts,omitempty"`
 }
@@ -4265,6 +4283,8 @@ type ExportRequest struct {
 	SessionToken string `protobuf:"bytes,8,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
 	Anonymous    bool   `protobuf:"varint,9,opt,name=anonymous,proto3" json:"anonymous,omitempty"`
 	Namespace    uint64 `protobuf:"varint,10,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// Only the changes made after since_ts are written out by incremental backups.
+	SinceTs uint64 `protobuf:"varint,11,opt,name=since_ts,json=sinceTs,proto3" json:"since_ts,omitempty"`
 }
 
 func (m *ExportRequest) Reset()         { *m = ExportRequest{} }
@@ -4370,6 +4390,13 @@ func (m *ExportRequest) GetNamespace() uint64 {
 	return 0
 }
 
+func (m *ExportRequest) GetSinceTs() uint64 {
+	if m != nil {
+		return m.SinceTs
+	}
+	return 0
+}
+
 type ExportResponse struct {
 	// 0 indicates a success, and a non-zero code indicates failure
 	Code  int32    `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
@@ -4658,6 +4685,8 @@ func (m *DeleteNsRequest) GetNamespace() uint64 {
 
 type TaskStatusRequest struct {
 	TaskId uint64 `protobuf:"varint,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	// Cancels the task, if it's still queued or running.
+	Cancel bool `protobuf:"varint,2,opt,name=cancel,proto3" json:"cancel,omitempty"`
 }
 
 func (m *TaskStatusRequest) Reset()         { *m = TaskStatusRequest{} }
@@ -4700,8 +4729,23 @@ func (m *TaskStatusRequest) GetTaskId() uint64 {
 	return 0
 }
 
+func (m *TaskStatusRequest) GetCancel() bool {
+	if m != nil {
+		return m.Cancel
+	}
+	return false
+}
+
 type TaskStatusResponse struct {
 	TaskMeta uint64 `protobuf:"varint,1,opt,name=task_meta,json=taskMeta,proto3" json:"task_meta,omitempty"`
+	// The progress of the task, as the work done out of the total work. Total is zero if it's
+	// unknown.
+	Done  uint64 `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+	Total uint64 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	// The UNIX timestamp the task started running at.
+	Started int64 `protobuf:"varint,4,opt,name=started,proto3" json:"started,omitempty"`
+	// Why the task failed.
+	Error string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
 }
 
 func (m *TaskStatusResponse) Reset()         { *m = TaskStatusResponse{} }
@@ -4744,6 +4788,34 @@ func (m *TaskStatusResponse) GetTaskMeta() uint64 {
 	return 0
 }
 
+func (m *TaskStatusResponse) GetDone() uint64 {
+	if m != nil {
+		return m.Done
+	}
+	return 0
+}
+
+func (m *TaskStatusResponse) GetTotal() uint64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *TaskStatusResponse) GetStarted() int64 {
+	if m != nil {
+		return m.Started
+	}
+	return 0
+}
+
+func (m *TaskStatusResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterEnum("pb.Mutations_DropOp", Mutations_DropOp_name, Mutations_DropOp_value)
 	proto.RegisterEnum("pb.Posting_PostingType", Posting_PostingType_name, Posting_PostingType_value)
@@ -7195,6 +7267,20 @@ func (m *Proposal) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.WritablePredicate) > 0 {
+		i -= len(m.WritablePredicate)
+		copy(dAtA[i:], m.WritablePredicate)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.WritablePredicate)))
+		i--
+		dAtA[i] = 0x72
+	}
+	if len(m.ReadOnlyPredicate) > 0 {
+		i -= len(m.ReadOnlyPredicate)
+		copy(dAtA[i:], m.ReadOnlyPredicate)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.ReadOnlyPredicate)))
+		i--
+		dAtA[i] = 0x6a
+	}
 	if m.DeleteNs != nil {
 		{
 			size, err := m.DeleteNs.MarshalToSizedBuffer(dAtA[:i])
@@ -9227,6 +9313,11 @@ func (m *ExportRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.SinceTs != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.SinceTs))
+		i--
+		dAtA[i] = 0x58
+	}
 	if m.Namespace != 0 {
 		i = encodeVarintPb(dAtA, i, uint64(m.Namespace))
 		i--
@@ -9535,6 +9626,16 @@ func (m *TaskStatusRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Cancel {
+		i--
+		if m.Cancel {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.TaskId != 0 {
 		i = encodeVarintPb(dAtA, i, uint64(m.TaskId))
 		i--
@@ -9563,6 +9664,28 @@ func (m *TaskStatusResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Error) > 0 {
+		i -= len(m.Error)
+		copy(dAtA[i:], m.Error)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.Error)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if m.Started != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.Started))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Total != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.Total))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Done != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.Done))
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.TaskMeta != 0 {
 		i = encodeVarintPb(dAtA, i, uint64(m.TaskMeta))
 		i--
@@ -10187,6 +10310,14 @@ func (m *Proposal) Size() (n int) {
 		l = m.DeleteNs.Size()
 		n += 1 + l + sovPb(uint64(l))
 	}
+	l = len(m.ReadOnlyPredicate)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	l = len(m.WritablePredicate)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
 	return n
 }
 
@@ -11078,6 +11209,9 @@ func (m *ExportRequest) Size() (n int) {
 	if m.Namespace != 0 {
 		n += 1 + sovPb(uint64(m.Namespace))
 	}
+	if m.SinceTs != 0 {
+		n += 1 + sovPb(uint64(m.SinceTs))
+	}
 	return n
 }
 
@@ -11193,6 +11327,9 @@ func (m *TaskStatusRequest) Size() (n int) {
 	if m.TaskId != 0 {
 		n += 1 + sovPb(uint64(m.TaskId))
 	}
+	if m.Cancel {
+		n += 2
+	}
 	return n
 }
 
@@ -11205,6 +11342,19 @@ func (m *TaskStatusResponse) Size() (n int) {
 	if m.TaskMeta != 0 {
 		n += 1 + sovPb(uint64(m.TaskMeta))
 	}
+	if m.Done != 0 {
+		n += 1 + sovPb(uint64(m.Done))
+	}
+	if m.Total != 0 {
+		n += 1 + sovPb(uint64(m.Total))
+	}
+	if m.Started != 0 {
+		n += 1 + sovPb(uint64(m.Started))
+	}
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
 	return n
 }
 
@@ -15269,6 +15419,70 @@ func (m *Proposal) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadOnlyPredicate", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReadOnlyPredicate = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WritablePredicate", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.WritablePredicate = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -21190,6 +21404,25 @@ func (m *ExportRequest) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SinceTs", wireType)
+			}
+			m.SinceTs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SinceTs |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -21933,6 +22166,26 @@ func (m *TaskStatusRequest) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Cancel", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Cancel = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -22002,6 +22255,95 @@ func (m *TaskStatusResponse) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Done", wireType)
+			}
+			m.Done = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Done |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Total", wireType)
+			}
+			m.Total = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Total |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Started", wireType)
+			}
+			m.Started = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Started |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Error = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -665,6 +665,8 @@ func valToBytes(v types.Val) ([]byte, error) {
 			return nil, err
 		}
 		return []byte(fmt.Sprintf("\"%s\"", v)), nil
+	case types.TypeVFloat:
+		return []byte(types.FormatVFloat(v.Value.([]float32))), nil
 	default:
 		return nil, errors.New("Unsupported types.Val.Tid")
 	}
@@ -1156,7 +1158,15 @@ func (sg *SubGraph) toGraphqlJSON(genc *graphQLEncoder, n fastJsonNode, f *gqlSc
 
 	// if this field has any @custom(http: {...}) children,
 	// then need to resolve them first before encoding the final GraphQL result.
-	genc.processCustomFields(f, n)
+	// For connection queries, the nodes are at the root, but their selection set is inside
+	// edges { node { ... } }.
+	if f.Type().IsConnection() {
+		if node := f.ConnectionNodeField(); node != nil {
+			genc.processCustomFields(node, n)
+		}
+	} else {
+		genc.processCustomFields(f, n)
+	}
 	// now encode the GraphQL results.
 	if !genc.encode(encodeInput{
 		parentField: nil,
@@ -110,6 +110,9 @@ func (genc *graphQLEncoder) encode(encInp encodeInput) bool {
 				genc.errs = append(genc.errs, err)
 				return false
 			}
+		} else if encInp.parentField.IsEmbedding() {
+			// the vector is already written as a JSON list of floats, like [0.1,0.2]
+			x.Check2(genc.buf.Write(val))
 		} else {
 			// we got a GraphQL scalar
 			// check coercion rules to see if it matches the GraphQL spec requirements.
@@ -219,8 +222,9 @@ func (genc *graphQLEncoder) encode(encInp encodeInput) bool {
 			// Write JSON key and opening [ for JSON arrays
 			curSelection.CompleteAlias(genc.buf)
 			keyEndPos = genc.buf.Len()
+			// An @embedding field is a list in GraphQL, but a single vector in Dgraph.
 			curSelectionIsDgList = (curSelection.Type().ListType() != nil) && !curSelection.
-				IsCustomHTTP()
+				IsCustomHTTP() && !curSelection.IsEmbedding()
 			if curSelectionIsDgList {
 				x.Check2(genc.buf.WriteRune('['))
 			}
@@ -365,6 +369,13 @@ func (genc *graphQLEncoder) encode(encInp encodeInput) bool {
 				// we need to iterate to the next fastJson node because we have used the data from
 				// the current fastJson node.
 				child = child.next
+			} else if !curSelectionIsDgList && encInp.fjIsRoot && curSelection.Type().
+				IsConnection() {
+				// handles connection queries at root, whose results are a list of nodes in
+				// Dgraph but a single object in GraphQL.
+				next = genc.completeRootConnectionQuery(cur, curSelection,
+					append(encInp.parentPath, curSelection.ResponseName()))
+				child = next
 			} else if !curSelectionIsDgList && (!genc.getList(cur) || (encInp.fjIsRoot &&
 				(next == nil || genc.getAttr(cur) != genc.getAttr(next)) &&
 				!curSelection.Type().IsAggregateResult())) {
@@ -483,6 +494,10 @@ func (genc *graphQLEncoder) encode(encInp encodeInput) bool {
 			encInp.parentPath) {
 			// do nothing, value for field has already been written.
 			// If the value weren't written, the next else would write null.
+		} else if encInp.fjIsRoot && curSelection.Type().IsConnection() {
+			// a connection query without any results still has a valid, empty connection.
+			genc.writeConnection(curSelection, nil, append(encInp.parentPath,
+				curSelection.ResponseName()))
 		} else {
 			if !writeGraphQLNull(curSelection, genc.buf, genc.buf.Len()) {
 				genc.errs = append(genc.errs, curSelection.GqlErrorf(append(encInp.parentPath,
@@ -838,50 +853,78 @@ func (genc *graphQLEncoder) resolveCustomField(childField *gqlSchema.Field,
 
 	switch fconf.Mode {
 	case gqlSchema.SINGLE:
-		// In SINGLE mode, we can consider steps 2-5 as a single isolated unit of computation,
-		// which can be executed in parallel for each uniqueParent.
-		// Step 6-7 can be executed in parallel to Step 2-5 in a separate goroutine to minimize
+		// In SINGLE mode, we can consider steps 3-5 as a single isolated unit of computation,
+		// which can be executed in parallel for each unique request built in step 2.
+		// Step 6-7 can be executed in parallel to Step 3-5 in a separate goroutine to minimize
 		// contention.
 
-		// used to wait on goroutines started for each uniqueParent
-		uniqueParentWg := &sync.WaitGroup{}
-		// iterate over all the uniqueParents to make HTTP requests
+		// Step-2: Construct correct URL and body using the data of requiredFields. Different
+		// parents can end up with identical requests, like the ones using the same symbol to
+		// look up a price, so the requests are deduplicated by their URL and body.
+		type singleRequest struct {
+			url     string
+			body    interface{}
+			parents []fastJsonNode
+		}
+		var requests []*singleRequest
+		requestIdx := make(map[string]int)
 		for i := range uniqueParents {
-			uniqueParentWg.Add(1)
-			go func(idx int) {
-				defer uniqueParentWg.Done() // signal when this goroutine finishes execution
-
-				// Step-2: Construct correct URL and body using the data of requiredFields
-				url := fconf.URL
-				var body interface{}
-				if isGraphqlReq {
-					// If it is a remote GraphQL request, then URL can't have variables.
-					// So, we only need to construct the body.
-					body = map[string]interface{}{
-						"query":     fconf.RemoteGqlQuery,
-						"variables": uniqueParents[idx],
-					}
-				} else {
-					// for REST requests, we need to correctly construct both URL & body
-					var err error
-					url, err = gqlSchema.SubstituteVarsInURL(url,
-						uniqueParents[idx].(map[string]interface{}))
-					if err != nil {
-						genc.errCh <- x.GqlErrorList{childField.GqlErrorf(nil,
-							"Evaluation of custom field failed while substituting variables "+
-								"into URL for remote endpoint with an error: %s for field: %s "+
-								"within type: %s.", err, childField.Name(),
-							childField.GetObjectName())}
-						return
-					}
-					body = gqlSchema.SubstituteVarsInBody(fconf.Template,
-						uniqueParents[idx].(map[string]interface{}))
+			url := fconf.URL
+			var body interface{}
+			if isGraphqlReq {
+				// If it is a remote GraphQL request, then URL can't have variables.
+				// So, we only need to construct the body.
+				body = map[string]interface{}{
+					"query":     fconf.RemoteGqlQuery,
+					"variables": uniqueParents[i],
 				}
+			} else {
+				// for REST requests, we need to correctly construct both URL & body
+				var err error
+				url, err = gqlSchema.SubstituteVarsInURL(url,
+					uniqueParents[i].(map[string]interface{}))
+				if err != nil {
+					genc.errCh <- x.GqlErrorList{childField.GqlErrorf(nil,
+						"Evaluation of custom field failed while substituting variables "+
+							"into URL for remote endpoint with an error: %s for field: %s "+
+							"within type: %s.", err, childField.Name(),
+						childField.GetObjectName())}
+					continue
+				}
+				body = gqlSchema.SubstituteVarsInBody(fconf.Template,
+					uniqueParents[i].(map[string]interface{}))
+			}
+
+			req := &singleRequest{
+				url:     url,
+				body:    body,
+				parents: parentNodes[uniqueParentIdxToIdFieldVal[i]],
+			}
+			// Maps get marshalled with their keys sorted. If the body can't be marshalled,
+			// the request isn't deduplicated, and fails while being made.
+			if b, err := json.Marshal(body); err == nil {
+				key := url + "\x00" + string(b)
+				if idx, ok := requestIdx[key]; ok {
+					requests[idx].parents = append(requests[idx].parents, req.parents...)
+					continue
+				}
+				requestIdx[key] = len(requests)
+			}
+			requests = append(requests, req)
+		}
+
+		// used to wait on goroutines started for each request
+		requestWg := &sync.WaitGroup{}
+		// iterate over all the requests to make HTTP requests
+		for _, req := range requests {
+			requestWg.Add(1)
+			go func(req *singleRequest) {
+				defer requestWg.Done() // signal when this goroutine finishes execution
 
 				// Step-3 & 4: Make the request to external HTTP endpoint using the URL and
 				// body. Then, Decode the HTTP response.
-				response, errs, hardErrs := fconf.MakeAndDecodeHTTPRequest(nil, url, body,
-					childField)
+				response, errs, hardErrs := fconf.MakeAndDecodeHTTPRequest(nil, req.url,
+					req.body, childField)
 				if hardErrs != nil {
 					genc.errCh <- hardErrs
 					return
@@ -894,7 +937,7 @@ func (genc *graphQLEncoder) resolveCustomField(childField *gqlSchema.Field,
 				// finally, send the fastJson tree update over the channel
 				if b != nil {
 					genc.customFieldResultCh <- customFieldResult{
-						parents:    parentNodes[uniqueParentIdxToIdFieldVal[idx]],
+						parents:    req.parents,
 						childField: childField,
 						childVal:   b,
 					}
@@ -905,9 +948,9 @@ func (genc *graphQLEncoder) resolveCustomField(childField *gqlSchema.Field,
 
 				// now, send all the collected errors together
 				genc.errCh <- errs
-			}(i)
+			}(req)
 		}
-		uniqueParentWg.Wait()
+		requestWg.Wait()
 	case gqlSchema.BATCH:
 		// In BATCH mode, we can break the above steps into following isolated units of computation:
 		// a. Step 2-4
@@ -1086,11 +1129,13 @@ func (genc *graphQLEncoder) completeRootAggregateQuery(fj fastJsonNode, query *g
 	var val []byte
 	var err error
 	comma := ""
+	// The groups, if any, are returned by separate query blocks after the aggregate properties.
+	groups := fj
 
 	x.Check2(genc.buf.WriteString("{"))
 	for _, f := range query.SelectionSet() {
 		if f.Skip() || !f.Include() {
-			if f.Name() != gqlSchema.Typename {
+			if f.Name() != gqlSchema.Typename && !f.IsAggregateGroups() {
 				fj = fj.next // need to skip data as well for this field
 			}
 			continue
@@ -1101,6 +1146,9 @@ func (genc *graphQLEncoder) completeRootAggregateQuery(fj fastJsonNode, query *g
 
 		if f.Name() == gqlSchema.Typename {
 			val = getTypename(f, nil)
+		} else if f.IsAggregateGroups() {
+			val = genc.completeAggregateGroups(groups, query, f,
+				append(qryPath, f.ResponseName()))
 		} else {
 			val, err = genc.getScalarVal(genc.children(fj))
 			if err != nil {
@@ -1119,6 +1167,279 @@ func (genc *graphQLEncoder) completeRootAggregateQuery(fj fastJsonNode, query *g
 	return fj
 }
 
+// completeAggregateGroups builds GraphQL JSON for the groups field of an aggregate query at root.
+// Dgraph returns the groups using a separate query block with @groupby, whose results come after
+// those for the aggregate properties. The values of the fields being grouped by come first in
+// each group, followed by the aggregates.
+// Dgraph result:
+// 		{
+// 		  "aggregateTweets": [ ... ],
+// 		  "TweetsAggregateResult.groups": [
+// 		    {
+// 		      "@groupby": [
+// 		        {
+// 		          "TweetsAggregateGroup.score": 10,
+// 		          "TweetsAggregateGroup.count": 2
+// 		        },
+// 		        ...
+// 		      ]
+// 		    }
+// 		  ]
+// 		}
+// GraphQL result:
+// 		{
+// 		  "groups": [
+// 		    {
+// 		      "score": 10,
+// 		      "count": 2
+// 		    },
+// 		    ...
+// 		  ]
+// 		}
+// fj can be any fastJson node for the query, the one for groups is searched for after it. If
+// there isn't one, groups is null if the query wasn't grouped, and an empty list otherwise.
+func (genc *graphQLEncoder) completeAggregateGroups(fj fastJsonNode, query,
+	groups *gqlSchema.Field, fieldPath []interface{}) []byte {
+	attr := groups.DgraphAlias()
+	for ; fj != nil && genc.attrForID(genc.getAttr(fj)) != attr; fj = fj.next {
+		// do nothing
+	}
+	if fj == nil {
+		if len(query.GroupBy()) == 0 {
+			return gqlSchema.JsonNull
+		}
+		return []byte("[]")
+	}
+
+	groupTypeName := groups.Type().Name()
+	var buf bytes.Buffer
+	x.Check2(buf.WriteString("["))
+	for i, grp := 0, genc.children(fj); grp != nil; i, grp = i+1, grp.next {
+		if i > 0 {
+			x.Check2(buf.WriteString(","))
+		}
+		comma := ""
+		x.Check2(buf.WriteString("{"))
+		for _, f := range groups.SelectionSet() {
+			if f.Skip() || !f.Include() {
+				continue
+			}
+			x.Check2(buf.WriteString(comma))
+			f.CompleteAlias(&buf)
+			comma = ","
+			if f.Name() == gqlSchema.Typename {
+				x.Check2(buf.Write(getTypename(f, nil)))
+				continue
+			}
+
+			// The values of the fields being grouped by are aliased by their name, while the
+			// aggregates are aliased like any other field.
+			alias := f.DgraphAlias()
+			for _, fld := range query.GroupBy() {
+				if fld == f.Name() {
+					alias = groupTypeName + "." + fld
+					break
+				}
+			}
+			val := gqlSchema.JsonNull
+			for c := genc.children(grp); c != nil; c = c.next {
+				if genc.attrForID(genc.getAttr(c)) != alias {
+					continue
+				}
+				v, err := genc.getScalarVal(c)
+				if err != nil {
+					genc.errs = append(genc.errs, f.GqlErrorf(append(fieldPath, i,
+						f.ResponseName()), err.Error()))
+					// all fields of groups are nullable, so no special checks are required
+					break
+				}
+				val = v
+				break
+			}
+			x.Check2(buf.Write(val))
+		}
+		x.Check2(buf.WriteString("}"))
+	}
+	x.Check2(buf.WriteString("]"))
+	return buf.Bytes()
+}
+
+// completeRootConnectionQuery builds GraphQL JSON for connection queries at root.
+// Dgraph result:
+// 		{
+// 		  "Query.queryPostConnection": [
+// 		    {
+// 		      "Post.title": "GraphQL",
+// 		      "dgraph.uid": "0x2712",
+// 		      "dgraph.cursor.uid": "0x2712",
+// 		      "dgraph.cursor.value": "GraphQL"
+// 		    },
+// 		    ...
+// 		  ]
+// 		}
+// GraphQL result:
+// 		{
+// 		  "queryPostConnection": {
+// 		    "edges": [
+// 		      {
+// 		        "cursor": "WyIweDI3MTIiLCJHcmFwaFFMIl0",
+// 		        "node": { "title": "GraphQL" }
+// 		      },
+// 		      ...
+// 		    ],
+// 		    "pageInfo": { "hasNextPage": true, ... }
+// 		  }
+// 		}
+// It returns the fastJson node after all the nodes for query.
+func (genc *graphQLEncoder) completeRootConnectionQuery(fj fastJsonNode, query *gqlSchema.Field,
+	qryPath []interface{}) fastJsonNode {
+	var nodes []fastJsonNode
+	attrId := genc.getAttr(fj)
+	for ; fj != nil && genc.getAttr(fj) == attrId; fj = fj.next {
+		// an empty node is added to the result at root if there were no results.
+		if genc.children(fj) != nil {
+			nodes = append(nodes, fj)
+		}
+	}
+	genc.writeConnection(query, nodes, qryPath)
+	return fj
+}
+
+// writeConnection writes the connection object for the given nodes. The query asks Dgraph for
+// one node more than first, so having more than first nodes means that there is a next page.
+func (genc *graphQLEncoder) writeConnection(query *gqlSchema.Field, nodes []fastJsonNode,
+	qryPath []interface{}) {
+	hasNextPage := query.ArgValue(gqlSchema.BeforeArgName) != nil
+	if first := query.ArgValue("first"); first != nil {
+		if n, err := strconv.Atoi(fmt.Sprintf("%v", first)); err == nil && len(nodes) > n {
+			nodes = nodes[:n]
+			hasNextPage = true
+		}
+	}
+	hasPreviousPage := query.ArgValue(gqlSchema.AfterArgName) != nil
+
+	ordered := query.ArgValue("order") != nil
+	cursors := make([]string, len(nodes))
+	for i, node := range nodes {
+		cursors[i] = genc.nodeCursor(node, ordered)
+	}
+
+	comma := ""
+	x.Check2(genc.buf.WriteString("{"))
+	for _, f := range query.SelectionSet() {
+		if f.Skip() || !f.Include() {
+			continue
+		}
+		x.Check2(genc.buf.WriteString(comma))
+		f.CompleteAlias(genc.buf)
+		comma = ","
+
+		switch f.Name() {
+		case gqlSchema.Typename:
+			x.Check2(genc.buf.Write(getTypename(f, nil)))
+		case "edges":
+			x.Check2(genc.buf.WriteString("["))
+			for i, node := range nodes {
+				if i > 0 {
+					x.Check2(genc.buf.WriteString(","))
+				}
+				genc.writeEdge(f, node, cursors[i], append(qryPath, f.ResponseName(), i))
+			}
+			x.Check2(genc.buf.WriteString("]"))
+		case "pageInfo":
+			genc.writePageInfo(f, cursors, hasNextPage, hasPreviousPage)
+		}
+	}
+	x.Check2(genc.buf.WriteString("}"))
+}
+
+func (genc *graphQLEncoder) writeEdge(edges *gqlSchema.Field, node fastJsonNode, cursor string,
+	edgePath []interface{}) {
+	comma := ""
+	x.Check2(genc.buf.WriteString("{"))
+	for _, f := range edges.SelectionSet() {
+		if f.Skip() || !f.Include() {
+			continue
+		}
+		x.Check2(genc.buf.WriteString(comma))
+		f.CompleteAlias(genc.buf)
+		comma = ","
+
+		switch f.Name() {
+		case gqlSchema.Typename:
+			x.Check2(genc.buf.Write(getTypename(f, nil)))
+		case "cursor":
+			x.Check2(genc.buf.WriteString(strconv.Quote(cursor)))
+		case "node":
+			keyEndPos := genc.buf.Len()
+			if !genc.encode(encodeInput{
+				parentField: f,
+				parentPath:  append(edgePath, f.ResponseName()),
+				fj:          node,
+				fjIsRoot:    false,
+				childSelSet: f.SelectionSet(),
+			}) {
+				// node is nullable, so this can't fail.
+				writeGraphQLNull(f, genc.buf, keyEndPos)
+			}
+		}
+	}
+	x.Check2(genc.buf.WriteString("}"))
+}
+
+func (genc *graphQLEncoder) writePageInfo(pageInfo *gqlSchema.Field, cursors []string,
+	hasNextPage, hasPreviousPage bool) {
+	comma := ""
+	x.Check2(genc.buf.WriteString("{"))
+	for _, f := range pageInfo.SelectionSet() {
+		if f.Skip() || !f.Include() {
+			continue
+		}
+		x.Check2(genc.buf.WriteString(comma))
+		f.CompleteAlias(genc.buf)
+		comma = ","
+
+		switch f.Name() {
+		case gqlSchema.Typename:
+			x.Check2(genc.buf.Write(getTypename(f, nil)))
+		case "hasNextPage":
+			x.Check2(genc.buf.WriteString(strconv.FormatBool(hasNextPage)))
+		case "hasPreviousPage":
+			x.Check2(genc.buf.WriteString(strconv.FormatBool(hasPreviousPage)))
+		case "startCursor", "endCursor":
+			if len(cursors) == 0 {
+				x.Check2(genc.buf.Write(gqlSchema.JsonNull))
+			} else if f.Name() == "startCursor" {
+				x.Check2(genc.buf.WriteString(strconv.Quote(cursors[0])))
+			} else {
+				x.Check2(genc.buf.WriteString(strconv.Quote(cursors[len(cursors)-1])))
+			}
+		}
+	}
+	x.Check2(genc.buf.WriteString("}"))
+}
+
+// nodeCursor builds the cursor for a node in the result of a connection query, from the uid and
+// order value fetched for it. If the query is ordered, but the node has no value for the order
+// field, the cursor holds a null value.
+func (genc *graphQLEncoder) nodeCursor(node fastJsonNode, ordered bool) string {
+	uidAttr := genc.idForAttr(gqlSchema.CursorUidAlias)
+	valAttr := genc.idForAttr(gqlSchema.CursorValueAlias)
+	var uid, val []byte
+	for child := genc.children(node); child != nil; child = child.next {
+		switch genc.getAttr(child) {
+		case uidAttr:
+			uid, _ = genc.getScalarVal(child)
+		case valAttr:
+			val, _ = genc.getScalarVal(child)
+		}
+	}
+	if ordered && val == nil {
+		val = gqlSchema.JsonNull
+	}
+	return gqlSchema.EncodeCursor(uid, val)
+}
+
 // completeAggregateChildren build GraphQL JSON for aggregate fields at child levels.
 // Dgraph result:
 // 		{
@@ -18,6 +18,7 @@ package query
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"runtime"
@@ -25,6 +26,7 @@ import (
 	"sync"
 	"testing"
 
+	gqlSchema "github.com/outcaste-io/outserv/graphql/schema"
 	"github.com/outcaste-io/outserv/types"
 	"github.com/outcaste-io/outserv/worker"
 	"github.com/outcaste-io/outserv/x"
@@ -261,3 +263,75 @@ func TestChildrenOrder(t *testing.T) {
 	}
 	require.Nil(t, child)
 }
+
+func aggregatePaymentField(t *testing.T, query string) *gqlSchema.Field {
+	handler, err := gqlSchema.NewHandler(`
+		type Author {
+			id: ID!
+			name: String
+		}
+		type Payment @generate(query: {groupBy: true}) {
+			id: String! @id
+			payer: String
+			amount: Int
+			payee: Author
+		}`)
+	require.NoError(t, err)
+	sch, err := gqlSchema.FromString(handler.GQLSchema(), x.GalaxyNamespace)
+	require.NoError(t, err)
+	op, err := sch.Operation(&gqlSchema.Request{Query: query})
+	require.NoError(t, err)
+	require.Len(t, op.Queries(), 1)
+	return op.Queries()[0]
+}
+
+func TestCompleteAggregateGroups(t *testing.T) {
+	query := aggregatePaymentField(t, `query {
+		aggregatePayment(groupBy: [payer, payee]) {
+			groups { payer payee total: amountSum count }
+		}
+	}`)
+	groups := query.SelectionSet()[0]
+	require.True(t, groups.IsAggregateGroups())
+
+	enc := newEncoder()
+	root := enc.newNode(enc.idForAttr("_root_"))
+	enc.AddListChild(root, enc.newNode(enc.idForAttr(query.DgraphAlias())))
+	g := enc.newNode(enc.idForAttr(groups.DgraphAlias()))
+	for _, vals := range []map[string]types.Val{
+		{
+			"PaymentAggregateGroup.payer": {Tid: types.TypeString, Value: "alice"},
+			"PaymentAggregateGroup.payee": {Tid: types.TypeUid, Value: uint64(2)},
+			"PaymentAggregateGroup.total": {Tid: types.TypeInt64, Value: int64(30)},
+			"PaymentAggregateGroup.count": {Tid: types.TypeInt64, Value: int64(2)},
+		},
+		{
+			// A group without a payee.
+			"PaymentAggregateGroup.payer": {Tid: types.TypeString, Value: "bob"},
+			"PaymentAggregateGroup.total": {Tid: types.TypeInt64, Value: int64(5)},
+			"PaymentAggregateGroup.count": {Tid: types.TypeInt64, Value: int64(1)},
+		},
+	} {
+		grp := enc.newNode(enc.idForAttr("@groupby"))
+		for attr, val := range vals {
+			require.NoError(t, enc.AddValue(grp, enc.idForAttr(attr), val))
+		}
+		enc.AddListChild(g, grp)
+	}
+	enc.AddListChild(root, g)
+	enc.fixOrder(root)
+
+	genc := newGraphQLEncoder(context.Background(), enc)
+	res := genc.completeAggregateGroups(enc.children(root), query, groups, nil)
+	require.Empty(t, genc.errs)
+	require.JSONEq(t, `[
+		{"payer": "alice", "payee": "0x2", "total": 30, "count": 2},
+		{"payer": "bob", "payee": null, "total": 5, "count": 1}
+	]`, string(res))
+
+	// Without any groups, groups is empty if the query was grouped, and null otherwise.
+	require.Equal(t, "[]", string(genc.completeAggregateGroups(nil, query, groups, nil)))
+	query = aggregatePaymentField(t, `query { aggregatePayment { groups { count } } }`)
+	groups = query.SelectionSet()[0]
+	require.Equal(t, "null", string(genc.completeAggregateGroups(nil, query, groups, nil)))
+}
@@ -512,7 +512,8 @@ func treeCopy(gq *gql.GraphQuery, sg *SubGraph) error {
 		}
 
 		if gchild.Func != nil &&
-			(gchild.Func.IsAggregator() || gchild.Func.IsPasswordVerifier()) {
+			(gchild.Func.IsAggregator() || gchild.Func.IsPasswordVerifier() ||
+				gchild.Func.IsSearch()) {
 			if len(gchild.Children) != 0 {
 				return errors.Errorf("Node with %q cant have child attr", gchild.Func.Name)
 			}
@@ -835,7 +836,7 @@ func calculatePaginationParams(sg *SubGraph) (int32, int32) {
 	shouldExclude := false
 	if sg.SrcFunc != nil {
 		switch sg.SrcFunc.Name {
-		case "regexp", "alloftext", "allofterms", "match":
+		case "regexp", "alloftext", "allofterms", "match", "similar_to":
 			shouldExclude = true
 		default:
 			shouldExclude = false
@@ -1806,6 +1807,11 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 			} else {
 				sg.DestMap = nil
 			}
+		case sg.SrcFunc != nil && isSearchFn(sg.SrcFunc.Name):
+			if err = sg.evalSearchFn(ctx); err != nil {
+				rch <- err
+				return
+			}
 		default:
 			taskQuery, err := createTaskQuery(ctx, sg)
 			if err != nil {
@@ -2244,7 +2250,8 @@ func isValidArg(a string) bool {
 func isValidFuncName(f string) bool {
 	switch f {
 	case "anyofterms", "allofterms", "val", "regexp", "anyoftext", "alloftext",
-		"has", "uid", "uid_in", "anyof", "allof", "type", "match":
+		"has", "uid", "uid_in", "anyof", "allof", "type", "match", "similar_to",
+		"starts_with":
 		return true
 	}
 	return isInequalityFn(f) || types.IsGeoFunc(f)
@@ -0,0 +1,447 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package query
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/outcaste-io/sroar"
+	"github.com/pkg/errors"
+
+	"github.com/outcaste-io/outserv/codec"
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/tok"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/worker"
+	"github.com/outcaste-io/outserv/x"
+)
+
+const (
+	// The parameters of BM25, as commonly used.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// highlightLen is the number of bytes of text kept around the matches in a highlight.
+	highlightLen = 160
+	// docCountTTL is how long the number of nodes having a value for a predicate, and the
+	// number of terms in those values, are cached.
+	docCountTTL = time.Minute
+	// docCountBatch is the number of nodes whose values are read at once, to count their terms.
+	docCountBatch = 10000
+)
+
+// docCounts caches the number of nodes having a value for each namespaced predicate, and the
+// number of full-text terms in those values, keyed by the predicate. Counting them takes a scan
+// over the predicate, so it's done at most once per docCountTTL.
+var docCounts sync.Map
+
+type docCount struct {
+	n, terms int
+	expiry   time.Time
+}
+
+func isSearchFn(f string) bool {
+	return f == "bm25" || f == "highlight" || f == "vector_distance"
+}
+
+// evalSearchFn evaluates the search function of sg for its SrcUIDs. It's one of
+//
+//	bm25(pred, "text", pred2, "text2", ...)
+//	highlight(pred, "text")
+//	vector_distance(pred, "[0.1, 0.2]", "cosine")
+//
+// bm25 scores the full-text matches of the texts in the values of the predicates, summing up
+// the scores of all the predicates. Every node gets a score, 0 if nothing matches. highlight
+// returns a snippet of the value of the predicate, with the matches of the text marked, for the
+// nodes where there's a match. vector_distance returns the distance of the vector of the
+// predicate from the given one, euclidean unless asked otherwise, for the nodes having a vector
+// of the same dimensions.
+//
+// Like for a predicate, the results are set in the valueMatrix, so that they get output and can
+// be assigned to a value variable.
+func (sg *SubGraph) evalSearchFn(ctx context.Context) error {
+	ns, err := x.ExtractNamespace(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "while evaluating %s", sg.SrcFunc.Name)
+	}
+	uids := codec.GetUids(sg.SrcUIDs)
+	var vals map[uint64]types.Val
+	switch sg.SrcFunc.Name {
+	case "bm25":
+		vals, err = sg.bm25(ctx, ns, uids)
+	case "highlight":
+		vals, err = sg.highlight(ctx, ns, uids)
+	default:
+		vals, err = sg.vectorDistance(ctx, ns, uids)
+	}
+	if err != nil {
+		return err
+	}
+
+	sg.uidMatrix = make([]*pb.List, len(uids))
+	sg.valueMatrix = make([]*pb.ValueList, len(uids))
+	for i, uid := range uids {
+		sg.uidMatrix[i] = &pb.List{}
+		sg.valueMatrix[i] = &pb.ValueList{}
+		val, ok := vals[uid]
+		if !ok {
+			continue
+		}
+		data, err := types.ToBinary(val.Tid, val.Value)
+		if err != nil {
+			return err
+		}
+		sg.valueMatrix[i].Values = []*pb.TaskValue{{Val: data}}
+	}
+	sg.DestMap = sroar.NewBitmap()
+	return nil
+}
+
+func (sg *SubGraph) bm25(ctx context.Context, ns uint64,
+	uids []uint64) (map[uint64]types.Val, error) {
+
+	scores := make(map[uint64]float64, len(uids))
+	args := sg.SrcFunc.Args
+	for i := 0; i+1 < len(args); i += 2 {
+		if err := sg.addBM25Scores(ctx, ns, args[i].Value, args[i+1].Value, uids,
+			scores); err != nil {
+			return nil, err
+		}
+	}
+	vals := make(map[uint64]types.Val, len(uids))
+	for _, uid := range uids {
+		vals[uid] = types.Val{Tid: types.TypeFloat, Value: scores[uid]}
+	}
+	return vals, nil
+}
+
+// addBM25Scores adds the BM25 scores of the text for the values of attr to the scores of the
+// nodes uids. The frequency of the terms in the documents comes from the fulltext index of attr.
+// The average length of the documents is taken over all the values of attr, so that the scores
+// don't depend on which nodes are being scored.
+func (sg *SubGraph) addBM25Scores(ctx context.Context, ns uint64, attr, text string,
+	uids []uint64, scores map[uint64]float64) error {
+
+	// The terms of the text, along with a word they are made from.
+	words := make(map[string]string)
+	for _, term := range tok.GetFullTextTerms(text) {
+		words[term.Term] = text[term.Start:term.End]
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	texts, err := sg.searchTexts(ctx, ns, attr, uids)
+	if err != nil || len(texts) == 0 {
+		return err
+	}
+
+	freqs := make(map[uint64]map[string]int, len(texts))
+	lens := make(map[uint64]int, len(texts))
+	for uid, doc := range texts {
+		terms := tok.GetFullTextTerms(doc)
+		freq := make(map[string]int)
+		for _, term := range terms {
+			if _, ok := words[term.Term]; ok {
+				freq[term.Term]++
+			}
+		}
+		freqs[uid] = freq
+		lens[uid] = len(terms)
+	}
+
+	c, err := sg.docCount(ctx, ns, attr)
+	if err != nil || c.n == 0 || c.terms == 0 {
+		return err
+	}
+	n, avgLen := c.n, float64(c.terms)/float64(c.n)
+	for term, word := range words {
+		df, err := sg.termDocCount(ctx, ns, attr, word)
+		if err != nil {
+			return err
+		}
+		idf := bm25IDF(n, df)
+		for uid, freq := range freqs {
+			if tf := freq[term]; tf > 0 {
+				scores[uid] += idf * bm25TF(tf, lens[uid], avgLen)
+			}
+		}
+	}
+	return nil
+}
+
+// bm25IDF returns the inverse document frequency of a term found in df of the n documents.
+func bm25IDF(n, df int) float64 {
+	if n < df {
+		n = df
+	}
+	return math.Log(1 + (float64(n-df)+0.5)/(float64(df)+0.5))
+}
+
+// bm25TF returns the weight of a term found tf times in a document of length docLen, given the
+// average length of the documents.
+func bm25TF(tf, docLen int, avgLen float64) float64 {
+	f := float64(tf)
+	return f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(docLen)/avgLen))
+}
+
+func (sg *SubGraph) highlight(ctx context.Context, ns uint64,
+	uids []uint64) (map[uint64]types.Val, error) {
+
+	attr, text := sg.SrcFunc.Args[0].Value, sg.SrcFunc.Args[1].Value
+	terms := make(map[string]struct{})
+	for _, term := range tok.GetFullTextTerms(text) {
+		terms[term.Term] = struct{}{}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	texts, err := sg.searchTexts(ctx, ns, attr, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[uint64]types.Val)
+	for uid, doc := range texts {
+		var matches []tok.FullTextTerm
+		for _, term := range tok.GetFullTextTerms(doc) {
+			if _, ok := terms[term.Term]; ok {
+				matches = append(matches, term)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		vals[uid] = types.Val{Tid: types.TypeString, Value: highlightText(doc, matches)}
+	}
+	return vals, nil
+}
+
+// highlightText returns a snippet of text around the first of the matches, with the matches
+// in it wrapped in <em></em>. The matches must be in the order they appear in text. The snippet
+// is cut at spaces, and starts or ends with "..." where text was cut.
+func highlightText(text string, matches []tok.FullTextTerm) string {
+	start, end := 0, len(text)
+	if len(text) > highlightLen {
+		first := matches[0]
+		// Keep some of the text before the first match, as context.
+		start = first.Start - highlightLen/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + highlightLen
+		if end < first.End {
+			end = first.End
+		}
+		if end > len(text) {
+			end = len(text)
+		}
+		if start > 0 {
+			if i := strings.IndexByte(text[start:first.Start], ' '); i >= 0 {
+				start += i + 1
+			}
+			for start > 0 && !utf8.RuneStart(text[start]) {
+				start--
+			}
+		}
+		if end < len(text) {
+			if i := strings.LastIndexByte(text[first.End:end], ' '); i >= 0 {
+				end = first.End + i
+			}
+			for end < len(text) && !utf8.RuneStart(text[end]) {
+				end++
+			}
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.Start < pos {
+			continue
+		}
+		if m.End > end {
+			break
+		}
+		sb.WriteString(text[pos:m.Start])
+		sb.WriteString("<em>")
+		sb.WriteString(text[m.Start:m.End])
+		sb.WriteString("</em>")
+		pos = m.End
+	}
+	sb.WriteString(text[pos:end])
+	if end < len(text) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
+
+func (sg *SubGraph) vectorDistance(ctx context.Context, ns uint64,
+	uids []uint64) (map[uint64]types.Val, error) {
+
+	args := sg.SrcFunc.Args
+	vec, err := types.ParseVFloat(args[1].Value)
+	if err != nil {
+		return nil, err
+	}
+	distance := types.EuclideanDistance
+	if len(args) > 2 {
+		distance = strings.ToLower(args[2].Value)
+	}
+	if !types.IsVectorDistance(distance) {
+		return nil, errors.Errorf("Invalid vector distance %q. It should be %s or %s",
+			args[2].Value, types.EuclideanDistance, types.CosineDistance)
+	}
+	rows, err := sg.searchValues(ctx, ns, args[0].Value, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[uint64]types.Val, len(rows))
+	for uid, row := range rows {
+		best, found := math.Inf(1), false
+		for _, tv := range row {
+			val, err := types.Convert(tv.Val, types.TypeVFloat)
+			if err != nil {
+				return nil, err
+			}
+			// Vectors of other dimensions are not comparable, so they are left out.
+			dist, err := types.VectorDistance(distance, vec, val.Value.([]float32))
+			if err != nil {
+				continue
+			}
+			if dist < best {
+				best, found = dist, true
+			}
+		}
+		if found {
+			vals[uid] = types.Val{Tid: types.TypeFloat, Value: best}
+		}
+	}
+	return vals, nil
+}
+
+// searchValues returns the values of attr for the nodes uids, keyed by uid.
+func (sg *SubGraph) searchValues(ctx context.Context, ns uint64, attr string,
+	uids []uint64) (map[uint64][]*pb.TaskValue, error) {
+
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	// The uids can be in any order, like the ones sorted by a predicate.
+	sorted := append([]uint64{}, uids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  sg.ReadTs,
+		Attr:    x.NamespaceAttr(ns, attr),
+		UidList: codec.ToList(sroar.FromSortedList(sorted)),
+	})
+	switch {
+	case err != nil && strings.Contains(err.Error(), worker.ErrNonExistentTabletMessage):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	rows := make(map[uint64][]*pb.TaskValue, len(uids))
+	// The rows of the ValueMatrix are in the order of the sorted uids.
+	for i, row := range result.ValueMatrix {
+		if i >= len(sorted) {
+			break
+		}
+		if len(row.Values) > 0 {
+			rows[sorted[i]] = row.Values
+		}
+	}
+	return rows, nil
+}
+
+// searchTexts returns the values of attr for the nodes uids, keyed by uid. The values of a list
+// predicate are joined into one text.
+func (sg *SubGraph) searchTexts(ctx context.Context, ns uint64, attr string,
+	uids []uint64) (map[uint64]string, error) {
+
+	rows, err := sg.searchValues(ctx, ns, attr, uids)
+	if err != nil {
+		return nil, err
+	}
+	texts := make(map[uint64]string, len(rows))
+	for uid, row := range rows {
+		var parts []string
+		for _, tv := range row {
+			val, err := types.Convert(tv.Val, types.TypeString)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := val.Value.(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			texts[uid] = strings.Join(parts, " ")
+		}
+	}
+	return texts, nil
+}
+
+// docCount returns the number of nodes having a value for attr, along with the number of
+// full-text terms in all of those values.
+func (sg *SubGraph) docCount(ctx context.Context, ns uint64, attr string) (docCount, error) {
+	nsAttr := x.NamespaceAttr(ns, attr)
+	if c, ok := docCounts.Load(nsAttr); ok && time.Now().Before(c.(docCount).expiry) {
+		return c.(docCount), nil
+	}
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  sg.ReadTs,
+		Attr:    nsAttr,
+		SrcFunc: &pb.SrcFunction{Name: "has"},
+		First:   math.MaxInt32,
+	})
+	if err != nil {
+		return docCount{}, errors.Wrapf(err, "while counting the values of %s", attr)
+	}
+	uids := codec.Merge(result.UidMatrix).ToArray()
+	c := docCount{n: len(uids), expiry: time.Now().Add(docCountTTL)}
+	for len(uids) > 0 {
+		batch := uids
+		if len(batch) > docCountBatch {
+			batch = batch[:docCountBatch]
+		}
+		uids = uids[len(batch):]
+		texts, err := sg.searchTexts(ctx, ns, attr, batch)
+		if err != nil {
+			return docCount{}, errors.Wrapf(err, "while counting the terms of %s", attr)
+		}
+		for _, text := range texts {
+			c.terms += len(tok.GetFullTextTerms(text))
+		}
+	}
+	docCounts.Store(nsAttr, c)
+	return c, nil
+}
+
+// termDocCount returns the number of nodes whose value of attr has the full-text term of word,
+// as found in the fulltext index.
+func (sg *SubGraph) termDocCount(ctx context.Context, ns uint64, attr, word string) (int, error) {
+	result, err := worker.ProcessTaskOverNetwork(ctx, &pb.Query{
+		ReadTs:  sg.ReadTs,
+		Attr:    x.NamespaceAttr(ns, attr),
+		SrcFunc: &pb.SrcFunction{Name: "anyoftext", Args: []string{word}},
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "while looking up %q in the fulltext index of %s", word, attr)
+	}
+	var df int
+	for _, row := range result.UidMatrix {
+		df += int(codec.ListCardinality(row))
+	}
+	return df, nil
+}
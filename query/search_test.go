@@ -0,0 +1,60 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/outcaste-io/outserv/tok"
+)
+
+func TestBM25(t *testing.T) {
+	// Rarer terms weigh more.
+	require.Greater(t, bm25IDF(1000, 10), bm25IDF(1000, 100))
+	// A term found in every document still weighs something.
+	require.Greater(t, bm25IDF(1000, 1000), 0.0)
+	// The counts can be off, when the documents change in between.
+	require.Equal(t, bm25IDF(10, 10), bm25IDF(5, 10))
+
+	// More occurrences weigh more, but never more than k1 + 1.
+	require.Greater(t, bm25TF(2, 10, 10), bm25TF(1, 10, 10))
+	require.Less(t, bm25TF(1000, 10, 10), bm25K1+1)
+	// The same occurrences weigh more in shorter documents.
+	require.Greater(t, bm25TF(1, 5, 10), bm25TF(1, 20, 10))
+}
+
+func TestHighlightText(t *testing.T) {
+	// matchesOf returns the matches of the words in text, as the fulltext terms would be.
+	matchesOf := func(text string, words ...string) []tok.FullTextTerm {
+		var matches []tok.FullTextTerm
+		for start := 0; start < len(text); {
+			end := strings.IndexByte(text[start:], ' ')
+			if end < 0 {
+				end = len(text)
+			} else {
+				end += start
+			}
+			for _, w := range words {
+				if text[start:end] == w {
+					matches = append(matches, tok.FullTextTerm{Term: w, Start: start, End: end})
+				}
+			}
+			start = end + 1
+		}
+		return matches
+	}
+
+	text := "the quick brown fox jumps over the lazy dog"
+	require.Equal(t, "the quick brown <em>fox</em> jumps over the lazy <em>dog</em>",
+		highlightText(text, matchesOf(text, "fox", "dog")))
+
+	long := strings.Repeat("lorem ipsum ", 20) + "quick fox " + strings.Repeat("dolor sit ", 20)
+	out := highlightText(long, matchesOf(long, "fox"))
+	require.True(t, strings.HasPrefix(out, "...ipsum "), out)
+	require.True(t, strings.HasSuffix(out, " dolor..."), out)
+	require.Contains(t, out, "quick <em>fox</em> dolor")
+	require.LessOrEqual(t, len(out), highlightLen+len("......<em></em>"))
+}
@@ -95,7 +95,8 @@ func (s *state) Delete(attr string, ts uint64) error {
 	return nil
 }
 
-// Namespaces returns the active namespaces based on the current types.
+// Namespaces returns the active namespaces based on the current predicates. Every namespace
+// gets the pre-defined predicates on creation, so none is missed.
 func (s *state) Namespaces() map[uint64]struct{} {
 	if s == nil {
 		return nil
@@ -104,8 +105,11 @@ func (s *state) Namespaces() map[uint64]struct{} {
 	s.RLock()
 	defer s.RUnlock()
 
-	// TODO: Find another way of figuring out namespaces.
-	return nil
+	namespaces := make(map[uint64]struct{})
+	for pred := range s.predicate {
+		namespaces[x.ParseNamespace(pred)] = struct{}{}
+	}
+	return namespaces
 }
 
 // DeletePredsForNs deletes the predicate information for the namespace from the schema.
@@ -505,6 +509,9 @@ func initialSchemaInternal(namespace uint64, all bool) []*pb.SchemaUpdate {
 			ValueType: types.TypeString.Int(),
 			Directive: pb.SchemaUpdate_INDEX,
 			Tokenizer: []string{"sha256"},
+		}, &pb.SchemaUpdate{
+			Predicate: "dgraph.graphql.p_allowed",
+			ValueType: types.TypeBool.Int(),
 		})
 
 	if all || x.WorkerConfig.AclEnabled {
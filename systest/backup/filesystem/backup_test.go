@@ -0,0 +1,106 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/outcaste-io/dgo/v210"
+	"github.com/outcaste-io/dgo/v210/protos/api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/outcaste-io/outserv/testutil"
+	"github.com/outcaste-io/outserv/worker"
+)
+
+const (
+	backupDst     = "/data/backup"
+	copyBackupDir = "./data/backup-copy"
+)
+
+func TestBackupRestoreAndMutate(t *testing.T) {
+	dg, err := testutil.DgraphClient(testutil.SockAddr)
+	require.NoError(t, err)
+	require.NoError(t, testutil.RetryAlter(dg, &api.Operation{DropAll: true}))
+	require.NoError(t, testutil.RetryAlter(dg, &api.Operation{
+		Schema: `name: string @index(exact) .`,
+	}))
+	require.NoError(t, testutil.DockerExec("alpha1", "bash", "-c", "rm -rf "+backupDst))
+
+	restored := mutate(t, dg, `_:a <name> "alice" .
+		_:b <name> "bob" .`)
+	requestAdmin(t, "backup", `mutation backup($dst: String!) {
+		backup(input: {destination: $dst}) { response { code } taskId }
+	}`, map[string]interface{}{"dst": backupDst})
+
+	// The manifest records leases past the ones used by the data in the backup.
+	require.NoError(t, os.RemoveAll(copyBackupDir))
+	require.NoError(t, testutil.DockerCp(
+		testutil.DockerPrefix+"_alpha1_1:"+backupDst, copyBackupDir))
+	defer os.RemoveAll("./data")
+	b, err := ioutil.ReadFile(filepath.Join(copyBackupDir, "manifest.json"))
+	require.NoError(t, err)
+	var master worker.MasterManifest
+	require.NoError(t, json.Unmarshal(b, &master))
+	require.Len(t, master.Manifests, 1)
+	m := master.Manifests[0]
+	for _, uid := range restored {
+		require.Less(t, uid, m.MaxUid)
+	}
+	require.Equal(t, m.ReadTs, m.MaxTs)
+	require.NotZero(t, m.MaxNsID)
+
+	requestAdmin(t, "restore", `mutation restore($loc: String!) {
+		restore(input: {location: $loc}) { response { code } taskId }
+	}`, map[string]interface{}{"loc": backupDst})
+
+	// A new mutation neither reuses the uids of the restored data, nor gets shadowed by it.
+	added := mutate(t, dg, `_:c <name> "carol" .`)
+	for _, uid := range restored {
+		require.Greater(t, added["c"], uid)
+	}
+	resp, err := testutil.RetryQuery(dg, `{
+		q(func: has(name), orderasc: name) { name }
+	}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"q": [{"name": "alice"}, {"name": "bob"}, {"name": "carol"}]}`,
+		string(resp.Json))
+}
+
+func mutate(t *testing.T, dg *dgo.Dgraph, nquads string) map[string]uint64 {
+	resp, err := dg.NewTxn().Mutate(context.Background(), &api.Mutation{
+		CommitNow: true,
+		SetNquads: []byte(nquads),
+	})
+	require.NoError(t, err)
+	uids := make(map[string]uint64)
+	for name, uid := range resp.Uids {
+		val, err := strconv.ParseUint(uid, 0, 64)
+		require.NoError(t, err)
+		uids[name] = val
+	}
+	return uids
+}
+
+// requestAdmin runs the admin mutation name, and waits for the task it queues to finish.
+func requestAdmin(t *testing.T, name, query string, vars map[string]interface{}) {
+	b, err := json.Marshal(testutil.GraphQLParams{Query: query, Variables: vars})
+	require.NoError(t, err)
+	resp, err := http.Post("http://"+testutil.SockAddrHttp+"/admin", "application/json",
+		bytes.NewBuffer(b))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var data interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	require.Equal(t, "Success", testutil.JsonGet(data, "data", name, "response", "code"))
+	testutil.WaitForTask(t, testutil.JsonGet(data, "data", name, "taskId").(string), false)
+}
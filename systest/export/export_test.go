@@ -84,6 +84,7 @@ var expectedSchema = `[0x0] <movie>:string .` + " " + `
 [0x0] <dgraph.graphql.xid>:string @index(exact) @upsert .` + " " + `
 [0x0] <dgraph.graphql.schema>:string .` + " " + `
 [0x0] <dgraph.graphql.p_query>:string @index(sha256) .` + " " + `
+[0x0] <dgraph.graphql.p_allowed>:bool .` + " " + `
 [0x0] type <Node> {
 	movie
 }
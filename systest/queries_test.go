@@ -380,6 +380,9 @@ func SchemaQueryTestPredicate1(t *testing.T, c *dgo.Dgraph) {
 	  {
 		"predicate": "dgraph.graphql.p_query"
 	  },
+	  {
+		"predicate": "dgraph.graphql.p_allowed"
+	  },
       {
         "predicate": "dgraph.xid"
 	  },
@@ -26,7 +26,9 @@ import (
 	"github.com/golang/glog"
 	geom "github.com/twpayne/go-geom"
 	"golang.org/x/crypto/blake2b"
+	"golang.org/x/text/cases"
 	"golang.org/x/text/collate"
+	"golang.org/x/text/unicode/norm"
 
 	"github.com/outcaste-io/outserv/types"
 	"github.com/outcaste-io/outserv/x"
@@ -55,6 +57,9 @@ const (
 	IdentHash      = 0xB
 	IdentSha       = 0xC
 	IdentBigInt    = 0xD
+	IdentLsh       = 0xE
+	IdentExactCI   = 0xF
+	IdentHashCI    = 0x10
 	IdentCustom    = 0x80
 	IdentDelimiter = 0x1f // ASCII 31 - Unit seperator
 )
@@ -101,9 +106,12 @@ func init() {
 	registerTokenizer(BoolTokenizer{})
 	registerTokenizer(TrigramTokenizer{})
 	registerTokenizer(HashTokenizer{})
+	registerTokenizer(ExactCITokenizer{})
+	registerTokenizer(HashCITokenizer{})
 	registerTokenizer(TermTokenizer{})
 	registerTokenizer(FullTextTokenizer{})
 	registerTokenizer(Sha256Tokenizer{})
+	registerTokenizer(LshTokenizer{})
 	setupBleve()
 }
 
@@ -190,6 +198,19 @@ func (t GeoTokenizer) Identifier() byte { return IdentGeo }
 func (t GeoTokenizer) IsSortable() bool { return false }
 func (t GeoTokenizer) IsLossy() bool    { return true }
 
+// LshTokenizer generates the tokens of the LSH index, the cells of the vector space that a
+// vector is in. See types.IndexVFloatTokens.
+type LshTokenizer struct{}
+
+func (t LshTokenizer) Name() string { return "lsh" }
+func (t LshTokenizer) Type() string { return "vfloat" }
+func (t LshTokenizer) Tokens(v interface{}) ([]string, error) {
+	return types.IndexVFloatTokens(v.([]float32))
+}
+func (t LshTokenizer) Identifier() byte { return IdentLsh }
+func (t LshTokenizer) IsSortable() bool { return false }
+func (t LshTokenizer) IsLossy() bool    { return true }
+
 // IntTokenizer generates tokens from integer data.
 type IntTokenizer struct{}
 
@@ -509,6 +530,52 @@ func (t HashTokenizer) IsSortable() bool { return false }
 // query operations using the hash index.
 func (t HashTokenizer) IsLossy() bool { return false }
 
+// NormalizeCI folds the case of the string and puts it in the NFKC normal form, so that the
+// strings which only differ in their case or in their Unicode representation are the same.
+func NormalizeCI(s string) string {
+	return norm.NFKC.String(cases.Fold().String(s))
+}
+
+// IsCaseInsensitive returns whether the tokenizer indexes the strings normalized by NormalizeCI.
+func IsCaseInsensitive(t Tokenizer) bool {
+	id := t.Identifier()
+	return id == IdentExactCI || id == IdentHashCI
+}
+
+// ExactCITokenizer returns the normalized string as a token, so that eq and the inequality
+// functions don't tell apart the strings which only differ in their case.
+type ExactCITokenizer struct{}
+
+func (t ExactCITokenizer) Name() string { return "exact_ci" }
+func (t ExactCITokenizer) Type() string { return "string" }
+func (t ExactCITokenizer) Tokens(v interface{}) ([]string, error) {
+	val, ok := v.(string)
+	if !ok {
+		return nil, errors.Errorf("Exact indices only supported for string types")
+	}
+	return []string{NormalizeCI(val)}, nil
+}
+func (t ExactCITokenizer) Identifier() byte { return IdentExactCI }
+func (t ExactCITokenizer) IsSortable() bool { return true }
+func (t ExactCITokenizer) IsLossy() bool    { return false }
+
+// HashCITokenizer returns the hash of the normalized string as a token. Like the
+// HashTokenizer, it isn't lossy.
+type HashCITokenizer struct{}
+
+func (t HashCITokenizer) Name() string { return "hash_ci" }
+func (t HashCITokenizer) Type() string { return "string" }
+func (t HashCITokenizer) Tokens(v interface{}) ([]string, error) {
+	term, ok := v.(string)
+	if !ok {
+		return nil, errors.Errorf("Hash tokenizer only supported for string types")
+	}
+	return HashTokenizer{}.Tokens(NormalizeCI(term))
+}
+func (t HashCITokenizer) Identifier() byte { return IdentHashCI }
+func (t HashCITokenizer) IsSortable() bool { return false }
+func (t HashCITokenizer) IsLossy() bool    { return false }
+
 // PluginTokenizer is implemented by external plugins loaded dynamically via
 // *.so files. It follows the implementation semantics of the Tokenizer
 // interface.
@@ -551,9 +618,57 @@ func EncodeGeoTokens(tokens []string) {
 	}
 }
 
+// EncodeLshTokens encodes the given list of tokens as LSH tokens.
+func EncodeLshTokens(tokens []string) {
+	for i := 0; i < len(tokens); i++ {
+		tokens[i] = encodeToken(tokens[i], LshTokenizer{}.Identifier())
+	}
+}
+
 // EncodeRegexTokens encodes the given list of strings as regex tokens.
 func EncodeRegexTokens(tokens []string) {
 	for i := 0; i < len(tokens); i++ {
 		tokens[i] = encodeToken(tokens[i], TrigramTokenizer{}.Identifier())
 	}
 }
+
+// DecodeTimeToken returns the start of the period in UTC that the given year, month, day
+// or hour token stands for. The token must start with the identifier of its tokenizer, as
+// returned by BuildTokens.
+func DecodeTimeToken(token string) (time.Time, error) {
+	var n int
+	switch {
+	case len(token) == 0:
+		return time.Time{}, errors.Errorf("Empty datetime token")
+	case token[0] == IdentYear:
+		n = 1
+	case token[0] == IdentMonth:
+		n = 2
+	case token[0] == IdentDay:
+		n = 3
+	case token[0] == IdentHour:
+		n = 4
+	default:
+		return time.Time{}, errors.Errorf("Not a datetime token: %v", []byte(token))
+	}
+	if len(token) != 1+2*n {
+		return time.Time{}, errors.Errorf("Invalid datetime token: %v", []byte(token))
+	}
+	// Year, month, day and hour. The parts missing from the token default to the start of
+	// the period.
+	parts := []int{0, 1, 1, 0}
+	buf := []byte(token[1:])
+	for i := 0; i < n; i++ {
+		parts[i] = int(binary.BigEndian.Uint16(buf[2*i : 2*i+2]))
+	}
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], 0, 0, 0, time.UTC), nil
+}
+
+// DecodeIntToken returns the value of the given int token. The token must start with the
+// identifier of the int tokenizer, as returned by BuildTokens.
+func DecodeIntToken(token string) (int64, error) {
+	if len(token) != 10 || token[0] != IdentInt {
+		return 0, errors.Errorf("Invalid int token: %v", []byte(token))
+	}
+	return int64(binary.BigEndian.Uint64([]byte(token[2:]))), nil
+}
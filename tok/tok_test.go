@@ -19,10 +19,13 @@ package tok
 import (
 	"math"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/outcaste-io/outserv/types"
 )
 
 type encL struct {
@@ -73,6 +76,20 @@ func TestFullTextTokenizer(t *testing.T) {
 	require.Equal(t, []string{encodeToken("stem", id), encodeToken("work", id)}, tokens)
 }
 
+func TestLshTokenizer(t *testing.T) {
+	tokenizer, has := GetTokenizer("lsh")
+	require.True(t, has)
+	require.False(t, tokenizer.IsSortable())
+
+	vec := []float32{0.5, -0.25, 1.5}
+	tokens, err := BuildTokens(vec, tokenizer)
+	require.NoError(t, err)
+	expected, err := types.IndexVFloatTokens(vec)
+	require.NoError(t, err)
+	EncodeLshTokens(expected)
+	require.Equal(t, expected, tokens)
+}
+
 func TestHourTokenizer(t *testing.T) {
 	var err error
 	tokenizer, has := GetTokenizer("hour")
@@ -178,6 +195,36 @@ func TestTrigramTokenizer(t *testing.T) {
 	require.Equal(t, expected, tokens)
 }
 
+func TestCaseInsensitiveTokenizers(t *testing.T) {
+	for _, name := range []string{"exact_ci", "hash_ci"} {
+		tokenizer, has := GetTokenizer(name)
+		require.True(t, has)
+		require.True(t, IsCaseInsensitive(tokenizer))
+
+		// An EIP-55 checksummed address and its lowercase form are the same.
+		checksummed, err := BuildTokens("0x52908400098527886E0F7030069857D2E4169EE7", tokenizer)
+		require.NoError(t, err)
+		lower, err := BuildTokens("0x52908400098527886e0f7030069857d2e4169ee7", tokenizer)
+		require.NoError(t, err)
+		require.Equal(t, checksummed, lower)
+
+		// So are the composed and decomposed forms of a string.
+		composed, err := BuildTokens("Caf\u00e9", tokenizer)
+		require.NoError(t, err)
+		decomposed, err := BuildTokens("cafe\u0301", tokenizer)
+		require.NoError(t, err)
+		require.Equal(t, composed, decomposed)
+
+		other, err := BuildTokens("0x52908400098527886e0f7030069857d2e4169ee8", tokenizer)
+		require.NoError(t, err)
+		require.NotEqual(t, lower, other)
+	}
+
+	exact, has := GetTokenizer("exact")
+	require.True(t, has)
+	require.False(t, IsCaseInsensitive(exact))
+}
+
 func TestGetFullTextTokens(t *testing.T) {
 	val := "Our chief weapon is surprise...surprise and fear...fear and surprise...." +
 		"Our two weapons are fear and surprise...and ruthless efficiency.... " +
@@ -199,6 +246,18 @@ func TestGetFullTextTokens1(t *testing.T) {
 	require.Equal(t, 3, len(tokens))
 }
 
+func TestGetFullTextTerms(t *testing.T) {
+	val := "Fear and surprise, our two weapons are fear and surprise."
+	var terms []string
+	for _, term := range GetFullTextTerms(val) {
+		terms = append(terms, term.Term)
+		// The terms are stemmed from the words they point to.
+		word := strings.ToLower(val[term.Start:term.End])
+		require.True(t, strings.HasPrefix(word, term.Term), "%q isn't a stem of %q", term.Term, word)
+	}
+	require.Equal(t, []string{"fear", "surpris", "two", "weapon", "fear", "surpris"}, terms)
+}
+
 func checkSortedAndUnique(t *testing.T, tokens []string) {
 	if !sort.StringsAreSorted(tokens) {
 		t.Error("tokens were not sorted")
@@ -217,3 +276,46 @@ func checkSortedAndUnique(t *testing.T, tokens []string) {
 func BenchmarkTermTokenizer(b *testing.B) {
 	b.Skip() // tmp
 }
+
+func TestDecodeTimeToken(t *testing.T) {
+	dt, err := time.Parse(time.RFC3339, "2017-03-04T12:12:12Z")
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"year":  "2017-01-01T00:00:00Z",
+		"month": "2017-03-01T00:00:00Z",
+		"day":   "2017-03-04T00:00:00Z",
+		"hour":  "2017-03-04T12:00:00Z",
+	}
+	for name, exp := range expected {
+		tokenizer, has := GetTokenizer(name)
+		require.True(t, has)
+		tokens, err := BuildTokens(dt, tokenizer)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(tokens))
+
+		start, err := DecodeTimeToken(tokens[0])
+		require.NoError(t, err)
+		require.Equal(t, exp, start.Format(time.RFC3339), name)
+	}
+
+	_, err = DecodeTimeToken(encodeToken(encodeInt(10), IdentInt))
+	require.Error(t, err)
+}
+
+func TestDecodeIntToken(t *testing.T) {
+	tokenizer, has := GetTokenizer("int")
+	require.True(t, has)
+	for _, val := range []int64{0, 1, -1, 1 << 40, math.MinInt64, math.MaxInt64} {
+		tokens, err := BuildTokens(val, tokenizer)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(tokens))
+
+		decoded, err := DecodeIntToken(tokens[0])
+		require.NoError(t, err)
+		require.Equal(t, val, decoded)
+	}
+
+	_, err := DecodeIntToken("")
+	require.Error(t, err)
+}
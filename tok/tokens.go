@@ -45,3 +45,25 @@ func GetFullTextTokens(funcArgs []string) ([]string, error) {
 	}
 	return BuildTokens(funcArgs[0], FullTextTokenizer{})
 }
+
+// FullTextTerm is a full-text term of a value, along with the byte offsets of the word it was
+// made from.
+type FullTextTerm struct {
+	Term       string
+	Start, End int
+}
+
+// GetFullTextTerms returns the full-text terms of the given value, in the order they appear in
+// it, repetitions included. These are the terms indexed by the fulltext tokenizer, before they
+// get encoded into tokens.
+func GetFullTextTerms(str string) []FullTextTerm {
+	lang := LangBase("")
+	tokens := fulltextAnalyzer.Analyze([]byte(str))
+	tokens = filterStopwords(lang, tokens)
+	tokens = filterStemmers(lang, tokens)
+	terms := make([]FullTextTerm, 0, len(tokens))
+	for _, t := range tokens {
+		terms = append(terms, FullTextTerm{Term: string(t.Term), Start: t.Start, End: t.End})
+	}
+	return terms
+}
@@ -84,6 +84,12 @@ func Convert(from Sval, toID TypeID) (Val, error) {
 					return to, errors.Errorf("Marshalling failed for bigint %v", data)
 				}
 				*res = *b
+			case TypeVFloat:
+				v, err := vfloatFromBinary(data)
+				if err != nil {
+					return to, err
+				}
+				*res = v
 			default:
 				return to, cantConvert(fromID, toID)
 			}
@@ -144,6 +150,12 @@ func Convert(from Sval, toID TypeID) (Val, error) {
 					return to, err
 				}
 				*res = p
+			case TypeVFloat:
+				v, err := ParseVFloat(vc)
+				if err != nil {
+					return to, err
+				}
+				*res = v
 			default:
 				return to, cantConvert(fromID, toID)
 			}
@@ -345,6 +357,23 @@ func Convert(from Sval, toID TypeID) (Val, error) {
 				return to, cantConvert(fromID, toID)
 			}
 		}
+	case TypeVFloat:
+		{
+			vc, err := vfloatFromBinary(data)
+			if err != nil {
+				return to, err
+			}
+			switch toID {
+			case TypeVFloat:
+				*res = vc
+			case TypeBinary:
+				*res = vfloatToBinary(vc)
+			case TypeString, TypeDefault:
+				*res = FormatVFloat(vc)
+			default:
+				return to, cantConvert(fromID, toID)
+			}
+		}
 	default:
 		return to, cantConvert(fromID, toID)
 	}
@@ -491,6 +520,16 @@ func Marshal(from Val, toID TypeID) (Val, error) {
 		default:
 			return to, cantConvert(fromID, toID)
 		}
+	case TypeVFloat:
+		vc := val.([]float32)
+		switch toID {
+		case TypeString:
+			*res = FormatVFloat(vc)
+		case TypeBinary:
+			*res = vfloatToBinary(vc)
+		default:
+			return to, cantConvert(fromID, toID)
+		}
 	default:
 		return to, cantConvert(fromID, toID)
 	}
@@ -545,6 +584,8 @@ func (v Val) MarshalJSON() ([]byte, error) {
 	case TypeBigInt:
 		i := v.Value.(big.Int)
 		return i.MarshalJSON()
+	case TypeVFloat:
+		return []byte(FormatVFloat(v.Value.([]float32))), nil
 	}
 	return nil, errors.Errorf("Invalid type for MarshalJSON: %v", v.Tid)
 }
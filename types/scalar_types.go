@@ -30,6 +30,7 @@ var typeNameMap = map[string]TypeID{
 	"password": TypePassword,
 	"upload":   TypeBinary,
 	"bigint":   TypeBigInt,
+	"vfloat":   TypeVFloat,
 }
 
 // TypeID represents the type of the data.
@@ -49,6 +50,7 @@ const (
 	TypeObject
 	TypeUndefined
 	TypeBigInt
+	TypeVFloat
 )
 
 // Name returns the name of the type.
@@ -76,6 +78,8 @@ func (t TypeID) String() string {
 		return "password"
 	case TypeBigInt:
 		return "bigint"
+	case TypeVFloat:
+		return "vfloat"
 	}
 	return ""
 }
@@ -182,6 +186,10 @@ func ValueForType(id TypeID) Val {
 		var i big.Int
 		return Val{TypeBigInt, &i}
 
+	case TypeVFloat:
+		var v []float32
+		return Val{TypeVFloat, v}
+
 	default:
 		return Val{}
 	}
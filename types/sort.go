@@ -67,7 +67,9 @@ func (s byValue) Less(i, j int) bool {
 		}
 		return less
 	}
-	return false
+	// Break the ties by UID, so that the order is stable across queries. Cursor based
+	// pagination relies on this.
+	return (*s.ul)[i] < (*s.ul)[j]
 }
 
 // IsSortable returns true, if tid is sortable. Otherwise it returns false.
@@ -122,6 +122,18 @@ func TestSortIntAndFloat(t *testing.T) {
 
 }
 
+func TestSortTiesByUID(t *testing.T) {
+	list := getInput(t, IntID, []string{"2", "1", "2", "1", "2"})
+	ul := &pb.List{SortedUids: []uint64{500, 400, 300, 200, 100}}
+	require.NoError(t, Sort(list, &ul.SortedUids, []bool{false}))
+	require.EqualValues(t, []uint64{200, 400, 100, 300, 500}, ul.SortedUids)
+
+	list = getInput(t, IntID, []string{"2", "1", "2", "1", "2"})
+	ul = &pb.List{SortedUids: []uint64{500, 400, 300, 200, 100}}
+	require.NoError(t, Sort(list, &ul.SortedUids, []bool{true}))
+	require.EqualValues(t, []uint64{100, 300, 500, 200, 400}, ul.SortedUids)
+}
+
 func TestEqual(t *testing.T) {
 	require.True(t, equal(Val{Tid: IntID, Value: int64(3)}, Val{Tid: IntID, Value: int64(3)}),
 		"equal should return true for two equal values")
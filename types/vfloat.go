@@ -0,0 +1,243 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package types
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// The distances between vectors.
+const (
+	EuclideanDistance = "euclidean"
+	CosineDistance    = "cosine"
+)
+
+const (
+	// lshTables is the number of independent partitions of the vector space in the LSH index.
+	lshTables = 4
+	// lshMaxBits is the number of hyperplanes of each partition, so it has up to 2^lshMaxBits
+	// cells.
+	lshMaxBits = 16
+)
+
+// LshLevels are the numbers of hyperplanes the cells of the LSH index are cut by, from the
+// coarsest to the finest. A vector is indexed in one cell of each level, in every partition.
+var LshLevels = []int{4, 8, 12, 16}
+
+// lshPlanes caches the hyperplanes of the LSH index, keyed by the number of dimensions.
+var lshPlanes sync.Map
+
+// ParseVFloat parses a vector written like [0.1, 0.2, 0.3].
+func ParseVFloat(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, errors.Errorf("Invalid vector %q, it should be like [0.1, 0.2]", s)
+	}
+	parts := strings.Split(s[1:len(s)-1], ",")
+	if len(parts) == 1 && strings.TrimSpace(parts[0]) == "" {
+		return nil, errors.Errorf("Invalid vector %q, it can't be empty", s)
+	}
+	vec := make([]float32, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while parsing vector %q", s)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errors.Errorf("Invalid value %q in vector", part)
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
+// FormatVFloat writes the vector like [0.1,0.2,0.3], which is valid JSON too.
+func FormatVFloat(vec []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, f := range vec {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func vfloatToBinary(vec []float32) []byte {
+	out := make([]byte, 4*len(vec))
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(f))
+	}
+	return out
+}
+
+func vfloatFromBinary(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, errors.Errorf("Invalid data for vfloat %v", data)
+	}
+	vec := make([]float32, len(data)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vec, nil
+}
+
+// IsVectorDistance returns whether the name is of a distance between vectors.
+func IsVectorDistance(name string) bool {
+	return name == EuclideanDistance || name == CosineDistance
+}
+
+// VectorDistance returns the given distance between the vectors a and b. The cosine distance is
+// 1 minus the cosine similarity, so it's between 0 and 2, and it's 1 if either vector is zero.
+func VectorDistance(distance string, a, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, errors.Errorf("Vectors of %d and %d dimensions can't be compared",
+			len(a), len(b))
+	}
+	switch distance {
+	case EuclideanDistance:
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return math.Sqrt(sum), nil
+	case CosineDistance:
+		var dot, na, nb float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+			na += float64(a[i]) * float64(a[i])
+			nb += float64(b[i]) * float64(b[i])
+		}
+		if na == 0 || nb == 0 {
+			return 1, nil
+		}
+		return 1 - dot/math.Sqrt(na*nb), nil
+	}
+	return 0, errors.Errorf("Invalid vector distance %q. It should be %s or %s",
+		distance, EuclideanDistance, CosineDistance)
+}
+
+// The LSH index hashes vectors with random-hyperplane locality-sensitive hashing: the hash of a
+// vector is the side of each of a set of random hyperplanes through the origin it's on, which
+// partitions the vector space into cells. Nearby vectors tend to fall on the same side of a
+// hyperplane, so they share their cells, for both the euclidean and the cosine distances. Unlike
+// an IVF index, there are no centroids to train, as the hyperplanes only depend on the number of
+// dimensions. So, the index can be built one vector at a time, like the other indexes.
+//
+// A vector is indexed in lshTables partitions, at each of the LshLevels. Its token at a level
+// is the partition, the level and the sides of the first hyperplanes the vector is on.
+
+// planesFor returns the hyperplanes of the partitions for vectors of dim dimensions. They come
+// from a seeded math/rand, whose sequence doesn't change, so the same ones are used across
+// restarts and versions.
+func planesFor(dim int) [][]float32 {
+	if planes, ok := lshPlanes.Load(dim); ok {
+		return planes.([][]float32)
+	}
+	r := rand.New(rand.NewSource(int64(dim)))
+	planes := make([][]float32, lshTables*lshMaxBits)
+	for i := range planes {
+		plane := make([]float32, dim)
+		for j := range plane {
+			plane[j] = float32(r.NormFloat64())
+		}
+		planes[i] = plane
+	}
+	actual, _ := lshPlanes.LoadOrStore(dim, planes)
+	return actual.([][]float32)
+}
+
+// lshProjections returns the projections of vec on the hyperplanes of each partition.
+func lshProjections(vec []float32) [][]float64 {
+	planes := planesFor(len(vec))
+	projs := make([][]float64, lshTables)
+	for t := range projs {
+		projs[t] = make([]float64, lshMaxBits)
+		for b := range projs[t] {
+			var dot float64
+			for i, f := range planes[t*lshMaxBits+b] {
+				dot += float64(f) * float64(vec[i])
+			}
+			projs[t][b] = dot
+		}
+	}
+	return projs
+}
+
+// lshCell returns the cell of the projections of a vector on the hyperplanes of a partition, at
+// the level of the given bits.
+func lshCell(proj []float64, bits int) uint16 {
+	var cell uint16
+	for b := 0; b < bits; b++ {
+		cell <<= 1
+		if proj[b] >= 0 {
+			cell |= 1
+		}
+	}
+	return cell
+}
+
+func lshToken(table, bits int, cell uint16) string {
+	return string([]byte{byte(table), byte(bits), byte(cell >> 8), byte(cell)})
+}
+
+// IndexVFloatTokens returns the tokens of the LSH index for the vector, its cells at every level
+// of every partition.
+func IndexVFloatTokens(vec []float32) ([]string, error) {
+	if len(vec) == 0 {
+		return nil, errors.Errorf("Can't index an empty vector")
+	}
+	projs := lshProjections(vec)
+	tokens := make([]string, 0, lshTables*len(LshLevels))
+	for t, proj := range projs {
+		for _, bits := range LshLevels {
+			tokens = append(tokens, lshToken(t, bits, lshCell(proj, bits)))
+		}
+	}
+	return tokens, nil
+}
+
+// ProbeVFloatTokens returns the tokens of the cells to look up for the vectors near vec, at the
+// level of the given bits. That's the cell of vec in every partition, along with the cells across
+// the probes hyperplanes closest to vec, where its neighbours are the likeliest to be.
+func ProbeVFloatTokens(vec []float32, bits, probes int) []string {
+	var tokens []string
+	for t, proj := range lshProjections(vec) {
+		cell := lshCell(proj, bits)
+		tokens = append(tokens, lshToken(t, bits, cell))
+
+		closest := make([]int, bits)
+		for b := range closest {
+			closest[b] = b
+		}
+		sort.Slice(closest, func(i, j int) bool {
+			return math.Abs(proj[closest[i]]) < math.Abs(proj[closest[j]])
+		})
+		for i := 0; i < probes && i < bits; i++ {
+			flip := uint16(1) << uint(bits-1-closest[i])
+			tokens = append(tokens, lshToken(t, bits, cell^flip))
+		}
+	}
+	return tokens
+}
+
+// AllVFloatTokens returns the tokens of all the cells of the first partition, at the level of the
+// given bits. Every indexed vector is in one of them.
+func AllVFloatTokens(bits int) []string {
+	tokens := make([]string, 0, 1<<uint(bits))
+	for cell := 0; cell < 1<<uint(bits); cell++ {
+		tokens = append(tokens, lshToken(0, bits, uint16(cell)))
+	}
+	return tokens
+}
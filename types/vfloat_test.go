@@ -0,0 +1,80 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVFloat(t *testing.T) {
+	vec, err := ParseVFloat(" [0.1, -2,3e2] ")
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.1, -2, 300}, vec)
+	require.Equal(t, "[0.1,-2,300]", FormatVFloat(vec))
+
+	for _, s := range []string{"", "[]", "0.1, 0.2", "[0.1, a]", "[0.1, NaN]", "[Inf]"} {
+		_, err := ParseVFloat(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestConvertVFloat(t *testing.T) {
+	src, err := ToBinary(TypeString, "[1.5, 2]")
+	require.NoError(t, err)
+	val, err := Convert(src, TypeVFloat)
+	require.NoError(t, err)
+	require.Equal(t, []float32{1.5, 2}, val.Value)
+
+	data, err := ToBinary(TypeVFloat, val.Value)
+	require.NoError(t, err)
+	val, err = Convert(data, TypeString)
+	require.NoError(t, err)
+	require.Equal(t, "[1.5,2]", val.Value)
+}
+
+func TestVectorDistance(t *testing.T) {
+	a, b := []float32{1, 0}, []float32{0, 2}
+	dist, err := VectorDistance(EuclideanDistance, a, b)
+	require.NoError(t, err)
+	require.InDelta(t, math.Sqrt(5), dist, 1e-9)
+
+	dist, err = VectorDistance(CosineDistance, a, b)
+	require.NoError(t, err)
+	require.InDelta(t, 1, dist, 1e-9)
+	dist, err = VectorDistance(CosineDistance, a, []float32{3, 0})
+	require.NoError(t, err)
+	require.InDelta(t, 0, dist, 1e-9)
+
+	_, err = VectorDistance(EuclideanDistance, a, []float32{1})
+	require.Error(t, err)
+	_, err = VectorDistance("manhattan", a, b)
+	require.Error(t, err)
+}
+
+func TestVFloatTokens(t *testing.T) {
+	vec := []float32{0.25, -0.125, 0.75, 0.5}
+	tokens, err := IndexVFloatTokens(vec)
+	require.NoError(t, err)
+	require.Len(t, tokens, lshTables*len(LshLevels))
+
+	// The cell of the vector itself is always probed, at every level.
+	for _, bits := range LshLevels {
+		probed := ProbeVFloatTokens(vec, bits, 2)
+		require.Len(t, probed, lshTables*3)
+		for table := 0; table < lshTables; table++ {
+			require.Contains(t, tokens, probed[table*3])
+		}
+	}
+
+	// A vector in the same direction is in the same cells.
+	scaled, err := IndexVFloatTokens([]float32{0.5, -0.25, 1.5, 1})
+	require.NoError(t, err)
+	require.Equal(t, tokens, scaled)
+
+	require.Len(t, AllVFloatTokens(LshLevels[0]), 1<<uint(LshLevels[0]))
+	_, err = IndexVFloatTokens(nil)
+	require.Error(t, err)
+}
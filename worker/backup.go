@@ -0,0 +1,605 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	"github.com/outcaste-io/outserv/badger"
+	bpb "github.com/outcaste-io/outserv/badger/pb"
+	"github.com/outcaste-io/outserv/ee/enc"
+	"github.com/outcaste-io/outserv/posting"
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/outserv/zero"
+	"github.com/outcaste-io/ristretto/z"
+)
+
+const (
+	// backupFormat and restoreFormat are the formats of the export requests sent to every group,
+	// to run their part of a backup or a restore.
+	backupFormat  = "backup"
+	restoreFormat = "restore"
+
+	backupFull        = "full"
+	backupIncremental = "incremental"
+
+	// backupManifest is the file at the root of the destination which records all the backups
+	// written to it.
+	backupManifest = "manifest.json"
+)
+
+// Manifest records a single backup in the series of backups written to a destination.
+type Manifest struct {
+	// Type is either "full" or "incremental".
+	Type string `json:"type"`
+	// BackupNum is the position of this backup in the series, starting at 1.
+	BackupNum int `json:"backup_num"`
+	// SinceTs is the read timestamp of the previous backup, for incremental backups.
+	SinceTs uint64 `json:"since_ts"`
+	ReadTs  uint64 `json:"read_ts"`
+	// Path is the directory holding the backup files, relative to the destination.
+	Path string `json:"path"`
+	// Groups maps the group ids to their backup files, relative to the destination.
+	Groups    map[uint32]string `json:"groups"`
+	Encrypted bool              `json:"encrypted"`
+	// MaxUid, MaxNsID and MaxTs are past the uids, namespaces and timestamps used by the data in
+	// the backup. A restore moves the leases of the cluster past them, so that new mutations
+	// don't reuse them.
+	MaxUid  uint64 `json:"max_uid"`
+	MaxNsID uint64 `json:"max_nsid"`
+	MaxTs   uint64 `json:"max_ts"`
+}
+
+// MasterManifest is the content of the manifest.json file at the root of the destination.
+type MasterManifest struct {
+	Manifests []*Manifest `json:"manifests"`
+}
+
+// BackupRequest asks for a backup of the whole cluster to be written to Destination.
+type BackupRequest struct {
+	Destination string
+	// These credentials are used to access the S3 or minio bucket.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Anonymous    bool
+	// ForceFull takes a full backup, even if a previous backup exists at the destination.
+	ForceFull bool
+}
+
+// RestoreRequest asks for the data of the cluster to be replaced with the one in the backups at
+// Location.
+type RestoreRequest struct {
+	Location string
+	// These credentials are used to access the S3 or minio bucket.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Anonymous    bool
+	// BackupNum is the backup to restore up to. Zero picks the latest one.
+	BackupNum int
+}
+
+// NewBackupHandler returns the UriHandler for the backups at the given location.
+func NewBackupHandler(location string, creds *x.MinioCredentials) (x.UriHandler, error) {
+	uri, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	return x.NewUriHandler(uri, creds)
+}
+
+func backupHandler(in *pb.ExportRequest) (x.UriHandler, error) {
+	return NewBackupHandler(in.Destination, &x.MinioCredentials{
+		AccessKey:    in.AccessKey,
+		SecretKey:    in.SecretKey,
+		SessionToken: in.SessionToken,
+		Anonymous:    in.Anonymous,
+	})
+}
+
+// ReadMasterManifest reads the manifest at the root of the backup location. An empty manifest is
+// returned if there are no backups yet.
+func ReadMasterManifest(handler x.UriHandler) (*MasterManifest, error) {
+	master := &MasterManifest{}
+	if !handler.FileExists(backupManifest) {
+		return master, nil
+	}
+	b, err := handler.Read(backupManifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading backup manifest")
+	}
+	if err := json.Unmarshal(b, master); err != nil {
+		return nil, errors.Wrap(err, "while parsing backup manifest")
+	}
+	return master, nil
+}
+
+func writeMasterManifest(handler x.UriHandler, master *MasterManifest) error {
+	b, err := json.MarshalIndent(master, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that a failure midway doesn't leave a corrupted
+	// manifest behind.
+	tmp := backupManifest + ".tmp"
+	w, err := handler.CreateFile(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return handler.Rename(tmp, backupManifest)
+}
+
+// BackupChain returns the backups which need to be restored, in order, to get to the backup
+// number backupNum. Zero picks the latest backup. The chain starts with the last full backup at or
+// before backupNum.
+func BackupChain(manifests []*Manifest, backupNum int) ([]*Manifest, error) {
+	if len(manifests) == 0 {
+		return nil, errors.Errorf("No backups found")
+	}
+	if backupNum == 0 {
+		backupNum = len(manifests)
+	}
+	if backupNum < 0 || backupNum > len(manifests) {
+		return nil, errors.Errorf("Invalid backup number: %d. There are %d backups",
+			backupNum, len(manifests))
+	}
+	end := backupNum - 1
+	start := end
+	for start >= 0 && manifests[start].Type != backupFull {
+		start--
+	}
+	if start < 0 {
+		return nil, errors.Errorf("No full backup found before backup %d", backupNum)
+	}
+	chain := manifests[start : end+1]
+	for i := 1; i < len(chain); i++ {
+		if chain[i].SinceTs != chain[i-1].ReadTs {
+			return nil, errors.Errorf("Backup %d at since ts %d doesn't follow backup %d at"+
+				" read ts %d", chain[i].BackupNum, chain[i].SinceTs, chain[i-1].BackupNum,
+				chain[i-1].ReadTs)
+		}
+	}
+	return chain, nil
+}
+
+// BackupOverNetwork takes a backup of all the groups at the current read timestamp, and records it
+// in the manifest at the destination. The backup only holds the changes since the previous backup,
+// unless there is none, or a full backup is asked for.
+func BackupOverNetwork(ctx context.Context, req *BackupRequest) (*Manifest, error) {
+	// If we haven't even had a single membership update, don't run backup.
+	if err := x.HealthCheck(); err != nil {
+		glog.Errorf("Rejecting backup request due to health check error: %v\n", err)
+		return nil, err
+	}
+	creds := &x.MinioCredentials{
+		AccessKey:    req.AccessKey,
+		SecretKey:    req.SecretKey,
+		SessionToken: req.SessionToken,
+		Anonymous:    req.Anonymous,
+	}
+	handler, err := NewBackupHandler(req.Destination, creds)
+	if err != nil {
+		return nil, err
+	}
+	master, err := ReadMasterManifest(handler)
+	if err != nil {
+		return nil, err
+	}
+
+	readTs := posting.ReadTimestamp()
+	// The leases only go up, so the ones read after picking readTs cover all the data at readTs.
+	ms, err := zero.LatestMembershipState(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading the leases")
+	}
+	m := &Manifest{
+		Type:      backupFull,
+		BackupNum: len(master.Manifests) + 1,
+		ReadTs:    readTs,
+		Path:      fmt.Sprintf("backup.r%d", readTs),
+		Groups:    make(map[uint32]string),
+		Encrypted: len(x.WorkerConfig.EncryptionKey) > 0,
+		MaxUid:    ms.MaxUID,
+		MaxNsID:   ms.MaxNsID,
+		MaxTs:     readTs,
+	}
+	if n := len(master.Manifests); n > 0 && !req.ForceFull {
+		last := master.Manifests[n-1]
+		if last.ReadTs >= readTs {
+			return nil, errors.Errorf("A backup at read ts %d already exists", last.ReadTs)
+		}
+		m.Type = backupIncremental
+		m.SinceTs = last.ReadTs
+	}
+	if !handler.DirExists(m.Path) {
+		if err := handler.CreateDir(m.Path); err != nil {
+			return nil, errors.Wrap(err, "while creating backup directory")
+		}
+	}
+	glog.Infof("Running %s backup at readTs %d, sinceTs %d", m.Type, m.ReadTs, m.SinceTs)
+
+	type groupFiles struct {
+		gid   uint32
+		files ExportedFiles
+		err   error
+	}
+	gids := KnownGroups()
+	ch := make(chan groupFiles, len(gids))
+	for _, gid := range gids {
+		go func(group uint32) {
+			files, err := handleExportOverNetwork(ctx, &pb.ExportRequest{
+				GroupId: group,
+				ReadTs:  m.ReadTs,
+				SinceTs: m.SinceTs,
+				UnixTs:  time.Now().Unix(),
+				Format:  backupFormat,
+
+				Destination:  req.Destination,
+				AccessKey:    req.AccessKey,
+				SecretKey:    req.SecretKey,
+				SessionToken: req.SessionToken,
+				Anonymous:    req.Anonymous,
+			})
+			ch <- groupFiles{gid: group, files: files, err: err}
+		}(gid)
+	}
+	for i := range gids {
+		res := <-ch
+		if res.err != nil {
+			return nil, errors.Wrapf(res.err, "Backup failed for group %d at readTs %d",
+				res.gid, m.ReadTs)
+		}
+		if len(res.files) != 1 {
+			return nil, errors.Errorf("Backup of group %d returned %d files",
+				res.gid, len(res.files))
+		}
+		m.Groups[res.gid] = res.files[0]
+		x.ReportProgress(ctx, uint64(i+1), uint64(len(gids)))
+	}
+
+	master.Manifests = append(master.Manifests, m)
+	if err := writeMasterManifest(handler, master); err != nil {
+		return nil, errors.Wrap(err, "while writing backup manifest")
+	}
+	glog.Infof("Backup %d at readTs %d DONE", m.BackupNum, m.ReadTs)
+	return m, nil
+}
+
+// backupGroup writes the data served by this group at in.ReadTs, which changed after in.SinceTs.
+// Posting lists are written out rolled up, so a backup never depends on the deltas held by the
+// previous ones.
+func backupGroup(ctx context.Context, in *pb.ExportRequest) (ExportedFiles, error) {
+	handler, err := backupHandler(in)
+	if err != nil {
+		return nil, err
+	}
+	fileName := filepath.Join(fmt.Sprintf("backup.r%d", in.ReadTs),
+		fmt.Sprintf("g%02d.backup.gz", in.GroupId))
+	writer, err := newExportWriter(handler, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := pstore.NewReadTxn(in.ReadTs)
+	defer txn.Discard()
+
+	stream := pstore.NewStreamAt(in.ReadTs)
+	stream.LogPrefix = fmt.Sprintf("Backup of group %d", in.GroupId)
+	stream.SinceTs = in.SinceTs
+
+	// The stream iterators skip the versions before SinceTs, which are needed to roll up the
+	// posting lists. So, use iterators reading all the versions instead.
+	itrs := make([]*badger.Iterator, stream.NumGo)
+	if in.SinceTs > 0 {
+		iopt := badger.DefaultIteratorOptions
+		iopt.AllVersions = true
+		for i := range itrs {
+			itrs[i] = txn.NewIterator(iopt)
+			defer itrs[i].Close()
+		}
+	}
+
+	stream.ChooseKey = func(item *badger.Item) bool {
+		// Incremental backups need to carry the deletions over.
+		if in.SinceTs == 0 && item.IsDeletedOrExpired() {
+			return false
+		}
+		pk, err := x.Parse(item.Key())
+		if err != nil {
+			return false
+		}
+		// The parts of multi-part lists are read from the main key.
+		if pk.HasStartUid {
+			return false
+		}
+		servesTablet, err := groups().ServesTablet(pk.Attr)
+		return err == nil && servesTablet
+	}
+	stream.KeyToList = func(key []byte, itr *badger.Iterator) (*bpb.KVList, error) {
+		if len(key) > 0 && key[0] == x.ByteSchema {
+			item := itr.Item()
+			kv := &bpb.KV{
+				Key:      itr.Alloc.Copy(key),
+				Version:  in.ReadTs,
+				UserMeta: []byte{item.UserMeta()},
+			}
+			if item.IsDeletedOrExpired() {
+				kv.Meta = []byte{posting.BitDelete}
+			} else {
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return nil, err
+				}
+				kv.Value = val
+			}
+			return &bpb.KVList{Kv: []*bpb.KV{kv}}, nil
+		}
+
+		bitr := itr
+		if itrs[itr.ThreadId] != nil {
+			bitr = itrs[itr.ThreadId]
+			bitr.Seek(key)
+		}
+		l, err := posting.ReadPostingList(key, bitr)
+		if err != nil {
+			return nil, err
+		}
+		kvs, err := l.Rollup(itr.Alloc)
+		for _, kv := range kvs {
+			kv.Version = in.ReadTs
+		}
+		return &bpb.KVList{Kv: kvs}, err
+	}
+	stream.Send = func(buf *z.Buffer) error {
+		return buf.SliceIterate(func(s []byte) error {
+			return writeBackupKV(writer.gw, s)
+		})
+	}
+	if err := stream.Orchestrate(ctx); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	glog.Infof("Backup DONE for group %d at timestamp %d.", in.GroupId, in.ReadTs)
+	return ExportedFiles{fileName}, nil
+}
+
+// writeBackupKV writes a marshalled KV, prefixed by its length.
+func writeBackupKV(w io.Writer, kv []byte) error {
+	var sz [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(sz[:], uint64(len(kv)))
+	if _, err := w.Write(sz[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(kv)
+	return err
+}
+
+// readBackupKVs calls fn for all the KVs written by writeBackupKV to r.
+func readBackupKVs(r *bufio.Reader, fn func(kv *bpb.KV) error) error {
+	var buf []byte
+	for {
+		sz, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if uint64(cap(buf)) < sz {
+			buf = make([]byte, sz)
+		}
+		buf = buf[:sz]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return errors.Wrap(err, "while reading backup")
+		}
+		kv := &bpb.KV{}
+		if err := kv.Unmarshal(buf); err != nil {
+			return err
+		}
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadBackup calls fn for all the KVs in the backup file at path, decrypting it with key.
+func ReadBackup(handler x.UriHandler, path string, key x.Sensitive,
+	fn func(kv *bpb.KV) error) error {
+	rc, err := handler.Stream(path)
+	if err != nil {
+		return errors.Wrapf(err, "while opening backup file %s", path)
+	}
+	defer rc.Close()
+	r, err := enc.GetReader(key, rc)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrapf(err, "while reading backup file %s", path)
+	}
+	defer gz.Close()
+	return readBackupKVs(bufio.NewReaderSize(gz, 1<<20), fn)
+}
+
+// RestoreOverNetwork drops all the data in the cluster, and replaces it with the data from the
+// chain of backups ending at req.BackupNum. Every group restores the data it had when the backup
+// was taken, so the cluster needs to have the same groups as the one that was backed up.
+func RestoreOverNetwork(ctx context.Context, req *RestoreRequest) error {
+	if err := x.HealthCheck(); err != nil {
+		glog.Errorf("Rejecting restore request due to health check error: %v\n", err)
+		return err
+	}
+	handler, err := NewBackupHandler(req.Location, &x.MinioCredentials{
+		AccessKey:    req.AccessKey,
+		SecretKey:    req.SecretKey,
+		SessionToken: req.SessionToken,
+		Anonymous:    req.Anonymous,
+	})
+	if err != nil {
+		return err
+	}
+	master, err := ReadMasterManifest(handler)
+	if err != nil {
+		return err
+	}
+	chain, err := BackupChain(master.Manifests, req.BackupNum)
+	if err != nil {
+		return err
+	}
+	last := chain[len(chain)-1]
+
+	gids := KnownGroups()
+	known := make(map[uint32]struct{})
+	for _, gid := range gids {
+		known[gid] = struct{}{}
+	}
+	for gid := range chain[0].Groups {
+		if _, ok := known[gid]; !ok {
+			return errors.Errorf("Backup has data for group %d, which isn't in the cluster", gid)
+		}
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+
+	glog.Infof("Restoring backups %d to %d from %s", chain[0].BackupNum, last.BackupNum,
+		req.Location)
+	if err := zero.BumpMaxUid(ctx, last.MaxUid); err != nil {
+		return errors.Wrap(err, "while bumping the uid lease before restore")
+	}
+	if err := zero.BumpMaxNsid(ctx, last.MaxNsID); err != nil {
+		return errors.Wrap(err, "while bumping the namespace lease before restore")
+	}
+	if _, err := MutateOverNetwork(ctx, &pb.Mutations{DropOp: pb.Mutations_ALL}); err != nil {
+		return errors.Wrap(err, "while dropping data before restore")
+	}
+
+	errCh := make(chan error, len(gids))
+	for _, gid := range gids {
+		go func(group uint32) {
+			_, err := handleExportOverNetwork(ctx, &pb.ExportRequest{
+				GroupId: group,
+				ReadTs:  last.ReadTs,
+				Format:  restoreFormat,
+
+				Destination:  req.Location,
+				AccessKey:    req.AccessKey,
+				SecretKey:    req.SecretKey,
+				SessionToken: req.SessionToken,
+				Anonymous:    req.Anonymous,
+			})
+			errCh <- errors.Wrapf(err, "Restore failed for group %d", group)
+		}(gid)
+	}
+	for i := range gids {
+		if err := <-errCh; err != nil {
+			return err
+		}
+		x.ReportProgress(ctx, uint64(i+1), uint64(len(gids)))
+	}
+	glog.Infof("Restore of backup %d DONE", last.BackupNum)
+	return nil
+}
+
+// restoreGroup proposes the data of this group from the chain of backups ending at the backup
+// taken at in.ReadTs.
+func restoreGroup(ctx context.Context, in *pb.ExportRequest) error {
+	handler, err := backupHandler(in)
+	if err != nil {
+		return err
+	}
+	master, err := ReadMasterManifest(handler)
+	if err != nil {
+		return err
+	}
+	backupNum := -1
+	for _, m := range master.Manifests {
+		if m.ReadTs == in.ReadTs {
+			backupNum = m.BackupNum
+		}
+	}
+	chain, err := BackupChain(master.Manifests, backupNum)
+	if err != nil {
+		return err
+	}
+	// Every group gets its timestamps past the ones of the restored data, even if it has no
+	// data in the backup, as the reads go to all of them.
+	maxTs := x.Max(chain[len(chain)-1].MaxTs, in.ReadTs)
+	if err := BumpTimestamp(ctx, maxTs); err != nil {
+		return errors.Wrap(err, "while bumping the timestamp before restore")
+	}
+
+	n := groups().Node
+	proposal := &pb.Proposal{}
+	size := 0
+	for _, m := range chain {
+		if m.Encrypted && len(x.WorkerConfig.EncryptionKey) == 0 {
+			return errors.Errorf("Backup %d is encrypted, but no encryption key is set",
+				m.BackupNum)
+		}
+		path, ok := m.Groups[in.GroupId]
+		if !ok {
+			continue
+		}
+		glog.Infof("Restoring %s for group %d", path, in.GroupId)
+		err := ReadBackup(handler, path, x.WorkerConfig.EncryptionKey, func(kv *bpb.KV) error {
+			if kv.Key[0] == x.ByteSchema && len(kv.Meta) == 0 {
+				// Claim the predicate, so that it doesn't get served by another group.
+				pk, err := x.Parse(kv.Key)
+				if err != nil {
+					return err
+				}
+				if servesTablet, err := groups().ServesTablet(pk.Attr); err != nil {
+					return err
+				} else if !servesTablet {
+					return errors.Errorf("Predicate %s is served by another group",
+						x.ParseAttr(pk.Attr))
+				}
+			}
+			proposal.Kv = append(proposal.Kv, kv)
+			size += len(kv.Key) + len(kv.Value)
+			if size >= 32<<20 { // 32 MB
+				if _, err := n.proposeAndWait(ctx, proposal); err != nil {
+					return err
+				}
+				proposal = &pb.Proposal{}
+				size = 0
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(proposal.Kv) > 0 {
+		if _, err := n.proposeAndWait(ctx, proposal); err != nil {
+			return err
+		}
+	}
+	glog.Infof("Restore DONE for group %d at timestamp %d.", in.GroupId, in.ReadTs)
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bpb "github.com/outcaste-io/outserv/badger/pb"
+)
+
+func TestBackupChain(t *testing.T) {
+	manifests := []*Manifest{
+		{Type: backupFull, BackupNum: 1, ReadTs: 10},
+		{Type: backupIncremental, BackupNum: 2, SinceTs: 10, ReadTs: 20},
+		{Type: backupFull, BackupNum: 3, ReadTs: 30},
+		{Type: backupIncremental, BackupNum: 4, SinceTs: 30, ReadTs: 40},
+		{Type: backupIncremental, BackupNum: 5, SinceTs: 40, ReadTs: 50},
+	}
+	backupNums := func(chain []*Manifest) []int {
+		var nums []int
+		for _, m := range chain {
+			nums = append(nums, m.BackupNum)
+		}
+		return nums
+	}
+
+	chain, err := BackupChain(manifests, 0)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4, 5}, backupNums(chain))
+
+	chain, err = BackupChain(manifests, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, backupNums(chain))
+
+	chain, err = BackupChain(manifests, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{3}, backupNums(chain))
+
+	_, err = BackupChain(manifests, 6)
+	require.Error(t, err)
+	_, err = BackupChain(nil, 0)
+	require.Error(t, err)
+	_, err = BackupChain(manifests[1:2], 0)
+	require.Error(t, err)
+
+	// An incremental backup which doesn't follow the previous one breaks the chain.
+	manifests[4].SinceTs = 35
+	_, err = BackupChain(manifests, 5)
+	require.Error(t, err)
+}
+
+func TestBackupKVs(t *testing.T) {
+	kvs := []*bpb.KV{
+		{Key: []byte("a"), Value: []byte("1"), Version: 10},
+		{Key: []byte("b"), Value: bytes.Repeat([]byte("2"), 300), Version: 10},
+		{Key: []byte("c"), Version: 10, Meta: []byte{1}},
+	}
+	var buf bytes.Buffer
+	for _, kv := range kvs {
+		b, err := kv.Marshal()
+		require.NoError(t, err)
+		require.NoError(t, writeBackupKV(&buf, b))
+	}
+
+	var got []*bpb.KV
+	require.NoError(t, readBackupKVs(bufio.NewReader(&buf), func(kv *bpb.KV) error {
+		got = append(got, kv)
+		return nil
+	}))
+	require.Equal(t, kvs, got)
+}
@@ -17,39 +17,488 @@
 package worker
 
 import (
+	"encoding/json"
 	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/raft/raftpb"
 
 	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/ristretto/z"
+)
+
+const (
+	cdcTopic = "outserv-cdc"
+	// cdcMaxBatch is the maximum number of events sent to the sink in one go.
+	cdcMaxBatch = 1000
+	// cdcStateRetry is how long the leader waits for its CDCState proposal to get applied, before
+	// proposing it again.
+	cdcStateRetry = 10 * time.Second
 )
 
+var errCDCBehind = errors.Errorf("Too many CDC events haven't been sent to the sink yet. " +
+	"Please retry the mutation once the sink catches up")
+
+// CDC streams the committed mutations to the configured sink (Kafka or file).
+//
+// Every replica turns the mutations it applies into events, and keeps them in memory until the
+// group agrees that they have been sent. Only the leader sends them. Once the sink acknowledges a
+// batch, the leader proposes a pb.CDCState with the commit ts of the last event sent. When that
+// proposal gets applied, every replica drops the events up to that ts. So, when the leadership
+// changes, the new leader picks up right after the last acknowledged event.
+//
+// The Raft log isn't snapshotted, nor is the Raft checkpoint moved, beyond the commit ts of the
+// oldest event which hasn't been acknowledged yet (see getTs). On a restart, the mutations which
+// were not sent are replayed from the log, which regenerates their events.
+//
+// If the leader crashes after the sink acknowledged a batch, but before its CDCState proposal got
+// committed, the next leader would send that batch again. So, a new leader first asks the sink
+// for the commit ts of the last events it holds, and skips the events up to it (see Sink.Sent).
+//
+// The events are kept in memory, up to max-pending-mb of them. Once they're over that, new
+// mutations are rejected till the sink catches up, instead of dropping any event.
 type CDC struct {
+	sync.Mutex
+	sink   Sink
+	closer *z.Closer
+	notify chan struct{}
+
+	// sentTs is the commit ts up to which the events have been acknowledged by the group.
+	sentTs uint64
+	// lastSent is the commit ts of the last event sent by this node while being the leader. It
+	// is reset once this node loses leadership.
+	lastSent uint64
+	// proposedAt is when this node last proposed a CDCState.
+	proposedAt time.Time
+	// pending holds the events which haven't been acknowledged yet, in commit ts order.
+	pending []*cdcEntry
+	// pendingSize is the size of the events in pending. New mutations are rejected while it's
+	// over maxPending.
+	pendingSize int
+	maxPending  int
+	// sent tells whether the sink already holds an event, as sent by a previous leader. It's
+	// fetched once this node becomes the leader, and reset once it loses leadership.
+	sent func(SinkMessage) bool
+}
+
+type cdcEntry struct {
+	index    uint64
+	commitTs uint64
+	msgs     []SinkMessage
+	size     int
+}
+
+// CDCEvent is the JSON document sent to the sink for every object touched by a mutation.
+type CDCEvent struct {
+	Meta  *CDCEventMeta  `json:"meta"`
+	Type  string         `json:"type"`
+	Event *MutationEvent `json:"event"`
+}
+
+type CDCEventMeta struct {
+	CommitTs uint64 `json:"commit_ts"`
+}
+
+// MutationEvent holds the changes done to one object by a mutation. Fields maps the predicates to
+// their new values for a set operation, or to the removed values for a delete operation. A nil
+// value in a delete operation means that all the values of the predicate were removed.
+type MutationEvent struct {
+	Operation string                 `json:"operation"`
+	Uid       string                 `json:"uid"`
+	Namespace uint64                 `json:"namespace"`
+	TypeName  string                 `json:"type_name,omitempty"`
+	Fields    map[string]interface{} `json:"fields"`
 }
 
 func newCDC() *CDC {
-	return nil
+	if Config.ChangeDataConf == "" {
+		return nil
+	}
+	cdcFlag := z.NewSuperFlag(Config.ChangeDataConf).MergeAndCheckDefault(CDCDefaults)
+	if cdcFlag.GetString("kafka") == "" && cdcFlag.GetPath("file") == "" {
+		return nil
+	}
+	sink, err := GetSink(cdcFlag)
+	x.Check(err)
+	return &CDC{
+		sink:       sink,
+		closer:     z.NewCloser(1),
+		notify:     make(chan struct{}, 1),
+		maxPending: int(cdcFlag.GetInt64("max-pending-mb")) << 20,
+	}
 }
 
-func (cd *CDC) getTs() uint64 {
-	return math.MaxUint64
+// getTs returns the commit ts up to which the Raft log can be discarded, without losing any event
+// which hasn't been acknowledged yet.
+func (cdc *CDC) getTs() uint64 {
+	if cdc == nil {
+		return math.MaxUint64
+	}
+	cdc.Lock()
+	defer cdc.Unlock()
+	if len(cdc.pending) == 0 {
+		return math.MaxUint64
+	}
+	return cdc.pending[0].commitTs - 1
 }
 
-func (cd *CDC) updateTs(ts uint64) {
-	return
+// updateTs marks the events up to ts as acknowledged.
+func (cdc *CDC) updateTs(ts uint64) {
+	if cdc == nil {
+		return
+	}
+	cdc.Lock()
+	defer cdc.Unlock()
+	if ts <= cdc.sentTs {
+		return
+	}
+	cdc.sentTs = ts
+	var i int
+	for i < len(cdc.pending) && cdc.pending[i].commitTs <= ts {
+		cdc.pendingSize -= cdc.pending[i].size
+		i++
+	}
+	cdc.pending = cdc.pending[i:]
 }
 
+// full returns true if the pending events are over max-pending-mb, in which case no new mutation
+// should be proposed.
+func (cdc *CDC) full() bool {
+	if cdc == nil {
+		return false
+	}
+	cdc.Lock()
+	defer cdc.Unlock()
+	return cdc.maxPending > 0 && cdc.pendingSize > cdc.maxPending
+}
+
+// getSeenIndex returns the Raft index up to which the log can be discarded, without losing any
+// event which hasn't been acknowledged yet.
 func (cdc *CDC) getSeenIndex() uint64 {
-	return math.MaxUint64
+	if cdc == nil {
+		return math.MaxUint64
+	}
+	cdc.Lock()
+	defer cdc.Unlock()
+	if len(cdc.pending) == 0 {
+		return math.MaxUint64
+	}
+	return cdc.pending[0].index - 1
 }
 
 func (cdc *CDC) updateCDCState(state *pb.CDCState) {
-	return
+	if cdc == nil || state == nil {
+		return
+	}
+	glog.V(2).Infof("Received CDC state with sent ts: %d", state.SentTs)
+	cdc.updateTs(state.SentTs)
+}
+
+// addMutations generates the events for a mutation proposal which got applied. resolved holds the
+// uids assigned to the new objects of the mutation.
+func (cdc *CDC) addMutations(prop *pb.Proposal, resolved map[string]string) {
+	if cdc == nil || prop.Mutations == nil {
+		return
+	}
+	m := prop.Mutations
+	if m.DropOp != pb.Mutations_NONE || len(m.Schema) > 0 {
+		return
+	}
+
+	cdc.Lock()
+	if prop.CommitTs <= cdc.sentTs {
+		// This mutation is being replayed, and its events were already sent.
+		cdc.Unlock()
+		return
+	}
+	cdc.Unlock()
+
+	edges := make([]*pb.Edge, 0, len(m.Edges))
+	for _, edge := range m.Edges {
+		if isDeletePredicate(edge) {
+			return
+		}
+		edges = append(edges, proto.Clone(edge).(*pb.Edge))
+	}
+	for _, obj := range m.NewObjects {
+		// Objects which already existed are left untouched by the mutation.
+		if resolved[obj.Var] != x.ToHexString(obj.Uid) {
+			continue
+		}
+		for _, edge := range obj.Edges {
+			edges = append(edges, proto.Clone(edge).(*pb.Edge))
+		}
+	}
+	x.ReplaceUidsIn(edges, resolved)
+
+	events, err := buildCDCEvents(edges, prop.CommitTs)
+	if err != nil {
+		glog.Errorf("While generating CDC events for commit ts: %d. Error: %v",
+			prop.CommitTs, err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	entry := &cdcEntry{
+		index:    prop.Index,
+		commitTs: prop.CommitTs,
+		msgs:     events,
+	}
+	for _, msg := range events {
+		entry.size += len(msg.Value)
+	}
+
+	// The mutations proposed before the pending events got full still get their events, so the
+	// pending events can go a bit over max-pending-mb.
+	cdc.Lock()
+	cdc.pending = append(cdc.pending, entry)
+	cdc.pendingSize += entry.size
+	cdc.Unlock()
+
+	select {
+	case cdc.notify <- struct{}{}:
+	default:
+	}
+}
+
+// buildCDCEvents groups the edges by object and operation, in the order in which they first
+// appear, and returns one message for each group.
+func buildCDCEvents(edges []*pb.Edge, commitTs uint64) ([]SinkMessage, error) {
+	type eventKey struct {
+		subject string
+		op      pb.Edge_Op
+	}
+	var order []eventKey
+	events := make(map[eventKey]*MutationEvent)
+
+	for _, edge := range edges {
+		key := eventKey{subject: edge.Subject, op: edge.Op}
+		ev, ok := events[key]
+		if !ok {
+			ev = &MutationEvent{
+				Operation: "set",
+				Uid:       edge.Subject,
+				Namespace: edge.Namespace,
+				Fields:    make(map[string]interface{}),
+			}
+			if edge.Op == pb.Edge_DEL {
+				ev.Operation = "del"
+			}
+			events[key] = ev
+			order = append(order, key)
+		}
+
+		var val interface{}
+		switch {
+		case len(edge.ObjectId) > 0:
+			val = edge.ObjectId
+		case x.IsStarAll(edge.ObjectValue):
+			val = nil
+		default:
+			v, err := types.FromBinary(edge.ObjectValue)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while converting value of %s", edge.Predicate)
+			}
+			val = v
+		}
+
+		attr := x.ParseAttr(edge.Predicate)
+		if attr == "dgraph.type" {
+			if v, ok := val.(types.Val); ok && v.Tid == types.TypeString {
+				ev.TypeName = v.Value.(string)
+			}
+		} else if ev.TypeName == "" {
+			if idx := strings.Index(attr, "."); idx > 0 {
+				ev.TypeName = attr[:idx]
+			}
+		}
+
+		// A predicate which shows up more than once within the same object, is a list.
+		if prev, ok := ev.Fields[attr]; ok && val != nil {
+			if list, ok := prev.([]interface{}); ok {
+				ev.Fields[attr] = append(list, val)
+			} else if prev != nil {
+				ev.Fields[attr] = []interface{}{prev, val}
+			} else {
+				ev.Fields[attr] = val
+			}
+			continue
+		}
+		ev.Fields[attr] = val
+	}
+
+	msgs := make([]SinkMessage, 0, len(order))
+	for _, key := range order {
+		ev := events[key]
+		data, err := json.Marshal(&CDCEvent{
+			Meta:  &CDCEventMeta{CommitTs: commitTs},
+			Type:  "mutation",
+			Event: ev,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "while marshalling event for %s", ev.Uid)
+		}
+		msgs = append(msgs, SinkMessage{
+			Meta:     SinkMeta{Topic: cdcTopic},
+			Key:      x.FromHex(ev.Uid),
+			Value:    data,
+			CommitTs: commitTs,
+		})
+	}
+	return msgs, nil
+}
+
+func (cdc *CDC) Close() {
+	if cdc == nil {
+		return
+	}
+	glog.Infof("Closing CDC events...")
+	cdc.closer.SignalAndWait()
+	if err := cdc.sink.Close(); err != nil {
+		glog.Errorf("While closing CDC sink: %v", err)
+	}
 }
 
-func (cd *CDC) Close() {
-	return
+// initSentTs picks up the last CDC state from the Raft log, so a restarted node doesn't send the
+// events of replayed mutations again. The states which have already been snapshotted, only cover
+// mutations which are part of the snapshot too, and so won't be replayed.
+func (cdc *CDC) initSentTs(n *node) error {
+	first, err := n.Store.FirstIndex()
+	if err != nil {
+		return err
+	}
+	last, err := n.Store.LastIndex()
+	if err != nil {
+		return err
+	}
+	var sentTs uint64
+	for batchFirst := first; batchFirst <= last; {
+		entries, err := n.Store.Entries(batchFirst, last+1, 256<<20)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		batchFirst = entries[len(entries)-1].Index + 1
+
+		for _, entry := range entries {
+			if entry.Type != raftpb.EntryNormal || len(entry.Data) == 0 {
+				continue
+			}
+			if proposal := getProposal(entry); proposal.CdcState != nil {
+				sentTs = x.Max(sentTs, proposal.CdcState.SentTs)
+			}
+		}
+	}
+	cdc.updateTs(sentTs)
+	return nil
+}
+
+// nextBatch returns the next batch of events to send, and the commit ts of the last one. The
+// events which the sink already holds are left out of the batch. cdc must be locked.
+func (cdc *CDC) nextBatch() ([]SinkMessage, uint64) {
+	var msgs []SinkMessage
+	var lastTs uint64
+	from := x.Max(cdc.sentTs, cdc.lastSent)
+	for _, entry := range cdc.pending {
+		if entry.commitTs <= from {
+			continue
+		}
+		if len(msgs) > 0 && len(msgs)+len(entry.msgs) > cdcMaxBatch {
+			break
+		}
+		for _, msg := range entry.msgs {
+			if cdc.sent == nil || !cdc.sent(msg) {
+				msgs = append(msgs, msg)
+			}
+		}
+		lastTs = entry.commitTs
+	}
+	return msgs, lastTs
 }
 
-// todo: test cases old cluster restart, live loader, bulk loader, backup restore etc
-func (cd *CDC) processCDCEvents() {
-	return
+// sendEvents sends the pending events to the sink, if this node is the leader.
+func (cdc *CDC) sendEvents(n *node) error {
+	if !n.AmLeader() {
+		cdc.Lock()
+		cdc.lastSent = 0
+		cdc.sent = nil
+		cdc.Unlock()
+		return nil
+	}
+
+	cdc.Lock()
+	if cdc.sent == nil {
+		cdc.Unlock()
+		sent, err := cdc.sink.Sent()
+		if err != nil {
+			return errors.Wrapf(err, "while reading the last CDC events from the sink")
+		}
+		cdc.Lock()
+		cdc.sent = sent
+	}
+	if cdc.lastSent > cdc.sentTs && time.Since(cdc.proposedAt) > cdcStateRetry {
+		// The last CDCState proposal didn't make it through. Propose it again, so the replicas
+		// can release the events.
+		cdc.proposedAt = time.Now()
+		lastSent := cdc.lastSent
+		cdc.Unlock()
+		return n.proposeCDCState(lastSent)
+	}
+	msgs, lastTs := cdc.nextBatch()
+	cdc.Unlock()
+
+	if lastTs == 0 {
+		return nil
+	}
+	// The events which a previous leader already sent are still acknowledged, even if there's
+	// nothing left to send.
+	if err := cdc.sink.Send(msgs); err != nil {
+		return errors.Wrapf(err, "while sending CDC events")
+	}
+	if err := n.proposeCDCState(lastTs); err != nil {
+		// The events would be sent again, once we retry.
+		return errors.Wrapf(err, "while proposing CDC state")
+	}
+	cdc.Lock()
+	cdc.lastSent = lastTs
+	cdc.proposedAt = time.Now()
+	cdc.Unlock()
+	return nil
+}
+
+func (cdc *CDC) processCDCEvents() {
+	if cdc == nil {
+		return
+	}
+	defer cdc.closer.Done()
+
+	n := groups().Node
+	if err := cdc.initSentTs(n); err != nil {
+		glog.Errorf("While reading CDC state from Raft log: %v", err)
+	}
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-cdc.closer.HasBeenClosed():
+			return
+		case <-tick.C:
+		case <-cdc.notify:
+		}
+		if err := cdc.sendEvents(n); err != nil {
+			glog.Errorf("Unable to send CDC events: %v", err)
+		}
+	}
 }
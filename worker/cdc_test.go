@@ -0,0 +1,142 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/x"
+)
+
+func TestBuildCDCEvents(t *testing.T) {
+	attr := func(a string) string { return x.NamespaceAttr(x.GalaxyNamespace, a) }
+	edges := []*pb.Edge{
+		{Subject: "0x1", Predicate: attr("dgraph.type"),
+			ObjectValue: types.StringToBinary("Author")},
+		{Subject: "0x1", Predicate: attr("Author.name"),
+			ObjectValue: types.StringToBinary("Alice")},
+		{Subject: "0x1", Predicate: attr("Author.posts"), ObjectId: "0x2"},
+		{Subject: "0x1", Predicate: attr("Author.posts"), ObjectId: "0x3"},
+		{Subject: "0x2", Predicate: attr("Post.title"), Op: pb.Edge_DEL,
+			ObjectValue: append([]byte{byte(types.TypeDefault)}, x.Star...)},
+	}
+	msgs, err := buildCDCEvents(edges, 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	require.Equal(t, uint64(1), msgs[0].Key)
+	require.Equal(t, cdcTopic, msgs[0].Meta.Topic)
+	require.JSONEq(t, `{
+		"meta": {"commit_ts": 10},
+		"type": "mutation",
+		"event": {
+			"operation": "set",
+			"uid": "0x1",
+			"namespace": 0,
+			"type_name": "Author",
+			"fields": {
+				"dgraph.type": "Author",
+				"Author.name": "Alice",
+				"Author.posts": ["0x2", "0x3"]
+			}
+		}
+	}`, string(msgs[0].Value))
+
+	var ev CDCEvent
+	require.NoError(t, json.Unmarshal(msgs[1].Value, &ev))
+	require.Equal(t, uint64(2), msgs[1].Key)
+	require.Equal(t, "del", ev.Event.Operation)
+	require.Equal(t, "Post", ev.Event.TypeName)
+	require.Equal(t, map[string]interface{}{"Post.title": nil}, ev.Event.Fields)
+}
+
+func TestCDCPendingEvents(t *testing.T) {
+	cdc := &CDC{notify: make(chan struct{}, 1)}
+	require.Equal(t, uint64(math.MaxUint64), cdc.getTs())
+
+	cdc.pending = []*cdcEntry{
+		{index: 3, commitTs: 6},
+		{index: 4, commitTs: 8},
+		{index: 6, commitTs: 12},
+	}
+	require.Equal(t, uint64(5), cdc.getTs())
+	require.Equal(t, uint64(2), cdc.getSeenIndex())
+
+	cdc.updateCDCState(&pb.CDCState{SentTs: 8})
+	require.Equal(t, uint64(11), cdc.getTs())
+	require.Equal(t, uint64(5), cdc.getSeenIndex())
+
+	// Replayed mutations which were already sent are ignored.
+	cdc.addMutations(&pb.Proposal{Index: 2, CommitTs: 4, Mutations: &pb.Mutations{
+		Edges: []*pb.Edge{{Subject: "0x1", Predicate: x.GalaxyAttr("name"),
+			ObjectValue: types.StringToBinary("Alice")}},
+	}}, nil)
+	require.Len(t, cdc.pending, 1)
+
+	cdc.updateTs(12)
+	require.Equal(t, uint64(math.MaxUint64), cdc.getTs())
+
+	var nilCDC *CDC
+	require.Equal(t, uint64(math.MaxUint64), nilCDC.getTs())
+	require.Equal(t, uint64(math.MaxUint64), nilCDC.getSeenIndex())
+}
+
+func TestCDCFull(t *testing.T) {
+	cdc := &CDC{notify: make(chan struct{}, 1), maxPending: 200}
+	mutation := func(index, commitTs uint64) *pb.Proposal {
+		return &pb.Proposal{Index: index, CommitTs: commitTs, Mutations: &pb.Mutations{
+			Edges: []*pb.Edge{{Subject: "0x1", Predicate: x.GalaxyAttr("name"),
+				ObjectValue: types.StringToBinary("Alice")}},
+		}}
+	}
+
+	cdc.addMutations(mutation(2, 4), nil)
+	require.Len(t, cdc.pending, 1)
+	require.False(t, cdc.full())
+
+	// Once the pending events are over the limit, new mutations are held back, but the events of
+	// the ones already proposed are kept.
+	cdc.addMutations(mutation(3, 6), nil)
+	require.True(t, cdc.full())
+	cdc.addMutations(mutation(4, 8), nil)
+	require.Len(t, cdc.pending, 3)
+	require.Equal(t, uint64(3), cdc.getTs())
+
+	// The events which are acknowledged make room for new ones.
+	cdc.updateTs(6)
+	require.False(t, cdc.full())
+	require.Len(t, cdc.pending, 1)
+
+	var nilCDC *CDC
+	require.False(t, nilCDC.full())
+}
+
+func TestCDCSkipsSentEvents(t *testing.T) {
+	cdc := &CDC{notify: make(chan struct{}, 1)}
+	for i, ts := range []uint64{4, 6, 8} {
+		cdc.addMutations(&pb.Proposal{Index: uint64(i + 2), CommitTs: ts,
+			Mutations: &pb.Mutations{Edges: []*pb.Edge{{Subject: "0x1",
+				Predicate: x.GalaxyAttr("name"), ObjectValue: types.StringToBinary("Alice")}}},
+		}, nil)
+	}
+	require.Len(t, cdc.pending, 3)
+
+	// A previous leader sent the events up to commit ts 6, but they weren't acknowledged.
+	cdc.sent = func(m SinkMessage) bool { return m.CommitTs <= 6 }
+	msgs, lastTs := cdc.nextBatch()
+	require.Len(t, msgs, 1)
+	require.Equal(t, uint64(8), msgs[0].CommitTs)
+	require.Equal(t, uint64(8), lastTs)
+
+	// The events still get acknowledged if the sink holds all of them.
+	cdc.sent = func(SinkMessage) bool { return true }
+	msgs, lastTs = cdc.nextBatch()
+	require.Empty(t, msgs)
+	require.Equal(t, uint64(8), lastTs)
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"sync"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/zero"
+)
+
+// CommitWatcher gets notified when a mutation touching any of the predicates it watches, is
+// committed by this Alpha. GraphQL subscriptions use it to avoid re-running their queries when
+// nothing they read has changed.
+type CommitWatcher struct {
+	preds map[string]struct{}
+	ch    chan struct{}
+}
+
+// C returns the channel which receives a value when a watched predicate gets changed. Multiple
+// commits happening before the value is received, are coalesced into one.
+func (w *CommitWatcher) C() <-chan struct{} {
+	return w.ch
+}
+
+func (w *CommitWatcher) notify() {
+	select {
+	case w.ch <- struct{}{}:
+	default:
+	}
+}
+
+type commitWatchers struct {
+	sync.RWMutex
+	watchers map[*CommitWatcher]struct{}
+}
+
+var watchers = &commitWatchers{watchers: make(map[*CommitWatcher]struct{})}
+
+// commitVersions counts the commits touching each namespaced predicate, and the drops.
+type commitVersions struct {
+	sync.RWMutex
+	preds map[string]uint64
+	drops uint64
+}
+
+var versions = &commitVersions{preds: make(map[string]uint64)}
+
+// CommitVersion returns a number which changes whenever a mutation touching any of the given
+// namespaced predicates, or a drop, is committed by this Alpha. Like for the CommitWatcher, the
+// commits done by the other groups aren't seen. The GraphQL response cache uses it to find out
+// if what it cached is still valid. It must be read before running the query to cache, as the
+// commits are only counted once they're visible to the new reads.
+func CommitVersion(preds []string) uint64 {
+	versions.RLock()
+	defer versions.RUnlock()
+	// The counts only ever go up. So, their sum changes whenever any of them does.
+	version := versions.drops
+	for _, pred := range preds {
+		version += versions.preds[pred]
+	}
+	return version
+}
+
+// WatchCommits returns a CommitWatcher for the given namespaced predicates. The watcher must be
+// released with StopWatchingCommits once it is no longer needed.
+func WatchCommits(preds []string) *CommitWatcher {
+	w := &CommitWatcher{
+		preds: make(map[string]struct{}, len(preds)),
+		ch:    make(chan struct{}, 1),
+	}
+	for _, pred := range preds {
+		w.preds[pred] = struct{}{}
+	}
+	watchers.Lock()
+	watchers.watchers[w] = struct{}{}
+	watchers.Unlock()
+	return w
+}
+
+// StopWatchingCommits releases the watcher w.
+func StopWatchingCommits(w *CommitWatcher) {
+	if w == nil {
+		return
+	}
+	watchers.Lock()
+	delete(watchers.watchers, w)
+	watchers.Unlock()
+}
+
+// ServesAllPredicates returns true if none of the given namespaced predicates is served by another
+// group. Only the mutations applied by this Alpha's group are seen by the commit watchers, so the
+// predicates served elsewhere need to be polled for.
+func ServesAllPredicates(preds []string) bool {
+	gid := groups().groupId()
+	st := zero.MembershipState()
+	for _, pred := range preds {
+		if tablet, ok := st.Tablets[pred]; ok && tablet.GetGroupId() != gid {
+			return false
+		}
+	}
+	return true
+}
+
+// notifyCommit wakes up the watchers of the predicates touched by the committed mutations m, and
+// bumps the commit versions of those predicates.
+func notifyCommit(m *pb.Mutations) {
+	if m == nil || len(m.Schema) > 0 {
+		// Schema changes terminate the subscriptions via the schema epoch.
+		return
+	}
+
+	if m.DropOp != pb.Mutations_NONE {
+		versions.Lock()
+		versions.drops++
+		versions.Unlock()
+
+		watchers.RLock()
+		defer watchers.RUnlock()
+		for w := range watchers.watchers {
+			w.notify()
+		}
+		return
+	}
+
+	touched := make(map[string]struct{})
+	for _, edge := range m.Edges {
+		touched[edge.Predicate] = struct{}{}
+	}
+	for _, obj := range m.NewObjects {
+		for _, edge := range obj.Edges {
+			touched[edge.Predicate] = struct{}{}
+		}
+	}
+	versions.Lock()
+	for pred := range touched {
+		versions.preds[pred]++
+	}
+	versions.Unlock()
+
+	watchers.RLock()
+	defer watchers.RUnlock()
+	for w := range watchers.watchers {
+		for pred := range touched {
+			if _, ok := w.preds[pred]; ok {
+				w.notify()
+				break
+			}
+		}
+	}
+}
@@ -74,7 +74,8 @@ type node struct {
 	canCampaign bool
 	elog        trace.EventLog
 
-	keysWritten *keysWritten
+	keysWritten   *keysWritten
+	readOnlyPreds *readOnlyPreds
 }
 
 type op int
@@ -458,6 +459,14 @@ func (n *node) concMutations(ctx context.Context, m *pb.Mutations, txn *posting.
 	return rerr
 }
 
+// trackDelete runs the deletion of data, keeping track of it in the task log.
+func trackDelete(ctx context.Context, del func() error) error {
+	_, finish := Tasks.Track(ctx, TaskKindDelete, false, nil)
+	err := del()
+	finish(err)
+	return err
+}
+
 // We don't support schema mutations across nodes in a transaction.
 // Wait for all transactions to either abort or complete and all write transactions
 // involving the predicate are aborted until schema mutations are done.
@@ -498,7 +507,7 @@ func (n *node) applyMutations(ctx context.Context, prop *pb.Proposal) (rerr erro
 		posting.Oracle().ResetTxns()
 		schema.State().DeleteAll()
 
-		if err := posting.DeleteAll(); err != nil {
+		if err := trackDelete(ctx, posting.DeleteAll); err != nil {
 			return err
 		}
 
@@ -520,6 +529,11 @@ func (n *node) applyMutations(ctx context.Context, prop *pb.Proposal) (rerr erro
 	if prop.CommitTs == 0 {
 		return errors.New("ReadTs and CommitTs must be provided")
 	}
+	// Reject the writes which didn't make it into the log before the fence of a tablet move, even
+	// if their proposer hadn't seen the tablet as read-only yet.
+	if pred := n.readOnlyPreds.anyOf(mutationPreds(prop.Mutations)); pred != "" {
+		return errors.Wrapf(errReadOnlyTablet, "predicate %q", x.ParseAttr(pred))
+	}
 
 	if len(prop.Mutations.Schema) > 0 {
 		n.keysWritten.rejectBeforeIndex = prop.Index
@@ -550,7 +564,9 @@ func (n *node) applyMutations(ctx context.Context, prop *pb.Proposal) (rerr erro
 		if isDeletePredicate(edge) {
 			span.Annotatef(nil, "Deleting predicate: %s", edge.Predicate)
 			n.keysWritten.rejectBeforeIndex = prop.Index
-			return posting.DeletePredicate(ctx, edge.Predicate, prop.CommitTs)
+			return trackDelete(ctx, func() error {
+				return posting.DeletePredicate(ctx, edge.Predicate, prop.CommitTs)
+			})
 		}
 		// Don't derive schema when doing deletion.
 		if edge.Op == pb.Edge_DEL {
@@ -629,6 +645,7 @@ func (n *node) applyCommitted(proposal *pb.Proposal) error {
 		}
 		if txn := posting.GetTxn(proposal.CommitTs); txn != nil {
 			n.commit(ctx, txn)
+			n.cdcTracker.addMutations(proposal, txn.Uids)
 		}
 
 		span.Annotate(nil, "Done")
@@ -639,8 +656,20 @@ func (n *node) applyCommitted(proposal *pb.Proposal) error {
 	case len(proposal.Kv) > 0:
 		return populateKeyValues(ctx, proposal.Kv)
 
+	case len(proposal.ReadOnlyPredicate) > 0:
+		n.elog.Printf("Fencing predicate: %s", proposal.ReadOnlyPredicate)
+		n.readOnlyPreds.set(proposal.ReadOnlyPredicate, true)
+		return nil
+
+	case len(proposal.WritablePredicate) > 0:
+		n.elog.Printf("Unfencing predicate: %s", proposal.WritablePredicate)
+		n.readOnlyPreds.set(proposal.WritablePredicate, false)
+		return nil
+
 	case len(proposal.CleanPredicate) > 0:
 		n.elog.Printf("Cleaning predicate: %s", proposal.CleanPredicate)
+		// The predicate now belongs to another group. So, drop its fence along with its data.
+		n.readOnlyPreds.set(proposal.CleanPredicate, false)
 		end := time.Now().Add(10 * time.Second)
 		for proposal.ExpectedChecksum > 0 && time.Now().Before(end) {
 			cur := atomic.LoadUint64(&groups().membershipChecksum)
@@ -657,7 +686,9 @@ func (n *node) applyCommitted(proposal *pb.Proposal) error {
 				proposal.CleanPredicate, proposal.ExpectedChecksum)
 			return nil
 		}
-		return posting.DeletePredicate(ctx, proposal.CleanPredicate, proposal.CommitTs)
+		return trackDelete(ctx, func() error {
+			return posting.DeletePredicate(ctx, proposal.CleanPredicate, proposal.CommitTs)
+		})
 
 	case proposal.Snapshot != nil:
 		existing, err := n.Store.Snapshot()
@@ -691,9 +722,24 @@ func (n *node) applyCommitted(proposal *pb.Proposal) error {
 		return nil
 
 	case proposal.DeleteNs != nil:
-		x.AssertTrue(proposal.DeleteNs.Namespace != x.GalaxyNamespace)
-		n.elog.Printf("Deleting namespace: %d", proposal.DeleteNs.Namespace)
-		return posting.DeleteNamespace(proposal.DeleteNs.Namespace)
+		ns := proposal.DeleteNs.Namespace
+		x.AssertTrue(ns != x.GalaxyNamespace)
+		n.elog.Printf("Deleting namespace: %d", ns)
+
+		// Ensures nothing get written to disk due to commit proposals.
+		n.keysWritten.rejectBeforeIndex = proposal.Index
+
+		// Stop rollups, otherwise we might end up writing back some of the dropped data.
+		n.stopTask(opRollup)
+		defer n.startTask(opRollup)
+
+		posting.Oracle().ResetTxnsForNs(ns)
+		err := trackDelete(ctx, func() error { return posting.DeleteNamespace(ns) })
+		if err != nil {
+			return err
+		}
+		namespaceDeleted(ns)
+		return nil
 
 	case proposal.CdcState != nil:
 		n.cdcTracker.updateCDCState(proposal.CdcState)
@@ -789,6 +835,10 @@ func (n *node) processApplyCh() {
 
 		n.Applied.Done(prop.Index)
 		posting.DoneTimestamp(prop.CommitTs)
+		if perr == nil {
+			// Notify only once the commit is visible to the new reads.
+			notifyCommit(prop.Mutations)
+		}
 		ostats.Record(context.Background(), x.RaftAppliedIndex.M(int64(n.Applied.DoneUntil())))
 	}
 
@@ -1068,7 +1118,9 @@ func (n *node) updateRaftProgress() error {
 	// stored applied.
 	applied := n.Store.Uint(raftwal.CheckpointIndex)
 
-	snap, err := n.calculateSnapshot(applied, n.Applied.DoneUntil(), math.MaxUint64)
+	// Don't move the checkpoint beyond the CDC events which haven't been sent yet, so they get
+	// regenerated on a restart.
+	snap, err := n.calculateSnapshot(applied, n.Applied.DoneUntil(), n.cdcTracker.getTs())
 	if err != nil || snap == nil || snap.Index <= applied {
 		return err
 	}
@@ -1117,6 +1169,32 @@ func (n *node) proposeBaseTimestamp() {
 	}
 }
 
+// nextBaseTimestamp returns the base timestamp to use after the proposal of base timestamp
+// proposed, with prev being the current one. The base timestamp never goes back, even if the
+// clock of the leader does, or if it was bumped past the timestamps of restored data. As the
+// commit timestamps only use the lower 32 bits on top of the base timestamp, moving it up by at
+// least 1<<32 keeps them increasing.
+func nextBaseTimestamp(prev, proposed uint64) uint64 {
+	if prev == 0 {
+		return proposed
+	}
+	return x.Max(proposed, prev+1<<32)
+}
+
+// BumpTimestamp moves the base timestamp of this group past ts, so that the commits of the
+// group come after the data restored at ts.
+func BumpTimestamp(ctx context.Context, ts uint64) error {
+	prop := &pb.Proposal{BaseTimestamp: (ts>>32 + 1) << 32}
+	for {
+		_, err := groups().Node.proposeAndWait(ctx, prop)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		glog.Warningf("While bumping the timestamp past %#x: %v. Retrying...", ts, err)
+		time.Sleep(time.Second)
+	}
+}
+
 var lastSnapshotTime int64 = time.Now().Unix()
 
 func (n *node) checkpointAndClose(done chan struct{}) {
@@ -1481,19 +1559,19 @@ func (n *node) Run() {
 				for _, e := range entries {
 					p := getProposal(e)
 					if p.BaseTimestamp > 0 {
+						baseTimestamp = nextBaseTimestamp(baseTimestamp, p.BaseTimestamp)
 						glog.V(1).Infof("Setting base timestamp to: %#x at index: %d\n",
-							p.BaseTimestamp, e.Index)
-						baseTimestamp = p.BaseTimestamp
+							baseTimestamp, e.Index)
 
-						// We can safely register p.BaseTimestamp with the
+						// We can safely register baseTimestamp with the
 						// Oracle. Because all future timestamps would be higher
 						// than this. We do this registeration and done to
 						// ensure that the Oracle clock advances even if there
 						// are no mutations. This is important to allow this
 						// alpha to be able to get fresh data from alphas in
 						// other groups.
-						posting.RegisterTimestamp(p.BaseTimestamp)
-						posting.DoneTimestamp(p.BaseTimestamp)
+						posting.RegisterTimestamp(baseTimestamp)
+						posting.DoneTimestamp(baseTimestamp)
 
 						n.Proposals.Done(p.Key, propResult(nil))
 						n.Applied.Done(p.Index)
@@ -1742,7 +1820,7 @@ func (n *node) calculateSnapshot(startIdx, lastIdx, lastCommitTs uint64) (*pb.Sn
 			}
 			proposal := getProposal(entry)
 			if proposal.BaseTimestamp > 0 {
-				baseTs = proposal.BaseTimestamp
+				baseTs = nextBaseTimestamp(baseTs, proposal.BaseTimestamp)
 				continue
 			}
 		}
@@ -114,3 +114,16 @@ func TestCalculateSnapshot(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, snap)
 }
+
+func TestNextBaseTimestamp(t *testing.T) {
+	now := uint64(1650000000) << 32
+	require.Equal(t, now, nextBaseTimestamp(0, now))
+	require.Equal(t, now+60<<32, nextBaseTimestamp(now, now+60<<32))
+
+	// A timestamp bumped past restored data isn't undone by the clock of the leader.
+	bumped := now + 3600<<32
+	require.Equal(t, bumped, nextBaseTimestamp(now, bumped))
+	next := nextBaseTimestamp(bumped, now+60<<32)
+	require.Equal(t, bumped+1<<32, next)
+	require.Greater(t, next, x.Timestamp(bumped, 1<<31-1))
+}
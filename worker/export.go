@@ -223,12 +223,18 @@ func export(ctx context.Context, in *pb.ExportRequest) (ExportedFiles, error) {
 		return nil, errors.Errorf("Export request group mismatch. Mine: %d. Requested: %d",
 			groups().groupId(), in.GroupId)
 	}
+	if in.Format == restoreFormat {
+		return nil, restoreGroup(ctx, in)
+	}
 	glog.Infof("Export requested at %d for namespace %d.", in.ReadTs, in.Namespace)
 
 	// Let's wait for this server to catch up to all the updates until this ts.
 	if err := posting.Oracle().WaitForTs(ctx, in.ReadTs); err != nil {
 		return nil, err
 	}
+	if in.Format == backupFormat {
+		return backupGroup(ctx, in)
+	}
 	glog.Infof("Running export for group %d at timestamp %d.", in.GroupId, in.ReadTs)
 
 	return exportInternal(ctx, in, pstore, false)
@@ -249,6 +255,7 @@ func ToExportKvList(pk x.ParsedKey, pl *posting.List, in *pb.ExportRequest) (*bp
 	case e.attr == "dgraph.graphql.xid":
 	case e.attr == "dgraph.drop.op":
 	case e.attr == "dgraph.graphql.p_query":
+	case e.attr == "dgraph.graphql.p_allowed":
 
 	case pk.IsData() && e.attr == "dgraph.graphql.schema":
 		// Export the graphql schema.
@@ -654,11 +661,12 @@ func handleExportOverNetwork(ctx context.Context, in *pb.ExportRequest) (Exporte
 
 	glog.Infof("Sending export request to group: %d, addr: %s\n", in.GroupId, pl.Addr)
 	c := pb.NewWorkerClient(pl.Get())
-	_, err := c.Export(ctx, in)
+	resp, err := c.Export(ctx, in)
 	if err != nil {
 		glog.Errorf("Export error received from group: %d. Error: %v\n", in.GroupId, err)
+		return nil, err
 	}
-	return nil, err
+	return resp.GetFiles(), nil
 }
 
 // ExportOverNetwork sends export requests to all the known groups.
@@ -710,6 +718,7 @@ func ExportOverNetwork(ctx context.Context, input *pb.ExportRequest) (ExportedFi
 			return nil, rerr
 		}
 		allFiles = append(allFiles, pair.ExportedFiles...)
+		x.ReportProgress(ctx, uint64(i+1), uint64(len(gids)))
 	}
 
 	glog.Infof("Export at readTs %d DONE", readTs)
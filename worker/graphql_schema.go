@@ -66,6 +66,12 @@ func (gs *GQLSchemaStore) Set(ns uint64, sch *GqlSchema) {
 	gs.schema[ns] = sch
 }
 
+func (gs *GQLSchemaStore) Delete(ns uint64) {
+	gs.mux.Lock()
+	defer gs.mux.Unlock()
+	delete(gs.schema, ns)
+}
+
 func (gs *GQLSchemaStore) GetCurrent(ns uint64) (*GqlSchema, bool) {
 	gs.mux.RLock()
 	defer gs.mux.RUnlock()
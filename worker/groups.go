@@ -37,6 +37,7 @@ type groupi struct {
 	// the membership information that the Alpha has. If so, Alpha cannot service a read.
 	deltaChecksum      uint64 // Checksum received by OracleDelta.
 	membershipChecksum uint64 // Checksum received by MembershipState.
+	liftingFences      int32  // Set while proposing to lift the fences of tablet moves.
 }
 
 var gr = &groupi{
@@ -68,14 +69,15 @@ func newNode(store *raftwal.DiskStorage, gid uint32, id uint64, myAddr string) *
 		// We need a generous size for applyCh, because raft.Tick happens every
 		// 10ms. If we restrict the size here, then Raft goes into a loop trying
 		// to maintain quorum health.
-		applyCh:      make(chan []*pb.Proposal, 1000),
-		concApplyCh:  make(chan *pb.Proposal, 100),
-		drainApplyCh: make(chan struct{}),
-		elog:         trace.NewEventLog("Dgraph", "ApplyCh"),
-		closer:       z.NewCloser(4), // Matches CLOSER:1
-		ops:          make(map[op]operation),
-		cdcTracker:   newCDC(),
-		keysWritten:  newKeysWritten(),
+		applyCh:       make(chan []*pb.Proposal, 1000),
+		concApplyCh:   make(chan *pb.Proposal, 100),
+		drainApplyCh:  make(chan struct{}),
+		elog:          trace.NewEventLog("Dgraph", "ApplyCh"),
+		closer:        z.NewCloser(4), // Matches CLOSER:1
+		ops:           make(map[op]operation),
+		cdcTracker:    newCDC(),
+		keysWritten:   newKeysWritten(),
+		readOnlyPreds: newReadOnlyPreds(),
 	}
 	return n
 }
@@ -251,6 +253,8 @@ func (g *groupi) applyState(state *pb.MembershipState) {
 			myId, g.groupId(), state)
 	}
 
+	atomic.StoreUint64(&g.membershipChecksum, zero.GroupChecksum(state, g.groupId()))
+
 	// Sometimes this can cause us to lose latest tablet info, but that shouldn't cause any issues.
 	for _, member := range state.Members {
 		conn.GetPools().Connect(member.Addr, x.WorkerConfig.TLSClientConfig)
@@ -557,9 +561,12 @@ func (g *groupi) doSendMembership(tablets map[string]*pb.Tablet) error {
 	var changes []*pb.Tablet
 	for key, dstTablet := range tablets {
 		srcTablet, has := src.Tablets[key]
-		if !has {
+		if !has || srcTablet.GroupId != dstTablet.GroupId {
+			// The tablet might have been moved to another group, and not deleted from here yet.
 			continue
 		}
+		// Only update the sizes. Zero sets whether the tablet is being moved.
+		dstTablet.ReadOnly, dstTablet.MoveTs = srcTablet.ReadOnly, srcTablet.MoveTs
 		s := float64(srcTablet.OnDiskBytes)
 		d := float64(dstTablet.OnDiskBytes)
 		if dstTablet.Remove || (s == 0 && d > 0) || (s > 0 && math.Abs(d/s-1) > 0.1) {
@@ -647,12 +654,46 @@ func (g *groupi) receiveMembershipUpdates() {
 				g.applyState(st)
 				lastIndex = st.RaftIndex
 			}
+			g.liftStaleFences(st)
 		case <-g.closer.HasBeenClosed():
 			return
 		}
 	}
 }
 
+// liftStaleFences proposes to make the predicates fenced by a tablet move writable again, once
+// the move isn't going on anymore. That is, once Zero dropped their tablets, or gave them back to
+// this group as writable. A move which went through drops the fence along with the predicate.
+func (g *groupi) liftStaleFences(st *pb.MembershipState) {
+	if g.Node == nil || !g.Node.AmLeader() {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&g.liftingFences, 0, 1) {
+		return
+	}
+	var preds []string
+	for _, pred := range g.Node.readOnlyPreds.list() {
+		tablet := st.Tablets[pred]
+		if tablet == nil || (tablet.GroupId == g.groupId() && !tablet.ReadOnly) {
+			preds = append(preds, pred)
+		}
+	}
+	if len(preds) == 0 {
+		atomic.StoreInt32(&g.liftingFences, 0)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&g.liftingFences, 0)
+		for _, pred := range preds {
+			glog.Infof("Proposing to make predicate %q writable again", pred)
+			p := &pb.Proposal{WritablePredicate: pred}
+			if _, err := g.Node.proposeAndWait(g.Ctx(), p); err != nil {
+				glog.Errorf("Error while making predicate %q writable: %v", pred, err)
+			}
+		}
+	}()
+}
+
 // SubscribeForUpdates will listen for updates for the given group.
 func SubscribeForUpdates(prefixes [][]byte, ignore string, cb func(kvs *badgerpb.KVList),
 	group uint32, closer *z.Closer) {
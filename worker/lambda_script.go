@@ -48,6 +48,12 @@ func (ls *LambdaScriptStore) Set(ns uint64, scr *LambdaScript) {
 	ls.script[ns] = scr
 }
 
+func (ls *LambdaScriptStore) Delete(ns uint64) {
+	ls.Lock()
+	defer ls.Unlock()
+	delete(ls.script, ns)
+}
+
 func (ls *LambdaScriptStore) GetCurrent(ns uint64) (*LambdaScript, bool) {
 	ls.RLock()
 	defer ls.RUnlock()
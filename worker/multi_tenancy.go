@@ -18,20 +18,106 @@ package worker
 
 import (
 	"context"
+	"sync"
 
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	"github.com/outcaste-io/outserv/conn"
 	"github.com/outcaste-io/outserv/protos/pb"
 	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/outserv/zero"
 )
 
+var nsDeleted struct {
+	sync.Mutex
+	fns []func(ns uint64)
+}
+
+// OnNamespaceDeleted registers fn to be called on every Alpha, once it applied the deletion of a
+// namespace. The packages which keep state per namespace in memory use it to drop the state of
+// the deleted namespace, on the Alphas which didn't serve the request to delete it too.
+func OnNamespaceDeleted(fn func(ns uint64)) {
+	nsDeleted.Lock()
+	defer nsDeleted.Unlock()
+	nsDeleted.fns = append(nsDeleted.fns, fn)
+}
+
+// namespaceDeleted calls the functions registered via OnNamespaceDeleted. They run outside of
+// the apply loop, as they may wait on locks held across proposals, like the GraphQL schema lock.
+func namespaceDeleted(ns uint64) {
+	nsDeleted.Lock()
+	fns := nsDeleted.fns
+	nsDeleted.Unlock()
+	go func() {
+		for _, fn := range fns {
+			fn(ns)
+		}
+	}()
+}
+
+// DeleteNamespace proposes the deletion of the namespace to the group served by this Alpha.
 func (w *grpcWorker) DeleteNamespace(ctx context.Context,
 	req *pb.DeleteNsRequest) (*pb.Status, error) {
-	return nil, x.ErrNotSupported
+	var emptyRes pb.Status
+	if !groups().ServesGroup(req.GroupId) {
+		return &emptyRes, errors.Errorf("The server doesn't serve group id: %v", req.GroupId)
+	}
+	if _, err := groups().Node.proposeAndWait(ctx, &pb.Proposal{DeleteNs: req}); err != nil {
+		return &emptyRes, errors.Wrapf(err, "Delete namespace failed for namespace %d on group %d",
+			req.Namespace, req.GroupId)
+	}
+	return &emptyRes, nil
 }
 
+// ProcessDeleteNsRequest drops the data of the namespace from all the groups, and then asks Zero
+// to forget about the tablets of the namespace.
 func ProcessDeleteNsRequest(ctx context.Context, ns uint64) error {
-	return x.ErrNotSupported
+	if ns == x.GalaxyNamespace {
+		return errors.New("The galaxy namespace can't be deleted")
+	}
+	if err := x.HealthCheck(); err != nil {
+		return errors.Wrapf(err, "while deleting namespace %#x", ns)
+	}
+
+	gids := KnownGroups()
+	glog.Infof("Deleting namespace %#x from groups: %v\n", ns, gids)
+	errCh := make(chan error, len(gids))
+	for _, gid := range gids {
+		req := &pb.DeleteNsRequest{Namespace: ns, GroupId: gid}
+		go func() {
+			errCh <- proposeDeleteOrSend(ctx, req)
+		}()
+	}
+	var rerr error
+	for range gids {
+		if err := <-errCh; err != nil && rerr == nil {
+			rerr = err
+		}
+	}
+	if rerr != nil {
+		return rerr
+	}
+
+	_, err := zero.ProposeAndWait(ctx, &pb.ZeroProposal{
+		DeleteNs: &pb.DeleteNsRequest{Namespace: ns},
+	})
+	return errors.Wrapf(err, "while removing the tablets of namespace %#x", ns)
 }
 
+// proposeDeleteOrSend either proposes the deletion of the namespace if this Alpha serves the
+// group, or sends the request to the leader of the group.
 func proposeDeleteOrSend(ctx context.Context, req *pb.DeleteNsRequest) error {
-	return nil
+	if groups().ServesGroup(req.GetGroupId()) {
+		_, err := groups().Node.proposeAndWait(ctx, &pb.Proposal{DeleteNs: req})
+		return err
+	}
+
+	pl := groups().Leader(req.GetGroupId())
+	if pl == nil {
+		return conn.ErrNoConnection
+	}
+	c := pb.NewWorkerClient(pl.Get())
+	_, err := c.DeleteNamespace(ctx, req)
+	return err
 }
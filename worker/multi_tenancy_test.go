@@ -0,0 +1,22 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnNamespaceDeleted(t *testing.T) {
+	prev := nsDeleted.fns
+	t.Cleanup(func() { nsDeleted.fns = prev })
+	nsDeleted.fns = nil
+
+	deleted := make(chan uint64, 2)
+	OnNamespaceDeleted(func(ns uint64) { deleted <- ns })
+	OnNamespaceDeleted(func(ns uint64) { deleted <- ns + 100 })
+	namespaceDeleted(7)
+	require.Equal(t, uint64(7), <-deleted)
+	require.Equal(t, uint64(107), <-deleted)
+}
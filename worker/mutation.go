@@ -31,6 +31,7 @@ var (
 	ErrNonExistentTabletMessage = "Requested predicate is not being served by any tablet"
 	errNonExistentTablet        = errors.Errorf(ErrNonExistentTabletMessage)
 	errUnservedTablet           = errors.Errorf("Tablet isn't being served by this instance")
+	errReadOnlyTablet           = errors.Errorf("Tablet is being moved to another group")
 )
 
 // Default limit on number of simultaneous open files on unix systems
@@ -147,14 +148,19 @@ func runSchemaMutation(ctx context.Context, updates []*pb.SchemaUpdate, startTs
 		}
 	}
 
-	buildIndexesHelper := func(update *pb.SchemaUpdate, rebuild posting.IndexRebuild) error {
-		wrtCtx := schema.GetWriteContext(context.Background())
+	buildIndexesHelper := func(ctx context.Context, update *pb.SchemaUpdate,
+		rebuild posting.IndexRebuild) error {
+		wrtCtx := schema.GetWriteContext(ctx)
 		if err := rebuild.BuildIndexes(wrtCtx); err != nil {
 			return err
 		}
+		// Switch the new indexes in. The old ones aren't served anymore, and can be dropped.
 		if err := updateSchema(update, rebuild.StartTs); err != nil {
 			return err
 		}
+		if err := rebuild.DropOldIndexes(wrtCtx); err != nil {
+			glog.Errorf("While dropping old indexes of %s: %v", update.Predicate, err)
+		}
 
 		glog.Infof("Done schema update %+v\n", update)
 		return nil
@@ -189,11 +195,18 @@ func runSchemaMutation(ctx context.Context, updates []*pb.SchemaUpdate, startTs
 		wg.Wait()
 
 		x.Check(throttle.Do())
+		// Index rebuilds can't be cancelled. Every replica applies the schema update on its own,
+		// so cancelling the rebuild on one of them would leave it with other indexes than the
+		// rest of the group.
+		req := &IndexRebuildRequest{Update: update, StartTs: rebuild.StartTs}
+		ctx, finish := Tasks.Track(context.Background(), TaskKindIndexRebuild, false, req)
 		// undo schema changes in case re-indexing fails.
-		if err := buildIndexesHelper(update, rebuild); err != nil {
+		err := buildIndexesHelper(ctx, update, rebuild)
+		if err != nil {
 			glog.Errorf("error in building indexes, aborting :: %v\n", err)
 			undoSchemaUpdate(update.Predicate)
 		}
+		finish(err)
 		throttle.Done(nil)
 	}
 
@@ -231,7 +244,7 @@ func runSchemaMutation(ctx context.Context, updates []*pb.SchemaUpdate, startTs
 		// Sets the schema only in memory. The schema is written to
 		// disk only after schema mutations are successful.
 		schema.State().Set(su.Predicate, querySchema)
-		schema.State().SetMutSchema(su.Predicate, su)
+		schema.State().SetMutSchema(su.Predicate, rebuild.GetMutSchema())
 
 		// TODO(Aman): If we return an error, we may not have right schema reflected.
 		setup := func() error {
@@ -259,6 +272,39 @@ func runSchemaMutation(ctx context.Context, updates []*pb.SchemaUpdate, startTs
 	return nil
 }
 
+// IndexRebuildRequest is the request of an index rebuild task, kept so that the task can be
+// resumed after a restart.
+type IndexRebuildRequest struct {
+	Update  *pb.SchemaUpdate
+	StartTs uint64
+}
+
+// resumeIndexRebuild resumes an index rebuild interrupted by a restart. The schema update is
+// still in the Raft log, as no snapshot can be taken while indexing, so replaying the log restarts
+// the rebuild, which picks up the task. This waits for the replay and the rebuild to be done.
+func resumeIndexRebuild(ctx context.Context, req *IndexRebuildRequest) error {
+	hs, err := gr.Node.Store.HardState()
+	if err != nil {
+		return err
+	}
+	if err := gr.Node.Applied.WaitForMark(ctx, hs.Commit); err != nil {
+		return err
+	}
+	gr.Node.waitForTask(opIndexing)
+
+	current, _ := schema.State().Get(ctx, req.Update.Predicate)
+	rebuild := posting.IndexRebuild{
+		Attr:          req.Update.Predicate,
+		OldSchema:     &current,
+		CurrentSchema: req.Update,
+	}
+	if rebuild.NeedIndexRebuild() {
+		return errors.Errorf("the indexes of %s weren't rebuilt by replaying the schema update",
+			req.Update.Predicate)
+	}
+	return nil
+}
+
 // updateSchema commits the schema to disk in blocking way, should be ok because this happens
 // only during schema mutations or we see a new predicate.
 func updateSchema(s *pb.SchemaUpdate, ts uint64) error {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/golang/glog"
@@ -50,11 +51,24 @@ func populateKeyValues(ctx context.Context, kvs []*bpb.KV) error {
 	if err := writer.Flush(); err != nil {
 		return err
 	}
-	pk, err := x.Parse(kvs[0].Key)
-	if err != nil {
-		return errors.Errorf("while parsing KV: %+v, got error: %v", kvs[0], err)
+	// Predicate moves send a single predicate, but restores send many of them. Reload the
+	// schema for all of them.
+	attrs := make(map[string]struct{})
+	for _, kv := range kvs {
+		pk, err := x.Parse(kv.Key)
+		if err != nil {
+			return errors.Errorf("while parsing KV: %+v, got error: %v", kv, err)
+		}
+		attrs[pk.Attr] = struct{}{}
 	}
-	return schema.Load(pk.Attr)
+	for attr := range attrs {
+		if err := schema.Load(attr); err != nil {
+			return err
+		}
+	}
+	// The lists might have been read, and cached, before these keys got written.
+	posting.ResetCache()
+	return nil
 }
 
 func batchAndProposeKeyValues(ctx context.Context, kvs chan *pb.KVS) error {
@@ -189,8 +203,6 @@ func (w *grpcWorker) ReceivePredicate(stream pb.Worker_ReceivePredicateServer) e
 
 func (w *grpcWorker) MovePredicate(ctx context.Context,
 	in *pb.MovePredicatePayload) (*pb.Payload, error) {
-	return nil, fmt.Errorf("TODO: Support MovePredicate")
-
 	ctx, span := otrace.StartSpan(ctx, "worker.MovePredicate")
 	defer span.End()
 
@@ -228,6 +240,25 @@ func (w *grpcWorker) MovePredicate(ctx context.Context,
 		_, err := groups().Node.proposeAndWait(ctx, p)
 		return &emptyPayload, err
 	}
+	if in.SinceTs > 0 {
+		// Zero made the tablet read-only before asking for what got written since the first
+		// phase. Wait for this group to see that, so no more writes get proposed for it. Then
+		// fence the tablet off in the Raft log, so the writes which got proposed before that, but
+		// come after the fence, get rejected by every member. Once the fence is applied, so is
+		// every write before it. Only then pick ReadTs, so none of them is left behind.
+		if err := waitForReadOnly(ctx, in.Predicate); err != nil {
+			return &emptyPayload, err
+		}
+		p := &pb.Proposal{ReadOnlyPredicate: in.Predicate}
+		if _, err := n.proposeAndWait(ctx, p); err != nil {
+			return &emptyPayload, errors.Wrapf(err, "while fencing predicate %q", in.Predicate)
+		}
+	}
+	if in.ReadTs == 0 {
+		// Zero doesn't hand out timestamps. Send the predicate as of the latest one of this
+		// group, and let Zero know which one that was.
+		in.ReadTs = posting.ReadTimestamp()
+	}
 	if err := posting.Oracle().WaitForTs(ctx, in.ReadTs); err != nil {
 		return &emptyPayload,
 			errors.Errorf("While waiting for read ts: %d. Error: %v", in.ReadTs, err)
@@ -247,11 +278,36 @@ func (w *grpcWorker) MovePredicate(ctx context.Context,
 	glog.Info(msg)
 	span.Annotate(nil, msg)
 
+	// If the move gets cancelled, the tablet stays with this group.
+	ctx, finish := Tasks.Track(ctx, TaskKindMovePredicate, true, nil)
 	err = movePredicateHelper(ctx, in)
+	finish(err)
 	if err != nil {
 		span.Annotatef(nil, "Error while movePredicateHelper: %v", err)
+		return &emptyPayload, err
+	}
+	return &pb.Payload{Data: []byte(strconv.FormatUint(in.ReadTs, 10))}, nil
+}
+
+// waitForReadOnly blocks until the membership state seen by this Alpha has the tablet of the
+// predicate read-only.
+func waitForReadOnly(ctx context.Context, pred string) error {
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for {
+		tablet, err := groups().Tablet(pred)
+		if err != nil {
+			return err
+		}
+		if tablet.GetReadOnly() {
+			return nil
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return errors.Errorf("Tablet %q isn't read-only yet: %v", pred, ctx.Err())
+		}
 	}
-	return &emptyPayload, err
 }
 
 func movePredicateHelper(ctx context.Context, in *pb.MovePredicatePayload) error {
@@ -354,10 +410,18 @@ func movePredicateHelper(ctx context.Context, in *pb.MovePredicatePayload) error
 		}
 		return &bpb.KVList{Kv: kvs}, err
 	}
+	// The progress is reported as the bytes sent, out of the size of the tablet. The size of what
+	// got written since the first phase isn't known.
+	var sent, total uint64
+	if tablet, err := groups().Tablet(in.Predicate); err == nil && in.SinceTs == 0 {
+		total = uint64(tablet.GetUncompressedBytes())
+	}
 	stream.Send = func(buf *z.Buffer) error {
 		kvs := &pb.KVS{
 			Data: buf.Bytes(),
 		}
+		sent += uint64(buf.LenNoPadding())
+		x.ReportProgress(ctx, sent, total)
 		return out.Send(kvs)
 	}
 	span.Annotatef(nil, "Starting stream list orchestrate")
@@ -118,6 +118,70 @@ func uniqueKey() uint64 {
 var errInternalRetry = errors.New("Retry Raft proposal internally")
 var errUnableToServe = errors.New("Server overloaded with pending proposals. Please retry later")
 
+// readOnlyPreds tracks the predicates fenced off by a ReadOnlyPredicate proposal, while their
+// tablets are being moved to another group. The fence is set and lifted by entries in the Raft
+// log, so every member of the group rejects the same mutations when applying them, no matter which
+// one of them proposed those, or how long they were in flight.
+type readOnlyPreds struct {
+	sync.Mutex
+	preds map[string]struct{}
+}
+
+func newReadOnlyPreds() *readOnlyPreds {
+	return &readOnlyPreds{preds: make(map[string]struct{})}
+}
+
+func (r *readOnlyPreds) set(pred string, readOnly bool) {
+	r.Lock()
+	defer r.Unlock()
+	if readOnly {
+		r.preds[pred] = struct{}{}
+	} else {
+		delete(r.preds, pred)
+	}
+}
+
+// anyOf returns the first one of the predicates which is read-only, or an empty string.
+func (r *readOnlyPreds) anyOf(preds []string) string {
+	r.Lock()
+	defer r.Unlock()
+	for _, pred := range preds {
+		if _, ok := r.preds[pred]; ok {
+			return pred
+		}
+	}
+	return ""
+}
+
+func (r *readOnlyPreds) list() []string {
+	r.Lock()
+	defer r.Unlock()
+	preds := make([]string, 0, len(r.preds))
+	for pred := range r.preds {
+		preds = append(preds, pred)
+	}
+	return preds
+}
+
+// mutationPreds returns the predicates the mutations write to, or change the schema of.
+func mutationPreds(m *pb.Mutations) []string {
+	seen := make(map[string]struct{})
+	var preds []string
+	add := func(pred string) {
+		if _, ok := seen[pred]; !ok {
+			seen[pred] = struct{}{}
+			preds = append(preds, pred)
+		}
+	}
+	for _, edge := range m.Edges {
+		add(edge.Predicate)
+	}
+	for _, su := range m.Schema {
+		add(su.Predicate)
+	}
+	return preds
+}
+
 // proposeAndWait sends a proposal through RAFT. It waits on a channel for the proposal
 // to be applied(written to WAL) to all the nodes in the group.
 func (n *node) proposeAndWait(ctx context.Context,
@@ -154,11 +218,19 @@ func (n *node) proposeAndWait(ctx context.Context,
 			return errNonExistentTablet
 		case tablet.GroupId != groups().groupId():
 			return errUnservedTablet
+		case tablet.ReadOnly:
+			return errReadOnlyTablet
 		default:
 			return nil
 		}
 	}
 
+	if m := proposal.Mutations; m != nil && m.DropOp == pb.Mutations_NONE && len(m.Schema) == 0 &&
+		n.cdcTracker.full() {
+		// Rather than dropping CDC events, hold back new mutations till the sink catches up.
+		return nil, errCDCBehind
+	}
+
 	span := otrace.FromContext(ctx)
 	// Do a type check here if schema is present
 	// In very rare cases invalid entries might pass through raft, which would
@@ -25,6 +25,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/outcaste-io/outserv/protos/pb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -146,3 +147,22 @@ func BenchmarkRateLimiter(b *testing.B) {
 		}
 	}
 }
+
+func TestReadOnlyPreds(t *testing.T) {
+	preds := mutationPreds(&pb.Mutations{
+		Edges:  []*pb.Edge{{Predicate: "name"}, {Predicate: "age"}, {Predicate: "name"}},
+		Schema: []*pb.SchemaUpdate{{Predicate: "email"}},
+	})
+	require.Equal(t, []string{"name", "age", "email"}, preds)
+
+	r := newReadOnlyPreds()
+	require.Empty(t, r.anyOf(preds))
+	r.set("email", true)
+	r.set("phone", true)
+	require.Equal(t, "email", r.anyOf(preds))
+	require.ElementsMatch(t, []string{"email", "phone"}, r.list())
+
+	r.set("email", false)
+	require.Empty(t, r.anyOf(preds))
+	require.Equal(t, []string{"phone"}, r.list())
+}
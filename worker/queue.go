@@ -4,12 +4,17 @@
 package worker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,18 +24,17 @@ import (
 	"github.com/outcaste-io/outserv/raftwal"
 	"github.com/outcaste-io/outserv/x"
 	"github.com/outcaste-io/outserv/zero"
-	"github.com/outcaste-io/ristretto/z"
 	"github.com/pkg/errors"
 )
 
 // TaskStatusOverNetwork fetches the status of a task over the network. Alphas only know about the
-// tasks created by them, but this function would fetch the task from the correct Alpha.
-func TaskStatusOverNetwork(ctx context.Context, req *pb.TaskStatusRequest,
-) (*pb.TaskStatusResponse, error) {
+// tasks created by them, but this function would fetch the task from the correct Alpha. If
+// req.Cancel is set, the task is cancelled first.
+func TaskStatusOverNetwork(ctx context.Context, req *pb.TaskStatusRequest) (Task, error) {
 	// Extract Raft ID from Task ID.
 	taskId := req.GetTaskId()
 	if taskId == 0 {
-		return nil, fmt.Errorf("invalid task ID: %#x", taskId)
+		return Task{}, fmt.Errorf("invalid task ID: %#x", taskId)
 	}
 	raftId := taskId >> 32
 
@@ -38,7 +42,11 @@ func TaskStatusOverNetwork(ctx context.Context, req *pb.TaskStatusRequest,
 	myRaftId := State.WALstore.Uint(raftwal.RaftId)
 	if raftId == myRaftId {
 		worker := (*grpcWorker)(nil)
-		return worker.TaskStatus(ctx, req)
+		resp, err := worker.TaskStatus(ctx, req)
+		if err != nil {
+			return Task{}, err
+		}
+		return taskFromStatus(taskId, resp), nil
 	}
 
 	// Find the Alpha with the required Raft ID.
@@ -48,29 +56,36 @@ func TaskStatusOverNetwork(ctx context.Context, req *pb.TaskStatusRequest,
 		addr = member.Addr
 	}
 	if addr == "" {
-		return nil, fmt.Errorf("the Alpha that served that task is not available")
+		return Task{}, fmt.Errorf("the Alpha that served that task is not available")
 	}
 
 	// Send the request to the Alpha.
 	pool, err := conn.GetPools().Get(addr)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to reach the Alpha that served that task")
+		return Task{}, errors.Wrapf(err, "unable to reach the Alpha that served that task")
 	}
 	client := pb.NewWorkerClient(pool.Get())
-	return client.TaskStatus(ctx, req)
+	resp, err := client.TaskStatus(ctx, req)
+	if err != nil {
+		return Task{}, err
+	}
+	return taskFromStatus(taskId, resp), nil
 }
 
-// TaskStatus retrieves metadata for a given task ID.
+// TaskStatus retrieves the state of a given task ID, cancelling it first if asked to.
 func (*grpcWorker) TaskStatus(ctx context.Context, req *pb.TaskStatusRequest,
 ) (*pb.TaskStatusResponse, error) {
 	taskId := req.GetTaskId()
-	meta, err := Tasks.get(taskId)
+	if req.GetCancel() {
+		if err := Tasks.cancel(taskId); err != nil {
+			return nil, err
+		}
+	}
+	task, err := Tasks.get(taskId)
 	if err != nil {
 		return nil, err
 	}
-
-	resp := &pb.TaskStatusResponse{TaskMeta: meta.uint64()}
-	return resp, nil
+	return task.status(), nil
 }
 
 var (
@@ -81,47 +96,130 @@ var (
 
 // InitTasks initializes the global Tasks variable.
 func InitTasks() {
-	path := filepath.Join(x.WorkerConfig.Dir.Tmp, "tasks.buf")
-	log, err := z.NewTreePersistent(path)
-	x.Check(err)
-
 	// #nosec G404: weak RNG
 	Tasks = &tasks{
 		queue: make(chan taskRequest, 16),
-		log:   log,
+		log:   make(map[uint64]*taskEntry),
 		logMu: new(sync.Mutex),
+		path:  filepath.Join(x.WorkerConfig.Dir.Tmp, "tasks.json"),
 		rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	if err := Tasks.load(); err != nil {
+		glog.Errorf("Unable to load the task log, starting with an empty one: %v", err)
+	}
 
-	// Mark all pending tasks as failed.
+	// Resume the tasks interrupted by a restart, in the order they were queued in. Only the
+	// tasks that kept their request can be resumed. An index rebuild is restarted by the replay
+	// of the schema update that started it, which picks up its task through Track.
 	Tasks.logMu.Lock()
-	Tasks.log.IterateKV(func(id, val uint64) uint64 {
-		meta := TaskMeta(val)
-		if status := meta.Status(); status == TaskStatusQueued || status == TaskStatusRunning {
-			return uint64(newTaskMeta(meta.Kind(), TaskStatusFailed))
+	var ids []uint64
+	for id, e := range Tasks.log {
+		if status := e.Meta.Status(); status == TaskStatusQueued || status == TaskStatusRunning {
+			ids = append(ids, id)
 		}
-		return 0
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return Tasks.log[ids[i]].Meta.Timestamp().Before(Tasks.log[ids[j]].Meta.Timestamp())
 	})
+	for _, id := range ids {
+		e := Tasks.log[id]
+		req, err := decodeTaskRequest(e.Meta.Kind(), e.Request)
+		if err == nil {
+			select {
+			case Tasks.queue <- taskRequest{id: id, req: req}:
+				glog.Infof("task %#x: resuming %s task", id, e.Meta.Kind())
+				e.Meta = newTaskMeta(e.Meta.Kind(), TaskStatusQueued)
+				e.Started, e.Done, e.Total = 0, 0, 0
+				e.resumed = true
+				continue
+			default:
+				err = fmt.Errorf("too many pending tasks")
+			}
+		}
+		e.Meta = newTaskMeta(e.Meta.Kind(), TaskStatusFailed)
+		e.Error = fmt.Sprintf("Interrupted by a restart: %v", err)
+		e.Request = nil
+	}
+	Tasks.save()
 	Tasks.logMu.Unlock()
 
 	// Start the task runner.
 	go Tasks.worker()
 }
 
-// tasks is a persistent task queue.
+// tasks is a persistent task queue. Along with the queued tasks, it keeps track of the ones run
+// elsewhere, like index rebuilds.
 type tasks struct {
 	// queue stores the full Protobuf request.
 	queue chan taskRequest
-	// log stores the timestamp, TaskKind, and TaskStatus.
-	log   *z.Tree
+	// log stores the state of the tasks by their IDs. It's persisted at path.
+	log   map[uint64]*taskEntry
 	logMu *sync.Mutex
+	path  string
+	// saved is when the log was last persisted.
+	saved time.Time
 
 	rng *rand.Rand
 }
 
+// taskEntry is the state of a task, as persisted in the task log.
+type taskEntry struct {
+	Meta    TaskMeta `json:"meta"`
+	Started int64    `json:"started,omitempty"`
+	Done    uint64   `json:"done,omitempty"`
+	Total   uint64   `json:"total,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	// Request is the request of a task, kept until it's done, so that it can be resumed after a
+	// restart. The requests carrying credentials aren't kept, so the credentials never get
+	// written to disk.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// cancel cancels the context the task runs with. It's nil if the task can't be cancelled.
+	cancel    context.CancelFunc
+	cancelled bool
+	// resumed is set if the task was resumed after a restart, till Track picks it up.
+	resumed bool
+}
+
+func (e *taskEntry) task(id uint64) Task {
+	task := Task{Id: id, Meta: e.Meta, Done: e.Done, Total: e.Total, Error: e.Error}
+	if e.Started > 0 {
+		task.Started = time.Unix(e.Started, 0)
+	}
+	return task
+}
+
+// load reads the task log persisted by an earlier run.
+func (t *tasks) load() error {
+	data, err := ioutil.ReadFile(t.path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	return json.Unmarshal(data, &t.log)
+}
+
+// save persists the task log. logMu must be acquired before calling this function.
+func (t *tasks) save() {
+	data, err := json.Marshal(t.log)
+	x.Check(err)
+	tmp := t.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err == nil {
+		err = os.Rename(tmp, t.path)
+	}
+	if err != nil {
+		glog.Errorf("While saving the task log: %v", err)
+	}
+	t.saved = time.Now()
+}
+
 // Enqueue adds a new task to the queue, waits for 3 seconds, and returns any errors that
 // may have happened in that span of time. The request must be of type:
 // - *pb.ExportRequest
+// - *BackupRequest
+// - *RestoreRequest
 func (t *tasks) Enqueue(req interface{}) (uint64, error) {
 	if t == nil {
 		return 0, fmt.Errorf("task queue hasn't been initialized yet")
@@ -136,14 +234,15 @@ func (t *tasks) Enqueue(req interface{}) (uint64, error) {
 	for i := 0; i < 3; i++ {
 		time.Sleep(time.Second)
 
-		t.logMu.Lock()
-		meta := TaskMeta(t.log.Get(id))
-		t.logMu.Unlock()
+		task, err := t.get(id)
+		if err != nil {
+			return 0, err
+		}
 
 		// Early return
-		switch meta.Status() {
+		switch task.Meta.Status() {
 		case TaskStatusFailed:
-			return 0, fmt.Errorf("task failed")
+			return 0, fmt.Errorf("task failed: %s", task.Error)
 		case TaskStatusSuccess:
 			return id, nil
 		}
@@ -154,15 +253,28 @@ func (t *tasks) Enqueue(req interface{}) (uint64, error) {
 
 // enqueue adds a new task to the queue. This must be of type:
 // - *pb.ExportRequest
+// - *BackupRequest
+// - *RestoreRequest
 func (t *tasks) enqueue(req interface{}) (uint64, error) {
 	var kind TaskKind
 	switch req.(type) {
 	case *pb.ExportRequest:
 		kind = TaskKindExport
+	case *BackupRequest:
+		kind = TaskKindBackup
+	case *RestoreRequest:
+		kind = TaskKindRestore
 	default:
 		err := fmt.Errorf("invalid TaskKind: %d", kind)
 		panic(err)
 	}
+	var data []byte
+	if !hasCredentials(req) {
+		var err error
+		if data, err = json.Marshal(req); err != nil {
+			return 0, errors.Wrapf(err, "while encoding %s request", kind)
+		}
+	}
 
 	t.logMu.Lock()
 	defer t.logMu.Unlock()
@@ -175,33 +287,214 @@ func (t *tasks) enqueue(req interface{}) (uint64, error) {
 	// t.logMu must be acquired before pushing to t.queue, otherwise the worker might start the
 	// task, and won't be able to find it in t.log.
 	case t.queue <- task:
-		t.log.Set(task.id, newTaskMeta(kind, TaskStatusQueued).uint64())
+		t.log[task.id] = &taskEntry{Meta: newTaskMeta(kind, TaskStatusQueued), Request: data}
+		t.save()
 		return task.id, nil
 	default:
 		return 0, fmt.Errorf("too many pending tasks, please try again later")
 	}
 }
 
-// get retrieves metadata for a given task ID.
-func (t *tasks) get(id uint64) (TaskMeta, error) {
+// hasCredentials tells whether the request carries the credentials to access a bucket.
+func hasCredentials(req interface{}) bool {
+	switch req := req.(type) {
+	case *pb.ExportRequest:
+		return req.AccessKey != "" || req.SecretKey != "" || req.SessionToken != ""
+	case *BackupRequest:
+		return req.AccessKey != "" || req.SecretKey != "" || req.SessionToken != ""
+	case *RestoreRequest:
+		return req.AccessKey != "" || req.SecretKey != "" || req.SessionToken != ""
+	}
+	return false
+}
+
+// Track adds a task run outside of the queue, like an index rebuild, to the task log. It returns
+// the context to run the task with, which reports the progress of the task. If the task can be
+// cancelled, so is the context. The returned function must be called with the result of the
+// task once it's done.
+//
+// req is kept in the task log, so that the task can be resumed after a restart. If the task is
+// run again with the same request after being resumed, it keeps its ID. req is nil if the task
+// can't be resumed.
+func (t *tasks) Track(ctx context.Context, kind TaskKind, cancellable bool, req interface{},
+) (context.Context, func(error)) {
 	if t == nil {
-		return 0, fmt.Errorf("task queue hasn't been initialized yet")
+		return ctx, func(error) {}
+	}
+	var data []byte
+	if req != nil && !hasCredentials(req) {
+		var err error
+		if data, err = json.Marshal(req); err != nil {
+			glog.Errorf("While encoding %s request, it can't be resumed: %v", kind, err)
+		}
+	}
+
+	t.logMu.Lock()
+	defer t.logMu.Unlock()
+
+	id, e := t.resumedEntry(kind, data)
+	if e != nil {
+		glog.Infof("task %#x: resumed %s task", id, kind)
+		e.resumed = false
+	} else {
+		id = t.newId()
+		e = &taskEntry{Request: data}
+		t.log[id] = e
+		glog.Infof("task %#x: started %s task", id, kind)
+	}
+	e.Meta = newTaskMeta(kind, TaskStatusRunning)
+	e.Started = time.Now().Unix()
+	// The queue might already be running the resumed task, waiting for it to be run here. If so,
+	// cancelling the task cancels both.
+	prev := e.cancel
+	e.cancel = nil
+	ctx = t.start(ctx, id, e, cancellable)
+	if cancel := e.cancel; cancel != nil && prev != nil {
+		e.cancel = func() { cancel(); prev() }
+	}
+	t.save()
+	return ctx, func(err error) { t.finish(id, err) }
+}
+
+// resumedEntry returns the task of the given kind and request that was resumed after a restart,
+// if any. logMu must be acquired before calling this function.
+func (t *tasks) resumedEntry(kind TaskKind, data []byte) (uint64, *taskEntry) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	for id, e := range t.log {
+		if e.resumed && e.Meta.Kind() == kind && bytes.Equal(e.Request, data) {
+			return id, e
+		}
+	}
+	return 0, nil
+}
+
+// start returns the context to run the task with. logMu must be acquired before calling this
+// function.
+func (t *tasks) start(ctx context.Context, id uint64, e *taskEntry, cancellable bool,
+) context.Context {
+	if cancellable {
+		ctx, e.cancel = context.WithCancel(ctx)
+	}
+	return x.WithProgress(ctx, func(done, total uint64) {
+		t.logMu.Lock()
+		defer t.logMu.Unlock()
+		e.Done, e.Total = done, total
+		// The progress is persisted only every so often, since it can change a lot.
+		if time.Since(t.saved) > 10*time.Second {
+			t.save()
+		}
+	})
+}
+
+// finish records the result of the task, unless it's already been recorded.
+func (t *tasks) finish(id uint64, err error) {
+	t.logMu.Lock()
+	defer t.logMu.Unlock()
+
+	e, ok := t.log[id]
+	if !ok || e.Meta.Status() != TaskStatusRunning {
+		return
+	}
+	status := TaskStatusSuccess
+	switch {
+	case err != nil && e.cancelled:
+		status = TaskStatusCancelled
+	case err != nil:
+		status = TaskStatusFailed
+		e.Error = err.Error()
+	case e.Total > 0:
+		e.Done = e.Total
+	}
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.Meta = newTaskMeta(e.Meta.Kind(), status)
+	e.Request = nil
+	t.save()
+}
+
+// cancel cancels the task, if it's still queued or running.
+func (t *tasks) cancel(id uint64) error {
+	if t == nil {
+		return fmt.Errorf("task queue hasn't been initialized yet")
+	}
+
+	t.logMu.Lock()
+	defer t.logMu.Unlock()
+	e, ok := t.log[id]
+	if !ok {
+		return fmt.Errorf("task does not exist or has expired")
+	}
+	switch status := e.Meta.Status(); status {
+	case TaskStatusQueued:
+		if e.Meta.Kind() == TaskKindIndexRebuild {
+			// A resumed index rebuild is run by the replay of its schema update anyway.
+			return fmt.Errorf("%s tasks can't be cancelled", e.Meta.Kind())
+		}
+		// The worker skips the task when it gets to it.
+		e.Meta = newTaskMeta(e.Meta.Kind(), TaskStatusCancelled)
+		e.Request = nil
+		t.save()
+	case TaskStatusRunning:
+		if e.cancel == nil {
+			return fmt.Errorf("%s tasks can't be cancelled", e.Meta.Kind())
+		}
+		e.cancelled = true
+		e.cancel()
+	default:
+		return fmt.Errorf("task has already finished with status %s", status)
+	}
+	return nil
+}
+
+// get retrieves the state of a given task ID.
+func (t *tasks) get(id uint64) (Task, error) {
+	if t == nil {
+		return Task{}, fmt.Errorf("task queue hasn't been initialized yet")
 	}
 
 	if id == 0 || id == math.MaxUint64 {
-		return 0, fmt.Errorf("task ID is invalid: %d", id)
+		return Task{}, fmt.Errorf("task ID is invalid: %d", id)
 	}
 	t.logMu.Lock()
 	defer t.logMu.Unlock()
-	meta := TaskMeta(t.log.Get(id))
-	if meta == 0 {
-		return 0, fmt.Errorf("task does not exist or has expired")
+	e, ok := t.log[id]
+	if !ok {
+		return Task{}, fmt.Errorf("task does not exist or has expired")
+	}
+	return e.task(id), nil
+}
+
+// List returns the tasks of this Alpha from the last week, the most recently updated first.
+func (t *tasks) List() []Task {
+	if t == nil {
+		return nil
 	}
-	return meta, nil
+
+	t.logMu.Lock()
+	res := make([]Task, 0, len(t.log))
+	for id, e := range t.log {
+		res = append(res, e.task(id))
+	}
+	t.logMu.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Meta.Timestamp() != res[j].Meta.Timestamp() {
+			return res[i].Meta.Timestamp().After(res[j].Meta.Timestamp())
+		}
+		return res[i].Id < res[j].Id
+	})
+	return res
 }
 
 // worker loops forever, running queued tasks one at a time. Any returned errors are logged.
 func (t *tasks) worker() {
+	// The tasks resumed after a restart can only run once the cluster is up.
+	x.BlockUntilHealthy()
+
 	shouldCleanup := time.NewTicker(time.Hour)
 	defer shouldCleanup.Stop()
 	for {
@@ -209,7 +502,6 @@ func (t *tasks) worker() {
 		var task taskRequest
 		select {
 		case <-x.ServerCloser.HasBeenClosed():
-			t.log.Close()
 			return
 		case <-shouldCleanup.C:
 			t.cleanup()
@@ -227,36 +519,29 @@ func (t *tasks) run(task taskRequest) error {
 	// Fetch the task from the log. If the task isn't found, this means it has expired (older than
 	// taskTtl).
 	t.logMu.Lock()
-	meta := TaskMeta(t.log.Get(task.id))
-	t.logMu.Unlock()
-	if meta == 0 {
+	e, ok := t.log[task.id]
+	if !ok {
+		t.logMu.Unlock()
 		return fmt.Errorf("is expired, skipping")
 	}
 
 	// Only proceed if the task is still queued. It's possible that the task got canceled before we
 	// were able to run it.
-	if status := meta.Status(); status != TaskStatusQueued {
+	if status := e.Meta.Status(); status != TaskStatusQueued {
+		t.logMu.Unlock()
 		return fmt.Errorf("status is set to %s, skipping", status)
 	}
 
 	// Change the task status to Running.
-	t.logMu.Lock()
-	t.log.Set(task.id, newTaskMeta(meta.Kind(), TaskStatusRunning).uint64())
+	e.Meta = newTaskMeta(e.Meta.Kind(), TaskStatusRunning)
+	e.Started = time.Now().Unix()
+	ctx := t.start(context.Background(), task.id, e, true)
+	t.save()
 	t.logMu.Unlock()
 
-	// Run the task.
-	var status TaskStatus
-	err := task.run()
-	if err != nil {
-		status = TaskStatusFailed
-	} else {
-		status = TaskStatusSuccess
-	}
-
-	// Change the task status to Success / Failed.
-	t.logMu.Lock()
-	t.log.Set(task.id, newTaskMeta(meta.Kind(), status).uint64())
-	t.logMu.Unlock()
+	// Run the task, and change its status to Success / Failed / Cancelled.
+	err := task.run(ctx)
+	t.finish(task.id, err)
 
 	// Return the error from the task.
 	return err
@@ -265,12 +550,20 @@ func (t *tasks) run(task taskRequest) error {
 // cleanup deletes all expired tasks.
 func (t *tasks) cleanup() {
 	const taskTtl = 7 * 24 * time.Hour // 1 week
-	minTs := time.Now().UTC().Add(-taskTtl).Unix()
-	minMeta := uint64(minTs) << 32
+	minTs := time.Now().Add(-taskTtl)
 
 	t.logMu.Lock()
 	defer t.logMu.Unlock()
-	t.log.DeleteBelow(minMeta)
+	for id, e := range t.log {
+		switch e.Meta.Status() {
+		case TaskStatusQueued, TaskStatusRunning:
+		default:
+			if e.Meta.Timestamp().Before(minTs) {
+				delete(t.log, id)
+			}
+		}
+	}
+	t.save()
 }
 
 // newId generates a random unique task ID. logMu must be acquired before calling this function.
@@ -282,27 +575,69 @@ func (t *tasks) newId() uint64 {
 	myRaftId := State.WALstore.Uint(raftwal.RaftId)
 	for {
 		id := myRaftId<<32 | uint64(t.rng.Intn(math.MaxUint32))
-		// z.Tree cannot store 0 or math.MaxUint64. Check that id is unique.
-		if id != 0 && id != math.MaxUint64 && t.log.Get(id) == 0 {
+		// Task IDs can't be 0 or math.MaxUint64. Check that id is unique.
+		if _, has := t.log[id]; id != 0 && id != math.MaxUint64 && !has {
 			return id
 		}
 	}
 }
 
 type taskRequest struct {
-	id  uint64
-	req interface{} // *pb.ExportRequest
+	id uint64
+	// *pb.ExportRequest, *BackupRequest, *RestoreRequest or *IndexRebuildRequest
+	req interface{}
+}
+
+// decodeTaskRequest decodes the request of a task from the task log.
+func decodeTaskRequest(kind TaskKind, data []byte) (interface{}, error) {
+	var req interface{}
+	switch {
+	case len(data) == 0:
+		return nil, fmt.Errorf("%s tasks can't be resumed without their request, which isn't "+
+			"kept if it has credentials", kind)
+	case kind == TaskKindExport:
+		req = &pb.ExportRequest{}
+	case kind == TaskKindBackup:
+		req = &BackupRequest{}
+	case kind == TaskKindRestore:
+		req = &RestoreRequest{}
+	case kind == TaskKindIndexRebuild:
+		req = &IndexRebuildRequest{}
+	default:
+		return nil, fmt.Errorf("%s tasks can't be resumed", kind)
+	}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, errors.Wrapf(err, "while decoding %s request", kind)
+	}
+	return req, nil
 }
 
 // run starts a task and blocks till it completes.
-func (t *taskRequest) run() error {
+func (t *taskRequest) run(ctx context.Context) error {
 	switch req := t.req.(type) {
 	case *pb.ExportRequest:
-		files, err := ExportOverNetwork(context.Background(), req)
+		files, err := ExportOverNetwork(ctx, req)
 		if err != nil {
 			return err
 		}
 		glog.Infof("task %#x: exported files: %v", t.id, files)
+	case *BackupRequest:
+		m, err := BackupOverNetwork(ctx, req)
+		if err != nil {
+			return err
+		}
+		glog.Infof("task %#x: %s backup %d written at read ts %d",
+			t.id, m.Type, m.BackupNum, m.ReadTs)
+	case *RestoreRequest:
+		if err := RestoreOverNetwork(ctx, req); err != nil {
+			return err
+		}
+		glog.Infof("task %#x: restored from %s", t.id, req.Location)
+	case *IndexRebuildRequest:
+		if err := resumeIndexRebuild(ctx, req); err != nil {
+			return err
+		}
+		glog.Infof("task %#x: rebuilt the indexes of %s", t.id, req.Update.Predicate)
 	default:
 		glog.Errorf(
 			"task %#x: received request of unknown type (%T)", t.id, reflect.TypeOf(t.req))
@@ -310,6 +645,69 @@ func (t *taskRequest) run() error {
 	return nil
 }
 
+// Task is the state of a task, as reported by TaskStatusOverNetwork.
+type Task struct {
+	Id   uint64
+	Meta TaskMeta
+	// Started is when the task started running. It's zero if it hasn't yet.
+	Started time.Time
+	// Done and Total are the progress of the task, as the work done out of the total work. Total
+	// is zero if it isn't known.
+	Done, Total uint64
+	// Error is why the task failed.
+	Error string
+}
+
+// Progress returns the percentage of the task that's done. It returns false if that isn't known.
+func (t Task) Progress() (float64, bool) {
+	switch {
+	case t.Meta.Status() == TaskStatusSuccess:
+		return 100, true
+	case t.Total == 0:
+		return 0, false
+	}
+	return math.Min(100, 100*float64(t.Done)/float64(t.Total)), true
+}
+
+// Eta returns when the task is estimated to be done, going by its progress so far. It returns
+// false if the task isn't running, or if it's too early to tell.
+func (t Task) Eta() (time.Time, bool) {
+	if t.Meta.Status() != TaskStatusRunning || t.Started.IsZero() || t.Done == 0 ||
+		t.Total == 0 {
+		return time.Time{}, false
+	}
+	done := math.Min(1, float64(t.Done)/float64(t.Total))
+	took := float64(time.Since(t.Started)) / done
+	return t.Started.Add(time.Duration(took)), true
+}
+
+func (t Task) status() *pb.TaskStatusResponse {
+	resp := &pb.TaskStatusResponse{
+		TaskMeta: t.Meta.uint64(),
+		Done:     t.Done,
+		Total:    t.Total,
+		Error:    t.Error,
+	}
+	if !t.Started.IsZero() {
+		resp.Started = t.Started.Unix()
+	}
+	return resp
+}
+
+func taskFromStatus(id uint64, resp *pb.TaskStatusResponse) Task {
+	task := Task{
+		Id:    id,
+		Meta:  TaskMeta(resp.GetTaskMeta()),
+		Done:  resp.GetDone(),
+		Total: resp.GetTotal(),
+		Error: resp.GetError(),
+	}
+	if resp.GetStarted() > 0 {
+		task.Started = time.Unix(resp.GetStarted(), 0)
+	}
+	return task
+}
+
 // TaskMeta stores a timestamp, a TaskKind and a Status.
 //
 // The format of this is:
@@ -346,6 +744,11 @@ func (t TaskMeta) uint64() uint64 {
 const (
 	// Reserve the zero value for errors.
 	TaskKindExport TaskKind = iota + 1
+	TaskKindBackup
+	TaskKindRestore
+	TaskKindIndexRebuild
+	TaskKindMovePredicate
+	TaskKindDelete
 )
 
 type TaskKind uint64
@@ -354,6 +757,16 @@ func (k TaskKind) String() string {
 	switch k {
 	case TaskKindExport:
 		return "Export"
+	case TaskKindBackup:
+		return "Backup"
+	case TaskKindRestore:
+		return "Restore"
+	case TaskKindIndexRebuild:
+		return "IndexRebuild"
+	case TaskKindMovePredicate:
+		return "MovePredicate"
+	case TaskKindDelete:
+		return "Delete"
 	default:
 		return "Unknown"
 	}
@@ -365,6 +778,7 @@ const (
 	TaskStatusRunning
 	TaskStatusFailed
 	TaskStatusSuccess
+	TaskStatusCancelled
 )
 
 type TaskStatus uint64
@@ -379,6 +793,8 @@ func (status TaskStatus) String() string {
 		return "Failed"
 	case TaskStatusSuccess:
 		return "Success"
+	case TaskStatusCancelled:
+		return "Cancelled"
 	default:
 		return "Unknown"
 	}
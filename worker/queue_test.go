@@ -0,0 +1,166 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newTasks := func() *tasks {
+		ts := &tasks{
+			queue: make(chan taskRequest, 16),
+			log:   make(map[uint64]*taskEntry),
+			logMu: new(sync.Mutex),
+			path:  filepath.Join(dir, "tasks.json"),
+			rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		}
+		require.NoError(t, ts.load())
+		return ts
+	}
+	ts := newTasks()
+	add := func(id uint64, kind TaskKind, status TaskStatus, cancellable bool) context.Context {
+		ts.logMu.Lock()
+		defer ts.logMu.Unlock()
+		e := &taskEntry{Meta: newTaskMeta(kind, status)}
+		ts.log[id] = e
+		return ts.start(context.Background(), id, e, cancellable)
+	}
+
+	// A queued task is cancelled right away.
+	add(1, TaskKindExport, TaskStatusQueued, true)
+	require.NoError(t, ts.cancel(1))
+	task, err := ts.get(1)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusCancelled, task.Meta.Status())
+	require.Error(t, ts.cancel(1))
+
+	// A running task reports its progress, and can only be cancelled if it's cancellable.
+	ctx := add(2, TaskKindIndexRebuild, TaskStatusRunning, false)
+	x.ReportProgress(ctx, 25, 100)
+	require.Error(t, ts.cancel(2))
+	task, err = ts.get(2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(25), task.Done)
+	require.Equal(t, uint64(100), task.Total)
+	ts.finish(2, nil)
+	task, err = ts.get(2)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusSuccess, task.Meta.Status())
+	require.Equal(t, uint64(100), task.Done)
+
+	ctx = add(3, TaskKindMovePredicate, TaskStatusRunning, true)
+	require.NoError(t, ts.cancel(3))
+	<-ctx.Done()
+	ts.finish(3, ctx.Err())
+	task, err = ts.get(3)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusCancelled, task.Meta.Status())
+
+	add(4, TaskKindBackup, TaskStatusRunning, true)
+	ts.finish(4, context.DeadlineExceeded)
+
+	// The log survives a restart.
+	ts = newTasks()
+	require.Len(t, ts.List(), 4)
+	task, err = ts.get(4)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusFailed, task.Meta.Status())
+	require.Equal(t, context.DeadlineExceeded.Error(), task.Error)
+}
+
+func TestTaskRequestCredentials(t *testing.T) {
+	// The requests with credentials aren't persisted, and so can't be resumed.
+	require.False(t, hasCredentials(&BackupRequest{Destination: "s3://bucket"}))
+	require.True(t, hasCredentials(&BackupRequest{Destination: "s3://bucket", SecretKey: "k"}))
+	require.True(t, hasCredentials(&RestoreRequest{Location: "s3://bucket", SessionToken: "t"}))
+	require.True(t, hasCredentials(&pb.ExportRequest{AccessKey: "k"}))
+	_, err := decodeTaskRequest(TaskKindBackup, nil)
+	require.Error(t, err)
+
+	req, err := decodeTaskRequest(TaskKindRestore, []byte(`{"Location": "/backups"}`))
+	require.NoError(t, err)
+	require.Equal(t, &RestoreRequest{Location: "/backups"}, req)
+}
+
+func TestTaskResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := &tasks{
+		log:   make(map[uint64]*taskEntry),
+		logMu: new(sync.Mutex),
+		path:  filepath.Join(dir, "tasks.json"),
+	}
+	req := &IndexRebuildRequest{Update: &pb.SchemaUpdate{Predicate: "name"}, StartTs: 10}
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	decoded, err := decodeTaskRequest(TaskKindIndexRebuild, data)
+	require.NoError(t, err)
+	require.Equal(t, "name", decoded.(*IndexRebuildRequest).Update.Predicate)
+	require.Equal(t, uint64(10), decoded.(*IndexRebuildRequest).StartTs)
+
+	// Rebuilding the same indexes again picks up the resumed task.
+	ts.log[1] = &taskEntry{
+		Meta:    newTaskMeta(TaskKindIndexRebuild, TaskStatusQueued),
+		Request: data,
+		resumed: true,
+	}
+	require.Error(t, ts.cancel(1))
+	_, finish := ts.Track(context.Background(), TaskKindIndexRebuild, false, req)
+	require.Len(t, ts.List(), 1)
+	task, err := ts.get(1)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusRunning, task.Meta.Status())
+
+	// Index rebuilds can't be cancelled, so that the replicas keep the same indexes.
+	require.Error(t, ts.cancel(1))
+	finish(nil)
+	// The result is only recorded once.
+	ts.finish(1, errors.New("failed"))
+	task, err = ts.get(1)
+	require.NoError(t, err)
+	require.Equal(t, TaskStatusSuccess, task.Meta.Status())
+}
+
+func TestTaskProgress(t *testing.T) {
+	task := Task{Meta: newTaskMeta(TaskKindIndexRebuild, TaskStatusRunning)}
+	_, ok := task.Progress()
+	require.False(t, ok)
+	_, ok = task.Eta()
+	require.False(t, ok)
+
+	task.Started = time.Now().Add(-10 * time.Second)
+	task.Done, task.Total = 1, 4
+	progress, ok := task.Progress()
+	require.True(t, ok)
+	require.Equal(t, 25.0, progress)
+	eta, ok := task.Eta()
+	require.True(t, ok)
+	require.InDelta(t, 30, time.Until(eta).Seconds(), 1)
+
+	task.Meta = newTaskMeta(TaskKindIndexRebuild, TaskStatusSuccess)
+	progress, ok = task.Progress()
+	require.True(t, ok)
+	require.Equal(t, 100.0, progress)
+	_, ok = task.Eta()
+	require.False(t, ok)
+}
@@ -22,19 +22,24 @@ const (
 	//       For easy readability, keep the options without default values (if any) at the end of
 	//       the *Defaults string. Also, since these strings are printed in --help text, avoid line
 	//       breaks.
-	AuditDefaults  = `compress=false; days=10; size=100; dir=; output=; encrypt-file=;`
+	AuditDefaults  = `compress=false; days=10; size=100; output=; encrypt-file=; redact=password;`
 	BadgerDefaults = `compression=snappy; numgoroutines=8;`
 	CacheDefaults  = `size-mb=1024; percentage=50,30,20;`
-	CDCDefaults    = `file=; kafka=; sasl_user=; sasl_password=; ca_cert=; client_cert=; ` +
-		`client_key=; sasl-mechanism=PLAIN; tls=false;`
-	GraphQLDefaults = `introspection=true; debug=false; extensions=true; poll-interval=1s; `
-	LambdaDefaults  = `url=; num=0; port=20000; restart-after=30s; `
-	LimitDefaults   = `disallow-mutations=false; query-edge=1000000; normalize-node=10000; ` +
+	CDCDefaults    = `file=; kafka=; sasl-user=; sasl-password=; ca-cert=; client-cert=; ` +
+		`client-key=; sasl-mechanism=PLAIN; tls=false; max-pending-mb=1024;`
+	GraphQLDefaults = `introspection=true; debug=false; extensions=true; poll-interval=1s; ` +
+		`push-subscriptions=true; persisted-queries-only=false; max-cost=0; custom-cost=10; ` +
+		`rate-limit=0; rate-interval=1m; rate-key=ip; rate-trust-header=false; cache-mb=64; `
+	IndexDefaults = `eth=; namespace=0; start=0; confirmations=0; poll-interval=5s; ` +
+		`max-reorg=128;`
+	LambdaDefaults = `url=; num=0; port=20000; restart-after=30s; `
+	LimitDefaults  = `disallow-mutations=false; query-edge=1000000; normalize-node=10000; ` +
 		`mutations-nquad=1000000; disallow-drop=false; query-timeout=0ms; txn-abort-after=5m;` +
 		`max-pending-queries=64;  max-retries=-1; shared-instance=false; max-splits=1000;` +
 		`max-upload-size-mb=20`
 	RaftDefaults = `learner=false; snapshot-after-entries=10000; ` +
 		`snapshot-after-duration=30m; pending-proposals=256; idx=1; group=1;`
+	RebalanceDefaults  = `interval=8m; ratio=1.5; min-size-mb=64; dry-run=false;`
 	SecurityDefaults   = `token=; whitelist=;`
 	ZeroLimitsDefaults = `uid-lease=0; refill-interval=30s; disable-admin-http=false;`
 )
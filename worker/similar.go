@@ -0,0 +1,104 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package worker
+
+import (
+	"math"
+	"sort"
+
+	"github.com/outcaste-io/outserv/posting"
+	"github.com/outcaste-io/outserv/tok"
+	"github.com/outcaste-io/outserv/types"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/sroar"
+)
+
+const (
+	// lshProbes is the number of the hyperplanes closest to the vector searched for, across
+	// which the cells of the LSH index are looked up too.
+	lshProbes = 2
+	// lshCandidates is the number of candidates looked up in the LSH index for every neighbour
+	// asked for. They are then ranked by their actual distance.
+	lshCandidates = 8
+)
+
+// neighbour is a node found by similar_to, along with the distance of its vector.
+type neighbour struct {
+	uid  uint64
+	dist float64
+}
+
+// uidsForSimilar collects the uids whose vectors are in the cells of the LSH index around the
+// vector of similar_to. It starts from the finest cells, moving on to coarser ones until it has
+// found want uids. If even the coarsest ones don't have that many, it returns all the uids in
+// the index, so that the neighbours found are exact for small sets of vectors.
+func uidsForSimilar(attr string, arg funcArgs, want int) (*sroar.Bitmap, error) {
+	opts := posting.ListOptions{ReadTs: arg.q.ReadTs}
+	res := sroar.NewBitmap()
+	lookup := func(tokens []string) error {
+		tok.EncodeLshTokens(tokens)
+		for _, t := range tokens {
+			pl, err := posting.GetNoStore(x.IndexKey(attr, t), arg.q.ReadTs)
+			if err != nil {
+				return err
+			}
+			bm, err := pl.Bitmap(opts)
+			if err != nil {
+				return err
+			}
+			res.Or(bm)
+		}
+		return nil
+	}
+
+	// The cells of a level are within the cells of the coarser levels, so the uids found at
+	// a level are kept for the next one.
+	for i := len(types.LshLevels) - 1; i >= 0; i-- {
+		tokens := types.ProbeVFloatTokens(arg.srcFn.vector, types.LshLevels[i], lshProbes)
+		if err := lookup(tokens); err != nil {
+			return nil, err
+		}
+		if int(res.GetCardinality()) >= want {
+			return res, nil
+		}
+	}
+	if err := lookup(types.AllVFloatTokens(types.LshLevels[0])); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// closestVector returns the distance to vec of the closest of the vectors vals. Vectors of other
+// dimensions are skipped, and it returns false if there are none left.
+func closestVector(distance string, vec []float32, vals []types.Sval) (float64, bool) {
+	best, ok := math.Inf(1), false
+	for _, val := range vals {
+		v, err := types.Convert(val, types.TypeVFloat)
+		if err != nil {
+			continue
+		}
+		dist, err := types.VectorDistance(distance, vec, v.Value.([]float32))
+		if err != nil {
+			continue
+		}
+		if dist < best {
+			best, ok = dist, true
+		}
+	}
+	return best, ok
+}
+
+// nearest returns the k nearest of the neighbours found. Ties are broken by the uid, so that
+// the same ones are picked every time.
+func nearest(found []neighbour, k int) []neighbour {
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].uid < found[j].uid
+	})
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
@@ -22,11 +22,13 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/xdg/scram"
@@ -41,6 +43,8 @@ type SinkMessage struct {
 	Meta  SinkMeta
 	Key   uint64
 	Value []byte
+	// CommitTs is the commit ts of the mutation which generated the message.
+	CommitTs uint64
 }
 
 type SinkMeta struct {
@@ -50,6 +54,10 @@ type SinkMeta struct {
 type Sink interface {
 	// send in bulk to the sink
 	Send(messages []SinkMessage) error
+	// Sent returns a function which tells whether the sink already holds a message, going by the
+	// commit ts of the last message it got. It's used by a new CDC leader, so as not to send again
+	// the messages sent by the previous one.
+	Sent() (func(SinkMessage) bool, error)
 	// close sink
 	Close() error
 }
@@ -182,6 +190,72 @@ func (k *kafkaSinkClient) Send(messages []SinkMessage) error {
 	return k.producer.SendMessages(msgs)
 }
 
+// Sent reads the commit ts of the last message of every partition of the CDC topic. A message is
+// held by the sink if it's not newer than the last message of the partition it's sent to, as the
+// messages of a partition are kept in the order they were sent in.
+func (k *kafkaSinkClient) Sent() (func(SinkMessage) bool, error) {
+	partitions, err := k.client.Partitions(cdcTopic)
+	if err == sarama.ErrUnknownTopicOrPartition {
+		return func(SinkMessage) bool { return false }, nil
+	} else if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(k.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	lastTs := make([]uint64, len(partitions))
+	for i, partition := range partitions {
+		oldest, err := k.client.GetOffset(cdcTopic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := k.client.GetOffset(cdcTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		if newest <= oldest {
+			continue
+		}
+		pc, err := consumer.ConsumePartition(cdcTopic, partition, newest-1)
+		if err != nil {
+			return nil, err
+		}
+		var msg *sarama.ConsumerMessage
+		select {
+		case msg = <-pc.Messages():
+		case <-time.After(time.Minute):
+		}
+		_ = pc.Close()
+		if msg == nil {
+			return nil, errors.Errorf("timed out reading the last message of partition %d",
+				partition)
+		}
+		var event CDCEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, errors.Wrapf(err, "while reading the last message of partition %d",
+				partition)
+		}
+		if event.Meta != nil {
+			lastTs[i] = event.Meta.CommitTs
+		}
+	}
+
+	// The producer picks the partition of a message the same way.
+	partitioner := sarama.NewHashPartitioner(cdcTopic)
+	return func(m SinkMessage) bool {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, m.Key)
+		i, err := partitioner.Partition(&sarama.ProducerMessage{
+			Topic: m.Meta.Topic,
+			Key:   sarama.ByteEncoder(key),
+		}, int32(len(partitions)))
+		return err == nil && m.CommitTs <= lastTs[i]
+	}, nil
+}
+
 func (k *kafkaSinkClient) Close() error {
 	_ = k.producer.Close()
 	return k.client.Close()
@@ -204,6 +278,12 @@ func (f *fileSink) Send(messages []SinkMessage) error {
 	return nil
 }
 
+// Sent returns a function which never finds a message, as every node writes its own file. So, the
+// file of a new leader never holds the messages sent by the previous one.
+func (f *fileSink) Sent() (func(SinkMessage) bool, error) {
+	return func(SinkMessage) bool { return false }, nil
+}
+
 func (f *fileSink) Close() error {
 	return f.fileWriter.Close()
 }
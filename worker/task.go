@@ -202,6 +202,9 @@ const (
 	uidInFn
 	customIndexFn
 	matchFn
+	histogramFn
+	similarFn
+	prefixFn
 	standardFn = 100
 )
 
@@ -244,6 +247,12 @@ func parseFuncTypeHelper(name string) (FuncType, string) {
 		return customIndexFn, f
 	case "match":
 		return matchFn, f
+	case "histogram":
+		return histogramFn, f
+	case "similar_to":
+		return similarFn, f
+	case "starts_with":
+		return prefixFn, f
 	default:
 		if types.IsGeoFunc(f) {
 			return geoFn, f
@@ -262,8 +271,11 @@ func needsIndex(fnType FuncType, uidList *pb.List) bool {
 			return false
 		}
 		return true
-	case geoFn, fullTextSearchFn, standardFn, matchFn:
+	case geoFn, fullTextSearchFn, standardFn, matchFn, histogramFn, prefixFn:
 		return true
+	case similarFn:
+		// As a filter, the given uids are ranked without the index.
+		return uidList == nil
 	}
 	return false
 }
@@ -288,12 +300,13 @@ func (srcFn *functionContext) needsValuePostings(typ types.TypeID) (bool, error)
 	switch srcFn.fnType {
 	case aggregatorFn, passwordFn:
 		return true, nil
-	case compareAttrFn:
+	case compareAttrFn, prefixFn:
 		if len(srcFn.tokens) > 0 {
 			return false, nil
 		}
 		return true, nil
-	case geoFn, regexFn, fullTextSearchFn, standardFn, hasFn, customIndexFn, matchFn:
+	case geoFn, regexFn, fullTextSearchFn, standardFn, hasFn, customIndexFn, matchFn,
+		histogramFn, similarFn:
 		// All of these require an index, hence would require fetching uid postings.
 		return false, nil
 	case uidInFn, compareScalarFn:
@@ -318,7 +331,7 @@ func (qs *queryState) handleValuePostings(ctx context.Context, args funcArgs) er
 	}
 
 	switch srcFn.fnType {
-	case notAFunction, aggregatorFn, passwordFn, compareAttrFn:
+	case notAFunction, aggregatorFn, passwordFn, compareAttrFn, prefixFn:
 	default:
 		return errors.Errorf("Unhandled function in handleValuePostings: %s", srcFn.fname)
 	}
@@ -406,7 +419,8 @@ func (qs *queryState) handleValuePostings(ctx context.Context, args funcArgs) er
 
 				// This means we fetched the value directly instead of fetching index key and
 				// intersecting. Lets compare the value and add filter the uid.
-				if srcFn.fnType == compareAttrFn {
+				switch srcFn.fnType {
+				case compareAttrFn:
 					// Lets convert the val to its type.
 					cval, err := types.Convert(val, srcFn.atype)
 					if err != nil {
@@ -429,8 +443,15 @@ func (qs *queryState) handleValuePostings(ctx context.Context, args funcArgs) er
 							res.Set(uid)
 						}
 					}
-
-				} else {
+				case prefixFn:
+					sval, err := types.Convert(val, types.TypeString)
+					if err != nil {
+						return err
+					}
+					if strings.HasPrefix(sval.Value.(string), srcFn.prefix) {
+						res.Set(uid)
+					}
+				default:
 					vl.Values = append(vl.Values, newValue)
 				}
 			}
@@ -604,7 +625,7 @@ func (qs *queryState) handleUidPostings(
 			case notAFunction, compareScalarFn, hasFn, uidInFn:
 				key = x.DataKey(q.Attr, uids[i])
 			case geoFn, regexFn, fullTextSearchFn, standardFn, customIndexFn, matchFn,
-				compareAttrFn:
+				compareAttrFn, histogramFn, prefixFn:
 				key = x.IndexKey(q.Attr, srcFn.tokens[i])
 			default:
 				return errors.Errorf("Unhandled function in handleUidPostings: %s", srcFn.fname)
@@ -851,6 +872,13 @@ func (qs *queryState) helpProcessTask(ctx context.Context, q *pb.Query, gid uint
 		}
 	}
 
+	if srcFn.fnType == similarFn {
+		span.Annotate(nil, "handleSimilarFunction")
+		if err := qs.handleSimilarFunction(ctx, args); err != nil {
+			return nil, err
+		}
+	}
+
 	// We fetch the actual value for the uids, compare them to the value in the
 	// request and filter the uids only if the tokenizer IsLossy.
 	if srcFn.fnType == compareAttrFn && len(srcFn.tokens) > 0 {
@@ -860,6 +888,15 @@ func (qs *queryState) helpProcessTask(ctx context.Context, q *pb.Query, gid uint
 		}
 	}
 
+	if srcFn.fnType == histogramFn {
+		// Each row of the UidMatrix is a bucket. Return the token of the bucket along with it,
+		// so the caller can tell which period or value the bucket stands for.
+		for _, token := range srcFn.tokens {
+			out.ValueMatrix = append(out.ValueMatrix,
+				&pb.ValueList{Values: []*pb.TaskValue{{Val: []byte(token)}}})
+		}
+	}
+
 	// If geo filter, do value check for correctness.
 	if srcFn.geoQuery != nil {
 		span.Annotate(nil, "handleGeoFunction")
@@ -1202,6 +1239,84 @@ func (qs *queryState) handleMatchFunction(ctx context.Context, arg funcArgs) err
 	return nil
 }
 
+func (qs *queryState) handleSimilarFunction(ctx context.Context, arg funcArgs) error {
+	span := otrace.FromContext(ctx)
+	stop := x.SpanTimer(span, "handleSimilarFunction")
+	defer stop()
+	if span != nil {
+		span.Annotatef(nil, "Number of uids: %d. args.srcFn: %+v", arg.srcFn.n, arg.srcFn)
+	}
+
+	attr := arg.q.Attr
+	typ := arg.srcFn.atype
+	span.Annotatef(nil, "Attr: %s. Type: %s", attr, typ)
+	topK := int(arg.srcFn.threshold[0])
+	var uids *sroar.Bitmap
+	switch {
+	case typ != types.TypeVFloat:
+		return errors.Errorf("Got type %s for attribute %s. Similarity search is allowed only "+
+			"on vfloat type.", typ, x.ParseAttr(attr))
+
+	case arg.q.UidList != nil:
+		uids = codec.FromList(arg.q.UidList)
+
+	case schema.State().HasTokenizer(ctx, tok.IdentLsh, attr):
+		var err error
+		uids, err = uidsForSimilar(attr, arg, topK*lshCandidates)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return errors.Errorf(
+			"Attribute %v does not have lsh index for similarity search. "+
+				"Please add an lsh index or use has/uid function with similar_to() as filter.",
+			x.ParseAttr(attr))
+	}
+
+	isList := schema.State().IsList(attr)
+	span.Annotatef(nil, "Total candidates: %d, list: %t", uids.GetCardinality(), isList)
+
+	var found []neighbour
+	itr := uids.NewIterator()
+	for uid := itr.Next(); uid > 0; uid = itr.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		pl, err := qs.cache.Get(x.DataKey(attr, uid))
+		if err != nil {
+			return err
+		}
+
+		vals := make([]types.Sval, 1)
+		switch {
+		case isList:
+			vals, err = pl.AllValues(arg.q.ReadTs)
+
+		default:
+			vals[0], err = pl.Value(arg.q.ReadTs)
+		}
+		if err != nil {
+			if err == posting.ErrNoValue {
+				continue
+			}
+			return err
+		}
+		if dist, ok := closestVector(arg.srcFn.distance, arg.srcFn.vector, vals); ok {
+			found = append(found, neighbour{uid: uid, dist: dist})
+		}
+	}
+
+	out := sroar.NewBitmap()
+	for _, n := range nearest(found, topK) {
+		out.Set(n.uid)
+	}
+	arg.out.UidMatrix = append(arg.out.UidMatrix, &pb.List{Bitmap: out.ToBuffer()})
+	return nil
+}
+
 func (qs *queryState) filterGeoFunction(ctx context.Context, arg funcArgs) error {
 	span := otrace.FromContext(ctx)
 	stop := x.SpanTimer(span, "filterGeoFunction")
@@ -1303,9 +1418,15 @@ type functionContext struct {
 	fname          string
 	fnType         FuncType
 	regex          *cregexp.Regexp
-	isFuncAtRoot   bool
-	isStringFn     bool
-	atype          types.TypeID
+	// vector and distance are used by similar_to, along with the number of neighbours to find
+	// in threshold.
+	vector   []float32
+	distance string
+	// prefix is the value starts_with looks for.
+	prefix       string
+	isFuncAtRoot bool
+	isStringFn   bool
+	atype        types.TypeID
 }
 
 const (
@@ -1420,12 +1541,14 @@ func parseSrcFn(ctx context.Context, q *pb.Query) (*functionContext, error) {
 		// from data keys directly and compare. Lets make tokens empty.
 		// We don't do this for eq because eq could have multiple arguments and we would have to
 		// compare the value with all of them. Also eq would usually have less arguments, hence we
-		// won't be fetching many index keys.
+		// won't be fetching many index keys. Nor for the case-insensitive indexes, whose tokens
+		// can't be compared with the values.
 		c := int(codec.ListCardinality(q.UidList))
 		switch {
 		case q.UidList != nil && !isIndexedAttr:
 			fc.n = c
-		case q.UidList != nil && len(fc.tokens) > c && fc.fname != eq:
+		case q.UidList != nil && len(fc.tokens) > c && fc.fname != eq &&
+			!usesCaseInsensitiveIndex(ctx, attr, f):
 			fc.tokens = fc.tokens[:0]
 			fc.n = c
 		default:
@@ -1502,6 +1625,38 @@ func parseSrcFn(ctx context.Context, q *pb.Query) (*functionContext, error) {
 		fc.threshold = []int64{int64(max)}
 		fc.tokens = q.SrcFunc.Args
 		fc.n = len(fc.tokens)
+	case prefixFn:
+		if err = ensureArgsCount(q.SrcFunc, 1); err != nil {
+			return nil, err
+		}
+		if !fc.isStringFn {
+			return nil, errors.Errorf("Attribute %s is not of type string, starts_with "+
+				"can only be applied to strings", x.ParseAttr(attr))
+		}
+		var tokenizer tok.Tokenizer
+		switch {
+		case schema.State().HasTokenizer(ctx, tok.IdentExact, attr):
+			tokenizer = tok.ExactTokenizer{}
+		case schema.State().HasTokenizer(ctx, tok.IdentExactCI, attr):
+			tokenizer = tok.ExactCITokenizer{}
+		default:
+			return nil, errors.Errorf("Attribute %s is not indexed with type exact",
+				x.ParseAttr(attr))
+		}
+		fc.prefix = q.SrcFunc.Args[0]
+		if fc.tokens, err = getPrefixTokens(q.ReadTs, attr, fc.prefix, tokenizer); err != nil {
+			return nil, err
+		}
+		// Like for the inequality functions, if there are more values starting with the
+		// prefix than uids to filter, the values of the uids are checked instead. That's
+		// unless the index is case-insensitive.
+		c := int(codec.ListCardinality(q.UidList))
+		if q.UidList != nil && len(fc.tokens) > c && !tok.IsCaseInsensitive(tokenizer) {
+			fc.tokens = fc.tokens[:0]
+			fc.n = c
+		} else {
+			fc.n = len(fc.tokens)
+		}
 	case customIndexFn:
 		if err = ensureArgsCount(q.SrcFunc, 2); err != nil {
 			return nil, err
@@ -1548,6 +1703,45 @@ func parseSrcFn(ctx context.Context, q *pb.Query) (*functionContext, error) {
 			return nil, err
 		}
 		checkRoot(q, fc)
+	case histogramFn:
+		if err = ensureArgsCount(q.SrcFunc, 1); err != nil {
+			return nil, err
+		}
+		tokenizer, err := pickHistogramTokenizer(ctx, attr, q.SrcFunc.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if fc.tokens, err = getHistogramTokens(q.ReadTs, attr, tokenizer); err != nil {
+			return nil, err
+		}
+		fc.n = len(fc.tokens)
+	case similarFn:
+		// similar_to(pred, topK, "[0.1, 0.2]") takes the distance as an optional third argument.
+		args := q.SrcFunc.Args
+		if len(args) != 2 && len(args) != 3 {
+			return nil, errors.Errorf("Function '%s' requires 2 or 3 arguments, but got %d (%v)",
+				q.SrcFunc.Name, len(args), args)
+		}
+		topK, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil || topK <= 0 {
+			return nil, errors.Errorf("The number of neighbours to find must be a positive int, "+
+				"got %v", args[0])
+		}
+		fc.threshold = []int64{topK}
+		if fc.vector, err = types.ParseVFloat(args[1]); err != nil {
+			return nil, err
+		}
+		fc.distance = types.EuclideanDistance
+		if len(args) == 3 {
+			fc.distance = strings.ToLower(args[2])
+			if !types.IsVectorDistance(fc.distance) {
+				return nil, errors.Errorf("Invalid distance %q. It should be %s or %s", args[2],
+					types.EuclideanDistance, types.CosineDistance)
+			}
+		}
+		// The neighbours are found by handleSimilarFunction, not from the uid postings.
+		fc.isFuncAtRoot = q.UidList == nil
+		fc.n = 0
 	case uidInFn:
 		var uids []uint64
 		for _, arg := range q.SrcFunc.Args {
@@ -71,19 +71,27 @@ func pickTokenizer(ctx context.Context, attr string, f string) (tok.Tokenizer, e
 	if tokenizers == nil {
 		return nil, errors.Errorf("Schema state not found for %s.", attr)
 	}
-	for _, t := range tokenizers {
+	suits := func(t tok.Tokenizer) bool {
 		// If function is eq and we found a tokenizer that's !Lossy(), lets return it
 		switch f {
 		case "eq":
 			// For equality, find a non-lossy tokenizer.
-			if !t.IsLossy() {
-				return t, nil
-			}
+			return !t.IsLossy()
 		default:
 			// rest of the cases: ge, gt, le, lt require a sortable tokenizer.
-			if t.IsSortable() {
-				return t, nil
-			}
+			return t.IsSortable()
+		}
+	}
+	// The case-insensitive tokenizers are only picked if there's no other one, so that eq stays
+	// case-sensitive on the predicates which have both a hash and a hash_ci index.
+	for _, t := range tokenizers {
+		if suits(t) && !tok.IsCaseInsensitive(t) {
+			return t, nil
+		}
+	}
+	for _, t := range tokenizers {
+		if suits(t) {
+			return t, nil
 		}
 	}
 
@@ -106,6 +114,14 @@ func pickTokenizer(ctx context.Context, attr string, f string) (tok.Tokenizer, e
 	return tokenizers[0], nil
 }
 
+// usesCaseInsensitiveIndex returns whether the function f on attr is evaluated over a
+// case-insensitive index. The values of such an index can't be compared directly with the
+// arguments, so the index has to be used even when filtering a few uids.
+func usesCaseInsensitiveIndex(ctx context.Context, attr, f string) bool {
+	tokenizer, err := pickTokenizer(ctx, attr, f)
+	return err == nil && tok.IsCaseInsensitive(tokenizer)
+}
+
 // getInequalityTokens gets tokens ge/le/between compared to given tokens using the first sortable
 // index that is found for the predicate.
 // In case of ge/gt/le/lt/eq len(ineqValues) should be 1, else(between) len(ineqValues) should be 2.
@@ -213,3 +229,112 @@ LOOP:
 
 	return out, ineqTokensFinal, nil
 }
+
+// getPrefixTokens returns the tokens of the given exact index of attr which start with the
+// given prefix, in sorted order. The exact tokens are the values themselves, so that's a seek
+// to the prefix followed by a scan over the keys sharing it.
+func getPrefixTokens(readTs uint64, attr, prefix string,
+	tokenizer tok.Tokenizer) ([]string, error) {
+
+	tokens, err := tok.BuildTokens(prefix, tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like for the inequality tokens, the index keys written by ongoing transactions aren't
+	// looked up.
+	txn := pstore.NewReadTxn(readTs)
+	defer txn.Discard()
+
+	itOpt := badger.DefaultIteratorOptions
+	itOpt.PrefetchValues = false
+	itOpt.Prefix = x.IndexKey(attr, tokens[0])
+	itr := txn.NewIterator(itOpt)
+	defer itr.Close()
+
+	var out []string
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		k, err := x.Parse(itr.Item().Key())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k.Term)
+	}
+	return out, nil
+}
+
+// histogramIntervals are the intervals of the datetime tokenizers, from the finest to the
+// coarsest.
+var histogramIntervals = []string{"hour", "day", "month", "year"}
+
+// pickHistogramTokenizer picks the index of attr whose tokens can be rolled up into buckets
+// of the given interval. For a datetime predicate, that's the coarsest of its datetime
+// indexes which isn't coarser than the interval. For an int predicate, that's the int
+// index, and the interval is left to the caller.
+func pickHistogramTokenizer(ctx context.Context, attr, interval string) (tok.Tokenizer, error) {
+	if !schema.State().IsIndexed(ctx, attr) {
+		return nil, errors.Errorf("Attribute %s is not indexed.", x.ParseAttr(attr))
+	}
+	typ, err := schema.State().TypeOf(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case types.TypeInt64:
+		for _, t := range schema.State().Tokenizer(ctx, attr) {
+			if t.Identifier() == tok.IdentInt {
+				return t, nil
+			}
+		}
+		return nil, errors.Errorf("Attribute %s is not indexed with type int",
+			x.ParseAttr(attr))
+	case types.TypeDatetime:
+		idx := -1
+		for i, name := range histogramIntervals {
+			if name == interval {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return nil, errors.Errorf("Invalid interval %q for datetime attribute %s. "+
+				"It should be one of %v", interval, x.ParseAttr(attr), histogramIntervals)
+		}
+		tokenizers := schema.State().Tokenizer(ctx, attr)
+		for i := idx; i >= 0; i-- {
+			for _, t := range tokenizers {
+				if t.Name() == histogramIntervals[i] {
+					return t, nil
+				}
+			}
+		}
+		return nil, errors.Errorf("Attribute %s doesn't have an index of %s or a finer "+
+			"interval", x.ParseAttr(attr), interval)
+	default:
+		return nil, errors.Errorf("Histogram can only be computed over int and datetime "+
+			"attributes. Got: %s of type %s", x.ParseAttr(attr), typ)
+	}
+}
+
+// getHistogramTokens returns all the tokens of the given index of attr, in sorted order.
+// Only the keys are read, not the posting lists.
+func getHistogramTokens(readTs uint64, attr string, tokenizer tok.Tokenizer) ([]string, error) {
+	txn := pstore.NewReadTxn(readTs)
+	defer txn.Discard()
+
+	itOpt := badger.DefaultIteratorOptions
+	itOpt.PrefetchValues = false
+	itOpt.Prefix = x.IndexKey(attr, string(tokenizer.Identifier()))
+	itr := txn.NewIterator(itOpt)
+	defer itr.Close()
+
+	var out []string
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		k, err := x.Parse(itr.Item().Key())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k.Term)
+	}
+	return out, nil
+}
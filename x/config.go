@@ -45,6 +45,16 @@ type Options struct {
 	// extensions bool - Will be set to see extensions in GraphQL results
 	// debug bool - Will enable debug mode in GraphQL.
 	// poll-interval duration - The polling interval for graphql subscription.
+	// push-subscriptions bool - Re-run graphql subscriptions on the commits touching the
+	// 		predicates they read, instead of every poll-interval.
+	// persisted-queries-only bool - Only execute the queries added via addPersistedQueries.
+	// max-cost int64 - The maximum estimated cost of an operation, if non-zero.
+	// custom-cost int64 - The cost of a field resolved via @custom or @lambda.
+	// rate-limit int64 - The cost each client can spend per rate-interval, if non-zero.
+	// rate-interval duration - The interval after which the clients get their rate-limit back.
+	// rate-key string - What identifies a client: ip, header:<name> or claim:<name>.
+	// rate-trust-header bool - Use the rate-key header, which clients can set to anything.
+	// cache-mb int64 - The size of the cache for the responses of queries with @cacheControl.
 	GraphQL GraphQLOptions
 
 	// Lambda options:
@@ -69,6 +79,27 @@ type GraphQLOptions struct {
 	Debug         bool
 	Extensions    bool
 	PollInterval  time.Duration
+	// PushSubscriptions is true if the subscriptions are re-run on commits, instead of polling.
+	PushSubscriptions bool
+	// PersistedQueriesOnly is true if /graphql only executes the persisted queries added via
+	// the addPersistedQueries admin mutation.
+	PersistedQueriesOnly bool
+	// MaxCost is the maximum estimated cost of an operation. It's not limited if zero.
+	MaxCost int64
+	// CustomCost is the cost of a field resolved via @custom or @lambda. Other fields cost 1.
+	CustomCost int64
+	// RateLimit is the cost that a client can spend per RateInterval. It's not limited if zero.
+	RateLimit    int64
+	RateInterval time.Duration
+	// RateKey identifies the clients for RateLimit. It's either ip, header:<name> or
+	// claim:<name>, the latter ones falling back to the IP if the header or claim isn't set.
+	RateKey string
+	// RateTrustHeader is true if the header of RateKey is set by a trusted proxy in front of
+	// the server. Clients can set it to anything, so the IP is used instead if it's false.
+	RateTrustHeader bool
+	// CacheMb is the size of the cache for the responses of the queries with @cacheControl. The
+	// responses aren't cached if zero.
+	CacheMb int64
 }
 
 type LambdaOptions struct {
@@ -118,6 +149,8 @@ type WorkerOptions struct {
 	TLSServerConfig *tls.Config
 	// Raft stores options related to Raft.
 	Raft *z.SuperFlag
+	// Rebalance stores options related to moving tablets across groups.
+	Rebalance *z.SuperFlag
 	// Badger stores the badger options.
 	Badger badger.Options
 	// WhiteListedIPRanges is a list of IP ranges from which requests will be allowed.
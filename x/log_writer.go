@@ -197,6 +197,42 @@ func decrypt(key []byte, baseIv [12]byte, src []byte) ([]byte, error) {
 	return src, nil
 }
 
+// DecryptLog reads back a log written by a LogWriter with an encryption key. Each chunk in the
+// log is preceded by its length, which is also part of the IV used to encrypt the chunk. The
+// verification text at the start of the log is checked, to catch a wrong key.
+func DecryptLog(key []byte, src io.Reader, dst io.Writer) error {
+	r := bufio.NewReaderSize(src, bufferSize)
+	var baseIv [12]byte
+	if _, err := io.ReadFull(r, baseIv[:]); err != nil {
+		return fmt.Errorf("unable to read the IV: %v", err)
+	}
+
+	var lenBuf [4]byte
+	for first := true; ; first = false {
+		if _, err := io.ReadFull(r, lenBuf[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read the length of the chunk: %v", err)
+		}
+		chunk := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return fmt.Errorf("unable to read the chunk: %v", err)
+		}
+		if _, err := decrypt(key, baseIv, chunk); err != nil {
+			return err
+		}
+		if first {
+			if string(chunk) != VerificationText {
+				return fmt.Errorf("unable to decrypt the log, the key is probably wrong")
+			}
+			continue
+		}
+		if _, err := dst.Write(chunk); err != nil {
+			return err
+		}
+	}
+}
+
 func (l *LogWriter) rotate() error {
 	if l == nil {
 		return nil
@@ -121,6 +121,38 @@ func TestLogWriterWithEncryption(t *testing.T) {
 	}
 }
 
+func TestDecryptLog(t *testing.T) {
+	path, _ := filepath.Abs("./log_test/audit.log.enc")
+	defer os.RemoveAll(filepath.Dir(path))
+	lw := &LogWriter{
+		FilePath:      path,
+		MaxSize:       1,
+		MaxAge:        1,
+		EncryptionKey: []byte("1234567890123456"),
+	}
+	lw, err := lw.Init()
+	require.NoError(t, err)
+
+	var expected bytes.Buffer
+	for i := 0; i < 100; i++ {
+		line := []byte(strings.Repeat("a", i) + "\n")
+		expected.Write(line)
+		_, err := lw.Write(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, lw.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, DecryptLog(lw.EncryptionKey, bytes.NewReader(data), &out))
+	require.Equal(t, expected.String(), out.String())
+
+	// A wrong key gets caught by the verification text.
+	out.Reset()
+	require.Error(t, DecryptLog([]byte("6543210987654321"), bytes.NewReader(data), &out))
+}
+
 func writeToLogWriterAndVerify(t *testing.T, lw *LogWriter, path string) {
 	msg := []byte("abcd")
 	msg = bytes.Repeat(msg, 256)
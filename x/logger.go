@@ -31,6 +31,8 @@ type LoggerConf struct {
 	Size          int64
 	Days          int64
 	MessageKey    string
+	// Redact holds the names of the variables whose values must not be logged.
+	Redact []string
 }
 
 func InitLogger(conf *LoggerConf, filename string) (*Logger, error) {
@@ -0,0 +1,23 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package x
+
+import "context"
+
+// ProgressFunc is told about the progress of a long running task, as the work done out of the
+// total work. The total is zero if it isn't known yet.
+type ProgressFunc func(done, total uint64)
+
+type progressKey struct{}
+
+// WithProgress returns a context, which reports the progress of the work done with it to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress reports the progress of the work done with ctx, if anything is listening.
+func ReportProgress(ctx context.Context, done, total uint64) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(done, total)
+	}
+}
@@ -1257,11 +1257,11 @@ func NewRateLimiter(maxTokens int64, refillAfter time.Duration, closer *z.Closer
 	return r
 }
 
-// Allow checks if the request for req number of tokens can be allowed for a given namespace.
-// If request is allowed, it subtracts the req from the available tokens.
-func (r *RateLimiter) Allow(ns uint64, req int64) bool {
+// Allow checks if the request for req number of tokens can be allowed for a given key, like a
+// namespace. If request is allowed, it subtracts the req from the available tokens.
+func (r *RateLimiter) Allow(key interface{}, req int64) bool {
 	v := r.maxTokens
-	val, _ := r.limiter.LoadOrStore(ns, &v)
+	val, _ := r.limiter.LoadOrStore(key, &v)
 	ptr := val.(*int64)
 	if cnt := atomic.AddInt64(ptr, -req); cnt < 0 {
 		atomic.AddInt64(ptr, req)
@@ -1270,12 +1270,14 @@ func (r *RateLimiter) Allow(ns uint64, req int64) bool {
 	return true
 }
 
-// RefillPeriodically refills the tokens of all the namespaces to maxTokens periodically .
+// RefillPeriodically refills the tokens of all the keys to maxTokens periodically. The keys
+// are dropped instead, as Allow starts them at maxTokens. So, the keys which weren't seen in
+// the last interval don't take up memory.
 func (r *RateLimiter) RefillPeriodically() {
 	defer r.closer.Done()
 	refill := func() {
-		r.limiter.Range(func(_, val interface{}) bool {
-			atomic.StoreInt64(val.(*int64), r.maxTokens)
+		r.limiter.Range(func(key, _ interface{}) bool {
+			r.limiter.Delete(key)
 			return true
 		})
 	}
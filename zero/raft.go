@@ -194,6 +194,10 @@ func (n *node) applyProposal(e raftpb.Entry) error {
 		// Keep the first 16 for special purposes.
 		dst.MaxUID = 16
 	}
+	if dst.MaxNsID == 0 {
+		// Namespace zero is the galaxy namespace, which always exists.
+		dst.MaxNsID = 1
+	}
 
 	if len(p.Cid) > 0 {
 		if len(dst.Cid) > 0 {
@@ -217,6 +221,9 @@ func (n *node) applyProposal(e raftpb.Entry) error {
 		}
 		glog.Infof("Proposal applied: %+v\n", p.Tablets)
 	}
+	if p.DeleteNs != nil {
+		n.handleDeleteNsProposal(dst, p.DeleteNs.Namespace)
+	}
 
 	switch {
 	case p.NumUids > 0:
@@ -238,6 +245,18 @@ func (n *node) applyProposal(e raftpb.Entry) error {
 	return nil
 }
 
+// handleDeleteNsProposal removes the tablets of all the predicates in the deleted namespace.
+func (n *node) handleDeleteNsProposal(dst *pb.MembershipState, ns uint64) {
+	n.state.AssertLock()
+
+	glog.Infof("Removing the tablets of namespace: %#x\n", ns)
+	for pred := range dst.Tablets {
+		if x.ParseNamespace(pred) == ns {
+			delete(dst.Tablets, pred)
+		}
+	}
+}
+
 func (n *node) handleMemberProposal(dst *pb.MembershipState, member *pb.Member) error {
 	n.state.AssertLock()
 
@@ -290,9 +309,13 @@ func (n *node) handleTabletProposal(dst *pb.MembershipState, tablets []*pb.Table
 		// Two servers ask to serve the same tablet, then we need to ensure that
 		// only the first one succeeds.
 		//
+		// A tablet can only change groups at the end of a move, which is when the tablet is
+		// read-only and gets a newer MoveTs.
+		//
 		// TODO: Do we need tablet.Force?
 		if prev := dst.Tablets[cur.Predicate]; prev != nil {
-			if prev.GroupId != cur.GroupId || prev.Predicate != cur.Predicate {
+			moved := prev.ReadOnly && cur.MoveTs > prev.MoveTs
+			if (prev.GroupId != cur.GroupId && !moved) || prev.Predicate != cur.Predicate {
 				return fmt.Errorf("Tablet %s is already served. Prev: %+v New: %+v\n",
 					cur.Predicate, prev, cur)
 			}
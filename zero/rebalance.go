@@ -0,0 +1,316 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package zero
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dustin/go-humanize"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/outcaste-io/outserv/conn"
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/outcaste-io/ristretto/z"
+	"github.com/pkg/errors"
+)
+
+// tabletMove is a move of a tablet to another group, as planned by the rebalancer.
+type tabletMove struct {
+	tablet *pb.Tablet
+	dstGid uint32
+}
+
+// groupSizes returns the sizes on disk of the groups with members, summed up from the sizes of
+// their tablets, which the group leaders report periodically.
+func groupSizes(st *pb.MembershipState) map[uint32]int64 {
+	sizes := make(map[uint32]int64)
+	for _, m := range st.Members {
+		if m.GroupId > 0 {
+			sizes[m.GroupId] = 0
+		}
+	}
+	for _, tablet := range st.Tablets {
+		if _, ok := sizes[tablet.GroupId]; ok {
+			sizes[tablet.GroupId] += tablet.OnDiskBytes
+		}
+	}
+	return sizes
+}
+
+// planTabletMove picks the tablet to move, to even out the sizes of the groups. It looks at the
+// largest group and the smallest one, and only moves a tablet if the largest one is at least
+// minBytes, and at least ratio times the smallest one. Out of the tablets of the largest group,
+// it picks the largest one which would leave the groups closer in size. It returns nil if there's
+// nothing to move.
+func planTabletMove(st *pb.MembershipState, ratio float64, minBytes int64) *tabletMove {
+	sizes := groupSizes(st)
+	if len(sizes) < 2 {
+		return nil
+	}
+	var src, dst uint32
+	for gid, size := range sizes {
+		if src == 0 || size > sizes[src] || (size == sizes[src] && gid < src) {
+			src = gid
+		}
+		if dst == 0 || size < sizes[dst] || (size == sizes[dst] && gid < dst) {
+			dst = gid
+		}
+	}
+	if src == dst || sizes[src] < minBytes ||
+		float64(sizes[src]) < ratio*float64(sizes[dst]) {
+		return nil
+	}
+
+	var candidates []*pb.Tablet
+	for _, tablet := range st.Tablets {
+		switch {
+		case tablet.GroupId != src || tablet.ReadOnly || tablet.OnDiskBytes == 0:
+		case x.IsReservedPredicate(tablet.Predicate):
+			// Group 1 always serves the reserved predicates.
+		case sizes[dst]+tablet.OnDiskBytes >= sizes[src]:
+			// Moving it would just make the smaller group the larger one.
+		default:
+			candidates = append(candidates, tablet)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].OnDiskBytes != candidates[j].OnDiskBytes {
+			return candidates[i].OnDiskBytes > candidates[j].OnDiskBytes
+		}
+		return candidates[i].Predicate < candidates[j].Predicate
+	})
+	return &tabletMove{tablet: candidates[0], dstGid: dst}
+}
+
+// writableTablets returns writable copies of the tablets which are read-only in the state, sorted
+// by their predicates.
+func writableTablets(st *pb.MembershipState) []*pb.Tablet {
+	var tablets []*pb.Tablet
+	for _, tablet := range st.Tablets {
+		if tablet.ReadOnly {
+			t := proto.Clone(tablet).(*pb.Tablet)
+			t.ReadOnly = false
+			tablets = append(tablets, t)
+		}
+	}
+	sort.Slice(tablets, func(i, j int) bool {
+		return tablets[i].Predicate < tablets[j].Predicate
+	})
+	return tablets
+}
+
+// GroupChecksum returns a checksum of the tablets served by the group in the membership state.
+// Alphas keep the one of the latest state they've seen, so the deletion of a moved tablet can
+// wait until all the members of its old group know that they no longer serve it.
+func GroupChecksum(st *pb.MembershipState, gid uint32) uint64 {
+	var preds []string
+	for pred, tablet := range st.Tablets {
+		if tablet.GroupId == gid {
+			preds = append(preds, pred)
+		}
+	}
+	sort.Strings(preds)
+	d := xxhash.New()
+	for _, pred := range preds {
+		_, _ = d.WriteString(pred)
+		_, _ = d.Write([]byte{0})
+	}
+	return d.Sum64()
+}
+
+// groupLeader returns the leader of the group, or nil if it doesn't have one.
+func groupLeader(st *pb.MembershipState, gid uint32) *pb.Member {
+	for _, m := range st.Members {
+		if m.GroupId == gid && m.Leader {
+			return m
+		}
+	}
+	return nil
+}
+
+// movePredicate asks the leader of the source group to send the predicate to the destination
+// group, or to delete it if there's none. It returns the timestamp the predicate was sent at.
+func movePredicate(ctx context.Context, in *pb.MovePredicatePayload) (uint64, error) {
+	leader := groupLeader(MembershipState(), in.SourceGid)
+	if leader == nil {
+		return 0, errors.Errorf("No leader found for group: %d", in.SourceGid)
+	}
+	pl, err := conn.GetPools().Get(leader.Addr)
+	if err != nil {
+		return 0, errors.Wrapf(err, "while connecting to the leader of group: %d", in.SourceGid)
+	}
+	c := pb.NewWorkerClient(pl.Get())
+	payload, err := c.MovePredicate(ctx, in)
+	switch {
+	case err != nil:
+		return 0, err
+	case len(payload.GetData()) == 0:
+		// Deleting a predicate doesn't send back a timestamp.
+		return 0, nil
+	}
+	return strconv.ParseUint(string(payload.Data), 10, 64)
+}
+
+// moveTablet moves the tablet to the group dstGid. The data is sent over in two phases. The
+// first one sends it all, while the tablet still takes writes. Then the tablet is made read-only,
+// and the second phase sends what got written since the first one, once the source group has
+// fenced the tablet off in its Raft log, and applied the writes before the fence. Once the tablet is served by the destination group, the source group
+// deletes its copy, after all its members have seen the tablet go.
+func (n *node) moveTablet(ctx context.Context, tablet *pb.Tablet, dstGid uint32) error {
+	pred, srcGid := tablet.Predicate, tablet.GroupId
+	proposeTablet := func(update func(t *pb.Tablet)) error {
+		t := proto.Clone(tablet).(*pb.Tablet)
+		update(t)
+		_, err := n.proposeAndWait(ctx, &pb.ZeroProposal{Tablets: []*pb.Tablet{t}})
+		return err
+	}
+
+	in := &pb.MovePredicatePayload{Predicate: pred, SourceGid: srcGid, DestGid: dstGid}
+	sinceTs, err := movePredicate(ctx, in)
+	if err != nil {
+		return errors.Wrapf(err, "while sending predicate %q", pred)
+	}
+
+	if err := proposeTablet(func(t *pb.Tablet) { t.ReadOnly = true }); err != nil {
+		return errors.Wrapf(err, "while making tablet %q read-only", pred)
+	}
+	in.SinceTs = sinceTs
+	moveTs, err := movePredicate(ctx, in)
+	if err == nil {
+		err = proposeTablet(func(t *pb.Tablet) {
+			t.GroupId = dstGid
+			t.MoveTs = moveTs
+		})
+	}
+	if err != nil {
+		// Let the source group take writes again.
+		if rerr := proposeTablet(func(t *pb.Tablet) { t.ReadOnly = false }); rerr != nil {
+			glog.Errorf("While making tablet %q writable again: %v", pred, rerr)
+		}
+		return errors.Wrapf(err, "while moving tablet %q", pred)
+	}
+
+	// The tablet is now served by the destination group. Delete it from the source group.
+	in = &pb.MovePredicatePayload{
+		Predicate:        pred,
+		SourceGid:        srcGid,
+		ReadTs:           moveTs,
+		ExpectedChecksum: GroupChecksum(MembershipState(), srcGid),
+	}
+	if _, err := movePredicate(ctx, in); err != nil {
+		return errors.Wrapf(err, "while deleting predicate %q from group: %d", pred, srcGid)
+	}
+	return nil
+}
+
+// releaseTablets makes the tablets left read-only by an unfinished move writable again. Moves run
+// one at a time, and are done or failed before the next rebalance. So, a tablet which is read-only
+// when no move is running was left behind: by a move which failed to make it writable again, or by
+// a leader which lost its leadership midway. If that old leader goes on with the move, it fails to
+// hand the tablet over, as the tablet isn't read-only anymore.
+func (n *node) releaseTablets(ctx context.Context) error {
+	st, err := LatestMembershipState(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "while getting latest membership state")
+	}
+	tablets := writableTablets(st)
+	if len(tablets) == 0 {
+		return nil
+	}
+	for _, t := range tablets {
+		glog.Infof("Making tablet %q of group %d writable again, after an unfinished move\n",
+			t.Predicate, t.GroupId)
+	}
+	_, err = n.proposeAndWait(ctx, &pb.ZeroProposal{Tablets: tablets})
+	return errors.Wrapf(err, "while making tablets writable again")
+}
+
+// rebalanceTablets periodically moves a tablet from the largest group to the smallest one, if
+// their sizes are too far apart. It's configured via the rebalance superflag. In dry-run mode,
+// it only logs the moves it would make. Even with rebalancing disabled, a new leader releases the
+// tablets left read-only by the moves of the previous one.
+func (n *node) rebalanceTablets(closer *z.Closer) {
+	defer closer.Done()
+
+	conf := x.WorkerConfig.Rebalance
+	interval := conf.GetDuration("interval")
+	ratio := conf.GetFloat64("ratio")
+	minBytes := conf.GetInt64("min-size-mb") << 20
+	dryRun := conf.GetBool("dry-run")
+
+	rebalance := func() error {
+		if !n.amLeader() {
+			return nil
+		}
+		if err := n.releaseTablets(closer.Ctx()); err != nil {
+			return err
+		}
+		st, err := LatestMembershipState(closer.Ctx())
+		if err != nil {
+			return errors.Wrapf(err, "while getting latest membership state")
+		}
+		move := planTabletMove(st, ratio, minBytes)
+		if move == nil {
+			return nil
+		}
+		msg := "Moving"
+		if dryRun {
+			msg = "Dry run. Would move"
+		}
+		glog.Infof("%s tablet %q of size %s from group %d to group %d\n", msg,
+			move.tablet.Predicate, humanize.IBytes(uint64(move.tablet.OnDiskBytes)),
+			move.tablet.GroupId, move.dstGid)
+		if dryRun {
+			return nil
+		}
+
+		start := time.Now()
+		if err := n.moveTablet(closer.Ctx(), move.tablet, move.dstGid); err != nil {
+			return err
+		}
+		glog.Infof("Moved tablet %q to group %d. Took: %s\n",
+			move.tablet.Predicate, move.dstGid, time.Since(start).Round(time.Millisecond))
+		return nil
+	}
+
+	var rebalanceCh <-chan time.Time
+	if interval == 0 {
+		glog.Infof("Tablet rebalancing is disabled.")
+	} else {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		rebalanceCh = tick.C
+	}
+	leaderTick := time.NewTicker(10 * time.Second)
+	defer leaderTick.Stop()
+
+	var wasLeader bool
+	for {
+		select {
+		case <-leaderTick.C:
+			isLeader := n.amLeader()
+			if isLeader && !wasLeader {
+				if err := n.releaseTablets(closer.Ctx()); err != nil {
+					// Retry on the next tick.
+					glog.Errorf("While releasing tablets: %v", err)
+					continue
+				}
+			}
+			wasLeader = isLeader
+		case <-rebalanceCh:
+			if err := rebalance(); err != nil {
+				glog.Errorf("While rebalancing tablets: %v", err)
+			}
+		case <-closer.HasBeenClosed():
+			return
+		}
+	}
+}
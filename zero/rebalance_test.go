@@ -0,0 +1,107 @@
+// Copyright 2022 Outcaste LLC. Licensed under the Sustainable License v1.0.
+
+package zero
+
+import (
+	"testing"
+
+	"github.com/outcaste-io/outserv/protos/pb"
+	"github.com/outcaste-io/outserv/x"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanTabletMove(t *testing.T) {
+	tablet := func(gid uint32, attr string, mb int64) *pb.Tablet {
+		return &pb.Tablet{
+			GroupId:     gid,
+			Predicate:   x.GalaxyAttr(attr),
+			OnDiskBytes: mb << 20,
+		}
+	}
+	state := func(tablets ...*pb.Tablet) *pb.MembershipState {
+		st := &pb.MembershipState{
+			Members: map[uint64]*pb.Member{
+				1: {Id: 1, GroupId: 1, Leader: true},
+				2: {Id: 2, GroupId: 2, Leader: true},
+				3: {Id: 3, GroupId: 3, Leader: true},
+			},
+			Tablets: make(map[string]*pb.Tablet),
+		}
+		for _, t := range tablets {
+			st.Tablets[t.Predicate] = t
+		}
+		return st
+	}
+
+	// The largest tablet which leaves the groups closer in size is moved to the smallest group.
+	st := state(tablet(1, "name", 300), tablet(1, "age", 200), tablet(1, "friend", 100),
+		tablet(2, "email", 150))
+	move := planTabletMove(st, 1.5, 64<<20)
+	require.Equal(t, x.GalaxyAttr("name"), move.tablet.Predicate)
+	require.Equal(t, uint32(3), move.dstGid)
+
+	// A tablet isn't moved if that would just make the smaller group the larger one.
+	st = state(tablet(1, "name", 600), tablet(1, "age", 10), tablet(2, "email", 300),
+		tablet(3, "phone", 300))
+	move = planTabletMove(st, 1.5, 64<<20)
+	require.Equal(t, x.GalaxyAttr("age"), move.tablet.Predicate)
+	require.Equal(t, uint32(2), move.dstGid)
+
+	// The groups are balanced enough.
+	st = state(tablet(1, "name", 140), tablet(2, "email", 100), tablet(3, "phone", 100))
+	require.True(t, planTabletMove(st, 1.5, 64<<20) == nil)
+
+	// The largest group is too small to bother.
+	st = state(tablet(1, "name", 32))
+	require.True(t, planTabletMove(st, 1.5, 64<<20) == nil)
+
+	// Reserved predicates and tablets being moved stay where they are.
+	moving := tablet(1, "name", 300)
+	moving.ReadOnly = true
+	st = state(moving, tablet(1, "dgraph.type", 200))
+	require.True(t, planTabletMove(st, 1.5, 64<<20) == nil)
+}
+
+func TestWritableTablets(t *testing.T) {
+	st := &pb.MembershipState{Tablets: map[string]*pb.Tablet{
+		"name":  {GroupId: 1, Predicate: "name", ReadOnly: true, MoveTs: 5},
+		"age":   {GroupId: 2, Predicate: "age", ReadOnly: true},
+		"email": {GroupId: 2, Predicate: "email"},
+	}}
+	require.Equal(t, []*pb.Tablet{
+		{GroupId: 2, Predicate: "age"},
+		{GroupId: 1, Predicate: "name", MoveTs: 5},
+	}, writableTablets(st))
+	// The state itself is left as is.
+	require.True(t, st.Tablets["name"].ReadOnly)
+
+	// Once a tablet is released, the handover of a move which went on regardless fails.
+	n := &node{state: &State{}}
+	n.state.Lock()
+	defer n.state.Unlock()
+	require.NoError(t, n.handleTabletProposal(st, writableTablets(st)))
+	require.Empty(t, writableTablets(st))
+	moved := &pb.Tablet{GroupId: 2, Predicate: "name", MoveTs: 10}
+	require.Error(t, n.handleTabletProposal(st, []*pb.Tablet{moved}))
+	require.Equal(t, uint32(1), st.Tablets["name"].GroupId)
+}
+
+func TestGroupChecksum(t *testing.T) {
+	st := &pb.MembershipState{Tablets: map[string]*pb.Tablet{
+		"name":  {GroupId: 1, Predicate: "name"},
+		"age":   {GroupId: 1, Predicate: "age"},
+		"email": {GroupId: 2, Predicate: "email"},
+	}}
+	before := GroupChecksum(st, 1)
+	require.Equal(t, before, GroupChecksum(st, 1))
+	require.NotEqual(t, before, GroupChecksum(st, 2))
+
+	// Only the tablets of the group count, not their sizes.
+	st.Tablets["name"].OnDiskBytes = 100
+	st.Tablets["email"].GroupId = 3
+	require.Equal(t, before, GroupChecksum(st, 1))
+
+	// Moving a tablet out of the group changes its checksum.
+	st.Tablets["age"].GroupId = 2
+	require.NotEqual(t, before, GroupChecksum(st, 1))
+}
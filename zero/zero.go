@@ -56,9 +56,6 @@ func NewState() *State {
 	// 	s.rateLimiter = x.NewRateLimiter(int64(opts.limiterConfig.UidLeaseLimit),
 	// 		opts.limiterConfig.RefillAfter, s.closer)
 	// }
-
-	// TODO: Add functionality to rebalance types.
-	// go s.rebalanceTablets()
 	return s
 }
 
@@ -262,7 +259,7 @@ func Run(closer *z.Closer, bindall bool) {
 	cn := conn.NewNode(&rc, store, x.WorkerConfig.TLSClientConfig)
 	conn.UpdateNode(rc.WhoIs, cn)
 
-	nodeCloser := z.NewCloser(3)
+	nodeCloser := z.NewCloser(4)
 	go x.MonitorDiskMetrics("wal_fs", wdir, nodeCloser)
 	go func() {
 		defer closer.Done()
@@ -283,6 +280,7 @@ func Run(closer *z.Closer, bindall bool) {
 		state:  NewState(),
 	}
 	go inode.periodicallyProposeUsage(nodeCloser)
+	go inode.rebalanceTablets(nodeCloser)
 	x.Check(inode.initAndStartNode())
 }
 
@@ -322,6 +320,27 @@ func BumpMaxUid(ctx context.Context, maxUid uint64) error {
 	}
 }
 
+func BumpMaxNsid(ctx context.Context, maxNsid uint64) error {
+	for {
+		ms, err := LatestMembershipState(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "while retrieving latest membership state")
+		}
+		if ms.MaxNsID > maxNsid {
+			return nil
+		}
+		ask := uint32(maxNsid + 1 - ms.MaxNsID)
+		if maxNsid-ms.MaxNsID >= math.MaxUint32 {
+			ask = uint32(math.MaxUint32)
+		}
+		glog.Infof("ms.MaxNsID: %#x maxNsid: %#x Assigning %#x namespace IDs\n",
+			ms.MaxNsID, maxNsid, ask)
+		if _, err := AssignNsids(ctx, ask); err != nil {
+			return errors.Wrapf(err, "while assigning namespace IDs")
+		}
+	}
+}
+
 func AssignNsids(ctx context.Context, num uint32) (*pb.AssignedIds, error) {
 	prop := &pb.ZeroProposal{NumNsids: num}
 	st, err := ProposeAndWait(ctx, prop)